@@ -0,0 +1,324 @@
+package hatchery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Users with access to this arborist resource can see and terminate every user's workspaces
+const adminResourcePath = "/services/hatchery/admin"
+
+// AdminWorkspace describes a running workspace, as returned by /admin/workspaces
+type AdminWorkspace struct {
+	UserName      string    `json:"userName"`
+	WorkspaceId   string    `json:"workspaceId"`
+	ContainerName string    `json:"containerName"`
+	LaunchTime    time.Time `json:"launchTime"`
+	PayModel      string    `json:"payModel"`
+	WorkspaceType string    `json:"workspaceType"`
+	Status        string    `json:"status"`
+	CurrentCost   *float64  `json:"currentCost,omitempty"`
+	IdleTime      int64     `json:"idleTime"`
+}
+
+// AdminTermination describes a workspace terminated by an admin, as returned by /admin/terminations
+type AdminTermination struct {
+	UserName    string    `json:"userName"`
+	WorkspaceId string    `json:"workspaceId"`
+	AdminUser   string    `json:"adminUser"`
+	Reason      string    `json:"reason"`
+	Time        time.Time `json:"time"`
+}
+
+// isAdminTermination returns true if the audit event is the termination of a workspace by
+// someone other than its user and hatchery itself, ie by an admin
+func isAdminTermination(event AuditEvent) bool {
+	if event.Action != auditActionTerminate || event.Actor == event.UserName {
+		return false
+	}
+	return event.Actor != auditActorIdleCuller && event.Actor != auditActorCostTracker
+}
+
+// getAdminTerminations reads the admin terminations from the audit log, oldest first
+func getAdminTerminations(ctx context.Context) ([]AdminTermination, error) {
+	events, err := auditor.sink.Query(ctx, AuditQuery{})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	result := []AdminTermination{}
+	for _, event := range events {
+		if !isAdminTermination(event) {
+			continue
+		}
+		result = append(result, AdminTermination{
+			UserName:    event.UserName,
+			WorkspaceId: event.WorkspaceId,
+			AdminUser:   event.Actor,
+			Reason:      event.Reason,
+			Time:        event.Time,
+		})
+	}
+	return result, nil
+}
+
+var isUserHatcheryAdmin = func(userName string, accessToken string) (bool, error) {
+	Config().Logger.Printf("DEBUG: Checking user '%s' access to resource path %s (service 'hatchery', method 'admin')", userName, adminResourcePath)
+
+	body := fmt.Sprintf("{\"user\": {\"token\": \"%s\"}, \"requests\": [{\"resource\": \"%s\", \"action\": {\"service\": \"hatchery\", \"method\": \"admin\"}}]}", accessToken, adminResourcePath)
+	return arboristAuthRequest(body)
+}
+
+// requireAdmin only lets users with access to the admin resource call the handler
+func requireAdmin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userName := getCurrentUserName(r)
+		accessToken := getBearerToken(r)
+		if userName == "" || accessToken == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		authorized, err := isUserHatcheryAdmin(userName, accessToken)
+		if err != nil {
+			Config().Logger.Printf("something went wrong when checking if user '%s' is an admin. Denying access. Details: %v", userName, err)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if !authorized {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// listWorkspaceServices lists the local services of every workspace. All backends
+// create one in the local namespace when launching a workspace
+var listWorkspaceServices = func(ctx context.Context) ([]k8sv1.Service, error) {
+	podClient := getLocalPodClient()
+	if podClient == nil {
+		return nil, errors.New("unable to get local pod client")
+	}
+	services, err := podClient.Services(Config().Config.UserNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return services.Items, nil
+}
+
+// getWorkspacePodCost returns the cost of the workspace pod since it was created
+var getWorkspacePodCost = func(ctx context.Context, userName string, workspaceId string, payModel *PayModel, now time.Time) (float64, error) {
+	podClient, _, err := getPodClient(ctx, userName, payModel)
+	if err != nil {
+		return 0, err
+	}
+	pod, err := podClient.Pods(Config().Config.UserNamespace).Get(ctx, workspaceToResourceName(userName, workspaceId, "pod"), metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return calculatePodPrice(pod, now.Sub(pod.CreationTimestamp.Time)).TotalCost, nil
+}
+
+// lastActivityTimes are the last activity times of the running workspaces, as read on
+// behalf of their users by their status requests and the idle culler. The admin listing
+// reports the idle time from them, since it can't read it without minting user tokens
+var lastActivityTimes = struct {
+	mu    sync.Mutex
+	times map[WorkspaceRef]int64
+}{times: map[WorkspaceRef]int64{}}
+
+// recordLastActivityTime keeps the last activity time of the workspace, if known, and
+// forgets it once the workspace is no longer running
+func recordLastActivityTime(ref WorkspaceRef, status *WorkspaceStatus) {
+	if status == nil {
+		return
+	}
+	lastActivityTimes.mu.Lock()
+	defer lastActivityTimes.mu.Unlock()
+	if status.Status != "Running" {
+		delete(lastActivityTimes.times, ref)
+	} else if status.LastActivityTime > 0 {
+		lastActivityTimes.times[ref] = status.LastActivityTime
+	}
+}
+
+func getLastActivityTime(ref WorkspaceRef) (int64, bool) {
+	lastActivityTimes.mu.Lock()
+	defer lastActivityTimes.mu.Unlock()
+	lastActivityTime, ok := lastActivityTimes.times[ref]
+	return lastActivityTime, ok
+}
+
+// listAdminWorkspaces returns every workspace across the local namespace,
+// external EKS pay models and ECS
+func listAdminWorkspaces(ctx context.Context, now time.Time) ([]AdminWorkspace, error) {
+	services, err := listWorkspaceServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []AdminWorkspace{}
+	for _, service := range services {
+		ref, ok := workspaceRefFromAnnotations(service.Annotations)
+		if !ok {
+			continue
+		}
+		workspace := AdminWorkspace{
+			UserName:      ref.UserName,
+			WorkspaceId:   ref.WorkspaceId,
+			ContainerName: service.Annotations[containerNameAnnotation],
+			LaunchTime:    service.CreationTimestamp.Time,
+			PayModel:      "None",
+			Status:        "Unknown",
+			IdleTime:      -1,
+		}
+
+		payModel, err := getCurrentPayModel(ref.UserName)
+		if err != nil {
+			Config().Logger.Printf("Admin: unable to get pay model of user %s: %v", ref.UserName, err)
+		}
+		if payModel != nil {
+			workspace.PayModel = payModel.Name
+		}
+
+		// the idle time can only be read on behalf of the user, so the status is read without
+		// it, and the idle time is the one last read for the user
+		status, err := getWorkspaceStatus(ctx, ref.UserName, ref.WorkspaceId, "")
+		if err != nil {
+			Config().Logger.Printf("Admin: unable to get status of workspace '%s' for user %s: %v", ref.WorkspaceId, ref.UserName, err)
+		} else if status != nil {
+			workspace.Status = status.Status
+			workspace.WorkspaceType = status.WorkspaceType
+			if lastActivityTime, ok := getLastActivityTime(ref); ok && status.Status == "Running" {
+				workspace.IdleTime = now.UnixMilli() - lastActivityTime
+			}
+		}
+
+		// ECS costs are only known through the pay model's total usage
+		if payModel == nil || !payModel.Ecs {
+			cost, err := getWorkspacePodCost(ctx, ref.UserName, ref.WorkspaceId, payModel, now)
+			if err != nil {
+				Config().Logger.Printf("Admin: unable to get cost of workspace '%s' for user %s: %v", ref.WorkspaceId, ref.UserName, err)
+			} else {
+				workspace.CurrentCost = &cost
+			}
+		}
+		result = append(result, workspace)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].UserName != result[j].UserName {
+			return result[i].UserName < result[j].UserName
+		}
+		return result[i].WorkspaceId < result[j].WorkspaceId
+	})
+	return result, nil
+}
+
+func adminWorkspaces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	result, err := listAdminWorkspaces(r.Context(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	out, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(out))
+}
+
+// adminTerminate terminates a user's workspace through the same cleanup path
+// as /terminate, and records the reason given by the admin in the audit log.
+// The workspace's API key can only be revoked with the user's token: when it
+// can't be obtained, the workspace is only terminated with `force=true`
+func adminTerminate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userName := r.URL.Query().Get("user")
+	if userName == "" {
+		http.Error(w, "Missing 'user' argument", http.StatusBadRequest)
+		return
+	}
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		http.Error(w, "Missing 'reason' argument", http.StatusBadRequest)
+		return
+	}
+	workspaceId, err := getWorkspaceId(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	adminUser := getCurrentUserName(r)
+	Config().Logger.Printf("Admin %s is terminating workspace '%s' of user %s. Reason: %s", adminUser, workspaceId, userName, reason)
+
+	// the user's token is needed to delete the API key mounted in the workspace
+	accessToken, err := getWorkspaceAccessToken(r.Context(), userName, workspaceId)
+	if err != nil {
+		apiKeyId, keyErr := getWorkspaceAPIKeyId(r.Context(), userName, workspaceId)
+		if keyErr != nil || apiKeyId == "" {
+			Config().Logger.Printf("Admin: unable to get the API key id of workspace '%s' for user %s: %v", workspaceId, userName, keyErr)
+			apiKeyId = "unknown"
+		}
+		if r.URL.Query().Get("force") != "true" {
+			Config().Logger.Printf("Admin: unable to get access token for workspace '%s' of user %s, not terminating it since API key %s can't be revoked: %v", workspaceId, userName, apiKeyId, err)
+			http.Error(w, fmt.Sprintf("Unable to revoke API key %s of the workspace: %v. Set 'force=true' to terminate the workspace anyway, the key must then be revoked in fence", apiKeyId, err), http.StatusConflict)
+			return
+		}
+		Config().Logger.Printf("Admin: unable to get access token for workspace '%s' of user %s, terminating it anyway (force=true). API key %s is not revoked and must be deleted in fence: %v", workspaceId, userName, apiKeyId, err)
+	}
+	ctx := withAuditActor(r.Context(), adminUser, reason)
+	result, err := terminateWorkspace(ctx, userName, workspaceId, accessToken)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, result)
+}
+
+func adminTerminationHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if auditor == nil {
+		http.Error(w, "Audit log is not enabled", http.StatusNotFound)
+		return
+	}
+	terminations, err := getAdminTerminations(r.Context())
+	if err == errAuditQueryNotSupported {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		Config().Logger.Printf("Error: unable to query admin terminations: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	out, err := json.Marshal(terminations)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(out))
+}
@@ -0,0 +1,239 @@
+package hatchery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_RequireAdmin(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalIsUserHatcheryAdmin := isUserHatcheryAdmin
+	defer func() {
+		isUserHatcheryAdmin = originalIsUserHatcheryAdmin
+	}()
+
+	testCases := []struct {
+		name        string
+		accessToken string
+		isAdmin     bool
+		arboristErr bool
+		want        int
+	}{
+		{name: "NoToken", accessToken: "", want: http.StatusUnauthorized},
+		{name: "NotAdmin", accessToken: "token", isAdmin: false, want: http.StatusForbidden},
+		{name: "ArboristError", accessToken: "token", isAdmin: true, arboristErr: true, want: http.StatusForbidden},
+		{name: "Admin", accessToken: "token", isAdmin: true, want: http.StatusOK},
+	}
+
+	for _, testcase := range testCases {
+		t.Logf("Testing RequireAdmin when %s", testcase.name)
+		isUserHatcheryAdmin = func(userName string, accessToken string) (bool, error) {
+			if testcase.arboristErr {
+				return false, errors.New("mocking an error while making call to arborist")
+			}
+			return testcase.isAdmin, nil
+		}
+		handlerCalled := false
+		handler := requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			handlerCalled = true
+		})
+
+		req := httptest.NewRequest("GET", "/admin/workspaces", nil)
+		req.Header.Set("REMOTE_USER", "adminUser")
+		if testcase.accessToken != "" {
+			req.Header.Set("Authorization", "Bearer "+testcase.accessToken)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		if w.Code != testcase.want {
+			t.Errorf("\nassertion error while testing `%s`: \nWant:%d\nGot:%d", testcase.name, testcase.want, w.Code)
+		}
+		if handlerCalled != (testcase.want == http.StatusOK) {
+			t.Errorf("\nassertion error while testing `%s` handler called: \nWant:%v\nGot:%v", testcase.name, testcase.want == http.StatusOK, handlerCalled)
+		}
+	}
+}
+
+func Test_ListAdminWorkspaces(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalListWorkspaceServices := listWorkspaceServices
+	originalGetCurrentPayModel := getCurrentPayModel
+	originalGetWorkspaceAccessToken := getWorkspaceAccessToken
+	originalGetWorkspaceStatus := getWorkspaceStatus
+	originalGetWorkspacePodCost := getWorkspacePodCost
+	defer func() {
+		listWorkspaceServices = originalListWorkspaceServices
+		getCurrentPayModel = originalGetCurrentPayModel
+		getWorkspaceAccessToken = originalGetWorkspaceAccessToken
+		getWorkspaceStatus = originalGetWorkspaceStatus
+		getWorkspacePodCost = originalGetWorkspacePodCost
+	}()
+
+	now := time.UnixMilli(10_000_000).UTC()
+	launchTime := now.Add(-time.Hour)
+	// the last activity of the K8s workspace was read by a status request of its user, the one
+	// of the stopped workspace is forgotten
+	recordLastActivityTime(WorkspaceRef{UserName: "k8sUser", WorkspaceId: "rstudio"}, &WorkspaceStatus{Status: "Running", LastActivityTime: now.UnixMilli() - 5000})
+	recordLastActivityTime(WorkspaceRef{UserName: "ecsUser"}, &WorkspaceStatus{Status: "Running", LastActivityTime: now.UnixMilli() - 9000})
+	recordLastActivityTime(WorkspaceRef{UserName: "ecsUser"}, &WorkspaceStatus{Status: "Stopped"})
+	defer func() {
+		lastActivityTimes.mu.Lock()
+		lastActivityTimes.times = map[WorkspaceRef]int64{}
+		lastActivityTimes.mu.Unlock()
+	}()
+	listWorkspaceServices = func(ctx context.Context) ([]k8sv1.Service, error) {
+		return []k8sv1.Service{
+			{ObjectMeta: metav1.ObjectMeta{
+				CreationTimestamp: metav1.NewTime(launchTime),
+				Annotations:       map[string]string{"gen3username": "k8sUser", workspaceIdAnnotation: "rstudio", containerNameAnnotation: "RStudio"},
+			}},
+			{ObjectMeta: metav1.ObjectMeta{
+				CreationTimestamp: metav1.NewTime(launchTime),
+				Annotations:       map[string]string{"gen3username": "ecsUser", containerNameAnnotation: "Jupyter"},
+			}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "not-a-workspace"}},
+		}, nil
+	}
+	getCurrentPayModel = func(userName string) (*PayModel, error) {
+		if userName == "ecsUser" {
+			return &PayModel{Name: "Direct Pay", Ecs: true}, nil
+		}
+		return nil, nil
+	}
+	getWorkspaceAccessToken = func(ctx context.Context, userName string, workspaceId string) (string, error) {
+		t.Errorf("getWorkspaceAccessToken called for the workspace of user %s", userName)
+		return "", errors.New("no tokens while listing")
+	}
+	getWorkspaceStatus = func(ctx context.Context, userName string, workspaceId string, accessToken string) (*WorkspaceStatus, error) {
+		if accessToken != "" {
+			t.Errorf("getWorkspaceStatus called with an access token for the workspace of user %s", userName)
+		}
+		if userName == "ecsUser" {
+			return &WorkspaceStatus{Status: "Running", WorkspaceType: "ECS", LastActivityTime: -1}, nil
+		}
+		return &WorkspaceStatus{Status: "Running", WorkspaceType: "Kubernetes", LastActivityTime: -1}, nil
+	}
+	getWorkspacePodCost = func(ctx context.Context, userName string, workspaceId string, payModel *PayModel, now time.Time) (float64, error) {
+		if payModel != nil && payModel.Ecs {
+			t.Errorf("getWorkspacePodCost called for ECS workspace of user %s", userName)
+		}
+		return 0.5, nil
+	}
+
+	got, err := listAdminWorkspaces(context.Background(), now)
+	if err != nil {
+		t.Fatalf("\nassertion error while testing `ListAdminWorkspaces`: unexpected error %v", err)
+	}
+
+	cost := 0.5
+	want := []AdminWorkspace{
+		{UserName: "ecsUser", WorkspaceId: "", ContainerName: "Jupyter", LaunchTime: launchTime, PayModel: "Direct Pay", WorkspaceType: "ECS", Status: "Running", IdleTime: -1},
+		{UserName: "k8sUser", WorkspaceId: "rstudio", ContainerName: "RStudio", LaunchTime: launchTime, PayModel: "None", WorkspaceType: "Kubernetes", Status: "Running", CurrentCost: &cost, IdleTime: 5000},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nassertion error while testing `ListAdminWorkspaces`: \nWant:%+v\nGot:%+v", want, got)
+	}
+}
+
+func Test_AdminTerminate(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalGetWorkspaceAccessToken := getWorkspaceAccessToken
+	originalGetWorkspaceAPIKeyId := getWorkspaceAPIKeyId
+	originalTerminateWorkspace := terminateWorkspace
+	originalAuditor := auditor
+	defer func() {
+		getWorkspaceAccessToken = originalGetWorkspaceAccessToken
+		getWorkspaceAPIKeyId = originalGetWorkspaceAPIKeyId
+		terminateWorkspace = originalTerminateWorkspace
+		auditor = originalAuditor
+	}()
+
+	auditor, _ = newAuditLog(&fileAuditSink{path: filepath.Join(t.TempDir(), "audit.jsonl")})
+	// terminations which are not by an admin
+	recordAuditEvent(AuditEvent{Action: auditActionTerminate, UserName: "someUser", Actor: "someUser"})
+	recordAuditEvent(AuditEvent{Action: auditActionTerminate, UserName: "someUser", Actor: auditActorIdleCuller, Reason: "idle"})
+
+	getWorkspaceAccessToken = func(ctx context.Context, userName string, workspaceId string) (string, error) {
+		if userName == "expiredUser" {
+			return "", errors.New("API key expired")
+		}
+		return "token-" + userName, nil
+	}
+	getWorkspaceAPIKeyId = func(ctx context.Context, userName string, workspaceId string) (string, error) {
+		return "key-" + userName, nil
+	}
+	terminated := []WorkspaceRef{}
+	terminateWorkspace = func(ctx context.Context, userName string, workspaceId string, accessToken string) (string, error) {
+		if userName != "expiredUser" && accessToken != "token-"+userName {
+			t.Errorf("terminateWorkspace called for user %s with unexpected token %s", userName, accessToken)
+		}
+		actor, reason := auditActorFromContext(ctx, userName)
+		if actor != "adminUser" || reason != "runaway costs" {
+			t.Errorf("terminateWorkspace called with unexpected audit actor %s and reason %s", actor, reason)
+		}
+		recordAuditEvent(AuditEvent{Action: auditActionTerminate, UserName: userName, Actor: actor, WorkspaceId: workspaceId, Reason: reason})
+		terminated = append(terminated, WorkspaceRef{UserName: userName, WorkspaceId: workspaceId})
+		return "Terminated workspace", nil
+	}
+
+	req := httptest.NewRequest("POST", "/admin/terminate?user=someUser&workspace=rstudio", nil)
+	req.Header.Set("REMOTE_USER", "adminUser")
+	w := httptest.NewRecorder()
+	adminTerminate(w, req)
+	if w.Code != http.StatusBadRequest || len(terminated) != 0 {
+		t.Errorf("\nassertion error while testing `AdminTerminate` without reason: \nWant:%d\nGot:%d", http.StatusBadRequest, w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/admin/terminate?user=someUser&workspace=rstudio&reason=runaway+costs", nil)
+	req.Header.Set("REMOTE_USER", "adminUser")
+	w = httptest.NewRecorder()
+	adminTerminate(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("\nassertion error while testing `AdminTerminate`: \nWant:%d\nGot:%d", http.StatusOK, w.Code)
+	}
+	if want := []WorkspaceRef{{UserName: "someUser", WorkspaceId: "rstudio"}}; !reflect.DeepEqual(terminated, want) {
+		t.Errorf("\nassertion error while testing `AdminTerminate` terminated workspaces: \nWant:%v\nGot:%v", want, terminated)
+	}
+
+	// the API key can't be revoked without the user's token
+	req = httptest.NewRequest("POST", "/admin/terminate?user=expiredUser&reason=runaway+costs", nil)
+	req.Header.Set("REMOTE_USER", "adminUser")
+	w = httptest.NewRecorder()
+	adminTerminate(w, req)
+	if w.Code != http.StatusConflict || len(terminated) != 1 || !strings.Contains(w.Body.String(), "key-expiredUser") {
+		t.Errorf("\nassertion error while testing `AdminTerminate` without access token: \nWant:%d\nGot:%d %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	req = httptest.NewRequest("POST", "/admin/terminate?user=expiredUser&reason=runaway+costs&force=true", nil)
+	req.Header.Set("REMOTE_USER", "adminUser")
+	w = httptest.NewRecorder()
+	adminTerminate(w, req)
+	if w.Code != http.StatusOK || len(terminated) != 2 {
+		t.Errorf("\nassertion error while testing `AdminTerminate` with force: \nWant:%d\nGot:%d", http.StatusOK, w.Code)
+	}
+
+	records, err := getAdminTerminations(context.Background())
+	if err != nil {
+		t.Fatalf("\nassertion error while testing `AdminTerminate`: unable to read the terminations: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("\nassertion error while testing `AdminTerminate` records: \nWant:%d\nGot:%+v", 2, records)
+	}
+	record := records[0]
+	if record.UserName != "someUser" || record.WorkspaceId != "rstudio" || record.AdminUser != "adminUser" || record.Reason != "runaway costs" {
+		t.Errorf("\nassertion error while testing `AdminTerminate` record: \nGot:%+v", record)
+	}
+}
@@ -0,0 +1,492 @@
+package hatchery
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/google/uuid"
+)
+
+// Audited actions
+const (
+	auditActionLaunch          = "launch"
+	auditActionTerminate       = "terminate"
+	auditActionSetPayModel     = "set-pay-model"
+	auditActionResetPayModels  = "reset-pay-models"
+	auditActionLicenseAllocate = "license-allocate"
+	auditActionLicenseRelease  = "license-release"
+	auditActionCostCharge      = "cost-charge"
+)
+
+// Actors for the actions hatchery takes on its own
+const (
+	auditActorIdleCuller  = "hatchery-idle-culler"
+	auditActorCostTracker = "hatchery-cost-tracker"
+)
+
+// Supported values for the `audit.sink` configuration
+const (
+	auditSinkStdout   = "stdout"
+	auditSinkFile     = "file"
+	auditSinkDynamodb = "dynamodb"
+)
+
+var errAuditQueryNotSupported = errors.New("the configured audit sink can not be queried")
+
+// errAuditChainMoved is returned by AuditSink.Write when another hatchery replica wrote an
+// event since the previous hash was read
+var errAuditChainMoved = errors.New("the audit chain has moved on since the previous event")
+
+// Number of times an event is written again after errAuditChainMoved
+const maxAuditWriteAttempts = 5
+
+// AuditEvent records a workspace lifecycle or billing action. Each event
+// contains the hash of the previous event, so removing or editing an event
+// breaks the chain
+type AuditEvent struct {
+	Id            string    `json:"id"`
+	Time          time.Time `json:"time"`
+	Action        string    `json:"action"`
+	UserName      string    `json:"userName"`
+	Actor         string    `json:"actor"`
+	WorkspaceId   string    `json:"workspaceId,omitempty"`
+	ContainerName string    `json:"containerName,omitempty"`
+	PayModelId    string    `json:"payModelId,omitempty"`
+	LicenseType   string    `json:"licenseType,omitempty"`
+	LicenseId     int       `json:"licenseId,omitempty"`
+	Cost          float64   `json:"cost,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	PreviousHash  string    `json:"previousHash"`
+	Hash          string    `json:"hash"`
+}
+
+// AuditQuery filters the events returned by AuditSink.Query. Zero values match everything
+type AuditQuery struct {
+	UserName string
+	From     time.Time
+	To       time.Time
+}
+
+func (q AuditQuery) matches(event AuditEvent) bool {
+	if q.UserName != "" && event.UserName != q.UserName {
+		return false
+	}
+	if !q.From.IsZero() && event.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && event.Time.After(q.To) {
+		return false
+	}
+	return true
+}
+
+// AuditSink stores audit events
+type AuditSink interface {
+	Write(event AuditEvent) error
+	Query(ctx context.Context, query AuditQuery) ([]AuditEvent, error)
+	// LastHash returns the hash of the most recent event, to continue the chain after a restart
+	LastHash() (string, error)
+}
+
+type auditLog struct {
+	mu       sync.Mutex
+	sink     AuditSink
+	lastHash string
+}
+
+// auditor is nil when auditing is disabled
+var auditor *auditLog
+
+func newAuditLog(sink AuditSink) (*auditLog, error) {
+	lastHash, err := sink.LastHash()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the last audit event: %v", err)
+	}
+	return &auditLog{sink: sink, lastHash: lastHash}, nil
+}
+
+func newAuditSink(config AuditConfig) (AuditSink, error) {
+	switch config.Sink {
+	case "":
+		return nil, nil
+	case auditSinkStdout:
+		return &stdoutAuditSink{out: os.Stdout}, nil
+	case auditSinkFile:
+		if config.FilePath == "" {
+			return nil, errors.New("audit: 'file-path' is required for the file sink")
+		}
+		return &fileAuditSink{path: config.FilePath}, nil
+	case auditSinkDynamodb:
+		if config.DynamodbTable == "" {
+			return nil, errors.New("audit: 'dynamodb-table' is required for the dynamodb sink")
+		}
+		return newDynamodbAuditSink(config), nil
+	}
+	return nil, fmt.Errorf("audit: unknown sink '%s'", config.Sink)
+}
+
+// InitAuditLog sets up the configured audit sink. Auditing is disabled when no sink is configured
+func InitAuditLog(config AuditConfig) error {
+	sink, err := newAuditSink(config)
+	if err != nil || sink == nil {
+		return err
+	}
+	log, err := newAuditLog(sink)
+	if err != nil {
+		return err
+	}
+	auditor = log
+	return nil
+}
+
+// computeAuditHash hashes the event, including the hash of the previous event
+func computeAuditHash(event AuditEvent) string {
+	event.Hash = ""
+	data, _ := json.Marshal(event)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordAuditEvent appends the event to the audit log. Failing to write the
+// event is logged but does not fail the audited action
+var recordAuditEvent = func(event AuditEvent) {
+	if auditor == nil {
+		return
+	}
+	auditor.record(event)
+}
+
+func (a *auditLog) record(event AuditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	event.Id = uuid.New().String()
+	var err error
+	for attempt := 1; attempt <= maxAuditWriteAttempts; attempt++ {
+		event.Time = time.Now().UTC()
+		event.PreviousHash = a.lastHash
+		event.Hash = computeAuditHash(event)
+		err = a.sink.Write(event)
+		if err != errAuditChainMoved {
+			break
+		}
+		// continue the chain from the event written by the other replica
+		if a.lastHash, err = a.sink.LastHash(); err != nil {
+			break
+		}
+		err = errAuditChainMoved
+	}
+	if err != nil {
+		out, _ := json.Marshal(event)
+		Config().Logger.Printf("Error: unable to write audit event %s: %v", string(out), err)
+		return
+	}
+	a.lastHash = event.Hash
+}
+
+type auditContextKey struct{}
+
+type auditContext struct {
+	actor  string
+	reason string
+}
+
+// withAuditActor sets who is taking the action, and why, for the audit events
+// recorded by functions such as terminateWorkspace
+func withAuditActor(ctx context.Context, actor string, reason string) context.Context {
+	return context.WithValue(ctx, auditContextKey{}, auditContext{actor: actor, reason: reason})
+}
+
+// auditActorFromContext returns the actor and reason set by withAuditActor.
+// By default, the user is acting on their own workspace
+func auditActorFromContext(ctx context.Context, userName string) (actor string, reason string) {
+	if value, ok := ctx.Value(auditContextKey{}).(auditContext); ok {
+		return value.actor, value.reason
+	}
+	return userName, ""
+}
+
+// stdoutAuditSink writes each event as a JSON line, eg for a log collector to pick up
+type stdoutAuditSink struct {
+	out io.Writer
+}
+
+func (s *stdoutAuditSink) Write(event AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(s.out, string(data))
+	return err
+}
+
+func (s *stdoutAuditSink) Query(ctx context.Context, query AuditQuery) ([]AuditEvent, error) {
+	return nil, errAuditQueryNotSupported
+}
+
+func (s *stdoutAuditSink) LastHash() (string, error) {
+	return "", nil
+}
+
+// fileAuditSink appends each event as a JSON line to a file
+type fileAuditSink struct {
+	path string
+}
+
+func (s *fileAuditSink) Write(event AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// readAll calls `fn` for each event in the file, in the order they were written
+func (s *fileAuditSink) readAll(fn func(event AuditEvent)) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		event := AuditEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("unable to decode audit event %q: %v", scanner.Text(), err)
+		}
+		fn(event)
+	}
+	return scanner.Err()
+}
+
+func (s *fileAuditSink) Query(ctx context.Context, query AuditQuery) ([]AuditEvent, error) {
+	events := []AuditEvent{}
+	err := s.readAll(func(event AuditEvent) {
+		if query.matches(event) {
+			events = append(events, event)
+		}
+	})
+	return events, err
+}
+
+func (s *fileAuditSink) LastHash() (string, error) {
+	lastHash := ""
+	err := s.readAll(func(event AuditEvent) {
+		lastHash = event.Hash
+	})
+	return lastHash, err
+}
+
+// Id of the item of the dynamodb sink's table which holds the hash of the most recent event
+const dynamodbAuditChainHeadId = "chain-head"
+
+// dynamodbAuditSink puts each event in a DynamoDB table with an `id` partition key. The hash
+// of the most recent event is kept in the chain head item, which is updated in the same
+// transaction as each event is put, so that hatchery replicas can't fork the chain
+type dynamodbAuditSink struct {
+	table  string
+	client dynamodbiface.DynamoDBAPI
+}
+
+func newDynamodbAuditSink(config AuditConfig) *dynamodbAuditSink {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{
+			Region: aws.String("us-east-1"),
+		},
+	}))
+	// assume a new role if the table is in another account
+	var awsConfig aws.Config
+	if config.DynamodbArn != "" {
+		awsConfig.Credentials = stscreds.NewCredentials(sess, config.DynamodbArn)
+	}
+	return &dynamodbAuditSink{table: config.DynamodbTable, client: dynamodb.New(sess, &awsConfig)}
+}
+
+func (s *dynamodbAuditSink) Write(event AuditEvent) error {
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return err
+	}
+	head := &dynamodb.Put{
+		TableName: aws.String(s.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":   {S: aws.String(dynamodbAuditChainHeadId)},
+			"hash": {S: aws.String(event.Hash)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}
+	if event.PreviousHash != "" {
+		head.ConditionExpression = aws.String("#hash = :previousHash")
+		head.ExpressionAttributeNames = map[string]*string{"#hash": aws.String("hash")}
+		head.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{":previousHash": {S: aws.String(event.PreviousHash)}}
+	}
+	start := time.Now()
+	// events are never overwritten, and the chain head must still be the previous event
+	_, err = s.client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{
+				TableName:           aws.String(s.table),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(id)"),
+			}},
+			{Put: head},
+		},
+	})
+	observeExternalRequest("dynamodb", "put_audit_event", start, err)
+	var canceled *dynamodb.TransactionCanceledException
+	if errors.As(err, &canceled) && len(canceled.CancellationReasons) == 2 &&
+		aws.StringValue(canceled.CancellationReasons[1].Code) == "ConditionalCheckFailed" {
+		return errAuditChainMoved
+	}
+	return err
+}
+
+func (s *dynamodbAuditSink) scan(ctx context.Context, input *dynamodb.ScanInput) ([]AuditEvent, error) {
+	events := []AuditEvent{}
+	var unmarshalErr error
+	start := time.Now()
+	err := s.client.ScanPagesWithContext(ctx, input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		pageEvents := []AuditEvent{}
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageEvents); unmarshalErr != nil {
+			return false
+		}
+		events = append(events, pageEvents...)
+		return true
+	})
+	observeExternalRequest("dynamodb", "scan_audit_events", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return events, unmarshalErr
+}
+
+func (s *dynamodbAuditSink) Query(ctx context.Context, query AuditQuery) ([]AuditEvent, error) {
+	input := &dynamodb.ScanInput{TableName: aws.String(s.table)}
+	if query.UserName != "" {
+		expr, err := expression.NewBuilder().WithFilter(expression.Name("userName").Equal(expression.Value(query.UserName))).Build()
+		if err != nil {
+			return nil, err
+		}
+		input.ExpressionAttributeNames = expr.Names()
+		input.ExpressionAttributeValues = expr.Values()
+		input.FilterExpression = expr.Filter()
+	}
+	events, err := s.scan(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	// times are stored as strings, so the time range is filtered here
+	filtered := []AuditEvent{}
+	for _, event := range events {
+		if event.Id != dynamodbAuditChainHeadId && query.matches(event) {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered, nil
+}
+
+func (s *dynamodbAuditSink) LastHash() (string, error) {
+	start := time.Now()
+	output, err := s.client.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            map[string]*dynamodb.AttributeValue{"id": {S: aws.String(dynamodbAuditChainHeadId)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	observeExternalRequest("dynamodb", "get_audit_chain_head", start, err)
+	if err != nil {
+		return "", err
+	}
+	// there is no chain head before the first event
+	if hash, ok := output.Item["hash"]; ok {
+		return aws.StringValue(hash.S), nil
+	}
+	return "", nil
+}
+
+func parseAuditTime(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid '%s' argument: expected an RFC 3339 time such as 2024-01-31T00:00:00Z", name)
+	}
+	return t, nil
+}
+
+// auditEvents returns the audit events, optionally filtered by user and time range
+func auditEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if auditor == nil {
+		http.Error(w, "Audit log is not enabled", http.StatusNotFound)
+		return
+	}
+	query := AuditQuery{UserName: r.URL.Query().Get("user")}
+	var err error
+	if query.From, err = parseAuditTime(r, "from"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.To, err = parseAuditTime(r, "to"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := auditor.sink.Query(r.Context(), query)
+	if err == errAuditQueryNotSupported {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		Config().Logger.Printf("Error: unable to query audit events: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	out, err := json.Marshal(events)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(out))
+}
@@ -0,0 +1,247 @@
+package hatchery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+func Test_AuditHashChain(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	sink := &fileAuditSink{path: filepath.Join(t.TempDir(), "audit.jsonl")}
+	log, err := newAuditLog(sink)
+	if err != nil {
+		t.Fatalf("unable to create audit log: %v", err)
+	}
+	log.record(AuditEvent{Action: auditActionLaunch, UserName: "alice", Actor: "alice", ContainerName: "Jupyter"})
+	log.record(AuditEvent{Action: auditActionSetPayModel, UserName: "bob", Actor: "bob", PayModelId: "pm-1"})
+
+	// after a restart, the chain continues from the last event in the file
+	log, err = newAuditLog(sink)
+	if err != nil {
+		t.Fatalf("unable to create audit log: %v", err)
+	}
+	log.record(AuditEvent{Action: auditActionTerminate, UserName: "alice", Actor: "admin", Reason: "runaway costs"})
+
+	events, err := sink.Query(context.Background(), AuditQuery{})
+	if err != nil {
+		t.Fatalf("unable to query audit events: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("\nassertion error while testing `AuditHashChain`: \nWant:3 events\nGot:%+v", events)
+	}
+	previousHash := ""
+	for _, event := range events {
+		if event.PreviousHash != previousHash {
+			t.Errorf("\nassertion error while testing `AuditHashChain` previous hash: \nWant:%s\nGot:%s", previousHash, event.PreviousHash)
+		}
+		if hash := computeAuditHash(event); event.Hash != hash {
+			t.Errorf("\nassertion error while testing `AuditHashChain` hash: \nWant:%s\nGot:%s", hash, event.Hash)
+		}
+		previousHash = event.Hash
+	}
+
+	// editing an event breaks the chain
+	tampered := events[1]
+	tampered.PayModelId = "pm-2"
+	if computeAuditHash(tampered) == events[1].Hash {
+		t.Errorf("\nassertion error while testing `AuditHashChain`: editing an event did not change its hash")
+	}
+}
+
+func Test_AuditQuery(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	now := time.Now().UTC()
+	event := AuditEvent{UserName: "alice", Time: now}
+	testCases := []struct {
+		name  string
+		query AuditQuery
+		want  bool
+	}{
+		{name: "NoFilter", query: AuditQuery{}, want: true},
+		{name: "SameUser", query: AuditQuery{UserName: "alice"}, want: true},
+		{name: "OtherUser", query: AuditQuery{UserName: "bob"}, want: false},
+		{name: "InRange", query: AuditQuery{From: now.Add(-time.Hour), To: now.Add(time.Hour)}, want: true},
+		{name: "BeforeRange", query: AuditQuery{From: now.Add(time.Minute)}, want: false},
+		{name: "AfterRange", query: AuditQuery{To: now.Add(-time.Minute)}, want: false},
+	}
+	for _, testcase := range testCases {
+		if got := testcase.query.matches(event); got != testcase.want {
+			t.Errorf("\nassertion error while testing `%s`: \nWant:%v\nGot:%v", testcase.name, testcase.want, got)
+		}
+	}
+}
+
+func Test_NewAuditSink(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	testCases := []struct {
+		name    string
+		config  AuditConfig
+		wantErr bool
+	}{
+		{name: "Disabled", config: AuditConfig{}, wantErr: false},
+		{name: "Stdout", config: AuditConfig{Sink: "stdout"}, wantErr: false},
+		{name: "File", config: AuditConfig{Sink: "file", FilePath: "/tmp/audit.jsonl"}, wantErr: false},
+		{name: "FileWithoutPath", config: AuditConfig{Sink: "file"}, wantErr: true},
+		{name: "DynamodbWithoutTable", config: AuditConfig{Sink: "dynamodb"}, wantErr: true},
+		{name: "Unknown", config: AuditConfig{Sink: "s3"}, wantErr: true},
+	}
+	for _, testcase := range testCases {
+		_, err := newAuditSink(testcase.config)
+		if (err != nil) != testcase.wantErr {
+			t.Errorf("\nassertion error while testing `%s`: \nWant error:%v\nGot:%v", testcase.name, testcase.wantErr, err)
+		}
+	}
+}
+
+func Test_AuditEndpoint(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalAuditor := auditor
+	defer func() {
+		auditor = originalAuditor
+	}()
+
+	sink := &fileAuditSink{path: filepath.Join(t.TempDir(), "audit.jsonl")}
+	auditor, _ = newAuditLog(sink)
+	recordAuditEvent(AuditEvent{Action: auditActionLaunch, UserName: "alice", Actor: "alice"})
+	recordAuditEvent(AuditEvent{Action: auditActionLaunch, UserName: "bob", Actor: "bob"})
+	recordAuditEvent(AuditEvent{Action: auditActionResetPayModels, UserName: "alice", Actor: "alice"})
+
+	req := httptest.NewRequest("GET", "/audit?user=alice&from="+time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), nil)
+	w := httptest.NewRecorder()
+	auditEvents(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("\nassertion error while testing `AuditEndpoint`: \nWant:%d\nGot:%d %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	events := []AuditEvent{}
+	if err := json.Unmarshal(w.Body.Bytes(), &events); err != nil {
+		t.Fatalf("unable to decode audit events %q: %v", w.Body.String(), err)
+	}
+	if len(events) != 2 || events[0].Action != auditActionLaunch || events[1].Action != auditActionResetPayModels {
+		t.Errorf("\nassertion error while testing `AuditEndpoint` events: \nGot:%+v", events)
+	}
+
+	req = httptest.NewRequest("GET", "/audit?to=yesterday", nil)
+	w = httptest.NewRecorder()
+	auditEvents(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("\nassertion error while testing `AuditEndpoint` with invalid time: \nWant:%d\nGot:%d", http.StatusBadRequest, w.Code)
+	}
+
+	// events written to stdout can't be queried
+	auditor, _ = newAuditLog(&stdoutAuditSink{out: &bytes.Buffer{}})
+	req = httptest.NewRequest("GET", "/audit", nil)
+	w = httptest.NewRecorder()
+	auditEvents(w, req)
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("\nassertion error while testing `AuditEndpoint` with stdout sink: \nWant:%d\nGot:%d", http.StatusNotImplemented, w.Code)
+	}
+}
+
+// auditDynamodbMockClient stores the items of the audit table in memory, and checks the
+// conditions of the dynamodb audit sink's writes
+type auditDynamodbMockClient struct {
+	dynamodbiface.DynamoDBAPI
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (m *auditDynamodbMockClient) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	reasons := []*dynamodb.CancellationReason{}
+	canceled := false
+	for _, transactItem := range input.TransactItems {
+		put := transactItem.Put
+		existing, exists := m.items[aws.StringValue(put.Item["id"].S)]
+		ok := !exists
+		if aws.StringValue(put.ConditionExpression) == "#hash = :previousHash" {
+			ok = exists && aws.StringValue(existing["hash"].S) == aws.StringValue(put.ExpressionAttributeValues[":previousHash"].S)
+		}
+		reason := &dynamodb.CancellationReason{Code: aws.String("None")}
+		if !ok {
+			reason.Code = aws.String("ConditionalCheckFailed")
+			canceled = true
+		}
+		reasons = append(reasons, reason)
+	}
+	if canceled {
+		return nil, &dynamodb.TransactionCanceledException{CancellationReasons: reasons}
+	}
+	for _, transactItem := range input.TransactItems {
+		m.items[aws.StringValue(transactItem.Put.Item["id"].S)] = transactItem.Put.Item
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (m *auditDynamodbMockClient) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: m.items[aws.StringValue(input.Key["id"].S)]}, nil
+}
+
+func (m *auditDynamodbMockClient) ScanPagesWithContext(ctx aws.Context, input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool, opts ...request.Option) error {
+	page := &dynamodb.ScanOutput{}
+	for _, item := range m.items {
+		page.Items = append(page.Items, item)
+	}
+	fn(page, true)
+	return nil
+}
+
+func Test_DynamodbAuditChain(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	sink := &dynamodbAuditSink{table: "audit", client: &auditDynamodbMockClient{items: map[string]map[string]*dynamodb.AttributeValue{}}}
+	// two hatchery replicas writing to the same table
+	replica1, err := newAuditLog(sink)
+	if err != nil {
+		t.Fatalf("unable to create audit log: %v", err)
+	}
+	replica2, err := newAuditLog(sink)
+	if err != nil {
+		t.Fatalf("unable to create audit log: %v", err)
+	}
+	replica1.record(AuditEvent{Action: auditActionLaunch, UserName: "alice", Actor: "alice"})
+	replica2.record(AuditEvent{Action: auditActionLaunch, UserName: "bob", Actor: "bob"})
+	replica1.record(AuditEvent{Action: auditActionTerminate, UserName: "alice", Actor: "alice"})
+
+	events, err := sink.Query(context.Background(), AuditQuery{})
+	if err != nil {
+		t.Fatalf("unable to query audit events: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("\nassertion error while testing `DynamodbAuditChain`: \nWant:3 events\nGot:%+v", events)
+	}
+	// the events form a single chain, ending at the chain head
+	next := map[string]AuditEvent{}
+	for _, event := range events {
+		if _, forked := next[event.PreviousHash]; forked {
+			t.Errorf("\nassertion error while testing `DynamodbAuditChain`: the chain forked at %q", event.PreviousHash)
+		}
+		next[event.PreviousHash] = event
+	}
+	hash := ""
+	for i := 0; i < len(events); i++ {
+		event, ok := next[hash]
+		if !ok {
+			t.Fatalf("\nassertion error while testing `DynamodbAuditChain`: no event follows %q", hash)
+		}
+		if computeAuditHash(event) != event.Hash {
+			t.Errorf("\nassertion error while testing `DynamodbAuditChain` hash: \nWant:%s\nGot:%s", computeAuditHash(event), event.Hash)
+		}
+		hash = event.Hash
+	}
+	if lastHash, err := sink.LastHash(); err != nil || lastHash != hash {
+		t.Errorf("\nassertion error while testing `DynamodbAuditChain` chain head: \nWant:%s\nGot:%s %v", hash, lastHash, err)
+	}
+}
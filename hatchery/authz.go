@@ -30,6 +30,8 @@ type AuthzVersion_0_1 struct {
 	PayModels     []string           `json:"pay_models"`
 }
 
+const arboristAuthRequestURL = "http://arborist-service/auth/request"
+
 type AuthRequestResponse struct {
 	Auth bool `json:"auth"`
 }
@@ -120,7 +122,7 @@ var isUserAuthorizedForContainer = func(userName string, accessToken string, con
 		return true, nil
 	}
 
-	Config.Logger.Printf("DEBUG: Checking user '%s' access to container '%s'", userName, container.Name)
+	Config().Logger.Printf("DEBUG: Checking user '%s' access to container '%s'", userName, container.Name)
 	if container.Authz.Version == 0.1 {
 		return isUserAuthorizedForContainerVersion_0_1(userName, accessToken, container.Name, container.Authz.AuthzVersion_0_1)
 	} else {
@@ -177,7 +179,7 @@ func isUserAuthorizedForContainerVersion_0_1(userName string, accessToken string
 	if !userIsAuthorized {
 		logPartial = "not "
 	}
-	Config.Logger.Printf("INFO: User '%s' is %sauthorized to run container '%s'", userName, logPartial, containerName)
+	Config().Logger.Printf("INFO: User '%s' is %sauthorized to run container '%s'", userName, logPartial, containerName)
 	return userIsAuthorized, nil
 }
 
@@ -198,7 +200,7 @@ var isUserAuthorizedForPayModels = func(userName string, allowedPayModels []stri
 		If the user is using any of the pay models specified in `allowedPayModels`, return true.
 		Otherwise, return false.
 	*/
-	Config.Logger.Printf("DEBUG: Checking user '%s' pay model against allowed pay models %v", userName, allowedPayModels)
+	Config().Logger.Printf("DEBUG: Checking user '%s' pay model against allowed pay models %v", userName, allowedPayModels)
 
 	if len(allowedPayModels) == 0 {
 		// no pay models are allowed => everyone is denied access (although we should never reach this block
@@ -207,12 +209,12 @@ var isUserAuthorizedForPayModels = func(userName string, allowedPayModels []stri
 	}
 
 	if userName == "" {
-		Config.Logger.Print("User is not logged in, assume they are not allowed to run container")
+		Config().Logger.Print("User is not logged in, assume they are not allowed to run container")
 		return false, nil
 	}
 	currentPayModel, err := getCurrentPayModel(userName)
 	if err != nil {
-		Config.Logger.Printf("Failed to get current pay model for user '%s', unable to check if user is authorized to launch container. Error: %s", userName, err.Error())
+		Config().Logger.Printf("Failed to get current pay model for user '%s', unable to check if user is authorized to launch container. Error: %s", userName, err.Error())
 		return false, nil
 	}
 
@@ -223,7 +225,7 @@ var isUserAuthorizedForPayModels = func(userName string, allowedPayModels []stri
 	}
 
 	if !stringArrayContains(allowedPayModels, currentPayModelName) {
-		Config.Logger.Printf("DEBUG: Pay model '%s' is not allowed for container", currentPayModelName)
+		Config().Logger.Printf("DEBUG: Pay model '%s' is not allowed for container", currentPayModelName)
 		return false, nil // do not return this pay model as an option
 	}
 
@@ -231,7 +233,7 @@ var isUserAuthorizedForPayModels = func(userName string, allowedPayModels []stri
 }
 
 var isUserAuthorizedForResourcePaths = func(userName string, accessToken string, resourcePaths []string) (bool, error) {
-	Config.Logger.Printf("DEBUG: Checking user '%s' access to resource paths %v (service 'jupyterhub', method 'launch')", userName, resourcePaths)
+	Config().Logger.Printf("DEBUG: Checking user '%s' access to resource paths %v (service 'jupyterhub', method 'launch')", userName, resourcePaths)
 
 	body := fmt.Sprintf("{\"user\": {\"token\": \"%s\"}, \"requests\": [", accessToken)
 	for _, resource := range resourcePaths {
@@ -242,16 +244,16 @@ var isUserAuthorizedForResourcePaths = func(userName string, accessToken string,
 
 	authorized, err := arboristAuthRequest(body)
 	if err != nil {
-		Config.Logger.Printf("something went wrong when making a call to arborist's `/auth/request` endpoint. Denying access. Details: %v", err.Error())
+		Config().Logger.Printf("something went wrong when making a call to arborist's `/auth/request` endpoint. Denying access. Details: %v", err.Error())
 		return false, nil
 	}
 
 	return authorized, nil
 }
 
-var arboristAuthRequest = func(body string) (bool, error) {
-	arboristUrl := "http://arborist-service/auth/request"
-	req, err := http.NewRequest("POST", arboristUrl, bytes.NewBufferString(body))
+var arboristAuthRequest = func(body string) (authorized bool, err error) {
+	defer func(start time.Time) { observeExternalRequest("arborist", "auth_request", start, err) }(time.Now())
+	req, err := http.NewRequest("POST", arboristAuthRequestURL, bytes.NewBufferString(body))
 	if err != nil {
 		return false, errors.New("Error occurred while generating HTTP request: " + err.Error())
 	}
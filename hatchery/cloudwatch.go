@@ -20,22 +20,22 @@ func (sess *CREDS) CreateLogGroup(LogGroupName string, creds *credentials.Creden
 
 	logGroup, err := c.DescribeLogGroups(describeLogGroupIn)
 	if err != nil {
-		Config.Logger.Printf("Error in DescribeLogGroup: %s", err)
+		Config().Logger.Printf("Error in DescribeLogGroup: %s", err)
 		return "", err
 	}
 	if len(logGroup.LogGroups) == 0 {
-		Config.Logger.Printf("Creating LogGroup: %s", LogGroupName)
+		Config().Logger.Printf("Creating LogGroup: %s", LogGroupName)
 		createLogGroupIn := &cloudwatchlogs.CreateLogGroupInput{
 			LogGroupName: aws.String(LogGroupName),
 		}
 		newLogGroup, err := c.CreateLogGroup(createLogGroupIn)
 		if err != nil {
-			Config.Logger.Printf("Error in  CreateLogGroup: %s, %s", err, newLogGroup)
+			Config().Logger.Printf("Error in  CreateLogGroup: %s, %s", err, newLogGroup)
 			return "", err
 		}
 		return LogGroupName, nil
 	} else {
-		Config.Logger.Printf("LogGroup already exists: %s", LogGroupName)
+		Config().Logger.Printf("LogGroup already exists: %s", LogGroupName)
 	}
 	return *logGroup.LogGroups[0].LogGroupName, nil
 }
@@ -48,6 +48,8 @@ type LicenseInfo struct {
 
 // Container Struct to hold the configuration for Pod Container
 type Container struct {
+	ID                 string            `json:"id,omitempty"`
+	LegacyIds          []string          `json:"legacy-ids,omitempty"`
 	Name               string            `json:"name"`
 	GPU                bool              `json:"gpu"`
 	CPULimit           string            `json:"cpu-limit"`
@@ -73,17 +75,178 @@ type Container struct {
 	NextflowConfig     NextflowConfig    `json:"nextflow"`
 	License            LicenseInfo       `json:"license"`
 	Authz              AuthzConfig       `json:"authz"`
+	GPUCount           int               `json:"gpu-count,omitempty"`
+	// where the gen3 credentials file with the workspace's API key is mounted, if set
+	CredentialsFile string `json:"credentials-file,omitempty"`
+	// settings of the user volume, overriding the global `user-volume`
+	UserVolume *UserVolumeConfig `json:"user-volume,omitempty"`
+	// run to completion before the workspace containers start, e.g. to seed the user volume
+	InitContainers []k8sv1.Container `json:"init-containers,omitempty"`
+	// volumes added to the pod on top of the user, shared data and gen3 volumes
+	ExtraVolumes []ExtraVolume `json:"extra-volumes,omitempty"`
+	// one of the `sidecar-profiles`, or "none" to run without sidecar. The global `sidecar` if not set
+	Sidecar string `json:"sidecar,omitempty"`
+	// replaces the global `security-profile`
+	SecurityProfile *SecurityProfile `json:"security-profile,omitempty"`
+	// requests are equal to the limits unless set, or scaled from the limits by the ratio
+	CPURequest    string  `json:"cpu-request,omitempty"`
+	MemoryRequest string  `json:"memory-request,omitempty"`
+	RequestRatio  float64 `json:"request-ratio,omitempty"`
+	// the extended resource of the GPUs, "nvidia.com/gpu" by default, e.g. a MIG slice
+	GPUResourceName string `json:"gpu-resource-name,omitempty"`
+	// the GPU model the workspaces are scheduled on, matched with the `gpu-model-node-label` label
+	GPUModel string          `json:"gpu-model,omitempty"`
+	Sizes    *ContainerSizes `json:"sizes,omitempty"`
+	// catalog metadata returned by `/options`
+	Description      string   `json:"description,omitempty"`
+	LogoURL          string   `json:"logo-url,omitempty"`
+	Categories       []string `json:"categories,omitempty"`
+	DocumentationURL string   `json:"documentation-url,omitempty"`
+	Version          string   `json:"version,omitempty"`
+	Deprecated       bool     `json:"deprecated,omitempty"`
+	Beta             bool     `json:"beta,omitempty"`
+	// egress allowed for this container's workspaces on top of `network-policy.allowed-egress`
+	NetworkEgress []NetworkPolicyRule `json:"network-egress,omitempty"`
+	// overrides the default `role: jupyter` or `role: gpu` placement
+	WorkspaceScheduling
+}
+
+// WorkspaceScheduling is where the pods of workspaces are scheduled. The settings that
+// are set replace the default placement, and the container's settings for pay models
+type WorkspaceScheduling struct {
+	NodeSelector      map[string]string                `json:"node-selector,omitempty"`
+	Tolerations       []k8sv1.Toleration               `json:"tolerations,omitempty"`
+	Affinity          *k8sv1.Affinity                  `json:"affinity,omitempty"`
+	PriorityClassName string                           `json:"priority-class-name,omitempty"`
+	TopologySpread    []k8sv1.TopologySpreadConstraint `json:"topology-spread,omitempty"`
+}
+
+// WorkspaceSize is the resources of a workspace, as chosen when launching it
+type WorkspaceSize struct {
+	Name        string `json:"name,omitempty"`
+	CPULimit    string `json:"cpu-limit,omitempty"`
+	MemoryLimit string `json:"memory-limit,omitempty"`
+	GPUCount    int    `json:"gpu-count,omitempty"`
+	VolumeSize  string `json:"volume-size,omitempty"`
+}
+
+// WorkspaceSizeTier is a named size of a container. Resources that are not set keep the container's
+type WorkspaceSizeTier struct {
+	Name        string `json:"name,omitempty"`
+	CPULimit    string `json:"cpu-limit,omitempty"`
+	MemoryLimit string `json:"memory-limit,omitempty"`
+	GPUCount    *int   `json:"gpu-count,omitempty"`
+	VolumeSize  string `json:"volume-size,omitempty"`
+}
+
+// WorkspaceSizeCaps are the largest resources of the workspaces launched with a pay model.
+// Resources that are not set are not capped
+type WorkspaceSizeCaps struct {
+	CPULimit    string `json:"cpu-limit,omitempty"`
+	MemoryLimit string `json:"memory-limit,omitempty"`
+	GPUCount    *int   `json:"gpu-count,omitempty"`
+	VolumeSize  string `json:"volume-size,omitempty"`
+}
+
+// ContainerSizes are the sizes users can choose from when launching a container:
+// named tiers, and ranges within which each resource can be set
+type ContainerSizes struct {
+	Tiers       []WorkspaceSizeTier `json:"tiers,omitempty"`
+	CPULimit    *QuantityRange      `json:"cpu-limit,omitempty"`
+	MemoryLimit *QuantityRange      `json:"memory-limit,omitempty"`
+	GPUCount    *CountRange         `json:"gpu-count,omitempty"`
+	VolumeSize  *QuantityRange      `json:"volume-size,omitempty"`
+}
+
+// UserVolumeConfig are the settings of the user volumes. Settings that are not set keep
+// the global ones
+type UserVolumeConfig struct {
+	// the size of new volumes
+	Size         string `json:"size,omitempty"`
+	StorageClass string `json:"storage-class,omitempty"`
+	// "ReadWriteOnce" (default), "ReadWriteOncePod" or "ReadWriteMany"
+	AccessMode string `json:"access-mode,omitempty"`
+	// the largest size users can expand their volumes to. Volumes can't be expanded unless set
+	MaxSize string `json:"max-size,omitempty"`
+}
+
+// ExtraVolume is a volume added to the workspace pods. Exactly one source must be set
+type ExtraVolume struct {
+	Name string `json:"name"`
+	// the name of a ConfigMap of the user namespace
+	ConfigMap           string                     `json:"config-map,omitempty"`
+	ServiceAccountToken *ServiceAccountTokenVolume `json:"service-account-token,omitempty"`
+	EmptyDir            *EmptyDirVolume            `json:"empty-dir,omitempty"`
+	Mounts              []ExtraVolumeMount         `json:"mounts,omitempty"`
+}
+
+// ServiceAccountTokenVolume is a token of the pod's service account, projected in the volume
+type ServiceAccountTokenVolume struct {
+	Audience          string `json:"audience,omitempty"`
+	ExpirationSeconds int64  `json:"expiration-seconds,omitempty"`
+	// the file of the token in the volume, "token" by default
+	Path string `json:"path,omitempty"`
+}
+
+// EmptyDirVolume is a scratch volume, deleted with the pod
+type EmptyDirVolume struct {
+	SizeLimit string `json:"size-limit,omitempty"`
+	// "Memory" for a tmpfs
+	Medium string `json:"medium,omitempty"`
+}
+
+// ExtraVolumeMount mounts an extra volume in a container of the pod: the workspace's
+// "hatchery-container" unless set, an init container or a friend
+type ExtraVolumeMount struct {
+	Container string `json:"container,omitempty"`
+	MountPath string `json:"mount-path"`
+	SubPath   string `json:"sub-path,omitempty"`
+	ReadOnly  bool   `json:"read-only,omitempty"`
+}
+
+// QuantityRange bounds a resource quantity such as "2Gi"
+type QuantityRange struct {
+	Min string `json:"min"`
+	Max string `json:"max"`
+}
+
+// CountRange bounds a number of resources
+type CountRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
 }
 
 // SidecarContainer holds fuse sidecar configuration
 type SidecarContainer struct {
 	CPULimit         string            `json:"cpu-limit"`
 	MemoryLimit      string            `json:"memory-limit"`
+	CPURequest       string            `json:"cpu-request"`
+	MemoryRequest    string            `json:"memory-request"`
+	RequestRatio     float64           `json:"request-ratio"`
 	Image            string            `json:"image"`
 	Env              map[string]string `json:"env"`
 	Args             []string          `json:"args"`
 	Command          []string          `json:"command"`
 	LifecyclePreStop []string          `json:"lifecycle-pre-stop"`
+	// run without privileges: FUSE mounts are not propagated to the workspace containers
+	Unprivileged bool `json:"unprivileged,omitempty"`
+}
+
+// SecurityProfile hardens the workspace containers: the main container, the friends, the
+// init containers and an unprivileged sidecar. Privileged containers are left as they are
+type SecurityProfile struct {
+	// "RuntimeDefault", "Unconfined" or "Localhost/<profile>"
+	Seccomp  string `json:"seccomp,omitempty"`
+	AppArmor string `json:"apparmor,omitempty"`
+	// added to the capabilities the containers drop and add, e.g. ["ALL"] and ["NET_BIND_SERVICE"]
+	DropCapabilities []string `json:"drop-capabilities,omitempty"`
+	AddCapabilities  []string `json:"add-capabilities,omitempty"`
+	// privilege escalation is disallowed unless set
+	AllowPrivilegeEscalation bool `json:"allow-privilege-escalation,omitempty"`
+	RunAsNonRoot             bool `json:"run-as-non-root,omitempty"`
+	ReadOnlyRootFilesystem   bool `json:"read-only-root-filesystem,omitempty"`
+	// scratch space mounted over the read-only root file system, e.g. /tmp
+	WritablePaths []string `json:"writable-paths,omitempty"`
 }
 
 // AppConfigInfo provides the type and path of a supplementary config path
@@ -91,6 +254,9 @@ type AppConfigInfo struct {
 	AppType string `json:"type"`
 	Path    string
 	Name    string
+	ID      string `json:"id"`
+	// scheduling of the app's workspaces, for `dockstore-compose:1.0.0` apps
+	WorkspaceScheduling
 }
 
 // TODO remove PayModel from config once DynamoDB contains all necessary data
@@ -122,6 +288,11 @@ type DbConfig struct {
 type Pricing struct {
 	Cpu    float64 `json:"cpu"`
 	Memory float64 `json:"memory"`
+	// "request" (default) to charge for the resources requested by workspaces, or "limit"
+	Basis string `json:"basis"`
+	// hourly price of a GPU by extended resource name, and by GPU model which takes precedence
+	GPU       map[string]float64 `json:"gpu"`
+	GPUModels map[string]float64 `json:"gpu-models"`
 }
 
 // HatcheryConfig is the root of all the configuration
@@ -130,6 +301,7 @@ type HatcheryConfig struct {
 	DefaultPayModel PayModel `json:"default-pay-model"`
 	// DisableLocalWS         bool             `json:"disable-local-ws"`
 	SkipNodeSelector       bool                 `json:"skip-node-selector"`
+	GPUModelNodeLabel      string               `json:"gpu-model-node-label"`
 	UseInteralServicesURL  bool                 `json:"use-internal-services-url"`
 	PayModels              []PayModel           `json:"pay-models"`
 	PayModelsDynamodbTable string               `json:"pay-models-dynamodb-table"`
@@ -145,6 +317,111 @@ type HatcheryConfig struct {
 	PrismaConfig           PrismaConfig         `json:"prisma"`
 	NextflowGlobalConfig   NextflowGlobalConfig `json:"nextflow-global"`
 	Pricing                Pricing              `json:"pricing"`
+	IdleCuller             IdleCullerConfig     `json:"idle-culler"`
+	MaxWorkspacesPerUser   int                  `json:"max-workspaces-per-user"`
+	Audit                  AuditConfig          `json:"audit"`
+	ConfigReload           ConfigReloadConfig   `json:"config-reload"`
+	// the largest workspaces users can launch, by pay model type (`workspace_type`)
+	PayModelSizeCaps map[string]WorkspaceSizeCaps `json:"pay-model-size-caps"`
+	Routing          RoutingConfig                `json:"routing"`
+	NetworkPolicy    NetworkPolicyConfig          `json:"network-policy"`
+	// scheduling of the workspaces by pay model type, overriding the containers' scheduling
+	PayModelScheduling map[string]WorkspaceScheduling `json:"pay-model-scheduling"`
+	// the user volumes, whose size defaults to `user-volume-size`
+	UserVolume UserVolumeConfig `json:"user-volume"`
+	// named sidecars which containers can run instead of the global `sidecar`
+	SidecarProfiles map[string]SidecarContainer `json:"sidecar-profiles"`
+	// the security context of the workspace containers, unless they set their own
+	SecurityProfile *SecurityProfile `json:"security-profile"`
+	// "restricted" refuses containers whose pods the Pod Security Admission "restricted" level would reject
+	PodSecurityStandard string `json:"pod-security-standard"`
+	// the user volumes by pay model type, overriding the containers' settings
+	PayModelUserVolume map[string]UserVolumeConfig `json:"pay-model-user-volume"`
+	// snapshots of the user volumes, and backups of the ECS workspaces' EFS file systems
+	Snapshots SnapshotConfig `json:"snapshots"`
+}
+
+// NetworkPolicyConfig is the NetworkPolicy created for each workspace pod. Workspaces
+// only accept requests from the proxy, and can't reach the instance metadata service
+// or the cluster CIDRs except for the allowed egress
+type NetworkPolicyConfig struct {
+	Enabled bool `json:"enabled"`
+	// labels of the proxy pods and of their namespace. Defaults to `app: ambassador` in any namespace
+	ProxyPodSelector       map[string]string `json:"proxy-pod-selector"`
+	ProxyNamespaceSelector map[string]string `json:"proxy-namespace-selector"`
+	// CIDRs allowed to send requests to the workspaces, e.g. the local cluster for external clusters
+	IngressCIDRs []string `json:"ingress-cidrs"`
+	// pod and service CIDRs of the cluster, which workspaces can't reach
+	ClusterCIDRs  []string            `json:"cluster-cidrs"`
+	AllowedEgress []NetworkPolicyRule `json:"allowed-egress"`
+	// labels of the DNS pods and of their namespace, which workspaces can reach on port 53.
+	// Default to `k8s-app: kube-dns` in the `kube-system` namespace
+	DNSPodSelector       map[string]string `json:"dns-pod-selector"`
+	DNSNamespaceSelector map[string]string `json:"dns-namespace-selector"`
+}
+
+// NetworkPolicyRule allows traffic to CIDRs or to the pods matching the selectors, on the ports if any
+type NetworkPolicyRule struct {
+	CIDRs             []string            `json:"cidrs,omitempty"`
+	PodSelector       map[string]string   `json:"pod-selector,omitempty"`
+	NamespaceSelector map[string]string   `json:"namespace-selector,omitempty"`
+	Ports             []NetworkPolicyPort `json:"ports,omitempty"`
+}
+
+// NetworkPolicyPort is a port of a NetworkPolicyRule
+type NetworkPolicyPort struct {
+	Port int32 `json:"port"`
+	// "TCP" (default), "UDP" or "SCTP"
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// RoutingConfig selects how the requests of users are routed to their workspaces
+type RoutingConfig struct {
+	// one of "ambassador-annotation" (default), "emissary-mapping", "ingress" and "gateway-httproute"
+	Type string `json:"type"`
+	// host matched by the routes, e.g. the commons hostname. All hosts by default
+	Hostname           string            `json:"hostname"`
+	IngressClassName   string            `json:"ingress-class-name"`
+	IngressAnnotations map[string]string `json:"ingress-annotations"`
+	GatewayName        string            `json:"gateway-name"`
+	GatewayNamespace   string            `json:"gateway-namespace"`
+}
+
+// Config for reloading the config file when it changes
+type ConfigReloadConfig struct {
+	Enabled         bool `json:"enabled"`
+	IntervalSeconds int  `json:"interval-seconds"`
+}
+
+// Config for the audit log of workspace lifecycle and billing actions
+type AuditConfig struct {
+	Sink          string `json:"sink"`
+	FilePath      string `json:"file-path"`
+	DynamodbTable string `json:"dynamodb-table"`
+	// role to assume to access the `dynamodb-table`, if set
+	DynamodbArn string `json:"dynamodb-arn"`
+}
+
+// Config for the server-side idle culler
+type IdleCullerConfig struct {
+	Enabled         bool `json:"enabled"`
+	IntervalSeconds int  `json:"interval-seconds"`
+}
+
+// Config for the snapshots of the user volumes. Kubernetes volumes are snapshotted with CSI
+// VolumeSnapshots, and EFS file systems are backed up with AWS Backup
+type SnapshotConfig struct {
+	Enabled             bool   `json:"enabled"`
+	VolumeSnapshotClass string `json:"volume-snapshot-class"`
+	// on-demand snapshots kept per volume
+	MaxSnapshots int `json:"max-snapshots"`
+	// scheduled snapshots are disabled unless set
+	ScheduleIntervalHours int `json:"schedule-interval-hours"`
+	// scheduled snapshots kept per volume
+	ScheduleRetention int `json:"schedule-retention"`
+	// the AWS Backup vault and the role AWS Backup assumes, in the pay models' accounts
+	EfsBackupVault    string `json:"efs-backup-vault"`
+	EfsBackupRoleName string `json:"efs-backup-role-name"`
 }
 
 // Config to allow for Prisma Agents
@@ -160,6 +437,52 @@ type FullHatcheryConfig struct {
 	ContainersMap map[string]Container
 	PayModelMap   map[string]PayModel
 	Logger        *log.Logger
+	// ContainerRevisions maps container ids to the revision of their config
+	ContainerRevisions map[string]string
+	// ContainerAliases maps the legacy ids of containers with an explicit id to that id
+	ContainerAliases map[string]string
+}
+
+// containerRevision is the MD5 hash of the container's config, which changes with any
+// edit of the container. It is the id of containers that do not have an explicit id
+func containerRevision(container Container) string {
+	container.ID = ""
+	container.LegacyIds = nil
+	jsonBytes, _ := json.Marshal(container)
+	return fmt.Sprintf("%x", md5.Sum([]byte(jsonBytes)))
+}
+
+// resolveContainerId returns the id of the container with the given id or legacy id
+func (config *FullHatcheryConfig) resolveContainerId(id string) (string, bool) {
+	if _, ok := config.ContainersMap[id]; ok {
+		return id, true
+	}
+	if current, ok := config.ContainerAliases[id]; ok {
+		return current, true
+	}
+	return "", false
+}
+
+// inheritContainerAliases keeps the ids used with the previous config resolving: the
+// revisions and aliases of containers that still exist become aliases
+func (config *FullHatcheryConfig) inheritContainerAliases(previous *FullHatcheryConfig) {
+	addAlias := func(alias string, id string) {
+		if _, ok := config.ContainersMap[id]; !ok {
+			return
+		}
+		if _, ok := config.ContainersMap[alias]; ok || alias == "" {
+			return
+		}
+		if _, ok := config.ContainerAliases[alias]; !ok {
+			config.ContainerAliases[alias] = id
+		}
+	}
+	for alias, id := range previous.ContainerAliases {
+		addAlias(alias, id)
+	}
+	for id, revision := range previous.ContainerRevisions {
+		addAlias(revision, id)
+	}
 }
 
 var evalSymLinks = filepath.EvalSymlinks
@@ -208,73 +531,83 @@ func LoadConfig(configFilePath string, loggerIn *log.Logger) (config *FullHatche
 	data.Logger.Printf("loaded config: %v", string(plan))
 	data.ContainersMap = make(map[string]Container)
 	data.PayModelMap = make(map[string]PayModel)
+	data.ContainerRevisions = make(map[string]string)
+	data.ContainerAliases = make(map[string]string)
 	err = json.Unmarshal(plan, &data.Config)
 	if nil != err {
 		data.Logger.Printf("Unable to unmarshal configuration: %v", err)
 		return nil, err
 	}
-	if nil != data.Config.MoreConfigs && 0 < len(data.Config.MoreConfigs) {
-		for _, info := range data.Config.MoreConfigs {
-			if info.AppType == "dockstore-compose:1.0.0" {
-				if info.Name == "" {
-					return nil, fmt.Errorf("empty name for more-configs app at: %v", info.Path)
-				}
-				data.Logger.Printf("loading config from %v", info.Path)
-				composeModel, err := DockstoreComposeFromFile(info.Path)
-				if nil != err {
-					data.Logger.Printf("failed to load config from %v, got: %v", info.Path, err)
-					return nil, err
-				}
-				data.Logger.Printf("%v", composeModel)
-				hatchApp, err := composeModel.BuildHatchApp()
-				hatchApp.Name = info.Name
-				if nil != err {
-					data.Logger.Printf("failed to translate app, got: %v", err)
-					return nil, err
-				}
-				data.Config.Containers = append(data.Config.Containers, *hatchApp)
-			} else {
-				data.Logger.Printf("ignoring config of unsupported type: %v", info.AppType)
+	containerPaths := make([]string, len(data.Config.Containers))
+	for i := range data.Config.Containers {
+		containerPaths[i] = fmt.Sprintf("$.containers[%d]", i)
+	}
+	// problems are collected so they can all be reported at once
+	var configErrors ConfigErrors
+	for i, info := range data.Config.MoreConfigs {
+		path := fmt.Sprintf("$.more-configs[%d]", i)
+		if info.AppType == "dockstore-compose:1.0.0" {
+			if info.Name == "" {
+				configErrors = append(configErrors, ConfigError{Path: path + ".name", Message: fmt.Sprintf("empty name for more-configs app at: %v", info.Path)})
+				continue
+			}
+			data.Logger.Printf("loading config from %v", info.Path)
+			composeModel, err := DockstoreComposeFromFile(info.Path)
+			if nil != err {
+				data.Logger.Printf("failed to load config from %v, got: %v", info.Path, err)
+				configErrors = append(configErrors, ConfigError{Path: path + ".path", Message: fmt.Sprintf("failed to load config from %v: %v", info.Path, err)})
+				continue
 			}
+			data.Logger.Printf("%v", composeModel)
+			hatchApp, err := composeModel.BuildHatchApp()
+			if nil != err {
+				data.Logger.Printf("failed to translate app, got: %v", err)
+				configErrors = append(configErrors, ConfigError{Path: path, Message: fmt.Sprintf("failed to translate app from %v: %v", info.Path, err)})
+				continue
+			}
+			hatchApp.Name = info.Name
+			hatchApp.ID = info.ID
+			hatchApp.WorkspaceScheduling = info.WorkspaceScheduling
+			data.Config.Containers = append(data.Config.Containers, *hatchApp)
+			containerPaths = append(containerPaths, path)
+		} else {
+			data.Logger.Printf("ignoring config of unsupported type: %v", info.AppType)
 		}
 	}
 
-	for _, container := range data.Config.Containers {
-		err = ValidateAuthzConfig(data.Logger, container.Authz)
-		if nil != err {
-			data.Logger.Printf("Container '%s' has an invalid 'authz' configuration: %v", container.Name, err)
-			return nil, err
+	configErrors = append(configErrors, validateHatcheryConfig(data.Logger, &data.Config, containerPaths)...)
+	if len(configErrors) > 0 {
+		for _, configError := range configErrors {
+			data.Logger.Printf("Error in configuration: %v", configError)
 		}
-		jsonBytes, _ := json.Marshal(container)
-		hash := fmt.Sprintf("%x", md5.Sum([]byte(jsonBytes)))
-		data.ContainersMap[hash] = container
-	}
-
-	if data.Config.LicenseUserMapsTable == "" {
-		data.Logger.Printf("Warning: no 'license-user-maps-dynamodb-table' in configuration: will be unable to store license-user-map data in DynamoDB")
-	} else if data.Config.LicenseUserMapsGSI == "" {
-		err = fmt.Errorf("'license-user-maps-dynamodb-table' is present but missing 'license-user-maps-global-secondary-index'")
-		data.Logger.Printf("Error in configuration: %v", err)
-		return nil, err
+		return nil, configErrors
 	}
 
 	for _, container := range data.Config.Containers {
-		data.Logger.Printf("Checking license config info for container %s", container.Name)
-		if container.License.Enabled {
-			if data.Config.LicenseUserMapsTable == "" {
-				err = fmt.Errorf("no 'license-user-maps-dynamodb-table' in configuration but license is configured for container '%s'", container.Name)
-				data.Logger.Printf("Error in configuration: %v", err)
-				return nil, err
-			}
-			err := validateContainerLicenseInfo(container.Name, container.License)
-			if err != nil {
-				err = fmt.Errorf("container '%s' has an invalid 'license' configuration", container.Name)
-				data.Logger.Printf("Error in configuration: %v", err)
-				return nil, err
+		// containers without an explicit id are identified by their revision
+		revision := containerRevision(container)
+		id := container.ID
+		if id == "" {
+			id = revision
+		} else {
+			// keep the ids used before the explicit id was set resolving
+			data.ContainerAliases[revision] = id
+			for _, legacyId := range container.LegacyIds {
+				data.ContainerAliases[legacyId] = id
 			}
 		}
+		data.ContainersMap[id] = container
+		data.ContainerRevisions[id] = revision
+	}
+	for alias := range data.ContainerAliases {
+		if _, ok := data.ContainersMap[alias]; ok {
+			delete(data.ContainerAliases, alias)
+		}
 	}
 
+	if data.Config.LicenseUserMapsTable == "" {
+		data.Logger.Printf("Warning: no 'license-user-maps-dynamodb-table' in configuration: will be unable to store license-user-map data in DynamoDB")
+	}
 	if data.Config.PayModelsDynamodbTable == "" {
 		data.Logger.Printf("Warning: no 'pay-models-dynamodb-table' in configuration: will be unable to query pay model data in DynamoDB")
 	}
@@ -292,5 +625,48 @@ func LoadConfig(configFilePath string, loggerIn *log.Logger) (config *FullHatche
 		data.Config.PrismaConfig.ConsoleVersion = "v32.02"
 	}
 
+	// Only allow one workspace per user unless configured otherwise
+	if data.Config.MaxWorkspacesPerUser <= 0 {
+		data.Config.MaxWorkspacesPerUser = 1
+	}
+
+	// Set default config reload interval
+	if data.Config.ConfigReload.IntervalSeconds <= 0 {
+		data.Config.ConfigReload.IntervalSeconds = 30
+	}
+
+	if data.Config.GPUModelNodeLabel == "" {
+		data.Config.GPUModelNodeLabel = defaultGPUModelNodeLabel
+	}
+
+	// Workspaces accept requests from ambassador by default
+	if len(data.Config.NetworkPolicy.ProxyPodSelector) == 0 {
+		data.Config.NetworkPolicy.ProxyPodSelector = map[string]string{"app": "ambassador"}
+	}
+	if len(data.Config.NetworkPolicy.DNSPodSelector) == 0 {
+		data.Config.NetworkPolicy.DNSPodSelector = map[string]string{"k8s-app": "kube-dns"}
+	}
+	if len(data.Config.NetworkPolicy.DNSNamespaceSelector) == 0 {
+		data.Config.NetworkPolicy.DNSNamespaceSelector = map[string]string{"kubernetes.io/metadata.name": "kube-system"}
+	}
+
+	if data.Config.Snapshots.MaxSnapshots <= 0 {
+		data.Config.Snapshots.MaxSnapshots = 5
+	}
+	if data.Config.Snapshots.ScheduleRetention <= 0 {
+		data.Config.Snapshots.ScheduleRetention = 7
+	}
+	if data.Config.Snapshots.EfsBackupVault == "" {
+		data.Config.Snapshots.EfsBackupVault = "Default"
+	}
+	if data.Config.Snapshots.EfsBackupRoleName == "" {
+		data.Config.Snapshots.EfsBackupRoleName = "service-role/AWSBackupDefaultServiceRole"
+	}
+
+	// Set default idle culler interval
+	if data.Config.IdleCuller.IntervalSeconds <= 0 {
+		data.Config.IdleCuller.IntervalSeconds = 300
+	}
+
 	return data, nil
 }
@@ -12,6 +12,18 @@ import (
 	"testing"
 )
 
+// withTestConfig replaces the config with a copy changed by update, until the end of the test
+func withTestConfig(t *testing.T, update func(config *FullHatcheryConfig)) {
+	t.Helper()
+	originalConfig := Config()
+	config := *originalConfig
+	update(&config)
+	SetConfig(&config)
+	t.Cleanup(func() {
+		SetConfig(originalConfig)
+	})
+}
+
 func TestLoadConfig(t *testing.T) {
 	defer SetupAndTeardownTest()()
 	expectedContainers := 8
@@ -197,17 +209,17 @@ func TestInvalidConfigs(t *testing.T) {
 		{
 			name:             "MissingLicenseGSI",
 			testData:         "../testData/testConfigMissingGSI.json",
-			wantErrorMessage: "'license-user-maps-dynamodb-table' is present but missing 'license-user-maps-global-secondary-index'",
+			wantErrorMessage: "$.license-user-maps-global-secondary-index: 'license-user-maps-dynamodb-table' is present but missing 'license-user-maps-global-secondary-index'",
 		},
 		{
 			name:             "MissingLicenseTable",
 			testData:         "../testData/testConfigMissingLicenseTable.json",
-			wantErrorMessage: "no 'license-user-maps-dynamodb-table' in configuration but license is configured for container 'Test-missing-license-table'",
+			wantErrorMessage: "$.containers[0].license: no 'license-user-maps-dynamodb-table' in configuration but license is configured for container 'Test-missing-license-table'",
 		},
 		{
 			name:             "InvalidLicenseInfo",
 			testData:         "../testData/testConfigInvalidLicense.json",
-			wantErrorMessage: "container 'Test-missing-license-type' has an invalid 'license' configuration",
+			wantErrorMessage: "$.containers[0].license.license-type: is required when the license is enabled",
 		},
 	}
 
@@ -248,3 +260,64 @@ func TestInvalidConfigs(t *testing.T) {
 	}
 
 }
+
+func Test_ContainerIds(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	// containers without an explicit id keep the id they had before explicit ids
+	config, err := LoadConfig("../testData/testConfig.json", nil)
+	if err != nil {
+		t.Fatalf("failed to load config, got: %v", err)
+	}
+	if got := config.ContainersMap["79f4f7bf4420161b6ab03740d84caf3e"].Name; got != "R Studio" {
+		t.Errorf("\nassertion error while testing `LoadConfig` legacy container id: \nWant:%v\nGot:%v", "R Studio", got)
+	}
+
+	loadContainers := func(containers string) *FullHatcheryConfig {
+		path := filepath.Join(t.TempDir(), "hatchery.json")
+		content := `{"sidecar": {"cpu-limit": "0.1", "memory-limit": "256Mi"}, "containers": [` + containers + `]}`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("unable to write config: %v", err)
+		}
+		config, err := LoadConfig(path, Config().Logger)
+		if err != nil {
+			t.Fatalf("failed to load config, got: %v", err)
+		}
+		return config
+	}
+	withoutId := loadContainers(`{"name": "Jupyter", "image": "jupyter", "cpu-limit": "1.0", "memory-limit": "1Gi", "target-port": 8888}`)
+	withId := loadContainers(`{"id": "jupyter", "legacy-ids": ["0123456789abcdef"], "name": "Jupyter", "image": "jupyter", "cpu-limit": "1.0", "memory-limit": "1Gi", "target-port": 8888}`)
+
+	var legacyId string
+	for id := range withoutId.ContainersMap {
+		legacyId = id
+	}
+	if _, ok := withId.ContainersMap["jupyter"]; !ok || len(withId.ContainersMap) != 1 {
+		t.Errorf("\nassertion error while testing `LoadConfig` explicit id: \nWant:jupyter\nGot:%v", withId.ContainersMap)
+	}
+	// the revision of a container is its id from before the explicit id was set
+	if got := withId.ContainerRevisions["jupyter"]; got != legacyId {
+		t.Errorf("\nassertion error while testing `LoadConfig` revision: \nWant:%v\nGot:%v", legacyId, got)
+	}
+	for _, id := range []string{"jupyter", legacyId, "0123456789abcdef"} {
+		if got, ok := withId.resolveContainerId(id); !ok || got != "jupyter" {
+			t.Errorf("\nassertion error while testing `resolveContainerId` for %s: \nWant:jupyter\nGot:%v", id, got)
+		}
+	}
+	if _, ok := withId.resolveContainerId("unknown"); ok {
+		t.Errorf("\nassertion error while testing `resolveContainerId` for an unknown id: \nWant:not found\nGot:found")
+	}
+
+	// editing the container changes its revision but not its id, and the previous
+	// revision keeps resolving after a reload
+	edited := loadContainers(`{"id": "jupyter", "name": "Jupyter", "image": "jupyter", "cpu-limit": "2.0", "memory-limit": "1Gi", "target-port": 8888}`)
+	if edited.ContainerRevisions["jupyter"] == legacyId {
+		t.Errorf("\nassertion error while testing `LoadConfig`: the revision did not change with the config")
+	}
+	edited.inheritContainerAliases(withId)
+	for _, id := range []string{legacyId, "0123456789abcdef"} {
+		if got, ok := edited.resolveContainerId(id); !ok || got != "jupyter" {
+			t.Errorf("\nassertion error while testing `inheritContainerAliases` for %s: \nWant:jupyter\nGot:%v", id, got)
+		}
+	}
+}
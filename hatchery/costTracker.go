@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"strings"
 	"sync"
@@ -28,6 +29,7 @@ import (
 type PodLifecycle struct {
 	PodName           string     `json:"pod_name"`
 	Namespace         string     `json:"namespace"`
+	WorkspaceId       string     `json:"workspace_id,omitempty"`
 	LaunchTime        time.Time  `json:"launch_time"`
 	StopTime          *time.Time `json:"stop_time,omitempty"`
 	NodeName          string     `json:"node_name"`
@@ -39,6 +41,7 @@ type PodLifecycle struct {
 type PodCost struct {
 	CPUCost    float64 `json:"cpu_cost"`
 	MemoryCost float64 `json:"memory_cost"`
+	GPUCost    float64 `json:"gpu_cost"`
 	TotalCost  float64 `json:"total_cost"`
 }
 
@@ -145,7 +148,7 @@ func (pt *PodTracker) startPodWatcher(ctx context.Context) {
 			return
 		default:
 			if err := pt.watchPods(ctx); err != nil {
-				Config.Logger.Printf("Pod watcher error: %v, retrying in 5s", err)
+				Config().Logger.Printf("Pod watcher error: %v, retrying in 5s", err)
 				time.Sleep(5 * time.Second)
 			}
 		}
@@ -198,7 +201,7 @@ func (pt *PodTracker) handlePodCreated(pod *v1.Pod, source string) {
 
 	// Don't overwrite if we already have it tracked
 	if _, exists := pt.podLifecycles[key]; exists {
-		Config.Logger.Printf("⚠️  Pod already tracked: %s", pod.Name)
+		Config().Logger.Printf("⚠️  Pod already tracked: %s", pod.Name)
 		return
 	}
 
@@ -207,13 +210,14 @@ func (pt *PodTracker) handlePodCreated(pod *v1.Pod, source string) {
 	pt.podLifecycles[key] = &PodLifecycle{
 		PodName:           pod.Name,
 		Namespace:         pod.Namespace,
+		WorkspaceId:       pod.Annotations[workspaceIdAnnotation],
 		LaunchTime:        launchTime,
 		NodeName:          pod.Spec.NodeName,
 		Source:            source,
 		CreationTimestamp: pod.CreationTimestamp.Time,
 	}
 
-	Config.Logger.Printf("🚀 Pod launched: %s at %s (source: %s)",
+	Config().Logger.Printf("🚀 Pod launched: %s at %s (source: %s)",
 		pod.Name, launchTime.Format(time.RFC3339), source)
 
 	// Check if user has a pay model and create one if needed
@@ -221,79 +225,181 @@ func (pt *PodTracker) handlePodCreated(pod *v1.Pod, source string) {
 }
 
 // calculatePodPrice calculates the cost of running a pod based on its resource requests and runtime
-func (pt *PodTracker) calculatePodPrice(pod *v1.Pod, runtime time.Duration) *PodCost {
+func calculatePodPrice(pod *v1.Pod, runtime time.Duration) *PodCost {
 	if runtime <= 0 {
 		return &PodCost{}
 	}
 
-	cpuPrice := Config.Config.Pricing.Cpu
-	memoryPrice := Config.Config.Pricing.Memory
+	cpuPrice := Config().Config.Pricing.Cpu
+	memoryPrice := Config().Config.Pricing.Memory
 
 	// Convert runtime to hours (fractional)
 	runtimeHours := runtime.Hours()
 
-	var totalCPUCost, totalMemoryCost float64
+	var totalCPUCost, totalMemoryCost, totalGPUCost float64
+	gpuModel := podGPUModel(pod)
 
 	// Iterate through all containers in the pod
 	for _, container := range pod.Spec.Containers {
-		// Get CPU request (convert from millicores to cores)
-		cpuRequest := container.Resources.Requests.Cpu()
+		billed := billedResources(container.Resources)
+		if gpus, gpuHourlyCost := gpuRequestsHourlyCost(billed, gpuModel); gpus > 0 {
+			gpuCost := gpuHourlyCost * runtimeHours
+			totalGPUCost += gpuCost
+
+			Config().Logger.Printf("Container %s - GPU: %.0f, Cost: $%.4f",
+				container.Name, gpus, gpuCost)
+		}
+
+		// Get CPU request, or limit (convert from millicores to cores)
+		cpuRequest := billed.Cpu()
 		if cpuRequest != nil {
 			cpuCores := float64(cpuRequest.MilliValue()) / 1000.0
 			cpuCost := cpuCores * cpuPrice * runtimeHours
 			totalCPUCost += cpuCost
 
-			Config.Logger.Printf("Container %s - CPU: %.3f cores, Cost: $%.4f",
+			Config().Logger.Printf("Container %s - CPU: %.3f cores, Cost: $%.4f",
 				container.Name, cpuCores, cpuCost)
 		}
 
-		// Get Memory request (convert to GB)
-		memRequest := container.Resources.Requests.Memory()
+		// Get Memory request, or limit (convert to GB)
+		memRequest := billed.Memory()
 		if memRequest != nil {
 			memoryGB := float64(memRequest.Value()) / (1024 * 1024 * 1024)
 			memoryCost := memoryGB * memoryPrice * runtimeHours
 			totalMemoryCost += memoryCost
 
-			Config.Logger.Printf("Container %s - Memory: %.3f GB, Cost: $%.4f",
+			Config().Logger.Printf("Container %s - Memory: %.3f GB, Cost: $%.4f",
 				container.Name, memoryGB, memoryCost)
 		}
 	}
 
 	// Also check init containers if they exist
 	for _, initContainer := range pod.Spec.InitContainers {
-		cpuRequest := initContainer.Resources.Requests.Cpu()
+		billed := billedResources(initContainer.Resources)
+		cpuRequest := billed.Cpu()
 		if cpuRequest != nil {
 			cpuCores := float64(cpuRequest.MilliValue()) / 1000.0
 			cpuCost := cpuCores * cpuPrice * runtimeHours
 			totalCPUCost += cpuCost
 
-			Config.Logger.Printf("InitContainer %s - CPU: %.3f cores, Cost: $%.4f",
+			Config().Logger.Printf("InitContainer %s - CPU: %.3f cores, Cost: $%.4f",
 				initContainer.Name, cpuCores, cpuCost)
 		}
 
-		memRequest := initContainer.Resources.Requests.Memory()
+		memRequest := billed.Memory()
 		if memRequest != nil {
 			memoryGB := float64(memRequest.Value()) / (1024 * 1024 * 1024)
 			memoryCost := memoryGB * memoryPrice * runtimeHours
 			totalMemoryCost += memoryCost
 
-			Config.Logger.Printf("InitContainer %s - Memory: %.3f GB, Cost: $%.4f",
+			Config().Logger.Printf("InitContainer %s - Memory: %.3f GB, Cost: $%.4f",
 				initContainer.Name, memoryGB, memoryCost)
 		}
 	}
 
-	totalCost := totalCPUCost + totalMemoryCost
+	totalCost := totalCPUCost + totalMemoryCost + totalGPUCost
 
-	Config.Logger.Printf("💰 Pod %s total cost: CPU=$%.4f, Memory=$%.4f, Total=$%.4f (runtime: %.2f hours)",
-		pod.Name, totalCPUCost, totalMemoryCost, totalCost, runtimeHours)
+	Config().Logger.Printf("💰 Pod %s total cost: CPU=$%.4f, Memory=$%.4f, GPU=$%.4f, Total=$%.4f (runtime: %.2f hours)",
+		pod.Name, totalCPUCost, totalMemoryCost, totalGPUCost, totalCost, runtimeHours)
 
 	return &PodCost{
 		CPUCost:    totalCPUCost,
 		MemoryCost: totalMemoryCost,
+		GPUCost:    totalGPUCost,
 		TotalCost:  totalCost,
 	}
 }
 
+// isGPUResource returns true if the extended resource is a GPU, or a slice of a GPU
+func isGPUResource(resourceName v1.ResourceName) bool {
+	name := string(resourceName)
+	if _, ok := Config().Config.Pricing.GPU[name]; ok {
+		return true
+	}
+	return name == defaultGPUResourceName || strings.HasPrefix(name, "nvidia.com/mig-") || strings.HasSuffix(name, "/gpu")
+}
+
+// gpuHourlyPrice returns the hourly price of a GPU of the extended resource: the price of its model
+// if there is one, else the price of the resource
+func gpuHourlyPrice(resourceName v1.ResourceName, model string) float64 {
+	pricing := Config().Config.Pricing
+	if price, ok := pricing.GPUModels[model]; ok && model != "" {
+		return price
+	}
+	return pricing.GPU[string(resourceName)]
+}
+
+// gpuRequestsHourlyCost returns the number of GPUs in the requests and their hourly price
+func gpuRequestsHourlyCost(requests v1.ResourceList, model string) (float64, float64) {
+	var gpus, cost float64
+	for name, quantity := range requests {
+		if !isGPUResource(name) {
+			continue
+		}
+		count := float64(quantity.Value())
+		gpus += count
+		cost += count * gpuHourlyPrice(name, model)
+	}
+	return gpus, cost
+}
+
+// podGPUModel returns the GPU model the pod is scheduled on, if any
+func podGPUModel(pod *v1.Pod) string {
+	label := Config().Config.GPUModelNodeLabel
+	if label == "" {
+		label = defaultGPUModelNodeLabel
+	}
+	return pod.Spec.NodeSelector[label]
+}
+
+// isPricingConfigured returns true if a price is set for any resource
+func isPricingConfigured() bool {
+	pricing := Config().Config.Pricing
+	return pricing.Cpu != 0 || pricing.Memory != 0 || len(pricing.GPU) > 0 || len(pricing.GPUModels) > 0
+}
+
+// estimateHourlyCost returns the cost of running the container's workspace for an hour at its
+// default size: the container, its friends and the sidecar, priced like calculatePodPrice, and the GPUs
+func estimateHourlyCost(hatchApp Container) float64 {
+	var containers []v1.ResourceRequirements
+	if sidecar, err := containerSidecar(&Config().Config, &hatchApp); err == nil && sidecar != nil {
+		if resources, err := sidecarResources(*sidecar); err == nil {
+			containers = append(containers, resources)
+		}
+	}
+	// some pods (ex - dockstore apps) only have "Friend" containers
+	if hatchApp.Image != "" {
+		if container, err := containerResources(&hatchApp); err == nil {
+			containers = append(containers, container)
+		}
+	}
+
+	var cpuCores, memoryGB float64
+	for _, container := range containers {
+		billed := billedResources(container)
+		cpuCores += float64(billed.Cpu().MilliValue()) / 1000.0
+		memoryGB += float64(billed.Memory().Value()) / (1024 * 1024 * 1024)
+	}
+	gpuModel := ""
+	var gpuCost float64
+	if hatchApp.GPU {
+		gpuModel = hatchApp.GPUModel
+		if hatchApp.Image != "" {
+			gpuCost += float64(containerGPUCount(&hatchApp)) * gpuHourlyPrice(containerGPUResourceName(&hatchApp), gpuModel)
+		}
+	}
+	for _, friend := range hatchApp.Friends {
+		billed := billedResources(friend.Resources)
+		cpuCores += float64(billed.Cpu().MilliValue()) / 1000.0
+		memoryGB += float64(billed.Memory().Value()) / (1024 * 1024 * 1024)
+		_, friendGPUCost := gpuRequestsHourlyCost(billed, gpuModel)
+		gpuCost += friendGPUCost
+	}
+
+	cost := cpuCores*Config().Config.Pricing.Cpu + memoryGB*Config().Config.Pricing.Memory + gpuCost
+	return math.Round(cost*10000) / 10000
+}
+
 // Handle pod deletion
 func (pt *PodTracker) handlePodDeleted(pod *v1.Pod, source string) {
 	key := pt.getPodKey(pod)
@@ -311,28 +417,29 @@ func (pt *PodTracker) handlePodDeleted(pod *v1.Pod, source string) {
 	var terminationTime time.Time
 	if pod.DeletionTimestamp != nil && !pod.DeletionTimestamp.IsZero() {
 		terminationTime = pod.DeletionTimestamp.Time
-		Config.Logger.Printf("Using DeletionTimestamp: %s", terminationTime.Format(time.RFC3339))
+		Config().Logger.Printf("Using DeletionTimestamp: %s", terminationTime.Format(time.RFC3339))
 	} else {
 		terminationTime = time.Now()
-		Config.Logger.Printf("DeletionTimestamp not available, using current time: %s", terminationTime.Format(time.RFC3339))
+		Config().Logger.Printf("DeletionTimestamp not available, using current time: %s", terminationTime.Format(time.RFC3339))
 	}
 
 	lifecycle, exists := pt.podLifecycles[key]
 	if !exists {
 		// We don't have launch time - try to figure it out!
-		Config.Logger.Printf("⚠️  Pod deleted but no launch time recorded: %s", pod.Name)
+		Config().Logger.Printf("⚠️  Pod deleted but no launch time recorded: %s", pod.Name)
 
 		// Use the pod's CreationTimestamp as fallback
 		launchTime := pod.CreationTimestamp.Time
 		if launchTime.IsZero() {
 			// Last resort - estimate based on deletion time
 			launchTime = now.Add(-time.Hour) // Assume it ran for 1 hour minimum
-			Config.Logger.Printf("⚠️  Using estimated launch time for %s: %s", pod.Name, launchTime.Format(time.RFC3339))
+			Config().Logger.Printf("⚠️  Using estimated launch time for %s: %s", pod.Name, launchTime.Format(time.RFC3339))
 		}
 
 		pt.podLifecycles[key] = &PodLifecycle{
 			PodName:           pod.Name,
 			Namespace:         pod.Namespace,
+			WorkspaceId:       pod.Annotations[workspaceIdAnnotation],
 			LaunchTime:        launchTime,
 			StopTime:          &now,
 			NodeName:          pod.Spec.NodeName,
@@ -341,9 +448,9 @@ func (pt *PodTracker) handlePodDeleted(pod *v1.Pod, source string) {
 		}
 
 		runtime := terminationTime.Sub(launchTime)
-		cost = pt.calculatePodPrice(pod, runtime)
+		cost = calculatePodPrice(pod, runtime)
 
-		Config.Logger.Printf("🛑 Pod deleted (recovered): %s, runtime: %s, cost: $%.4f (source: %s)",
+		Config().Logger.Printf("🛑 Pod deleted (recovered): %s, runtime: %s, cost: $%.4f (source: %s)",
 			pod.Name, runtime.String(), cost.TotalCost, source+"_recovery")
 
 		// remove it from the memory
@@ -352,19 +459,20 @@ func (pt *PodTracker) handlePodDeleted(pod *v1.Pod, source string) {
 		// Normal case - we have the launch time
 		lifecycle.StopTime = &now
 		runtime := terminationTime.Sub(lifecycle.LaunchTime)
-		cost = pt.calculatePodPrice(pod, runtime)
+		cost = calculatePodPrice(pod, runtime)
 
-		Config.Logger.Printf("🛑 Pod deleted: %s, runtime: %s, cost: $%.4f (source: %s)",
+		Config().Logger.Printf("🛑 Pod deleted: %s, runtime: %s, cost: $%.4f (source: %s)",
 			pod.Name, runtime.String(), cost.TotalCost, source)
 
 		// remove it from the memory
 		delete(pt.podLifecycles, key)
 	}
-	Config.Logger.Printf("🧑‍💻 User and workpaceid info %v, %s", userName, podPaymodelID)
+	recordCostAccrued(pod.Annotations[containerNameAnnotation], cost.TotalCost)
+	Config().Logger.Printf("🧑‍💻 User and workpaceid info %v, %s (workspace '%s')", userName, podPaymodelID, pod.Annotations[workspaceIdAnnotation])
 	// Update pay model cost if we have user info
 	if userName != "" && podPaymodelID != "" {
 		if err := UpdatePayModelCost(userName, podPaymodelID, cost.TotalCost); err != nil {
-			Config.Logger.Printf("⚠️  Failed to update cost for user %s: %v", userName, err)
+			Config().Logger.Printf("⚠️  Failed to update cost for user %s: %v", userName, err)
 		}
 	}
 }
@@ -383,7 +491,7 @@ func (pt *PodTracker) handlePodModified(pod *v1.Pod, source string) {
 		pt.mu.Lock()
 		lifecycle.NodeName = pod.Spec.NodeName
 		pt.mu.Unlock()
-		Config.Logger.Printf("📍 Updated node for pod %s: %s", pod.Name, pod.Spec.NodeName)
+		Config().Logger.Printf("📍 Updated node for pod %s: %s", pod.Name, pod.Spec.NodeName)
 	}
 }
 
@@ -397,30 +505,30 @@ func (pt *PodTracker) ensureUserInPayModelTable(pod *v1.Pod) {
 	userName := pt.extractUserNameFromPod(pod)
 	podPaymodelID := pt.extractPaymodelIDFromPod(pod)
 	if userName == "" {
-		Config.Logger.Printf("⚠️  Could not extract username from pod %s, skipping pay model check", pod.Name)
+		Config().Logger.Printf("⚠️  Could not extract username from pod %s, skipping pay model check", pod.Name)
 		return
 	}
 	if podPaymodelID == "" {
-		Config.Logger.Printf("Could not extract paymodel_type from pod %s, skipping pay model check", pod.Name)
+		Config().Logger.Printf("Could not extract paymodel_type from pod %s, skipping pay model check", pod.Name)
 		return
 	}
 
-	Config.Logger.Printf("🔍 Checking pay models for user: %s", userName)
+	Config().Logger.Printf("🔍 Checking pay models for user: %s", userName)
 	payModels, err := payModelsFromDatabase(userName, false)
 	if err != nil {
-		Config.Logger.Printf("⚠️  Error checking pay models for user %s: %v", userName, err)
+		Config().Logger.Printf("⚠️  Error checking pay models for user %s: %v", userName, err)
 		return
 	}
 
 	if payModels == nil || len(*payModels) == 0 {
-		Config.Logger.Printf("📝 No pay models found for user %s, creating default Trial Paymodel", userName)
+		Config().Logger.Printf("📝 No pay models found for user %s, creating default Trial Paymodel", userName)
 
 		defaultPayModel, err := pt.createDefaultTrialPayPayModel(userName, podPaymodelID)
 
 		if err != nil {
-			Config.Logger.Printf("❌ Failed to create default pay model for user %s: %v", userName, err)
+			Config().Logger.Printf("❌ Failed to create default pay model for user %s: %v", userName, err)
 		} else {
-			Config.Logger.Printf("✅ Created default Trial Paymodel for user %s with workspace ID: %s",
+			Config().Logger.Printf("✅ Created default Trial Paymodel for user %s with workspace ID: %s",
 				userName, defaultPayModel.Id)
 		}
 	} else {
@@ -434,16 +542,16 @@ func (pt *PodTracker) ensureUserInPayModelTable(pod *v1.Pod) {
 		}
 
 		if currentPayModelFromDb == nil {
-			Config.Logger.Printf("Could not match pod workspace ID to database for user %v. Creating new record in database", userName)
+			Config().Logger.Printf("Could not match pod workspace ID to database for user %v. Creating new record in database", userName)
 			defaultPayModel, err := pt.createDefaultTrialPayPayModel(userName, podPaymodelID)
 			if err != nil {
-				Config.Logger.Printf("❌ Failed to create default pay model for user %s: %v", userName, err)
+				Config().Logger.Printf("❌ Failed to create default pay model for user %s: %v", userName, err)
 			} else {
-				Config.Logger.Printf("✅ Created default Trial Paymodel for user %s with workspace ID: %s",
+				Config().Logger.Printf("✅ Created default Trial Paymodel for user %s with workspace ID: %s",
 					userName, defaultPayModel.Id)
 			}
 		}
-		Config.Logger.Printf("Workspace ID from pod matched with data base for user %v", userName)
+		Config().Logger.Printf("Workspace ID from pod matched with data base for user %v", userName)
 		return
 	}
 }
@@ -451,7 +559,7 @@ func (pt *PodTracker) ensureUserInPayModelTable(pod *v1.Pod) {
 // createDefaultTrialPayPayModel creates a default Trial Pay pay model for a new user
 func (pt *PodTracker) createDefaultTrialPayPayModel(userName string, podPaymodelID ...string) (*PayModel, error) {
 
-	if Config.Config.PayModelsDynamodbTable == "" {
+	if Config().Config.PayModelsDynamodbTable == "" {
 		return nil, fmt.Errorf("no pay models DynamoDB table configured")
 	}
 	payModelID := uuid.New().String()
@@ -469,7 +577,7 @@ func (pt *PodTracker) createDefaultTrialPayPayModel(userName string, podPaymodel
 
 	dynamodbSvc := dynamodb.New(sess)
 
-	defaultPayModel := Config.Config.DefaultPayModel
+	defaultPayModel := Config().Config.DefaultPayModel
 	defaultPayModel.Id = payModelID
 	defaultPayModel.User = userName
 	defaultPayModel.CurrentPayModel = true
@@ -483,31 +591,31 @@ func (pt *PodTracker) createDefaultTrialPayPayModel(userName string, podPaymodel
 		return nil, fmt.Errorf("failed to marshal pay model: %v", err)
 	}
 
-	Config.Logger.Printf("Putting item into table")
+	Config().Logger.Printf("Putting item into table")
 
 	// Put the item in DynamoDB
 	_, err = dynamodbSvc.PutItem(&dynamodb.PutItemInput{
-		TableName: aws.String(Config.Config.PayModelsDynamodbTable),
+		TableName: aws.String(Config().Config.PayModelsDynamodbTable),
 		Item:      item,
 	})
 	if err != nil {
 		if strings.Contains(err.Error(), "AccessDeniedException") ||
 			strings.Contains(err.Error(), "is not authorized") {
-			Config.Logger.Printf("WARNING: No DynamoDB write permissions. Creating in-memory pay model for user %s", userName)
-			Config.Logger.Printf("DynamoDB error: %v", err)
+			Config().Logger.Printf("WARNING: No DynamoDB write permissions. Creating in-memory pay model for user %s", userName)
+			Config().Logger.Printf("DynamoDB error: %v", err)
 			// Return the pay model anyway so the user can proceed
 			return &defaultPayModel, nil
 		}
 		return nil, fmt.Errorf("failed to put pay model in DynamoDB: %v", err)
 	}
 
-	Config.Logger.Printf("Created default Direct Pay pay model for user %s", userName)
+	Config().Logger.Printf("Created default Direct Pay pay model for user %s", userName)
 	return &defaultPayModel, nil
 }
 
 // UpdatePayModelCost adds cost to a user's pay model in DynamoDB
 func UpdatePayModelCost(userName string, podPaymodelID string, additionalCost float64) error {
-	if Config.Config.PayModelsDynamodbTable == "" {
+	if Config().Config.PayModelsDynamodbTable == "" {
 		return fmt.Errorf("no pay models DynamoDB table configured")
 	}
 
@@ -522,7 +630,7 @@ func UpdatePayModelCost(userName string, podPaymodelID string, additionalCost fl
 	dynamodbSvc := dynamodb.New(sess)
 	// get the current cost
 	input := &dynamodb.UpdateItemInput{
-		TableName: aws.String(Config.Config.PayModelsDynamodbTable),
+		TableName: aws.String(Config().Config.PayModelsDynamodbTable),
 		Key: map[string]*dynamodb.AttributeValue{
 			"user_id": {
 				S: aws.String(userName),
@@ -546,20 +654,29 @@ func UpdatePayModelCost(userName string, podPaymodelID string, additionalCost fl
 		ReturnValues:     aws.String("UPDATED_NEW"),
 	}
 
-	Config.Logger.Printf("Attempting to update item in table")
+	Config().Logger.Printf("Attempting to update item in table")
+	start := time.Now()
 	result, err := dynamodbSvc.UpdateItem(input)
+	observeExternalRequest("dynamodb", "update_pay_model_cost", start, err)
 	if err != nil {
 		if strings.Contains(err.Error(), "AccessDeniedException") ||
 			strings.Contains(err.Error(), "is not authorized") {
-			Config.Logger.Printf("WARNING: No DynamoDB update permissions. Cost tracking disabled for user %s", userName)
-			Config.Logger.Printf("📝 Updated cost tracking: %v", input)
+			Config().Logger.Printf("WARNING: No DynamoDB update permissions. Cost tracking disabled for user %s", userName)
+			Config().Logger.Printf("📝 Updated cost tracking: %v", input)
 			return nil
 		}
 		return fmt.Errorf("failed to update pay model cost: %v", err)
 	}
+	recordAuditEvent(AuditEvent{
+		Action:     auditActionCostCharge,
+		UserName:   userName,
+		Actor:      auditActorCostTracker,
+		PayModelId: podPaymodelID,
+		Cost:       additionalCost,
+	})
 
 	if result.Attributes["total-usage"] != nil {
-		Config.Logger.Printf("Updated cost for user %s, workspace %s: $%s (added: $%.4f)",
+		Config().Logger.Printf("Updated cost for user %s, workspace %s: $%s (added: $%.4f)",
 			userName, podPaymodelID, *result.Attributes["total-usage"].N, additionalCost)
 	}
 
@@ -43,7 +43,7 @@ func setupTestConfig() {
 			Logger: log.New(os.Stdout, "[TEST] ", log.LstdFlags),
 		}
 	}
-	Config = testConfig
+	SetConfig(testConfig)
 }
 
 // Test helper functions
@@ -191,7 +191,6 @@ func TestPodTracker_HandlePodCreated(t *testing.T) {
 
 func TestPodTracker_CalculatePodPrice(t *testing.T) {
 	setupTestConfig()
-	tracker := &PodTracker{}
 
 	pod := &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -224,7 +223,7 @@ func TestPodTracker_CalculatePodPrice(t *testing.T) {
 	t.Run("should calculate cost correctly for 2 hours", func(t *testing.T) {
 		runtime := 2 * time.Hour
 
-		cost := tracker.calculatePodPrice(pod, runtime)
+		cost := calculatePodPrice(pod, runtime)
 
 		// Expected calculations:
 		// CPU: (1 + 0.5) cores * $0.10/hour * 2 hours = $0.30
@@ -236,7 +235,7 @@ func TestPodTracker_CalculatePodPrice(t *testing.T) {
 	})
 
 	t.Run("should return zero cost for zero runtime", func(t *testing.T) {
-		cost := tracker.calculatePodPrice(pod, 0)
+		cost := calculatePodPrice(pod, 0)
 
 		assert.Equal(t, 0.0, cost.TotalCost)
 		assert.Equal(t, 0.0, cost.CPUCost)
@@ -244,6 +243,55 @@ func TestPodTracker_CalculatePodPrice(t *testing.T) {
 	})
 }
 
+func TestPodTracker_CalculatePodPriceWithGPUs(t *testing.T) {
+	setupTestConfig()
+	config := *testConfig
+	config.Config.Pricing = Pricing{
+		Cpu:       0.10,
+		Memory:    0.05,
+		GPU:       map[string]float64{"nvidia.com/gpu": 1.0, "nvidia.com/mig-1g.5gb": 0.15},
+		GPUModels: map[string]float64{"NVIDIA-A100-SXM4-40GB": 3.0},
+	}
+	SetConfig(&config)
+	defer SetConfig(testConfig)
+
+	gpuPod := func(resourceName v1.ResourceName, count string, model string) *v1.Pod {
+		pod := &v1.Pod{Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name: "hatchery-container",
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+				v1.ResourceCPU: resource.MustParse("1"),
+				resourceName:   resource.MustParse(count),
+			}},
+		}}}}
+		if model != "" {
+			pod.Spec.NodeSelector = map[string]string{"nvidia.com/gpu.product": model}
+		}
+		return pod
+	}
+
+	t.Run("should price GPUs by resource name", func(t *testing.T) {
+		cost := calculatePodPrice(gpuPod("nvidia.com/gpu", "2", ""), 2*time.Hour)
+		// 2 GPUs * $1/hour * 2 hours + 1 core * $0.10/hour * 2 hours
+		assert.InEpsilon(t, 4.0, cost.GPUCost, 0.001)
+		assert.InEpsilon(t, 4.2, cost.TotalCost, 0.001)
+	})
+
+	t.Run("should price MIG slices", func(t *testing.T) {
+		cost := calculatePodPrice(gpuPod("nvidia.com/mig-1g.5gb", "1", ""), time.Hour)
+		assert.InEpsilon(t, 0.15, cost.GPUCost, 0.001)
+	})
+
+	t.Run("should price GPUs by model first", func(t *testing.T) {
+		cost := calculatePodPrice(gpuPod("nvidia.com/gpu", "1", "NVIDIA-A100-SXM4-40GB"), time.Hour)
+		assert.InEpsilon(t, 3.0, cost.GPUCost, 0.001)
+	})
+
+	t.Run("should not price unpriced GPUs", func(t *testing.T) {
+		cost := calculatePodPrice(gpuPod("amd.com/gpu", "1", ""), time.Hour)
+		assert.Equal(t, 0.0, cost.GPUCost)
+	})
+}
+
 func TestPodTracker_HandlePodModified(t *testing.T) {
 	setupTestConfig()
 
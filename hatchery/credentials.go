@@ -0,0 +1,178 @@
+package hatchery
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	k8sv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// Records the id of the workspace's API key on its pod, so that the key can be deleted on terminate
+const apiKeyIdAnnotation = "gen3apikeyid"
+
+// The key of the gen3 credentials file in the workspace's Secret
+const credentialsFileKey = "credentials.json"
+
+// licenseEnvVarName returns the env var the license string is passed in, named after the g3auto secret
+func licenseEnvVarName(licenseInfo LicenseInfo) string {
+	return strings.ToUpper(strings.ReplaceAll(licenseInfo.G3autoName, "-", "_"))
+}
+
+// isCredentialEnvVar returns true if the env var holds a secret, which is passed to the
+// workspace through its Secret instead of the pod spec
+func isCredentialEnvVar(hatchApp *Container, name string) bool {
+	switch name {
+	case "API_KEY", "ACCESS_TOKEN", "AWS_SECRET_ACCESS_KEY":
+		return true
+	}
+	return hatchApp.License.Enabled && name == licenseEnvVarName(hatchApp.License)
+}
+
+// buildWorkspaceSecret moves the credentials of the pod's env vars to the workspace's Secret,
+// which the env vars reference instead. The Secret also holds a gen3 credentials file with
+// the API key, mounted at the container's `credentials-file` if set
+func buildWorkspaceSecret(hatchApp *Container, userName string, workspaceId string, pod *k8sv1.Pod, apiKey *APIKeyStruct) (*k8sv1.Secret, error) {
+	secretName := workspaceToResourceName(userName, workspaceId, "credentials")
+	credentialsFile, err := json.Marshal(apiKey)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string][]byte{credentialsFileKey: credentialsFile}
+
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		for j := range container.Env {
+			envVar := &container.Env[j]
+			if envVar.ValueFrom != nil || !isCredentialEnvVar(hatchApp, envVar.Name) {
+				continue
+			}
+			data[envVar.Name] = []byte(envVar.Value)
+			envVar.Value = ""
+			envVar.ValueFrom = &k8sv1.EnvVarSource{
+				SecretKeyRef: &k8sv1.SecretKeySelector{
+					LocalObjectReference: k8sv1.LocalObjectReference{Name: secretName},
+					Key:                  envVar.Name,
+				},
+			}
+		}
+		if hatchApp.CredentialsFile != "" && container.Name == "hatchery-container" {
+			container.VolumeMounts = append(container.VolumeMounts, k8sv1.VolumeMount{
+				MountPath: hatchApp.CredentialsFile,
+				Name:      "credentials",
+				SubPath:   credentialsFileKey,
+				ReadOnly:  true,
+			})
+		}
+	}
+	if hatchApp.CredentialsFile != "" {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, k8sv1.Volume{
+			Name: "credentials",
+			VolumeSource: k8sv1.VolumeSource{
+				Secret: &k8sv1.SecretVolumeSource{
+					SecretName: secretName,
+					Items:      []k8sv1.KeyToPath{{Key: credentialsFileKey, Path: credentialsFileKey}},
+				},
+			},
+		})
+	}
+	pod.Annotations[apiKeyIdAnnotation] = apiKey.KeyID
+
+	// the Secret must not share the pod's maps, which are changed after this
+	annotations := make(map[string]string)
+	for key, value := range pod.Annotations {
+		annotations[key] = value
+	}
+	labels := make(map[string]string)
+	for key, value := range pod.Labels {
+		labels[key] = value
+	}
+	return &k8sv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        secretName,
+			Namespace:   pod.Namespace,
+			Annotations: annotations,
+			Labels:      labels,
+		},
+		Type: k8sv1.SecretTypeOpaque,
+		Data: data,
+	}, nil
+}
+
+// createWorkspaceSecret creates the Secret of the workspace, or replaces the one left over
+// by a workspace that was not cleaned up. It must exist before the pod is created
+func createWorkspaceSecret(ctx context.Context, podClient corev1.CoreV1Interface, userName string, secret *k8sv1.Secret) error {
+	secrets := podClient.Secrets(secret.Namespace)
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	if k8sErrors.IsAlreadyExists(err) {
+		var existing *k8sv1.Secret
+		existing, err = secrets.Get(ctx, secret.Name, metav1.GetOptions{})
+		if err == nil {
+			secret.ResourceVersion = existing.ResourceVersion
+			_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+		}
+	}
+	if err != nil {
+		Config().Logger.Printf("Failed to create Secret %s for user %s. Error: %v", secret.Name, userName, err)
+		return err
+	}
+	Config().Logger.Printf("Created Secret %s for user %s", secret.Name, userName)
+	return nil
+}
+
+// setWorkspaceSecretOwner makes the pod the owner of the workspace's Secret, so that the Secret
+// is garbage collected with the pod if terminate does not delete it
+func setWorkspaceSecretOwner(ctx context.Context, podClient corev1.CoreV1Interface, userName string, secretName string, pod *k8sv1.Pod) {
+	secrets := podClient.Secrets(pod.Namespace)
+	secret, err := secrets.Get(ctx, secretName, metav1.GetOptions{})
+	if err == nil {
+		secret.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       pod.Name,
+			UID:        pod.UID,
+		}}
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		Config().Logger.Printf("Failed to set the owner of Secret %s for user %s. Error: %v", secretName, userName, err)
+	}
+}
+
+// deleteWorkspaceSecret deletes the Secret of the workspace, if any
+func deleteWorkspaceSecret(ctx context.Context, podClient corev1.CoreV1Interface, userName string, workspaceId string) error {
+	secretName := workspaceToResourceName(userName, workspaceId, "credentials")
+	err := podClient.Secrets(Config().Config.UserNamespace).Delete(ctx, secretName, metav1.DeleteOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// getPodCredential returns the value of the given env var of the pod's "hatchery-container",
+// read from the workspace's Secret if the env var references it
+func getPodCredential(ctx context.Context, podClient corev1.CoreV1Interface, pod *k8sv1.Pod, envVarName string) (string, error) {
+	for _, container := range pod.Spec.Containers {
+		if container.Name != "hatchery-container" {
+			continue
+		}
+		for _, envVar := range container.Env {
+			if envVar.Name != envVarName {
+				continue
+			}
+			if envVar.ValueFrom == nil || envVar.ValueFrom.SecretKeyRef == nil {
+				return envVar.Value, nil
+			}
+			secretKeyRef := envVar.ValueFrom.SecretKeyRef
+			secret, err := podClient.Secrets(pod.Namespace).Get(ctx, secretKeyRef.Name, metav1.GetOptions{})
+			if err != nil {
+				return "", err
+			}
+			return string(secret.Data[secretKeyRef.Key]), nil
+		}
+	}
+	return "", nil
+}
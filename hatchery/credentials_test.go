@@ -0,0 +1,128 @@
+package hatchery
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_BuildWorkspaceSecret(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	loadRenderTestConfig(t)
+
+	hatchApp := &Container{
+		Name:            "Stata",
+		Image:           "stata",
+		CPULimit:        "1",
+		MemoryLimit:     "1Gi",
+		CredentialsFile: "/home/jovyan/.gen3/credentials.json",
+		License:         LicenseInfo{Enabled: true, G3autoName: "stata-license"},
+	}
+	apiKey := &APIKeyStruct{APIKey: "secret-api-key", KeyID: "key-id"}
+	envVars := append(localWorkspaceEnvVars(apiKey), k8sv1.EnvVar{Name: "STATA_LICENSE", Value: "secret-license"})
+	pod, err := buildPod(Config(), hatchApp, "frickjack", "stata", envVars)
+	if err != nil {
+		t.Fatalf("failed to build a pod - %v", err)
+	}
+
+	secret, err := buildWorkspaceSecret(hatchApp, "frickjack", "stata", pod, apiKey)
+	if err != nil {
+		t.Fatalf("\nassertion error while testing `buildWorkspaceSecret`: unexpected error: %v", err)
+	}
+	if secret.Name != "credentials-frickjack--stata" {
+		t.Errorf("\nassertion error while testing `buildWorkspaceSecret` name: \nWant:%s\nGot:%s", "credentials-frickjack--stata", secret.Name)
+	}
+	if got := string(secret.Data["API_KEY"]); got != "secret-api-key" {
+		t.Errorf("\nassertion error while testing `buildWorkspaceSecret` API key: \nWant:%s\nGot:%s", "secret-api-key", got)
+	}
+	if got := string(secret.Data["STATA_LICENSE"]); got != "secret-license" {
+		t.Errorf("\nassertion error while testing `buildWorkspaceSecret` license: \nWant:%s\nGot:%s", "secret-license", got)
+	}
+	credentialsFile := APIKeyStruct{}
+	if err := json.Unmarshal(secret.Data[credentialsFileKey], &credentialsFile); err != nil || credentialsFile != *apiKey {
+		t.Errorf("\nassertion error while testing `buildWorkspaceSecret` credentials file: \nWant:%+v\nGot:%s", *apiKey, secret.Data[credentialsFileKey])
+	}
+	if got := pod.Annotations[apiKeyIdAnnotation]; got != "key-id" {
+		t.Errorf("\nassertion error while testing `buildWorkspaceSecret` API key id annotation: \nWant:%s\nGot:%s", "key-id", got)
+	}
+	// changing the pod's metadata does not change the Secret's
+	pod.Annotations["gen3lastlaunch"] = "now"
+	pod.Labels["app"] = "changed"
+	if _, ok := secret.Annotations["gen3lastlaunch"]; ok || secret.Labels["app"] == "changed" {
+		t.Errorf("\nassertion error while testing `buildWorkspaceSecret` metadata: the Secret shares the pod's maps\nGot:%+v", secret.ObjectMeta)
+	}
+
+	// no secret is left in the pod spec, in the sidecar or in the workspace container
+	for _, container := range pod.Spec.Containers {
+		for _, envVar := range container.Env {
+			if envVar.Value == "secret-api-key" || envVar.Value == "secret-license" {
+				t.Errorf("\nassertion error while testing `buildWorkspaceSecret`: env var %s of container %s is not in the Secret", envVar.Name, container.Name)
+			}
+		}
+	}
+	if got := getPodEnvVarValue(pod, "API_KEY_ID"); got != "key-id" {
+		t.Errorf("\nassertion error while testing `buildWorkspaceSecret` API key id: \nWant:%s\nGot:%s", "key-id", got)
+	}
+	mounted := false
+	for _, volumeMount := range pod.Spec.Containers[1].VolumeMounts {
+		mounted = mounted || (volumeMount.Name == "credentials" && volumeMount.MountPath == hatchApp.CredentialsFile && volumeMount.SubPath == credentialsFileKey)
+	}
+	if !mounted {
+		t.Errorf("\nassertion error while testing `buildWorkspaceSecret`: the credentials file is not mounted at %s\nGot:%+v", hatchApp.CredentialsFile, pod.Spec.Containers[1].VolumeMounts)
+	}
+}
+
+func Test_WorkspaceSecretLifecycle(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	loadRenderTestConfig(t)
+
+	ctx := context.Background()
+	podClient := fake.NewSimpleClientset().CoreV1()
+	hatchApp := &Container{Name: "Jupyter", Image: "jupyter", CPULimit: "1", MemoryLimit: "1Gi"}
+	pod, err := buildPod(Config(), hatchApp, "frickjack", "", localWorkspaceEnvVars(&APIKeyStruct{APIKey: "old-api-key", KeyID: "old-key-id"}))
+	if err != nil {
+		t.Fatalf("failed to build a pod - %v", err)
+	}
+	secret, _ := buildWorkspaceSecret(hatchApp, "frickjack", "", pod, &APIKeyStruct{APIKey: "old-api-key", KeyID: "old-key-id"})
+	if err := createWorkspaceSecret(ctx, podClient, "frickjack", secret); err != nil {
+		t.Fatalf("\nassertion error while testing `createWorkspaceSecret`: unexpected error: %v", err)
+	}
+
+	// a Secret left over by a previous workspace is replaced
+	pod, _ = buildPod(Config(), hatchApp, "frickjack", "", localWorkspaceEnvVars(&APIKeyStruct{APIKey: "api-key", KeyID: "key-id"}))
+	secret, _ = buildWorkspaceSecret(hatchApp, "frickjack", "", pod, &APIKeyStruct{APIKey: "api-key", KeyID: "key-id"})
+	if err := createWorkspaceSecret(ctx, podClient, "frickjack", secret); err != nil {
+		t.Errorf("\nassertion error while testing `createWorkspaceSecret` with an existing Secret: unexpected error: %v", err)
+	}
+
+	pod.UID = "pod-uid"
+	setWorkspaceSecretOwner(ctx, podClient, "frickjack", secret.Name, pod)
+	created, err := podClient.Secrets(pod.Namespace).Get(ctx, "credentials-frickjack", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("\nassertion error while testing `createWorkspaceSecret`: the Secret was not created: %v", err)
+	}
+	if len(created.OwnerReferences) != 1 || created.OwnerReferences[0].UID != "pod-uid" {
+		t.Errorf("\nassertion error while testing `setWorkspaceSecretOwner`: \nWant:%s\nGot:%+v", "pod-uid", created.OwnerReferences)
+	}
+
+	// the API key is read from the Secret
+	apiKey, err := getPodCredential(ctx, podClient, pod, "API_KEY")
+	if err != nil || apiKey != "api-key" {
+		t.Errorf("\nassertion error while testing `getPodCredential`: \nWant:%s\nGot:%s (%v)", "api-key", apiKey, err)
+	}
+
+	if err := deleteWorkspaceSecret(ctx, podClient, "frickjack", ""); err != nil {
+		t.Errorf("\nassertion error while testing `deleteWorkspaceSecret`: unexpected error: %v", err)
+	}
+	if _, err := podClient.Secrets(pod.Namespace).Get(ctx, "credentials-frickjack", metav1.GetOptions{}); err == nil {
+		t.Errorf("\nassertion error while testing `deleteWorkspaceSecret`: the Secret was not deleted")
+	}
+	// deleting a Secret that does not exist is not an error
+	if err := deleteWorkspaceSecret(ctx, podClient, "frickjack", ""); err != nil {
+		t.Errorf("\nassertion error while testing `deleteWorkspaceSecret` without a Secret: unexpected error: %v", err)
+	}
+}
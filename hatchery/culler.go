@@ -0,0 +1,165 @@
+package hatchery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IdleCuller periodically terminates workspaces that have been idle for
+// longer than their configured idle time limit, so that workspaces are
+// shut down even when no browser tab is open to enforce the limit
+type IdleCuller struct {
+	interval time.Duration
+
+	// Control channels
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// NewIdleCuller creates an idle culler that checks workspaces every interval
+func NewIdleCuller(interval time.Duration) *IdleCuller {
+	return &IdleCuller{
+		interval: interval,
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+// Start runs the culling loop until Stop is called or ctx is cancelled
+func (ic *IdleCuller) Start(ctx context.Context) {
+	log.Printf("Starting idle culler with interval: %s", ic.interval)
+	defer close(ic.doneCh)
+
+	ticker := time.NewTicker(ic.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ic.stopCh:
+			log.Println("Idle culler stopped")
+			return
+		case <-ctx.Done():
+			log.Println("Idle culler stopped")
+			return
+		case <-ticker.C:
+			cullIdleWorkspaces(ctx, time.Now())
+		}
+	}
+}
+
+// Stop gracefully shuts down the culler
+func (ic *IdleCuller) Stop() {
+	close(ic.stopCh)
+	<-ic.doneCh
+}
+
+// cullIdleWorkspaces checks every running workspace and terminates the idle ones.
+// Returns the list of workspaces that were terminated
+func cullIdleWorkspaces(ctx context.Context, now time.Time) []WorkspaceRef {
+	refs, err := listWorkspaces(ctx)
+	if err != nil {
+		Config().Logger.Printf("Idle culler: unable to list workspaces: %v", err)
+		return nil
+	}
+
+	culled := []WorkspaceRef{}
+	for _, ref := range refs {
+		accessToken, err := getWorkspaceAccessToken(ctx, ref.UserName, ref.WorkspaceId)
+		if err != nil {
+			Config().Logger.Printf("Idle culler: unable to get access token for workspace '%s' of user %s: %v", ref.WorkspaceId, ref.UserName, err)
+			continue
+		}
+		status, err := getWorkspaceStatus(ctx, ref.UserName, ref.WorkspaceId, accessToken)
+		if err != nil {
+			Config().Logger.Printf("Idle culler: unable to get status of workspace '%s' for user %s: %v", ref.WorkspaceId, ref.UserName, err)
+			continue
+		}
+		if !isWorkspaceIdle(status, now) {
+			continue
+		}
+		Config().Logger.Printf("Idle culler: workspace '%s' for user %s has been idle since %s, terminating", ref.WorkspaceId, ref.UserName, time.UnixMilli(status.LastActivityTime).UTC().Format(time.RFC3339))
+		reason := fmt.Sprintf("idle for longer than %s", time.Duration(status.IdleTimeLimit)*time.Millisecond)
+		_, err = terminateWorkspace(withAuditActor(ctx, auditActorIdleCuller, reason), ref.UserName, ref.WorkspaceId, accessToken)
+		if err != nil {
+			Config().Logger.Printf("Idle culler: unable to terminate workspace '%s' for user %s: %v", ref.WorkspaceId, ref.UserName, err)
+			continue
+		}
+		culled = append(culled, ref)
+	}
+	return culled
+}
+
+// isWorkspaceIdle returns true if the workspace is running and has had no
+// activity for longer than its idle time limit. Workspaces without an idle
+// time limit or without a known last activity time are never idle
+func isWorkspaceIdle(status *WorkspaceStatus, now time.Time) bool {
+	if status == nil || status.Status != "Running" {
+		return false
+	}
+	if status.IdleTimeLimit <= 0 || status.LastActivityTime <= 0 {
+		return false
+	}
+	return now.UnixMilli()-status.LastActivityTime > int64(status.IdleTimeLimit)
+}
+
+// getWorkspaceAccessToken gets an access token on behalf of the user by
+// exchanging the API key that was mounted in their workspace
+var getWorkspaceAccessToken = func(ctx context.Context, userName string, workspaceId string) (string, error) {
+	apiKey, err := getWorkspaceAPIKey(ctx, userName, workspaceId)
+	if err != nil {
+		return "", err
+	}
+	if apiKey == "" {
+		return "", errors.New("no API key mounted in workspace")
+	}
+	return getAccessTokenFromAPIKeyWithContext(ctx, apiKey)
+}
+
+// getWorkspaceAPIKey returns the API key mounted in the user's workspace
+var getWorkspaceAPIKey = func(ctx context.Context, userName string, workspaceId string) (string, error) {
+	return getWorkspaceCredential(ctx, userName, workspaceId, "API_KEY")
+}
+
+// getWorkspaceAPIKeyId returns the id of the API key mounted in the user's workspace
+var getWorkspaceAPIKeyId = func(ctx context.Context, userName string, workspaceId string) (string, error) {
+	return getWorkspaceCredential(ctx, userName, workspaceId, "API_KEY_ID")
+}
+
+// getWorkspaceCredential returns the value of the workspace's env var. The API key id is
+// recorded on the pods' annotations
+func getWorkspaceCredential(ctx context.Context, userName string, workspaceId string, name string) (string, error) {
+	payModel, err := getCurrentPayModel(userName)
+	if err != nil {
+		return "", err
+	}
+
+	if payModel != nil && payModel.Ecs && workspaceId == "" {
+		roleARN := "arn:aws:iam::" + payModel.AWSAccountId + ":role/csoc_adminvm"
+		sess := session.Must(session.NewSession(&aws.Config{
+			// TODO: Make this configurable
+			Region: aws.String("us-east-1"),
+		}))
+		svc := NewSVC(sess, roleARN)
+		return svc.getEcsWorkspaceEnvVar(userName, name)
+	}
+
+	podClient, _, err := getPodClient(ctx, userName, payModel)
+	if err != nil {
+		return "", err
+	}
+	pod, err := podClient.Pods(Config().Config.UserNamespace).Get(ctx, workspaceToResourceName(userName, workspaceId, "pod"), metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if name == "API_KEY_ID" && pod.Annotations[apiKeyIdAnnotation] != "" {
+		return pod.Annotations[apiKeyIdAnnotation], nil
+	}
+	return getPodCredential(ctx, podClient, pod, name)
+}
@@ -0,0 +1,121 @@
+package hatchery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_IsWorkspaceIdle(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	now := time.UnixMilli(10_000_000)
+	testCases := []struct {
+		name   string
+		status *WorkspaceStatus
+		want   bool
+	}{
+		{
+			name:   "NilStatus",
+			status: nil,
+			want:   false,
+		},
+		{
+			name:   "NotRunning",
+			status: &WorkspaceStatus{Status: "Launching", IdleTimeLimit: 1000, LastActivityTime: 1},
+			want:   false,
+		},
+		{
+			name:   "NoIdleTimeLimit",
+			status: &WorkspaceStatus{Status: "Running", IdleTimeLimit: -1, LastActivityTime: 1},
+			want:   false,
+		},
+		{
+			name:   "UnknownLastActivityTime",
+			status: &WorkspaceStatus{Status: "Running", IdleTimeLimit: 1000, LastActivityTime: -1},
+			want:   false,
+		},
+		{
+			name:   "RecentActivity",
+			status: &WorkspaceStatus{Status: "Running", IdleTimeLimit: 1000, LastActivityTime: now.UnixMilli() - 500},
+			want:   false,
+		},
+		{
+			name:   "IdleForLongerThanLimit",
+			status: &WorkspaceStatus{Status: "Running", IdleTimeLimit: 1000, LastActivityTime: now.UnixMilli() - 1500},
+			want:   true,
+		},
+	}
+
+	for _, testcase := range testCases {
+		t.Logf("Testing IsWorkspaceIdle when %s", testcase.name)
+		if got := isWorkspaceIdle(testcase.status, now); got != testcase.want {
+			t.Errorf("\nassertion error while testing `%s`: \nWant:%v\nGot:%v", testcase.name, testcase.want, got)
+		}
+	}
+}
+
+func Test_CullIdleWorkspaces(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalListWorkspaces := listWorkspaces
+	originalGetWorkspaceAccessToken := getWorkspaceAccessToken
+	originalGetWorkspaceStatus := getWorkspaceStatus
+	originalTerminateWorkspace := terminateWorkspace
+	defer func() {
+		listWorkspaces = originalListWorkspaces
+		getWorkspaceAccessToken = originalGetWorkspaceAccessToken
+		getWorkspaceStatus = originalGetWorkspaceStatus
+		terminateWorkspace = originalTerminateWorkspace
+	}()
+
+	now := time.UnixMilli(10_000_000)
+	statuses := map[string]*WorkspaceStatus{
+		"idleUser":    {Status: "Running", IdleTimeLimit: 1000, LastActivityTime: now.UnixMilli() - 5000},
+		"activeUser":  {Status: "Running", IdleTimeLimit: 1000, LastActivityTime: now.UnixMilli() - 10},
+		"noLimitUser": {Status: "Running", IdleTimeLimit: -1, LastActivityTime: now.UnixMilli() - 5000},
+	}
+
+	listWorkspaces = func(ctx context.Context) ([]WorkspaceRef, error) {
+		return []WorkspaceRef{
+			{UserName: "idleUser"},
+			{UserName: "idleUser", WorkspaceId: "rstudio"},
+			{UserName: "activeUser"},
+			{UserName: "noLimitUser"},
+			{UserName: "noKeyUser"},
+		}, nil
+	}
+	getWorkspaceAccessToken = func(ctx context.Context, userName string, workspaceId string) (string, error) {
+		if userName == "noKeyUser" {
+			return "", errors.New("no API key mounted in workspace")
+		}
+		return "token-" + userName, nil
+	}
+	getWorkspaceStatus = func(ctx context.Context, userName string, workspaceId string, accessToken string) (*WorkspaceStatus, error) {
+		if accessToken != "token-"+userName {
+			t.Errorf("getWorkspaceStatus called for user %s with unexpected token %s", userName, accessToken)
+		}
+		if workspaceId != "" {
+			// the user's named workspace is still in use
+			return statuses["activeUser"], nil
+		}
+		return statuses[userName], nil
+	}
+	terminated := []WorkspaceRef{}
+	terminateWorkspace = func(ctx context.Context, userName string, workspaceId string, accessToken string) (string, error) {
+		terminated = append(terminated, WorkspaceRef{UserName: userName, WorkspaceId: workspaceId})
+		return "Terminated workspace", nil
+	}
+
+	culled := cullIdleWorkspaces(context.Background(), now)
+
+	want := []WorkspaceRef{{UserName: "idleUser"}}
+	if !reflect.DeepEqual(culled, want) {
+		t.Errorf("\nassertion error while testing `CullIdleWorkspaces`: \nWant:%v\nGot:%v", want, culled)
+	}
+	if !reflect.DeepEqual(terminated, want) {
+		t.Errorf("\nassertion error while testing `CullIdleWorkspaces` terminated workspaces: \nWant:%v\nGot:%v", want, terminated)
+	}
+}
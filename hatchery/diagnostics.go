@@ -0,0 +1,210 @@
+package hatchery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// WorkspaceDiagnostics explains why a workspace is not running
+type WorkspaceDiagnostics struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Hint    string `json:"hint"`
+}
+
+// User-facing hints for the launch failure reasons reported by Kubernetes and ECS
+var launchFailureHints = map[string]string{
+	// Kubernetes container states
+	"ErrImagePull":               "The workspace image could not be pulled. Check that the image exists and that the cluster can access its registry.",
+	"ImagePullBackOff":           "The workspace image could not be pulled. Check that the image exists and that the cluster can access its registry.",
+	"ErrImageNeverPull":          "The workspace image is not present on the node and the pull policy does not allow pulling it.",
+	"InvalidImageName":           "The workspace image name is invalid. Check the image in the Hatchery configuration.",
+	"CrashLoopBackOff":           "The workspace keeps crashing right after starting. Check the container logs and command.",
+	"CreateContainerConfigError": "The workspace container configuration is invalid, e.g. it references a missing secret or config map.",
+	"CreateContainerError":       "The workspace container could not be created.",
+	"OOMKilled":                  "The workspace ran out of memory. Try a workspace with a higher memory limit.",
+	"Error":                      "The workspace container exited with an error. Check the container logs.",
+	"Evicted":                    "The workspace was evicted from its node, usually because the node ran low on resources.",
+	// Kubernetes pod conditions and events
+	"Unschedulable":          "No node currently has enough resources, or the requested GPU, to run the workspace. It will start once capacity is available.",
+	"FailedScheduling":       "No node currently has enough resources, or the requested GPU, to run the workspace. It will start once capacity is available.",
+	"NotTriggerScaleUp":      "No node group can provide the resources this workspace needs, so it can never be scheduled. Choose a different workspace or contact your administrator.",
+	"FailedMount":            "A volume could not be mounted into the workspace.",
+	"FailedAttachVolume":     "The workspace's persistent volume could not be attached to the node.",
+	"FailedCreatePodSandBox": "The node could not set up networking for the workspace.",
+	// ECS stopped task reasons
+	"CannotPullContainerError":    "The workspace image could not be pulled. Check that the image exists and that the task can access its registry.",
+	"OutOfMemoryError":            "The workspace ran out of memory. Try a workspace with a higher memory limit.",
+	"ResourceInitializationError": "The workspace task could not be initialized, e.g. its secrets or volumes could not be retrieved.",
+	"CannotStartContainerError":   "The workspace container could not be started.",
+	"EssentialContainerExited":    "The workspace container exited. Check the container logs.",
+}
+
+func newWorkspaceDiagnostics(reason string, message string) *WorkspaceDiagnostics {
+	return &WorkspaceDiagnostics{
+		Reason:  reason,
+		Message: message,
+		Hint:    launchFailureHints[reason],
+	}
+}
+
+// isTerminalLaunchFailure returns true if a workspace with these diagnostics can never start
+func isTerminalLaunchFailure(diagnostics *WorkspaceDiagnostics) bool {
+	if diagnostics == nil {
+		return false
+	}
+	switch diagnostics.Reason {
+	case "InvalidImageName", "ErrImageNeverPull", "NotTriggerScaleUp":
+		return true
+	case "ErrImagePull", "ImagePullBackOff", "CannotPullContainerError":
+		// retrying will not help when the image does not exist
+		message := strings.ToLower(diagnostics.Message)
+		return strings.Contains(message, "not found") || strings.Contains(message, "manifest unknown")
+	}
+	return false
+}
+
+// applyDiagnostics sets the diagnostics on a status, and marks a launching
+// workspace as "Failed" if it can never start
+func applyDiagnostics(status *WorkspaceStatus, diagnostics *WorkspaceDiagnostics) {
+	if diagnostics == nil {
+		return
+	}
+	status.Diagnostics = diagnostics
+	if status.Status == "Launching" && isTerminalLaunchFailure(diagnostics) {
+		status.Status = "Failed"
+	}
+}
+
+// diagnosePod derives the reason a pod is not running from its status
+func diagnosePod(pod *k8sv1.Pod) *WorkspaceDiagnostics {
+	if pod.Status.Phase == k8sv1.PodFailed && pod.Status.Reason != "" {
+		return newWorkspaceDiagnostics(pod.Status.Reason, pod.Status.Message)
+	}
+
+	containerStatuses := append(append([]k8sv1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, cs := range containerStatuses {
+		if waiting := cs.State.Waiting; waiting != nil {
+			if waiting.Reason == "" || waiting.Reason == "ContainerCreating" || waiting.Reason == "PodInitializing" {
+				continue
+			}
+			// a crash loop caused by running out of memory is more useful reported as such
+			if waiting.Reason == "CrashLoopBackOff" && cs.LastTerminationState.Terminated != nil && cs.LastTerminationState.Terminated.Reason == "OOMKilled" {
+				return newWorkspaceDiagnostics("OOMKilled", fmt.Sprintf("Container %s was killed for running out of memory", cs.Name))
+			}
+			return newWorkspaceDiagnostics(waiting.Reason, waiting.Message)
+		}
+		if terminated := cs.State.Terminated; terminated != nil && terminated.Reason != "Completed" {
+			message := terminated.Message
+			if message == "" {
+				message = fmt.Sprintf("Container %s exited with code %d", cs.Name, terminated.ExitCode)
+			}
+			return newWorkspaceDiagnostics(terminated.Reason, message)
+		}
+	}
+
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == k8sv1.PodScheduled && cond.Status == k8sv1.ConditionFalse && cond.Reason != "" {
+			return newWorkspaceDiagnostics(cond.Reason, cond.Message)
+		}
+	}
+	return nil
+}
+
+// diagnoseEvents derives the reason a pod is not running from the most
+// recent of its events that has a known reason
+func diagnoseEvents(events []k8sv1.Event) *WorkspaceDiagnostics {
+	sorted := append([]k8sv1.Event{}, events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return eventTime(sorted[i]).After(eventTime(sorted[j]))
+	})
+	for _, event := range sorted {
+		if _, ok := launchFailureHints[event.Reason]; ok {
+			return newWorkspaceDiagnostics(event.Reason, event.Message)
+		}
+	}
+	return nil
+}
+
+func eventTime(event k8sv1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// getPodEvents lists the Kubernetes events about the given pod
+var getPodEvents = func(ctx context.Context, podClient corev1.CoreV1Interface, podName string) ([]k8sv1.Event, error) {
+	events, err := podClient.Events(Config().Config.UserNamespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.name", podName).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events.Items, nil
+}
+
+// diagnoseLaunchingPod combines the pod's status and events to explain why it is not running.
+// The events are only used when the pod status is not conclusive, or when the
+// autoscaler reports that the pod can never be scheduled
+func diagnoseLaunchingPod(ctx context.Context, podClient corev1.CoreV1Interface, pod *k8sv1.Pod) *WorkspaceDiagnostics {
+	diagnostics := diagnosePod(pod)
+	events, err := getPodEvents(ctx, podClient, pod.Name)
+	if err != nil {
+		Config().Logger.Printf("Error getting events for pod %s: %v", pod.Name, err)
+		return diagnostics
+	}
+	eventDiagnostics := diagnoseEvents(events)
+	if eventDiagnostics != nil && (diagnostics == nil || eventDiagnostics.Reason == "NotTriggerScaleUp") {
+		return eventDiagnostics
+	}
+	return diagnostics
+}
+
+// diagnoseStoppedEcsTasks derives the reason an ECS workspace is not running
+// from its most recently stopped task
+func diagnoseStoppedEcsTasks(tasks []*ecs.Task) *WorkspaceDiagnostics {
+	var latest *ecs.Task
+	for _, task := range tasks {
+		if latest == nil || aws.TimeValue(task.StoppedAt).After(aws.TimeValue(latest.StoppedAt)) {
+			latest = task
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+
+	// container reasons look like "CannotPullContainerError: pull image manifest has been retried..."
+	for _, container := range latest.Containers {
+		if reason := aws.StringValue(container.Reason); reason != "" {
+			return newWorkspaceDiagnostics(ecsReasonCode(reason), reason)
+		}
+	}
+	stoppedReason := aws.StringValue(latest.StoppedReason)
+	if stoppedReason == "" {
+		return nil
+	}
+	reason := ecsReasonCode(stoppedReason)
+	if _, ok := launchFailureHints[reason]; !ok && aws.StringValue(latest.StopCode) != "" {
+		reason = aws.StringValue(latest.StopCode)
+	}
+	return newWorkspaceDiagnostics(reason, stoppedReason)
+}
+
+func ecsReasonCode(reason string) string {
+	code, _, _ := strings.Cut(reason, ":")
+	return strings.TrimSpace(code)
+}
@@ -0,0 +1,241 @@
+package hatchery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+func pendingPodWithContainerState(state k8sv1.ContainerState, lastState k8sv1.ContainerState) *k8sv1.Pod {
+	return &k8sv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "hatchery-testuser"},
+		Status: k8sv1.PodStatus{
+			Phase: k8sv1.PodPending,
+			ContainerStatuses: []k8sv1.ContainerStatus{
+				{Name: "hatchery-container", State: state, LastTerminationState: lastState},
+			},
+		},
+	}
+}
+
+func Test_WorkspaceStatusFromPodDiagnostics(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	testCases := []struct {
+		name       string
+		pod        *k8sv1.Pod
+		wantStatus string
+		wantReason string
+	}{
+		{
+			name: "ContainerCreating",
+			pod: pendingPodWithContainerState(
+				k8sv1.ContainerState{Waiting: &k8sv1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+				k8sv1.ContainerState{},
+			),
+			wantStatus: "Launching",
+			wantReason: "",
+		},
+		{
+			name: "ImagePullBackOff",
+			pod: pendingPodWithContainerState(
+				k8sv1.ContainerState{Waiting: &k8sv1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image"}},
+				k8sv1.ContainerState{},
+			),
+			wantStatus: "Launching",
+			wantReason: "ImagePullBackOff",
+		},
+		{
+			name: "ImageNotFound",
+			pod: pendingPodWithContainerState(
+				k8sv1.ContainerState{Waiting: &k8sv1.ContainerStateWaiting{Reason: "ErrImagePull", Message: "quay.io/cdis/foo:bar: not found"}},
+				k8sv1.ContainerState{},
+			),
+			wantStatus: "Failed",
+			wantReason: "ErrImagePull",
+		},
+		{
+			name: "InvalidImageName",
+			pod: pendingPodWithContainerState(
+				k8sv1.ContainerState{Waiting: &k8sv1.ContainerStateWaiting{Reason: "InvalidImageName"}},
+				k8sv1.ContainerState{},
+			),
+			wantStatus: "Failed",
+			wantReason: "InvalidImageName",
+		},
+		{
+			name: "CrashLoopBackOffFromOOMKilled",
+			pod: pendingPodWithContainerState(
+				k8sv1.ContainerState{Waiting: &k8sv1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				k8sv1.ContainerState{Terminated: &k8sv1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+			),
+			wantStatus: "Launching",
+			wantReason: "OOMKilled",
+		},
+		{
+			name: "Unschedulable",
+			pod: &k8sv1.Pod{
+				Status: k8sv1.PodStatus{
+					Phase: k8sv1.PodPending,
+					Conditions: []k8sv1.PodCondition{
+						{Type: k8sv1.PodScheduled, Status: k8sv1.ConditionFalse, Reason: "Unschedulable", Message: "0/3 nodes are available: 3 Insufficient nvidia.com/gpu."},
+					},
+				},
+			},
+			wantStatus: "Launching",
+			wantReason: "Unschedulable",
+		},
+		{
+			name: "Evicted",
+			pod: &k8sv1.Pod{
+				Status: k8sv1.PodStatus{Phase: k8sv1.PodFailed, Reason: "Evicted", Message: "The node was low on resource: memory."},
+			},
+			wantStatus: "Failed",
+			wantReason: "Evicted",
+		},
+		{
+			name: "FailedFromOOMKilled",
+			pod: &k8sv1.Pod{
+				Status: k8sv1.PodStatus{
+					Phase: k8sv1.PodFailed,
+					ContainerStatuses: []k8sv1.ContainerStatus{
+						{Name: "hatchery-container", State: k8sv1.ContainerState{Terminated: &k8sv1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}}},
+					},
+				},
+			},
+			wantStatus: "Failed",
+			wantReason: "OOMKilled",
+		},
+	}
+
+	for _, testcase := range testCases {
+		t.Logf("Testing WorkspaceStatusFromPod diagnostics when %s", testcase.name)
+		status := workspaceStatusFromPod(context.Background(), testcase.pod, "")
+		if status.Status != testcase.wantStatus {
+			t.Errorf("\nassertion error while testing `%s` status: \nWant:%s\nGot:%s", testcase.name, testcase.wantStatus, status.Status)
+		}
+		gotReason := ""
+		if status.Diagnostics != nil {
+			gotReason = status.Diagnostics.Reason
+			if status.Diagnostics.Hint == "" {
+				t.Errorf("\nassertion error while testing `%s`: missing hint", testcase.name)
+			}
+		}
+		if gotReason != testcase.wantReason {
+			t.Errorf("\nassertion error while testing `%s` reason: \nWant:%s\nGot:%s", testcase.name, testcase.wantReason, gotReason)
+		}
+	}
+}
+
+func Test_DiagnoseLaunchingPodEvents(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalGetPodEvents := getPodEvents
+	defer func() {
+		getPodEvents = originalGetPodEvents
+	}()
+
+	now := time.Now()
+	getPodEvents = func(ctx context.Context, podClient corev1.CoreV1Interface, podName string) ([]k8sv1.Event, error) {
+		return []k8sv1.Event{
+			{Reason: "FailedScheduling", Message: "0/3 nodes are available", LastTimestamp: metav1.NewTime(now.Add(-time.Minute))},
+			{Reason: "NotTriggerScaleUp", Message: "pod didn't trigger scale-up: 2 node(s) didn't match Pod's node affinity/selector", LastTimestamp: metav1.NewTime(now)},
+			{Reason: "Scheduled", Message: "ignored", LastTimestamp: metav1.NewTime(now.Add(-time.Hour))},
+		}, nil
+	}
+
+	pod := &k8sv1.Pod{
+		Status: k8sv1.PodStatus{
+			Phase: k8sv1.PodPending,
+			Conditions: []k8sv1.PodCondition{
+				{Type: k8sv1.PodScheduled, Status: k8sv1.ConditionFalse, Reason: "Unschedulable"},
+			},
+		},
+	}
+	status := workspaceStatusFromPod(context.Background(), pod, "")
+	applyDiagnostics(status, diagnoseLaunchingPod(context.Background(), nil, pod))
+
+	if status.Status != "Failed" || status.Diagnostics == nil || status.Diagnostics.Reason != "NotTriggerScaleUp" {
+		t.Errorf("\nassertion error while testing `DiagnoseLaunchingPodEvents`: \nWant:Failed/NotTriggerScaleUp\nGot:%+v", status)
+	}
+}
+
+func Test_DiagnoseStoppedEcsTasks(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	now := time.Now()
+	testCases := []struct {
+		name         string
+		tasks        []*ecs.Task
+		wantReason   string
+		wantTerminal bool
+	}{
+		{
+			name:       "NoTasks",
+			tasks:      []*ecs.Task{},
+			wantReason: "",
+		},
+		{
+			name: "LatestTaskOutOfMemory",
+			tasks: []*ecs.Task{
+				{
+					StoppedAt:     aws.Time(now.Add(-time.Hour)),
+					StoppedReason: aws.String("CannotPullContainerError: pull image manifest has been retried 5 time(s)"),
+				},
+				{
+					StoppedAt:     aws.Time(now),
+					StopCode:      aws.String("EssentialContainerExited"),
+					StoppedReason: aws.String("Essential container in task exited"),
+					Containers: []*ecs.Container{
+						{Reason: aws.String("OutOfMemoryError: Container killed due to memory usage")},
+					},
+				},
+			},
+			wantReason: "OutOfMemoryError",
+		},
+		{
+			name: "ImageNotFound",
+			tasks: []*ecs.Task{
+				{
+					StoppedAt:     aws.Time(now),
+					StopCode:      aws.String("TaskFailedToStart"),
+					StoppedReason: aws.String("CannotPullContainerError: quay.io/cdis/foo:bar: not found"),
+				},
+			},
+			wantReason:   "CannotPullContainerError",
+			wantTerminal: true,
+		},
+		{
+			name: "UnknownReasonFallsBackToStopCode",
+			tasks: []*ecs.Task{
+				{
+					StoppedAt:     aws.Time(now),
+					StopCode:      aws.String("EssentialContainerExited"),
+					StoppedReason: aws.String("Essential container in task exited"),
+				},
+			},
+			wantReason: "EssentialContainerExited",
+		},
+	}
+
+	for _, testcase := range testCases {
+		t.Logf("Testing DiagnoseStoppedEcsTasks when %s", testcase.name)
+		diagnostics := diagnoseStoppedEcsTasks(testcase.tasks)
+		gotReason := ""
+		if diagnostics != nil {
+			gotReason = diagnostics.Reason
+		}
+		if gotReason != testcase.wantReason {
+			t.Errorf("\nassertion error while testing `%s` reason: \nWant:%s\nGot:%s", testcase.name, testcase.wantReason, gotReason)
+		}
+		if isTerminalLaunchFailure(diagnostics) != testcase.wantTerminal {
+			t.Errorf("\nassertion error while testing `%s` terminal: \nWant:%v\nGot:%v", testcase.name, testcase.wantTerminal, !testcase.wantTerminal)
+		}
+	}
+}
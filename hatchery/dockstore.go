@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -40,6 +41,42 @@ type ComposeHealthCheck struct {
 	Retries  int
 }
 
+// ComposeDependency is the condition under which a service
+// starts after one of its dependencies
+type ComposeDependency struct {
+	Condition string `yaml:"condition,omitempty"`
+}
+
+// ComposeDependsOn holds the depends_on block of a service,
+// in either the short (list) or the long (map) syntax
+type ComposeDependsOn map[string]ComposeDependency
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (dependsOn *ComposeDependsOn) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var services []string
+	if err := unmarshal(&services); err == nil {
+		*dependsOn = make(ComposeDependsOn, len(services))
+		for _, service := range services {
+			(*dependsOn)[service] = ComposeDependency{Condition: dependsOnServiceStarted}
+		}
+		return nil
+	}
+	dependencies := map[string]ComposeDependency{}
+	if err := unmarshal(&dependencies); err != nil {
+		return err
+	}
+	*dependsOn = dependencies
+	return nil
+}
+
+// ComposeVolume is an entry in the top-level volumes block of
+// docker-compose. Named volumes are scratch space deleted with the pod
+type ComposeVolume struct {
+	Name string
+	// "size" limits the volume, and "type: tmpfs" keeps it in memory
+	DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
+}
+
 // ComposeService is an entry in the services
 // block of docker-compose
 type ComposeService struct {
@@ -56,6 +93,11 @@ type ComposeService struct {
 	SecurityContext []string `yaml:"security_context"`
 	Deploy          ComposeDeployDetails
 	Healthcheck     ComposeHealthCheck
+	DependsOn       ComposeDependsOn `yaml:"depends_on,omitempty"`
+	CapAdd          []string         `yaml:"cap_add,omitempty"`
+	CapDrop         []string         `yaml:"cap_drop,omitempty"`
+	ReadOnly        bool             `yaml:"read_only,omitempty"`
+	SecurityOpt     []string         `yaml:"security_opt,omitempty"`
 }
 
 // ComposeFull holds all the data harvested from
@@ -64,6 +106,7 @@ type ComposeFull struct {
 	// name of the root service mapped to the magic port
 	RootService string `yaml:"-"`
 	Services    map[string]ComposeService
+	Volumes     map[string]ComposeVolume `yaml:"volumes,omitempty"`
 }
 
 var dslog = log.New(os.Stdout, "hatchery/dockstore", log.LstdFlags)
@@ -74,6 +117,69 @@ const sharedMemoryVolumePrefix = "${SHARED_MEMORY_VOLUME}"
 const gen3VolumePrefix = "${GEN3_VOLUME}"
 const magicPort = "${SERVICE_PORT}" // make it easy to test locally
 
+// depends_on conditions - services other services wait to complete run as init containers
+const dependsOnServiceStarted = "service_started"
+const dependsOnServiceHealthy = "service_healthy"
+const dependsOnServiceCompleted = "service_completed_successfully"
+
+// applyComposeSecurityOpt translates a security_opt entry - no-new-privileges, and the
+// seccomp and apparmor profiles. Other profiles than unconfined and the runtime default
+// name profiles loaded on the nodes
+func applyComposeSecurityOpt(securityContext *k8sv1.SecurityContext, opt string) error {
+	// docker accepts both ":" and "=" separators
+	kvSlice := strings.SplitN(strings.Replace(opt, "=", ":", 1), ":", 2)
+	value := ""
+	if len(kvSlice) == 2 {
+		value = kvSlice[1]
+	}
+	profile := ""
+	switch value {
+	case "unconfined":
+		profile = "Unconfined"
+	case "runtime/default", "docker-default":
+		profile = "RuntimeDefault"
+	default:
+		profile = localhostProfilePrefix + value
+	}
+	var err error
+	switch kvSlice[0] {
+	case "no-new-privileges":
+		if value != "" && value != "true" && value != "false" {
+			return fmt.Errorf("invalid security_opt: %v", opt)
+		}
+		allowPrivilegeEscalation := value == "false"
+		securityContext.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+	case "seccomp":
+		securityContext.SeccompProfile, err = buildSeccompProfile(profile)
+	case "apparmor":
+		securityContext.AppArmorProfile, err = buildAppArmorProfile(profile)
+	default:
+		return fmt.Errorf("unsupported security_opt: %v", opt)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid security_opt %v: %v", opt, err)
+	}
+	return nil
+}
+
+// composeCapabilities strips the CAP_ prefix docker accepts, which k8s does not
+func composeCapabilities(names []string) []string {
+	capabilities := make([]string, len(names))
+	for i, name := range names {
+		capabilities[i] = strings.TrimPrefix(strings.ToUpper(name), "CAP_")
+	}
+	return capabilities
+}
+
+// dnsSafeName converts a compose key to a k8s DNS-safe name
+func dnsSafeName(key string) string {
+	name := strings.ToLower(key)
+	for _, badChar := range [...]string{"_", "/", " "} {
+		name = strings.ReplaceAll(name, badChar, "-")
+	}
+	return name
+}
+
 // DockstoreComposeFromFile loads a hatchery application (container)
 // config from a compose.yaml file
 func DockstoreComposeFromFile(filePath string) (model *ComposeFull, err error) {
@@ -104,22 +210,39 @@ func DockstoreComposeFromBytes(yamlBytes []byte) (model *ComposeFull, err error)
 
 // Sanitize scans, validates, and decorates a given ComposeFull model
 func (model *ComposeFull) Sanitize() error {
+	cleanVolumes := make(map[string]ComposeVolume, len(model.Volumes))
+	for key, volume := range model.Volumes {
+		volume.Name = dnsSafeName(key)
+		for _, builtinName := range builtinVolumeNames {
+			if volume.Name == builtinName {
+				return fmt.Errorf("volume name %v is reserved", key)
+			}
+		}
+		if size := volume.DriverOpts["size"]; size != "" {
+			if _, err := resource.ParseQuantity(size); err != nil {
+				return fmt.Errorf("invalid size '%v' for volume %v: %v", size, key, err)
+			}
+		}
+		cleanVolumes[key] = volume
+	}
+	model.Volumes = cleanVolumes
+
 	cleanServices := make(map[string]ComposeService, len(model.Services))
 	for key, service := range model.Services {
 		// k8s wants DNS-safe container names - let's just do that here
-		service.Name = strings.ToLower(key)
-		for _, badChar := range [...]string{"_", "/", " "} {
-			service.Name = strings.ReplaceAll(service.Name, badChar, "-")
-		}
+		service.Name = dnsSafeName(key)
 		// some basic validation ...
 		if len(service.Image) == 0 {
 			return fmt.Errorf("must specify an Image for service %v", key)
 		}
 		for _, mount := range service.Volumes {
+			mountSlice := strings.SplitN(mount, ":", 2)
+			if _, ok := model.Volumes[mountSlice[0]]; ok && len(mountSlice) == 2 {
+				continue
+			}
 			if !strings.HasPrefix(mount, userVolumePrefix) && !strings.HasPrefix(mount, dataVolumePrefix) && !strings.HasPrefix(mount, gen3VolumePrefix) && !strings.HasPrefix(mount, sharedMemoryVolumePrefix) {
-				return fmt.Errorf("illegal volume mount - only support %s, %s, %s and %s mounts: %v", userVolumePrefix, dataVolumePrefix, gen3VolumePrefix, sharedMemoryVolumePrefix, mount)
+				return fmt.Errorf("illegal volume mount - only support %s, %s, %s and %s mounts, and named volumes: %v", userVolumePrefix, dataVolumePrefix, gen3VolumePrefix, sharedMemoryVolumePrefix, mount)
 			}
-			mountSlice := strings.SplitN(mount, ":", 2)
 			if len(mountSlice) != 2 && !strings.HasPrefix(mount, sharedMemoryVolumePrefix) {
 				return fmt.Errorf("illegal volume mount: %v", mount)
 			}
@@ -150,6 +273,11 @@ func (model *ComposeFull) Sanitize() error {
 				return fmt.Errorf("Could not parse port entry: %v", portEntry)
 			}
 		}
+		for _, opt := range service.SecurityOpt {
+			if err := applyComposeSecurityOpt(&k8sv1.SecurityContext{}, opt); err != nil {
+				return err
+			}
+		}
 		if model.RootService == "" {
 			for _, portMap := range service.Ports {
 				if strings.HasPrefix(portMap, magicPort+":") {
@@ -157,25 +285,108 @@ func (model *ComposeFull) Sanitize() error {
 				}
 			}
 		}
+		for dependency, details := range service.DependsOn {
+			// every other service starts with the pod, whatever it depends on
+			switch details.Condition {
+			case "", dependsOnServiceStarted, dependsOnServiceHealthy:
+			case dependsOnServiceCompleted:
+				if _, ok := model.Services[dependency]; !ok {
+					return fmt.Errorf("service %v depends on unknown service %v", key, dependency)
+				}
+			default:
+				return fmt.Errorf("unsupported depends_on condition for service %v: %v", key, details.Condition)
+			}
+		}
 		cleanServices[key] = service
 	}
 	model.Services = cleanServices
 	if len(model.RootService) == 0 {
 		return fmt.Errorf("must map exactly one service to port %s", magicPort)
 	}
+	if _, err := model.oneShotServices(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// oneShotServices lists the services other services wait to complete, in the
+// order they must run. They become the init containers of the pod
+func (model *ComposeFull) oneShotServices() ([]string, error) {
+	oneShot := map[string]bool{}
+	for _, service := range model.Services {
+		for dependency, details := range service.DependsOn {
+			if details.Condition == dependsOnServiceCompleted {
+				oneShot[dependency] = true
+			}
+		}
+	}
+	if oneShot[model.RootService] {
+		return nil, fmt.Errorf("root service %v can't run to completion", model.RootService)
+	}
+	keys := make([]string, 0, len(oneShot))
+	for key := range oneShot {
+		// init containers run before every other container
+		for dependency := range model.Services[key].DependsOn {
+			if !oneShot[dependency] {
+				return nil, fmt.Errorf("service %v runs to completion, so it can only depend on services that also run to completion: %v", key, dependency)
+			}
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// depth-first topological sort, dependencies first
+	ordered := make([]string, 0, len(keys))
+	state := map[string]int{} // 1 - visiting, 2 - done
+	var visit func(key string) error
+	visit = func(key string) error {
+		switch state[key] {
+		case 1:
+			return fmt.Errorf("circular depends_on through service %v", key)
+		case 2:
+			return nil
+		}
+		state[key] = 1
+		dependencies := make([]string, 0, len(model.Services[key].DependsOn))
+		for dependency := range model.Services[key].DependsOn {
+			dependencies = append(dependencies, dependency)
+		}
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		state[key] = 2
+		ordered = append(ordered, key)
+		return nil
+	}
+	for _, key := range keys {
+		if err := visit(key); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
 // BuildK8sResource from a compose resource spec
-func (rspec *ComposeResourceSpec) BuildK8sResource() map[k8sv1.ResourceName]resource.Quantity {
+func (rspec *ComposeResourceSpec) BuildK8sResource() (map[k8sv1.ResourceName]resource.Quantity, error) {
 	result := make(map[k8sv1.ResourceName]resource.Quantity)
 	if rspec.CPU != "" {
-		result[k8sv1.ResourceCPU] = resource.MustParse(rspec.CPU)
+		quantity, err := resource.ParseQuantity(rspec.CPU)
+		if nil != err {
+			return nil, fmt.Errorf("invalid cpus '%v': %v", rspec.CPU, err)
+		}
+		result[k8sv1.ResourceCPU] = quantity
 	}
 	if rspec.Memory != "" {
-		result[k8sv1.ResourceMemory] = resource.MustParse(rspec.Memory)
+		quantity, err := resource.ParseQuantity(rspec.Memory)
+		if nil != err {
+			return nil, fmt.Errorf("invalid memory '%v': %v", rspec.Memory, err)
+		}
+		result[k8sv1.ResourceMemory] = quantity
 	}
-	return result
+	return result, nil
 }
 
 // ToK8sContainer copies data from the given service to the container friend
@@ -229,6 +440,11 @@ func (service *ComposeService) ToK8sContainer(friend *k8sv1.Container) (mountUse
 					volumeMountsIndex++
 				} else if strings.HasPrefix(sourceDrive, sharedMemoryVolumePrefix) {
 					mountSharedMemory = true
+				} else if len(mountSplit) == 2 && !strings.HasPrefix(sourceDrive, "$") && !strings.HasPrefix(sourceDrive, "/") {
+					// named volume, declared in the top-level volumes block
+					dest.MountPath = mountSplit[1]
+					dest.Name = dnsSafeName(sourceDrive)
+					volumeMountsIndex++
 				} else {
 					return mountUserVolume, mountSharedMemory, fmt.Errorf("Unknown mount point: %v", source)
 				}
@@ -249,7 +465,7 @@ func (service *ComposeService) ToK8sContainer(friend *k8sv1.Container) (mountUse
 		}
 	}
 
-	if nil != service.SecurityContext {
+	if nil != service.SecurityContext || len(service.CapAdd) > 0 || len(service.CapDrop) > 0 || service.ReadOnly || len(service.SecurityOpt) > 0 {
 		friend.SecurityContext = &k8sv1.SecurityContext{}
 		for _, securityContextEntry := range service.SecurityContext {
 			kvSlice := strings.SplitN(securityContextEntry, "=", 2)
@@ -264,6 +480,21 @@ func (service *ComposeService) ToK8sContainer(friend *k8sv1.Container) (mountUse
 				friend.SecurityContext.Privileged = &priv
 			}
 		}
+		if len(service.CapAdd) > 0 || len(service.CapDrop) > 0 {
+			friend.SecurityContext.Capabilities = &k8sv1.Capabilities{
+				Add:  appendCapabilities(nil, composeCapabilities(service.CapAdd)),
+				Drop: appendCapabilities(nil, composeCapabilities(service.CapDrop)),
+			}
+		}
+		if service.ReadOnly {
+			readOnly := true
+			friend.SecurityContext.ReadOnlyRootFilesystem = &readOnly
+		}
+		for _, opt := range service.SecurityOpt {
+			if err := applyComposeSecurityOpt(friend.SecurityContext, opt); err != nil {
+				return mountUserVolume, mountSharedMemory, err
+			}
+		}
 	}
 
 	// ignore service.Ports - only the magic port is mapped at the pod level
@@ -276,8 +507,14 @@ func (service *ComposeService) ToK8sContainer(friend *k8sv1.Container) (mountUse
 		copy(friend.Args, service.Command)
 	}
 
-	friend.Resources.Limits = service.Deploy.Resources.Limits.BuildK8sResource()
-	friend.Resources.Requests = service.Deploy.Resources.Requests.BuildK8sResource()
+	friend.Resources.Limits, err = service.Deploy.Resources.Limits.BuildK8sResource()
+	if nil != err {
+		return mountUserVolume, mountSharedMemory, err
+	}
+	friend.Resources.Requests, err = service.Deploy.Resources.Requests.BuildK8sResource()
+	if nil != err {
+		return mountUserVolume, mountSharedMemory, err
+	}
 
 	if 1 < len(service.Healthcheck.Test) && service.Healthcheck.Test[0] == "CMD" {
 		friend.ReadinessProbe = &k8sv1.Probe{
@@ -337,18 +574,57 @@ func (model *ComposeFull) BuildHatchApp() (*Container, error) {
 	if numServices < 1 {
 		return nil, fmt.Errorf("no services found in compose model")
 	}
-	hatchApp.Friends = make([]k8sv1.Container, numServices)
-	friendIndex := 0
+	oneShotKeys, err := model.oneShotServices()
+	if nil != err {
+		return nil, err
+	}
+	oneShot := make(map[string]bool, len(oneShotKeys))
+	for _, key := range oneShotKeys {
+		oneShot[key] = true
+	}
+	keys := make([]string, 0, numServices)
+	for key := range model.Services {
+		if !oneShot[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	hatchApp.Friends = []k8sv1.Container{}
+	hatchApp.InitContainers = []k8sv1.Container{}
 	mountUserVolume := false // does this app mount the user volume?
 	mountSharedMemory := false
-	for _, service := range model.Services {
-		usesUserVolume, useSharedMemory, err := service.ToK8sContainer(&hatchApp.Friends[friendIndex])
+	for _, key := range append(oneShotKeys, keys...) {
+		service := model.Services[key]
+		friend := k8sv1.Container{}
+		usesUserVolume, useSharedMemory, err := service.ToK8sContainer(&friend)
 		if nil != err {
 			return nil, err
 		}
 		mountUserVolume = mountUserVolume || usesUserVolume
 		mountSharedMemory = mountSharedMemory || useSharedMemory
-		friendIndex++
+		if oneShot[key] {
+			// init containers don't support probes
+			friend.ReadinessProbe = nil
+			friend.LivenessProbe = nil
+			hatchApp.InitContainers = append(hatchApp.InitContainers, friend)
+		} else {
+			hatchApp.Friends = append(hatchApp.Friends, friend)
+		}
+	}
+
+	volumeKeys := make([]string, 0, len(model.Volumes))
+	for key := range model.Volumes {
+		volumeKeys = append(volumeKeys, key)
+	}
+	sort.Strings(volumeKeys)
+	for _, key := range volumeKeys {
+		volume := model.Volumes[key]
+		emptyDir := &EmptyDirVolume{SizeLimit: volume.DriverOpts["size"]}
+		if volume.DriverOpts["type"] == "tmpfs" {
+			emptyDir.Medium = string(k8sv1.StorageMediumMemory)
+		}
+		hatchApp.ExtraVolumes = append(hatchApp.ExtraVolumes, ExtraVolume{Name: volume.Name, EmptyDir: emptyDir})
 	}
 	if mountUserVolume {
 		// pods.go defines the k8s volume for the user space if this variable is set ...
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"gopkg.in/yaml.v2"
+	k8sv1 "k8s.io/api/core/v1"
 )
 
 func TestDockstoreComposeLoad(t *testing.T) {
@@ -112,3 +113,173 @@ func TestFirefoxAppTranslate(t *testing.T) {
 	hatchAppBytes, _ := yaml.Marshal(hatchApp)
 	dslog.Printf("translated hatchery app: %v", string(hatchAppBytes))
 }
+
+func TestDockstoreComposeInitContainers(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	path := "../testData/dockstore/seeded-notebook-app.yml"
+	composeModel, err := DockstoreComposeFromFile(path)
+	if nil != err {
+		t.Fatalf("failed to load config from %v, got: %v", path, err)
+	}
+	if condition := composeModel.Services["notebook"].DependsOn["seed_notebooks"].Condition; condition != dependsOnServiceCompleted {
+		t.Errorf("\nassertion error while testing `depends_on` long syntax: \nWant:%s\nGot:%s", dependsOnServiceCompleted, condition)
+	}
+	hatchApp, err := composeModel.BuildHatchApp()
+	if nil != err {
+		t.Fatalf("failed to translate app, got: %v", err)
+	}
+
+	// one-shot services run as init containers, dependencies first
+	initNames := []string{}
+	for _, initContainer := range hatchApp.InitContainers {
+		initNames = append(initNames, initContainer.Name)
+		if initContainer.ReadinessProbe != nil || initContainer.LivenessProbe != nil {
+			t.Errorf("\nassertion error while testing `BuildHatchApp` init container probes: \nWant:%v\nGot:%+v", nil, initContainer)
+		}
+	}
+	if strings.Join(initNames, ",") != "fetch-examples,seed-notebooks" {
+		t.Errorf("\nassertion error while testing `BuildHatchApp` init containers: \nWant:%s\nGot:%v", "fetch-examples,seed-notebooks", initNames)
+	}
+	if len(hatchApp.Friends) != 1 || hatchApp.Friends[0].Name != "notebook" {
+		t.Errorf("\nassertion error while testing `BuildHatchApp` friends: \nWant:%s\nGot:%+v", "notebook", hatchApp.Friends)
+	}
+	if hatchApp.UserVolumeLocation == "" {
+		t.Error("dockstore hatchApp should set UserVolumeLocation property")
+	}
+
+	// named volumes are scratch space
+	if len(hatchApp.ExtraVolumes) != 1 || hatchApp.ExtraVolumes[0].Name != "scratch" || hatchApp.ExtraVolumes[0].EmptyDir == nil || hatchApp.ExtraVolumes[0].EmptyDir.SizeLimit != "1Gi" {
+		t.Errorf("\nassertion error while testing `BuildHatchApp` extra volumes: \nWant:%s\nGot:%+v", "scratch", hatchApp.ExtraVolumes)
+	}
+	if mounts := hatchApp.InitContainers[0].VolumeMounts; len(mounts) != 1 || mounts[0].Name != "scratch" || mounts[0].MountPath != "/examples" {
+		t.Errorf("\nassertion error while testing `ToK8sContainer` named volume: \nWant:%s\nGot:%+v", "/examples", mounts)
+	}
+}
+
+func TestDockstoreComposeInvalidDependencies(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	root := `
+   webapp:
+      image: python:3.8-buster
+      ports:
+         - "${SERVICE_PORT}:8000"
+`
+	for _, testCase := range []struct {
+		name     string
+		services string
+	}{
+		{
+			name: "RootRunsToCompletion",
+			services: root + `      depends_on:
+         worker:
+            condition: service_completed_successfully
+   worker:
+      image: ubuntu:18.04
+      depends_on:
+         webapp:
+            condition: service_completed_successfully
+`,
+		},
+		{
+			name: "OneShotDependsOnService",
+			services: root + `      depends_on:
+         seed:
+            condition: service_completed_successfully
+   seed:
+      image: ubuntu:18.04
+      depends_on:
+         - worker
+   worker:
+      image: ubuntu:18.04
+`,
+		},
+		{
+			name: "CircularDependencies",
+			services: root + `      depends_on:
+         seed:
+            condition: service_completed_successfully
+   seed:
+      image: ubuntu:18.04
+      depends_on:
+         fetch:
+            condition: service_completed_successfully
+   fetch:
+      image: ubuntu:18.04
+      depends_on:
+         seed:
+            condition: service_completed_successfully
+`,
+		},
+		{
+			name: "UnknownCondition",
+			services: root + `      depends_on:
+         worker:
+            condition: service_stopped
+   worker:
+      image: ubuntu:18.04
+`,
+		},
+		{
+			name: "UndeclaredVolume",
+			services: root + `      volumes:
+         - scratch:/scratch
+`,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if _, err := DockstoreComposeFromStr("version: '3'\nservices:\n" + testCase.services); err == nil {
+				t.Errorf("\nassertion error while testing `Sanitize`: \nWant:%s\nGot:%v", "an error", err)
+			}
+		})
+	}
+}
+
+func TestDockstoreComposeSecurity(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	composeModel, err := DockstoreComposeFromStr(`
+version: '3'
+services:
+   webapp:
+      image: python:3.8-buster
+      ports:
+         - "${SERVICE_PORT}:8000"
+      cap_drop: [ ALL ]
+      cap_add: [ CAP_NET_BIND_SERVICE ]
+      read_only: true
+      security_opt:
+         - no-new-privileges:true
+         - seccomp=runtime/default
+         - apparmor:webapp
+`)
+	if nil != err {
+		t.Fatalf("failed to load compose app, got: %v", err)
+	}
+	hatchApp, err := composeModel.BuildHatchApp()
+	if nil != err {
+		t.Fatalf("failed to translate app, got: %v", err)
+	}
+	securityContext := hatchApp.Friends[0].SecurityContext
+	if securityContext == nil || len(securityContext.Capabilities.Drop) != 1 || securityContext.Capabilities.Drop[0] != "ALL" ||
+		len(securityContext.Capabilities.Add) != 1 || securityContext.Capabilities.Add[0] != "NET_BIND_SERVICE" {
+		t.Fatalf("\nassertion error while testing `ToK8sContainer` capabilities: \nWant:%s\nGot:%+v", "ALL dropped, NET_BIND_SERVICE added", securityContext)
+	}
+	if !*securityContext.ReadOnlyRootFilesystem || *securityContext.AllowPrivilegeEscalation ||
+		securityContext.SeccompProfile.Type != k8sv1.SeccompProfileTypeRuntimeDefault || *securityContext.AppArmorProfile.LocalhostProfile != "webapp" {
+		t.Errorf("\nassertion error while testing `ToK8sContainer` security_opt: \nWant:%s\nGot:%+v", "a hardened container", securityContext)
+	}
+
+	if _, err := DockstoreComposeFromStr(`
+version: '3'
+services:
+   webapp:
+      image: python:3.8-buster
+      ports:
+         - "${SERVICE_PORT}:8000"
+      security_opt: [ label:disable ]
+`); err == nil {
+		t.Errorf("\nassertion error while testing `Sanitize` unsupported security_opt: \nWant:%s\nGot:%v", "an error", err)
+	}
+}
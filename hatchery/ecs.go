@@ -20,17 +20,21 @@ type CreateTaskDefinitionInput struct {
 	ExecutionRoleArn string
 	Image            string
 	Memory           string
-	Name             string
-	Port             int64
-	LogGroupName     string
-	Volumes          []*ecs.Volume
-	MountPoints      []*ecs.MountPoint
-	LogRegion        string
-	TaskRole         string
-	Type             string
-	EntryPoint       []string
-	Args             []string
-	SidecarContainer ecs.ContainerDefinition
+	// CPU units and soft memory limit (MiB) reserved for the workspace container, if any
+	CpuReservation    int64
+	MemoryReservation int64
+	Name              string
+	Port              int64
+	LogGroupName      string
+	Volumes           []*ecs.Volume
+	MountPoints       []*ecs.MountPoint
+	LogRegion         string
+	TaskRole          string
+	Type              string
+	EntryPoint        []string
+	Args              []string
+	// nil for containers without sidecar
+	SidecarContainer *ecs.ContainerDefinition
 }
 
 type EnvVar struct {
@@ -128,13 +132,13 @@ func (sess *CREDS) findEcsCluster() (*ecs.Cluster, error) {
 		} else {
 			// Print the error, cast err to awserr.Error to get the Code and
 			// Message from an error.
-			Config.Logger.Println(err.Error())
+			Config().Logger.Println(err.Error())
 		}
 	}
 	if len(describeClusterResult.Failures) > 0 {
 		for _, failure := range describeClusterResult.Failures {
 			if *failure.Reason == "MISSING" {
-				Config.Logger.Printf("ECS cluster named %s not found, trying to create this ECS cluster", clusterName)
+				Config().Logger.Printf("ECS cluster named %s not found, trying to create this ECS cluster", clusterName)
 				input := &ecs.CreateClusterInput{
 					ClusterName: aws.String(clusterName),
 					Tags: []*ecs.Tag{
@@ -166,7 +170,7 @@ func (sess *CREDS) findEcsCluster() (*ecs.Cluster, error) {
 				return describeClusterResult.Clusters[0], nil
 			}
 		}
-		Config.Logger.Printf("ECS cluster named %s cannot be described", clusterName)
+		Config().Logger.Printf("ECS cluster named %s cannot be described", clusterName)
 		return nil, fmt.Errorf("ECS cluster named %s cannot be described", clusterName)
 	} else {
 		return describeClusterResult.Clusters[0], nil
@@ -209,7 +213,7 @@ func (sess *CREDS) statusEcsWorkspace(ctx context.Context, userName string, acce
 		if statusMessage == "ACTIVE" && (*service.Services[0].RunningCount == *service.Services[0].DesiredCount) {
 			taskDefName = *service.Services[0].TaskDefinition
 			if taskDefName == "" {
-				Config.Logger.Printf("No task definition found for user %s", userName)
+				Config().Logger.Printf("No task definition found for user %s", userName)
 			} else {
 				desTaskDefOutput, err := sess.svc.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
 					TaskDefinition: &taskDefName,
@@ -225,25 +229,25 @@ func (sess *CREDS) statusEcsWorkspace(ctx context.Context, userName string, acce
 									idleTimeLimit, err := strconv.Atoi(argSplit[len(argSplit)-1])
 									if err == nil {
 										status.IdleTimeLimit = idleTimeLimit * 1000
-										lastActivityTime, err := getKernelIdleTimeWithContext(ctx, accessToken)
+										lastActivityTime, err := getKernelIdleTimeWithContext(ctx, accessToken, "")
 										status.LastActivityTime = lastActivityTime
 										if err != nil {
-											Config.Logger.Println(err.Error())
+											Config().Logger.Println(err.Error())
 										}
 									} else {
-										Config.Logger.Println(err.Error())
+										Config().Logger.Println(err.Error())
 									}
 									break
 								}
 								if i == len(args)-1 {
-									Config.Logger.Printf("Unable to find kernel idle shutdown time in args\n")
+									Config().Logger.Printf("Unable to find kernel idle shutdown time in args\n")
 								}
 							}
 						} else {
-							Config.Logger.Printf("No env vars found for task definition %s\n", taskDefName)
+							Config().Logger.Printf("No env vars found for task definition %s\n", taskDefName)
 						}
 					} else {
-						Config.Logger.Printf("No container definition found for task definition %s\n", taskDefName)
+						Config().Logger.Printf("No container definition found for task definition %s\n", taskDefName)
 					}
 				}
 			}
@@ -253,16 +257,86 @@ func (sess *CREDS) statusEcsWorkspace(ctx context.Context, userName string, acce
 		} else {
 			status.Status = statusMap[statusMessage]
 		}
+		if status.Status == "Launching" || (statusMessage == "ACTIVE" && *service.Services[0].RunningCount < *service.Services[0].DesiredCount) {
+			// tasks that failed to start are retried by the service, so the workspace
+			// can look like it is launching forever
+			status.Status = statusMap["LAUNCHING"]
+			diagnostics, err := sess.diagnoseEcsWorkspace(ctx, cluster.ClusterName, svcName)
+			if err != nil {
+				Config().Logger.Printf("Error getting stopped tasks for user %s: %v", userName, err)
+			}
+			applyDiagnostics(&status, diagnostics)
+		}
 	} else {
 		status.Status = statusMap[statusMessage]
 	}
 	return &status, nil
 }
 
+// Explain why the workspace running in ECS is not running, using its stopped tasks
+func (sess *CREDS) diagnoseEcsWorkspace(ctx context.Context, clusterName *string, svcName string) (*WorkspaceDiagnostics, error) {
+	listTasksOutput, err := sess.svc.ListTasksWithContext(ctx, &ecs.ListTasksInput{
+		Cluster:       clusterName,
+		ServiceName:   aws.String(svcName),
+		DesiredStatus: aws.String(ecs.DesiredStatusStopped),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(listTasksOutput.TaskArns) == 0 {
+		return nil, nil
+	}
+	describeTasksOutput, err := sess.svc.DescribeTasksWithContext(ctx, &ecs.DescribeTasksInput{
+		Cluster: clusterName,
+		Tasks:   listTasksOutput.TaskArns,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return diagnoseStoppedEcsTasks(describeTasksOutput.Tasks), nil
+}
+
+// Get the value of an environment variable of the workspace container running in ECS
+func (sess *CREDS) getEcsWorkspaceEnvVar(userName string, envVarName string) (string, error) {
+	cluster, err := sess.findEcsCluster()
+	if err != nil {
+		return "", err
+	}
+	svcName := strings.ReplaceAll(os.Getenv("GEN3_ENDPOINT"), ".", "-") + userToResourceName(userName, "pod") + "svc"
+	desServiceOutput, err := sess.svc.DescribeServices(&ecs.DescribeServicesInput{
+		Cluster: cluster.ClusterName,
+		Services: []*string{
+			aws.String(svcName),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(desServiceOutput.Services) == 0 || aws.StringValue(desServiceOutput.Services[0].TaskDefinition) == "" {
+		return "", errors.New("No task definition found for " + userName)
+	}
+	desTaskDefOutput, err := sess.svc.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: desServiceOutput.Services[0].TaskDefinition,
+	})
+	if err != nil {
+		return "", err
+	}
+	containerDefs := desTaskDefOutput.TaskDefinition.ContainerDefinitions
+	if len(containerDefs) == 0 {
+		return "", errors.New("No container definition found for " + userName)
+	}
+	for _, ev := range containerDefs[0].Environment {
+		if aws.StringValue(ev.Name) == envVarName {
+			return aws.StringValue(ev.Value), nil
+		}
+	}
+	return "", nil
+}
+
 // Terminate workspace running in ECS
 // TODO: Make this terminate ALB as well.
 var terminateEcsWorkspace = func(ctx context.Context, userName string, accessToken string, awsAcctID string) (string, error) {
-	Config.Logger.Printf("Terminating ECS workspace for user %s", userName)
+	Config().Logger.Printf("Terminating ECS workspace for user %s", userName)
 	roleARN := "arn:aws:iam::" + awsAcctID + ":role/csoc_adminvm"
 	sess := session.Must(session.NewSession(&aws.Config{
 		// TODO: Make this configurable
@@ -290,7 +364,7 @@ var terminateEcsWorkspace = func(ctx context.Context, userName string, accessTok
 		return "", errors.New("No service found for " + userName)
 	}
 	if taskDefName == "" {
-		Config.Logger.Printf("No task definition found for user %s, skipping API key deletion", userName)
+		Config().Logger.Printf("No task definition found for user %s, skipping API key deletion", userName)
 	} else {
 		desTaskDefOutput, err := svc.svc.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
 			TaskDefinition: &taskDefName,
@@ -304,26 +378,26 @@ var terminateEcsWorkspace = func(ctx context.Context, userName string, accessTok
 			if len(envVars) > 0 {
 				for i, ev := range envVars {
 					if *ev.Name == "API_KEY_ID" {
-						Config.Logger.Printf("Found mounted API key. Attempting to delete API Key with ID %s for user %s\n", *ev.Value, userName)
+						Config().Logger.Printf("Found mounted API key. Attempting to delete API Key with ID %s for user %s\n", *ev.Value, userName)
 						err := deleteAPIKeyWithContext(ctx, accessToken, *ev.Value)
 						if err != nil {
-							Config.Logger.Printf("Error occurred when deleting API Key with ID %s for user %s: %s\n", *ev.Value, userName, err.Error())
+							Config().Logger.Printf("Error occurred when deleting API Key with ID %s for user %s: %s\n", *ev.Value, userName, err.Error())
 						}
 						break
 					}
 					if i == len(envVars)-1 {
-						Config.Logger.Printf("Unable to find API Key ID in env vars for user %s\n", userName)
+						Config().Logger.Printf("Unable to find API Key ID in env vars for user %s\n", userName)
 					}
 				}
 			} else {
-				Config.Logger.Printf("No env vars found for task definition %s, skipping API key deletion\n", taskDefName)
+				Config().Logger.Printf("No env vars found for task definition %s, skipping API key deletion\n", taskDefName)
 			}
 		} else {
-			Config.Logger.Printf("No container definition found for task definition %s, skipping API key deletion\n", taskDefName)
+			Config().Logger.Printf("No container definition found for task definition %s, skipping API key deletion\n", taskDefName)
 		}
 	}
 	// Terminate ECS service
-	Config.Logger.Printf("Terminating ECS service %s for user %s\n", svcName, userName)
+	Config().Logger.Printf("Terminating ECS service %s for user %s\n", svcName, userName)
 	delServiceOutput, err := svc.svc.DeleteService(&ecs.DeleteServiceInput{
 		Cluster: cluster.ClusterName,
 		Force:   aws.Bool(true),
@@ -334,66 +408,29 @@ var terminateEcsWorkspace = func(ctx context.Context, userName string, accessTok
 	}
 
 	// Terminate load balancer
-	Config.Logger.Printf("Terminating load balancer for user %s\n", userName)
+	Config().Logger.Printf("Terminating load balancer for user %s\n", userName)
 	err = svc.terminateLoadBalancer(userName)
 	if err != nil {
-		Config.Logger.Printf("Error occurred when terminating load balancer for user %s: %s\n", userName, err.Error())
+		Config().Logger.Printf("Error occurred when terminating load balancer for user %s: %s\n", userName, err.Error())
 	}
 
 	// Terminate target group
 	err = svc.terminateLoadBalancerTargetGroup(userName)
 	if err != nil {
-		Config.Logger.Printf("Error occurred when terminating load balancer target group for user %s: %s\n", userName, err.Error())
+		Config().Logger.Printf("Error occurred when terminating load balancer target group for user %s: %s\n", userName, err.Error())
 	}
 
 	// Terminate transit gateway
 	err = teardownTransitGateway(userName)
 	if err != nil {
-		Config.Logger.Printf("Error occurred when terminating transit gateway resources for user %s: %s\n", userName, err.Error())
+		Config().Logger.Printf("Error occurred when terminating transit gateway resources for user %s: %s\n", userName, err.Error())
 	}
 	return fmt.Sprintf("Service '%s' is in status: %s", userToResourceName(userName, "pod"), *delServiceOutput.Service.Status), nil
 }
 
-func launchEcsWorkspace(userName string, hash string, accessToken string, payModel PayModel, envVars []EnvVar) error {
-	// Set up background context, as this runs in a goroutine
-	ctx := context.Background()
-
-	roleARN := "arn:aws:iam::" + payModel.AWSAccountId + ":role/csoc_adminvm"
-	sess := session.Must(session.NewSession(&aws.Config{
-		// TODO: Make this configurable
-		Region: aws.String("us-east-1"),
-	}))
-	svc := NewSVC(sess, roleARN)
-	hatchApp := Config.ContainersMap[hash]
-	mem, err := mem(hatchApp.MemoryLimit)
-	if err != nil {
-		// Log error and return without launching workspace
-		Config.Logger.Printf("Failed to launch ECS workspace for user %v, Error: %v", userName, err)
-		return err
-	}
-	cpu, err := cpu(hatchApp.CPULimit)
-	if err != nil {
-		// Log error and return without launching workspace
-		Config.Logger.Printf("Failed to launch ECS workspace for user %v, Error: %v", userName, err)
-	}
-
-	// Make sure ECS cluster exists
-	_, err = svc.launchEcsCluster(userName)
-	if err != nil {
-		Config.Logger.Printf("Failed to launch ECS cluster for user %v, Error: %v", userName, err)
-		return err
-	}
-
-	// Get Gen3 API key to be used in workspace
-	Config.Logger.Printf("Creating API key for user %s", userName)
-	apiKey, err := getAPIKeyWithContext(ctx, accessToken)
-	if err != nil {
-		Config.Logger.Printf("Failed to create API key for user %v, Error: %v. Moving on but workspace won't have API key", userName, err)
-		apiKey = &APIKeyStruct{}
-	} else {
-		Config.Logger.Printf("Created API key for user %v, key ID: %v", userName, apiKey.KeyID)
-	}
-
+// ecsWorkspaceEnvVars adds the container's env vars and the env vars hatchery sets in
+// every ECS workspace to `envVars`
+func ecsWorkspaceEnvVars(hatchApp *Container, envVars []EnvVar, apiKey *APIKeyStruct, accessToken string) []EnvVar {
 	for k, v := range hatchApp.Env {
 		envVars = append(envVars, EnvVar{
 			Key:   k,
@@ -417,38 +454,19 @@ func launchEcsWorkspace(userName string, hash string, accessToken string, payMod
 		Key:   "GEN3_ENDPOINT",
 		Value: os.Getenv("GEN3_ENDPOINT"),
 	})
+	return envVars
+}
 
-	Config.Logger.Printf("Settign up EFS for user %s", userName)
-	volumes, err := svc.EFSFileSystem(userName)
-	if err != nil {
-		Config.Logger.Printf("Failed to set up EFS for user %v, Error: %v", userName, err)
-		return err
-	}
-
-	Config.Logger.Printf("Setting up task role for user %s", userName)
-	taskRole, err := svc.taskRole(userName)
-	if err != nil {
-		// Log the error
-		Config.Logger.Printf("Failed to set up task role for user %v, Error: %v", userName, err)
-		return err
-	}
-
-	Config.Logger.Printf("Setting up execution role for user %s", userName)
-	_, err = svc.CreateEcsTaskExecutionRole()
-	if err != nil {
-		// Log the error
-		Config.Logger.Printf("Failed to set up execution role for user %v, Error: %v", userName, err)
-		return err
-	}
-
-	Config.Logger.Printf("Setting up ECS task definition for user %s", userName)
+// buildEcsTaskDefinition describes the workspace task, using the task role and EFS
+// volume created for the user
+func buildEcsTaskDefinition(hatchConfig *FullHatcheryConfig, hatchApp *Container, userName string, payModel PayModel, cpu string, mem string, envVars []EnvVar, taskRole string, volumes *EFS) (CreateTaskDefinitionInput, error) {
 	taskDef := CreateTaskDefinitionInput{
 		Image:      hatchApp.Image,
 		Cpu:        cpu,
 		Memory:     mem,
 		Name:       userToResourceName(userName, "pod"),
 		Type:       "ws",
-		TaskRole:   *taskRole,
+		TaskRole:   taskRole,
 		EntryPoint: hatchApp.Command,
 		Volumes: []*ecs.Volume{
 			{
@@ -492,63 +510,168 @@ func launchEcsWorkspace(userName string, hash string, accessToken string, payMod
 		EnvVars:          envVars,
 		Port:             int64(hatchApp.TargetPort),
 		ExecutionRoleArn: fmt.Sprintf("arn:aws:iam::%s:role/ecsTaskExecutionRole", payModel.AWSAccountId), // TODO: Make this configurable?
-		SidecarContainer: ecs.ContainerDefinition{
-			Image: &Config.Config.Sidecar.Image,
-			Name:  aws.String("sidecar-container"),
-			// 2 seconds is the smallest value allowed.
-			StopTimeout: aws.Int64(2),
-			Essential:   aws.Bool(false),
-			MountPoints: []*ecs.MountPoint{
-				{
-					ContainerPath: aws.String("/data"),
-					SourceVolume:  aws.String("data-volume"),
-				},
-				{
-					ContainerPath: aws.String("/.gen3"),
-					SourceVolume:  aws.String("gen3"),
-				},
+	}
+
+	// the task size is the limits, and the containers reserve their requests
+	if hasResourceRequests(hatchApp.CPURequest, hatchApp.MemoryRequest, hatchApp.RequestRatio) {
+		resources, err := containerResources(hatchApp)
+		if err != nil {
+			return taskDef, fmt.Errorf("invalid resources for container '%s': %v", hatchApp.Name, err)
+		}
+		taskDef.CpuReservation, taskDef.MemoryReservation = ecsReservations(resources)
+	}
+
+	// Fargate containers are never privileged, so the sidecar runs the same way whether
+	// its profile is unprivileged or not
+	sidecar, err := containerSidecar(&hatchConfig.Config, hatchApp)
+	if err != nil {
+		return taskDef, err
+	}
+	if sidecar == nil {
+		return taskDef, nil
+	}
+	taskDef.SidecarContainer = &ecs.ContainerDefinition{
+		Image: aws.String(sidecar.Image),
+		Name:  aws.String("sidecar-container"),
+		// 2 seconds is the smallest value allowed.
+		StopTimeout: aws.Int64(2),
+		Essential:   aws.Bool(false),
+		MountPoints: []*ecs.MountPoint{
+			{
+				ContainerPath: aws.String("/data"),
+				SourceVolume:  aws.String("data-volume"),
+			},
+			{
+				ContainerPath: aws.String("/.gen3"),
+				SourceVolume:  aws.String("gen3"),
 			},
 		},
 	}
-	taskDefResult, err := svc.CreateTaskDefinition(&taskDef, userName, hash, payModel.AWSAccountId)
+
+	if hasResourceRequests(sidecar.CPURequest, sidecar.MemoryRequest, sidecar.RequestRatio) {
+		resources, err := sidecarResources(*sidecar)
+		if err != nil {
+			return taskDef, fmt.Errorf("invalid sidecar resources: %v", err)
+		}
+		cpuReservation, memoryReservation := ecsReservations(resources)
+		taskDef.SidecarContainer.Cpu = aws.Int64(cpuReservation)
+		taskDef.SidecarContainer.MemoryReservation = aws.Int64(memoryReservation)
+	}
+	return taskDef, nil
+}
+
+func launchEcsWorkspace(ctx context.Context, hatchConfig *FullHatcheryConfig, hatchApp Container, size WorkspaceSize, userName string, accessToken string, payModel PayModel, envVars []EnvVar) error {
+
+	roleARN := "arn:aws:iam::" + payModel.AWSAccountId + ":role/csoc_adminvm"
+	sess := session.Must(session.NewSession(&aws.Config{
+		// TODO: Make this configurable
+		Region: aws.String("us-east-1"),
+	}))
+	svc := NewSVC(sess, roleARN)
+	hatchApp = workspaceContainer(hatchConfig, hatchApp, size, &payModel)
+	mem, err := mem(hatchApp.MemoryLimit)
+	if err != nil {
+		// Log error and return without launching workspace
+		hatchConfig.Logger.Printf("Failed to launch ECS workspace for user %v, Error: %v", userName, err)
+		return err
+	}
+	cpu, err := cpu(hatchApp.CPULimit)
+	if err != nil {
+		// Log error and return without launching workspace
+		hatchConfig.Logger.Printf("Failed to launch ECS workspace for user %v, Error: %v", userName, err)
+		return err
+	}
+
+	// Make sure ECS cluster exists
+	_, err = svc.launchEcsCluster(userName)
+	if err != nil {
+		hatchConfig.Logger.Printf("Failed to launch ECS cluster for user %v, Error: %v", userName, err)
+		return err
+	}
+
+	// Get Gen3 API key to be used in workspace
+	hatchConfig.Logger.Printf("Creating API key for user %s", userName)
+	apiKey, err := getAPIKeyWithContext(ctx, accessToken)
+	if err != nil {
+		hatchConfig.Logger.Printf("Failed to create API key for user %v, Error: %v. Moving on but workspace won't have API key", userName, err)
+		apiKey = &APIKeyStruct{}
+	} else {
+		hatchConfig.Logger.Printf("Created API key for user %v, key ID: %v", userName, apiKey.KeyID)
+	}
+
+	envVars = ecsWorkspaceEnvVars(&hatchApp, envVars, apiKey, accessToken)
+
+	hatchConfig.Logger.Printf("Settign up EFS for user %s", userName)
+	volumes, err := svc.EFSFileSystem(userName)
+	if err != nil {
+		hatchConfig.Logger.Printf("Failed to set up EFS for user %v, Error: %v", userName, err)
+		return err
+	}
+
+	hatchConfig.Logger.Printf("Setting up task role for user %s", userName)
+	taskRole, err := svc.taskRole(userName)
 	if err != nil {
 		// Log the error
-		Config.Logger.Printf("Failed to set up task definition for user %v, Error: %v", userName, err)
+		hatchConfig.Logger.Printf("Failed to set up task role for user %v, Error: %v", userName, err)
+		return err
+	}
+
+	hatchConfig.Logger.Printf("Setting up execution role for user %s", userName)
+	_, err = svc.CreateEcsTaskExecutionRole()
+	if err != nil {
+		// Log the error
+		hatchConfig.Logger.Printf("Failed to set up execution role for user %v, Error: %v", userName, err)
+		return err
+	}
+
+	hatchConfig.Logger.Printf("Setting up ECS task definition for user %s", userName)
+	taskDef, err := buildEcsTaskDefinition(hatchConfig, &hatchApp, userName, payModel, cpu, mem, envVars, *taskRole, volumes)
+	if err != nil {
+		hatchConfig.Logger.Printf("Failed to configure task definition for user %v, Error: %v", userName, err)
 		aerr := deleteAPIKeyWithContext(ctx, accessToken, apiKey.KeyID)
 		if aerr != nil {
-			Config.Logger.Printf("Error occurred when deleting API Key with ID %s for user %s: %s\n", apiKey.KeyID, userName, err.Error())
+			hatchConfig.Logger.Printf("Error occurred when deleting API Key with ID %s for user %s: %s\n", apiKey.KeyID, userName, aerr.Error())
+		}
+		return err
+	}
+	taskDefResult, err := svc.CreateTaskDefinition(hatchConfig, &taskDef, userName, payModel.AWSAccountId)
+	if err != nil {
+		// Log the error
+		hatchConfig.Logger.Printf("Failed to set up task definition for user %v, Error: %v", userName, err)
+		aerr := deleteAPIKeyWithContext(ctx, accessToken, apiKey.KeyID)
+		if aerr != nil {
+			hatchConfig.Logger.Printf("Error occurred when deleting API Key with ID %s for user %s: %s\n", apiKey.KeyID, userName, err.Error())
 		}
 		return err
 	}
 
-	Config.Logger.Printf("Launching ECS workspace service for user %s", userName)
-	launchTask, err := svc.launchService(ctx, taskDefResult, userName, hash, payModel)
+	hatchConfig.Logger.Printf("Launching ECS workspace service for user %s", userName)
+	launchTask, err := svc.launchService(ctx, hatchConfig, taskDefResult, userName, &hatchApp, payModel)
 	if err != nil {
 		// Log the error
-		Config.Logger.Printf("Failed to launch ECS workspace service for user %v, Error: %v", userName, err)
+		hatchConfig.Logger.Printf("Failed to launch ECS workspace service for user %v, Error: %v", userName, err)
 		aerr := deleteAPIKeyWithContext(ctx, accessToken, apiKey.KeyID)
 		if aerr != nil {
-			Config.Logger.Printf("Error occurred when deleting API Key with ID %s for user %s: %s\n", apiKey.KeyID, userName, err.Error())
+			hatchConfig.Logger.Printf("Error occurred when deleting API Key with ID %s for user %s: %s\n", apiKey.KeyID, userName, err.Error())
 		}
 		return err
 	}
 
-	Config.Logger.Printf("Setting up Transit Gateway for user %s", userName)
+	hatchConfig.Logger.Printf("Setting up Transit Gateway for user %s", userName)
 	err = setupTransitGateway(userName)
 	if err != nil {
 		// Log the error
-		Config.Logger.Printf("Failed to set up Transit Gateway for user %v, Error: %v", userName, err)
+		hatchConfig.Logger.Printf("Failed to set up Transit Gateway for user %v, Error: %v", userName, err)
 		return err
 	}
 
-	Config.Logger.Printf("Launched ECS workspace service at %s for user %s\n", launchTask, userName)
+	hatchConfig.Logger.Printf("Launched ECS workspace service at %s for user %s\n", launchTask, userName)
 	return nil
 }
 
 // Launch ECS service for task definition + LB for routing
-func (sess *CREDS) launchService(ctx context.Context, taskDefArn string, userName string, hash string, payModel PayModel) (string, error) {
+func (sess *CREDS) launchService(ctx context.Context, hatchConfig *FullHatcheryConfig, taskDefArn string, userName string, hatchApp *Container, payModel PayModel) (string, error) {
 	svc := sess.svc
-	hatchApp := Config.ContainersMap[hash]
 	cluster, err := sess.findEcsCluster()
 	if err != nil {
 		return "", err
@@ -590,16 +713,16 @@ func (sess *CREDS) launchService(ctx context.Context, taskDefArn string, userNam
 			switch aerr.Code() {
 			case ecs.ErrCodeInvalidParameterException:
 				if aerr.Error() == "InvalidParameterException: Creation of service was not idempotent." {
-					Config.Logger.Print("Service already exists.. ")
+					hatchConfig.Logger.Print("Service already exists.. ")
 					return "", nil
 				}
 			}
 		}
-		Config.Logger.Println(err.Error())
+		hatchConfig.Logger.Println(err.Error())
 		return "", err
 	}
-	Config.Logger.Printf("Service launched: %s", *result.Service.ClusterArn)
-	err = createLocalService(ctx, userName, hash, *loadBalancer.LoadBalancers[0].DNSName, payModel)
+	hatchConfig.Logger.Printf("Service launched: %s", *result.Service.ClusterArn)
+	err = createLocalService(ctx, hatchConfig, userName, "", hatchApp, *loadBalancer.LoadBalancers[0].DNSName, payModel)
 	if err != nil {
 		return "", err
 	}
@@ -607,11 +730,11 @@ func (sess *CREDS) launchService(ctx context.Context, taskDefArn string, userNam
 }
 
 // Create/Update Task Definition in ECS
-func (sess *CREDS) CreateTaskDefinition(input *CreateTaskDefinitionInput, userName string, hash string, awsAcctID string) (string, error) {
+func (sess *CREDS) CreateTaskDefinition(hatchConfig *FullHatcheryConfig, input *CreateTaskDefinitionInput, userName string, awsAcctID string) (string, error) {
 	creds := sess.creds
 	LogGroup, err := sess.CreateLogGroup(fmt.Sprintf("/hatchery/%s/", awsAcctID), creds)
 	if err != nil {
-		Config.Logger.Printf("Failed to create/get LogGroup. Error: %s", err)
+		hatchConfig.Logger.Printf("Failed to create/get LogGroup. Error: %s", err)
 		return "", err
 	}
 	svc := ecs.New(session.Must(session.NewSession(&aws.Config{
@@ -619,13 +742,48 @@ func (sess *CREDS) CreateTaskDefinition(input *CreateTaskDefinitionInput, userNa
 		Region:      aws.String("us-east-1"),
 	})))
 
-	Config.Logger.Printf("Creating ECS task definition")
+	hatchConfig.Logger.Printf("Creating ECS task definition")
+
+	var prismaDefender *ecs.ContainerDefinition
+	if hatchConfig.Config.PrismaConfig.Enable {
+		installBundle, err := getInstallBundle()
+		if err != nil {
+			hatchConfig.Logger.Print(err, " error getting prisma install bundle")
+			return "", err
+		}
+
+		image, err := getPrismaImage()
+		if err != nil {
+			hatchConfig.Logger.Print(err, " error getting prisma image")
+			return "", err
+		}
+
+		paloAltoContainerDefinition := prismaDefenderContainerDefinition(installBundle.Bundle, *image, userName)
+		prismaDefender = &paloAltoContainerDefinition
+	}
+
+	resp, err := svc.RegisterTaskDefinition(input.registerTaskDefinitionInput(userName, LogGroup, prismaDefender))
 
+	if err != nil {
+		hatchConfig.Logger.Print(err, " Couldn't register ECS task definition")
+		return "", err
+	}
+
+	td := resp.TaskDefinition
+
+	hatchConfig.Logger.Printf("Created ECS task definition [%s:%d]", aws.StringValue(td.Family), aws.Int64Value(td.Revision))
+
+	return aws.StringValue(td.TaskDefinitionArn), nil
+}
+
+// registerTaskDefinitionInput builds the task definition registered for the workspace.
+// The Prisma defender container is added when `prismaDefender` is not nil
+func (input *CreateTaskDefinitionInput) registerTaskDefinitionInput(userName string, logGroup string, prismaDefender *ecs.ContainerDefinition) *ecs.RegisterTaskDefinitionInput {
 	logConfiguration := &ecs.LogConfiguration{
 		LogDriver: aws.String(ecs.LogDriverAwslogs),
 		Options: map[string]*string{
 			"awslogs-region":        aws.String("us-east-1"),
-			"awslogs-group":         aws.String(LogGroup),
+			"awslogs-group":         aws.String(logGroup),
 			"awslogs-stream-prefix": aws.String(userName),
 		},
 	}
@@ -641,10 +799,12 @@ func (sess *CREDS) CreateTaskDefinition(input *CreateTaskDefinitionInput, userNa
 		EntryPoint:       aws.StringSlice(input.EntryPoint),
 		Command:          aws.StringSlice(input.Args),
 	}
-
-	sidecarContainerDefinition := input.SidecarContainer
-	sidecarContainerDefinition.LogConfiguration = logConfiguration
-	sidecarContainerDefinition.Environment = input.Environment()
+	if input.CpuReservation > 0 {
+		containerDefinition.Cpu = aws.Int64(input.CpuReservation)
+	}
+	if input.MemoryReservation > 0 {
+		containerDefinition.MemoryReservation = aws.Int64(input.MemoryReservation)
+	}
 
 	if input.Port != 0 {
 		containerDefinition.SetPortMappings(
@@ -656,95 +816,76 @@ func (sess *CREDS) CreateTaskDefinition(input *CreateTaskDefinitionInput, userNa
 		)
 	}
 
-	containerDefinitions := []*ecs.ContainerDefinition{
-		containerDefinition,
-		&sidecarContainerDefinition,
+	containerDefinitions := []*ecs.ContainerDefinition{containerDefinition}
+	if input.SidecarContainer != nil {
+		sidecarContainerDefinition := *input.SidecarContainer
+		sidecarContainerDefinition.LogConfiguration = logConfiguration
+		sidecarContainerDefinition.Environment = input.Environment()
+		containerDefinitions = append(containerDefinitions, &sidecarContainerDefinition)
 	}
 
-	if Config.Config.PrismaConfig.Enable {
-		installBundle, err := getInstallBundle()
-		if err != nil {
-			Config.Logger.Print(err, " error getting prisma install bundle")
-			return "", err
-		}
+	if prismaDefender != nil {
+		prismaDefender.LogConfiguration = logConfiguration
+		containerDefinitions = append(containerDefinitions, prismaDefender)
+	}
 
-		image, err := getPrismaImage()
-		if err != nil {
-			Config.Logger.Print(err, " error getting prisma image")
-			return "", err
-		}
+	return &ecs.RegisterTaskDefinitionInput{
+		ContainerDefinitions:    containerDefinitions,
+		Cpu:                     aws.String(input.Cpu),
+		ExecutionRoleArn:        aws.String(input.ExecutionRoleArn),
+		Family:                  aws.String(fmt.Sprintf("%s_%s", input.Type, input.Name)),
+		Memory:                  aws.String(input.Memory),
+		NetworkMode:             aws.String(ecs.NetworkModeAwsvpc),
+		RequiresCompatibilities: aws.StringSlice([]string{ecs.CompatibilityFargate}),
+		TaskRoleArn:             aws.String(input.TaskRole),
+		Volumes:                 input.Volumes,
+	}
+}
 
-		paloAltoContainerDefinition := ecs.ContainerDefinition{
-			EntryPoint: aws.StringSlice([]string{
+// prismaDefenderContainerDefinition is the Prisma Cloud defender sidecar added to ECS workspaces
+func prismaDefenderContainerDefinition(installBundle string, image string, userName string) ecs.ContainerDefinition {
+	return ecs.ContainerDefinition{
+		EntryPoint: aws.StringSlice([]string{
+			"/usr/local/bin/defender",
+			"fargate",
+			"sidecar",
+		}),
+		Environment: []*ecs.KeyValuePair{
+			{
+				Name:  aws.String("INSTALL_BUNDLE"),
+				Value: aws.String(installBundle),
+			},
+			{
+				Name:  aws.String("DEFENDER_TYPE"),
+				Value: aws.String("fargate"),
+			},
+			{
+				Name:  aws.String("FARGATE_TASK"),
+				Value: aws.String(userName),
+			},
+			{
+				Name: aws.String("WS_ADDRESS"),
+				// TODO: Hardcoding in the address for now as the prisma api is returning wrong value
+				Value: aws.String("wss://us-west1.cloud.twistlock.com:443"),
+			},
+			{
+				Name:  aws.String("FILESYSTEM_MONITORING"),
+				Value: aws.String("false"),
+			},
+		},
+		Essential: aws.Bool(true),
+		HealthCheck: &ecs.HealthCheck{
+			Command: aws.StringSlice([]string{
 				"/usr/local/bin/defender",
 				"fargate",
-				"sidecar",
+				"healthcheck",
 			}),
-			Environment: []*ecs.KeyValuePair{
-				{
-					Name:  aws.String("INSTALL_BUNDLE"),
-					Value: aws.String(installBundle.Bundle),
-				},
-				{
-					Name:  aws.String("DEFENDER_TYPE"),
-					Value: aws.String("fargate"),
-				},
-				{
-					Name:  aws.String("FARGATE_TASK"),
-					Value: aws.String(userName),
-				},
-				{
-					Name: aws.String("WS_ADDRESS"),
-					// TODO: Hardcoding in the address for now as the prisma api is returning wrong value
-					Value: aws.String("wss://us-west1.cloud.twistlock.com:443"),
-				},
-				{
-					Name:  aws.String("FILESYSTEM_MONITORING"),
-					Value: aws.String("false"),
-				},
-			},
-			Essential: aws.Bool(true),
-			HealthCheck: &ecs.HealthCheck{
-				Command: aws.StringSlice([]string{
-					"/usr/local/bin/defender",
-					"fargate",
-					"healthcheck",
-				}),
-				Interval:    aws.Int64(5),
-				Retries:     aws.Int64(3),
-				StartPeriod: aws.Int64(1),
-				Timeout:     aws.Int64(5),
-			},
-			Image:            aws.String(*image),
-			Name:             aws.String("TwistlockDefender"),
-			LogConfiguration: logConfiguration,
-		}
-
-		containerDefinitions = append(containerDefinitions, &paloAltoContainerDefinition)
-	}
-
-	resp, err := svc.RegisterTaskDefinition(
-		&ecs.RegisterTaskDefinitionInput{
-			ContainerDefinitions:    containerDefinitions,
-			Cpu:                     aws.String(input.Cpu),
-			ExecutionRoleArn:        aws.String(input.ExecutionRoleArn),
-			Family:                  aws.String(fmt.Sprintf("%s_%s", input.Type, input.Name)),
-			Memory:                  aws.String(input.Memory),
-			NetworkMode:             aws.String(ecs.NetworkModeAwsvpc),
-			RequiresCompatibilities: aws.StringSlice([]string{ecs.CompatibilityFargate}),
-			TaskRoleArn:             aws.String(input.TaskRole),
-			Volumes:                 input.Volumes,
+			Interval:    aws.Int64(5),
+			Retries:     aws.Int64(3),
+			StartPeriod: aws.Int64(1),
+			Timeout:     aws.Int64(5),
 		},
-	)
-
-	if err != nil {
-		Config.Logger.Print(err, " Couldn't register ECS task definition")
-		return "", err
+		Image: aws.String(image),
+		Name:  aws.String("TwistlockDefender"),
 	}
-
-	td := resp.TaskDefinition
-
-	Config.Logger.Printf("Created ECS task definition [%s:%d]", aws.StringValue(td.Family), aws.Int64Value(td.Revision))
-
-	return aws.StringValue(td.TaskDefinitionArn), nil
 }
@@ -8,9 +8,20 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/backup"
 	"github.com/aws/aws-sdk-go/service/efs"
 )
 
+// The vault of the automatic backups of EFS file systems, managed by AWS Backup
+const efsAutomaticBackupVault = "aws/efs/automatic-backup-vault"
+
+// Tags of the user's file system: the job restoring a backup into a new file system, and the
+// restored file system which replaces it
+const (
+	efsRestoreJobTag        = "gen3-restore-job-id"
+	efsCurrentFileSystemTag = "gen3-current-file-system-id"
+)
+
 type EFS struct {
 	EFSArn        string
 	FileSystemId  string
@@ -69,7 +80,8 @@ func (creds *CREDS) createAccessPoint(FileSystemId string, userName string, svc
 	if len(exResult.AccessPoints) == 0 {
 		input := &efs.CreateAccessPointInput{
 			// A string of up to 64 ASCII characters that Amazon EFS uses to ensure idempotent creation.
-			ClientToken:  aws.String(ap),
+			// Restored file systems get their own access point
+			ClientToken:  aws.String(truncateString(FileSystemId+"-"+ap, 64)),
 			FileSystemId: aws.String(FileSystemId),
 			PosixUser: &efs.PosixUser{
 				Gid: aws.Int64(100),
@@ -111,7 +123,7 @@ func (creds *CREDS) EFSFileSystem(userName string) (*EFS, error) {
 	}
 	if exisitingFS == nil {
 		input := &efs.CreateFileSystemInput{
-			Backup:          aws.Bool(false),
+			Backup:          aws.Bool(efsAutomaticBackups()),
 			CreationToken:   aws.String(fsName),
 			Encrypted:       aws.Bool(true),
 			PerformanceMode: aws.String("generalPurpose"),
@@ -134,7 +146,7 @@ func (creds *CREDS) EFSFileSystem(userName string) (*EFS, error) {
 
 		exisitingFS, _ = creds.getEFSFileSystem(userName, svc)
 		for *exisitingFS.FileSystems[0].LifeCycleState != "available" {
-			Config.Logger.Printf("EFS filesystem is in state: %s ...  Waiting for 2 seconds", *exisitingFS.FileSystems[0].LifeCycleState)
+			Config().Logger.Printf("EFS filesystem is in state: %s ...  Waiting for 2 seconds", *exisitingFS.FileSystems[0].LifeCycleState)
 			// sleep for 2 sec
 			time.Sleep(2 * time.Second)
 			exisitingFS, _ = creds.getEFSFileSystem(userName, svc)
@@ -145,12 +157,12 @@ func (creds *CREDS) EFSFileSystem(userName string) (*EFS, error) {
 		if err != nil {
 			return nil, fmt.Errorf("Failed to create EFS MountTarget: %s", err)
 		}
-		Config.Logger.Printf("MountTarget created: %s", *mountTarget.MountTargetId)
+		Config().Logger.Printf("MountTarget created: %s", *mountTarget.MountTargetId)
 		accessPoint, err := creds.createAccessPoint(*result.FileSystemId, userName, svc)
 		if err != nil {
 			return nil, fmt.Errorf("Failed to create EFS AccessPoint: %s", err)
 		}
-		Config.Logger.Printf("AccessPoint created: %s", *accessPoint)
+		Config().Logger.Printf("AccessPoint created: %s", *accessPoint)
 
 		return &EFS{
 			EFSArn:        *result.FileSystemArn,
@@ -158,6 +170,19 @@ func (creds *CREDS) EFSFileSystem(userName string) (*EFS, error) {
 			AccessPointId: *accessPoint,
 		}, nil
 	} else {
+		exisitingFS.FileSystems[0], err = creds.currentEFSFileSystem(svc, exisitingFS.FileSystems[0])
+		if err != nil {
+			return nil, err
+		}
+		if efsAutomaticBackups() {
+			_, err = svc.PutBackupPolicy(&efs.PutBackupPolicyInput{
+				FileSystemId: exisitingFS.FileSystems[0].FileSystemId,
+				BackupPolicy: &efs.BackupPolicy{Status: aws.String(efs.StatusEnabled)},
+			})
+			if err != nil {
+				Config().Logger.Printf("Failed to enable the automatic backups of EFS filesystem %s: %s", *exisitingFS.FileSystems[0].FileSystemId, err)
+			}
+		}
 		// create accesspoint if it doesn't exist
 		accessPoint, err := svc.DescribeAccessPoints(&efs.DescribeAccessPointsInput{
 			FileSystemId: exisitingFS.FileSystems[0].FileSystemId,
@@ -187,7 +212,7 @@ func (creds *CREDS) EFSFileSystem(userName string) (*EFS, error) {
 			if err != nil {
 				return nil, fmt.Errorf("Failed to create EFS MountTarget: %s", err)
 			}
-			Config.Logger.Printf("MountTarget created: %s", *mountTarget.MountTargetId)
+			Config().Logger.Printf("MountTarget created: %s", *mountTarget.MountTargetId)
 		}
 
 		return &EFS{
@@ -197,3 +222,284 @@ func (creds *CREDS) EFSFileSystem(userName string) (*EFS, error) {
 		}, nil
 	}
 }
+
+// efsAutomaticBackups returns true if the EFS file systems are backed up daily by AWS Backup,
+// when scheduled snapshots are configured
+func efsAutomaticBackups() bool {
+	snapshots := Config().Config.Snapshots
+	return snapshots.Enabled && snapshots.ScheduleIntervalHours > 0
+}
+
+func (creds *CREDS) efsService() *efs.EFS {
+	return efs.New(session.Must(session.NewSession(&aws.Config{
+		Credentials: creds.creds,
+		// TODO: Make this configurable
+		Region: aws.String("us-east-1"),
+	})))
+}
+
+func (creds *CREDS) backupService() *backup.Backup {
+	return backup.New(session.Must(session.NewSession(&aws.Config{
+		Credentials: creds.creds,
+		// TODO: Make this configurable
+		Region: aws.String("us-east-1"),
+	})))
+}
+
+func efsTag(fileSystem *efs.FileSystemDescription, key string) string {
+	for _, tag := range fileSystem.Tags {
+		if aws.StringValue(tag.Key) == key {
+			return aws.StringValue(tag.Value)
+		}
+	}
+	return ""
+}
+
+// currentEFSFileSystem returns the file system the user's workspaces use: the user's file system,
+// or the last file system a backup was restored into. A completed restore replaces the file
+// system from the next launch, and the replaced file system is kept
+func (creds *CREDS) currentEFSFileSystem(svc *efs.EFS, fileSystem *efs.FileSystemDescription) (*efs.FileSystemDescription, error) {
+	if restoreJobId := efsTag(fileSystem, efsRestoreJobTag); restoreJobId != "" {
+		restoreJob, err := creds.backupService().DescribeRestoreJob(&backup.DescribeRestoreJobInput{RestoreJobId: aws.String(restoreJobId)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe restore job %s: %s", restoreJobId, err)
+		}
+		switch aws.StringValue(restoreJob.Status) {
+		case backup.RestoreJobStatusCompleted:
+			// arn:aws:elasticfilesystem:<region>:<account>:file-system/<id>
+			restoredArn := aws.StringValue(restoreJob.CreatedResourceArn)
+			restoredId := restoredArn[strings.LastIndex(restoredArn, "/")+1:]
+			_, err = svc.TagResource(&efs.TagResourceInput{
+				ResourceId: fileSystem.FileSystemId,
+				Tags:       []*efs.Tag{{Key: aws.String(efsCurrentFileSystemTag), Value: aws.String(restoredId)}},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to tag EFS filesystem: %s", err)
+			}
+			Config().Logger.Printf("EFS filesystem %s is replaced by restored filesystem %s", *fileSystem.FileSystemId, restoredId)
+			fileSystem.Tags = append(fileSystem.Tags, &efs.Tag{Key: aws.String(efsCurrentFileSystemTag), Value: aws.String(restoredId)})
+		case backup.RestoreJobStatusAborted, backup.RestoreJobStatusFailed:
+			Config().Logger.Printf("Restore job %s of EFS filesystem %s did not complete: %s", restoreJobId, *fileSystem.FileSystemId, aws.StringValue(restoreJob.StatusMessage))
+		default:
+			return nil, fmt.Errorf("the restore of your file system is in progress (%s%% done)", aws.StringValue(restoreJob.PercentDone))
+		}
+		_, err = svc.UntagResource(&efs.UntagResourceInput{
+			ResourceId: fileSystem.FileSystemId,
+			TagKeys:    []*string{aws.String(efsRestoreJobTag)},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to untag EFS filesystem: %s", err)
+		}
+	}
+
+	currentId := efsTag(fileSystem, efsCurrentFileSystemTag)
+	if currentId == "" || currentId == *fileSystem.FileSystemId {
+		return fileSystem, nil
+	}
+	result, err := svc.DescribeFileSystems(&efs.DescribeFileSystemsInput{FileSystemId: aws.String(currentId)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe restored EFS filesystem %s: %s", currentId, err)
+	}
+	return result.FileSystems[0], nil
+}
+
+// userEFSFileSystems returns the user's file system, and the file system it was replaced by
+// if a backup was restored
+func (creds *CREDS) userEFSFileSystems(userName string) ([]*efs.FileSystemDescription, error) {
+	svc := creds.efsService()
+	exisitingFS, err := creds.getEFSFileSystem(userName, svc)
+	if err != nil {
+		return nil, err
+	}
+	if exisitingFS == nil {
+		return nil, errUserVolumeNotFound
+	}
+	fileSystems := []*efs.FileSystemDescription{exisitingFS.FileSystems[0]}
+	if currentId := efsTag(exisitingFS.FileSystems[0], efsCurrentFileSystemTag); currentId != "" && currentId != *exisitingFS.FileSystems[0].FileSystemId {
+		current, err := svc.DescribeFileSystems(&efs.DescribeFileSystemsInput{FileSystemId: aws.String(currentId)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe restored EFS filesystem %s: %s", currentId, err)
+		}
+		fileSystems = append(fileSystems, current.FileSystems[0])
+	}
+	return fileSystems, nil
+}
+
+// listEFSBackups returns the backups of the user's file systems, from the oldest. The automatic
+// backups are the scheduled ones
+func (creds *CREDS) listEFSBackups(userName string) ([]UserVolumeSnapshot, error) {
+	fileSystems, err := creds.userEFSFileSystems(userName)
+	if err == errUserVolumeNotFound {
+		return []UserVolumeSnapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	svc := creds.backupService()
+	result := []UserVolumeSnapshot{}
+	for _, fileSystem := range fileSystems {
+		err := svc.ListRecoveryPointsByResourcePages(&backup.ListRecoveryPointsByResourceInput{
+			ResourceArn: fileSystem.FileSystemArn,
+		}, func(page *backup.ListRecoveryPointsByResourceOutput, lastPage bool) bool {
+			for _, recoveryPoint := range page.RecoveryPoints {
+				snapshot := UserVolumeSnapshot{
+					Name:         aws.StringValue(recoveryPoint.RecoveryPointArn),
+					Type:         snapshotTypeOnDemand,
+					CreationTime: aws.TimeValue(recoveryPoint.CreationDate).UTC(),
+					ReadyToUse:   aws.StringValue(recoveryPoint.Status) == backup.RecoveryPointStatusCompleted,
+					Error:        aws.StringValue(recoveryPoint.StatusMessage),
+				}
+				if aws.StringValue(recoveryPoint.BackupVaultName) == efsAutomaticBackupVault {
+					snapshot.Type = snapshotTypeScheduled
+				}
+				if recoveryPoint.BackupSizeBytes != nil {
+					snapshot.RestoreSize = fmt.Sprintf("%d", *recoveryPoint.BackupSizeBytes)
+				}
+				result = append(result, snapshot)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the backups of EFS filesystem %s: %s", *fileSystem.FileSystemId, err)
+		}
+	}
+	sortVolumeSnapshots(result)
+	return result, nil
+}
+
+// backupEFSFileSystem starts an on-demand backup of the file system the user's workspaces use
+func (creds *CREDS) backupEFSFileSystem(userName string, awsAccountId string) (*UserVolumeSnapshot, error) {
+	backups, err := creds.listEFSBackups(userName)
+	if err != nil {
+		return nil, err
+	}
+	count := 0
+	for _, snapshot := range backups {
+		if snapshot.Type == snapshotTypeOnDemand {
+			count++
+		}
+	}
+	if max := Config().Config.Snapshots.MaxSnapshots; count >= max {
+		return nil, fmt.Errorf("the file system already has %d snapshots: delete one before taking a new one", max)
+	}
+	fileSystems, err := creds.userEFSFileSystems(userName)
+	if err != nil {
+		return nil, err
+	}
+	svc := creds.efsService()
+	fileSystem, err := creds.currentEFSFileSystem(svc, fileSystems[0])
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotConfig := Config().Config.Snapshots
+	result, err := creds.backupService().StartBackupJob(&backup.StartBackupJobInput{
+		BackupVaultName: aws.String(snapshotConfig.EfsBackupVault),
+		IamRoleArn:      aws.String("arn:aws:iam::" + awsAccountId + ":role/" + snapshotConfig.EfsBackupRoleName),
+		ResourceArn:     fileSystem.FileSystemArn,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the backup of EFS filesystem %s: %s", *fileSystem.FileSystemId, err)
+	}
+	Config().Logger.Printf("Started backup job %s of EFS filesystem %s for user %s", aws.StringValue(result.BackupJobId), *fileSystem.FileSystemId, userName)
+	return &UserVolumeSnapshot{
+		Name:         aws.StringValue(result.RecoveryPointArn),
+		Type:         snapshotTypeOnDemand,
+		CreationTime: aws.TimeValue(result.CreationDate).UTC(),
+	}, nil
+}
+
+// findEFSBackup returns the vault of the backup, and the file system of the user it is a backup of
+func (creds *CREDS) findEFSBackup(userName string, recoveryPointArn string) (string, *efs.FileSystemDescription, error) {
+	fileSystems, err := creds.userEFSFileSystems(userName)
+	if err == errUserVolumeNotFound {
+		return "", nil, errSnapshotNotFound
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	svc := creds.backupService()
+	for _, fileSystem := range fileSystems {
+		vault := ""
+		err := svc.ListRecoveryPointsByResourcePages(&backup.ListRecoveryPointsByResourceInput{
+			ResourceArn: fileSystem.FileSystemArn,
+		}, func(page *backup.ListRecoveryPointsByResourceOutput, lastPage bool) bool {
+			for _, recoveryPoint := range page.RecoveryPoints {
+				if aws.StringValue(recoveryPoint.RecoveryPointArn) == recoveryPointArn {
+					vault = aws.StringValue(recoveryPoint.BackupVaultName)
+					return false
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to list the backups of EFS filesystem %s: %s", *fileSystem.FileSystemId, err)
+		}
+		if vault != "" {
+			return vault, fileSystem, nil
+		}
+	}
+	return "", nil, errSnapshotNotFound
+}
+
+func (creds *CREDS) deleteEFSBackup(userName string, recoveryPointArn string) error {
+	vault, _, err := creds.findEFSBackup(userName, recoveryPointArn)
+	if err != nil {
+		return err
+	}
+	_, err = creds.backupService().DeleteRecoveryPoint(&backup.DeleteRecoveryPointInput{
+		BackupVaultName:  aws.String(vault),
+		RecoveryPointArn: aws.String(recoveryPointArn),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete backup %s: %s", recoveryPointArn, err)
+	}
+	Config().Logger.Printf("Deleted backup %s for user %s", recoveryPointArn, userName)
+	return nil
+}
+
+// restoreEFSBackup restores the backup into a new file system, which the user's workspaces
+// use from the next launch after the restore completes
+func (creds *CREDS) restoreEFSBackup(userName string, awsAccountId string, recoveryPointArn string) error {
+	_, source, err := creds.findEFSBackup(userName, recoveryPointArn)
+	if err != nil {
+		return err
+	}
+	fileSystems, err := creds.userEFSFileSystems(userName)
+	if err != nil {
+		return err
+	}
+	// replaces the file system with the last completed restore, or fails while a restore is in progress
+	svc := creds.efsService()
+	if _, err := creds.currentEFSFileSystem(svc, fileSystems[0]); err != nil {
+		return err
+	}
+
+	fsName := strings.ReplaceAll(os.Getenv("GEN3_ENDPOINT"), ".", "-") + userToResourceName(userName, "pod") + "fs"
+	restoredName := truncateString(fsName, 49) + "-" + time.Now().UTC().Format("20060102150405")
+	snapshotConfig := Config().Config.Snapshots
+	result, err := creds.backupService().StartRestoreJob(&backup.StartRestoreJobInput{
+		RecoveryPointArn: aws.String(recoveryPointArn),
+		IamRoleArn:       aws.String("arn:aws:iam::" + awsAccountId + ":role/" + snapshotConfig.EfsBackupRoleName),
+		ResourceType:     aws.String("EFS"),
+		Metadata: map[string]*string{
+			"file-system-id":  source.FileSystemId,
+			"newFileSystem":   aws.String("true"),
+			"CreationToken":   aws.String(restoredName),
+			"Encrypted":       aws.String("true"),
+			"PerformanceMode": aws.String("generalPurpose"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start the restore of backup %s: %s", recoveryPointArn, err)
+	}
+	_, err = svc.TagResource(&efs.TagResourceInput{
+		ResourceId: fileSystems[0].FileSystemId,
+		Tags:       []*efs.Tag{{Key: aws.String(efsRestoreJobTag), Value: result.RestoreJobId}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to tag EFS filesystem: %s", err)
+	}
+	Config().Logger.Printf("Started restore job %s of backup %s for user %s", aws.StringValue(result.RestoreJobId), recoveryPointArn, userName)
+	return nil
+}
@@ -28,6 +28,7 @@ type Gen3LicenseUserMap struct {
 	LicenseType        string `json:"licenseType"`
 	IsActive           string `json:"isActive"`
 	UserId             string `json:"userId"`
+	WorkspaceId        string `json:"workspaceId,omitempty"`
 	LicenseId          int    `json:"licenseId"`
 	FirstUsedTimestamp int    `json:"firstUsedTimestamp"`
 	LastUsedTimestamp  int    `json:"lastUsedTimestamp"`
@@ -52,31 +53,31 @@ var validateContainerLicenseInfo = func(containerName string, licenseInfo Licens
 	var ok = true
 	// print any items that are missing from LicenseInfo
 	if !licenseInfo.Enabled {
-		Config.Logger.Printf("Warning: License is not enabled for container %s\n", containerName)
+		Config().Logger.Printf("Warning: License is not enabled for container %s\n", containerName)
 		ok = false
 	}
 	if licenseInfo.LicenseType == "" {
-		Config.Logger.Printf("Error in container config. Empty LicenseType for container %s\n", containerName)
+		Config().Logger.Printf("Error in container config. Empty LicenseType for container %s\n", containerName)
 		ok = false
 	}
 	if licenseInfo.MaxLicenseIds == 0 {
-		Config.Logger.Printf("Error in container config. Empty or 0 MaxLicenseIds for container %s\n", containerName)
+		Config().Logger.Printf("Error in container config. Empty or 0 MaxLicenseIds for container %s\n", containerName)
 		ok = false
 	}
 	if licenseInfo.G3autoName == "" {
-		Config.Logger.Printf("Error in container config. Empty G3autoName for container %s\n", containerName)
+		Config().Logger.Printf("Error in container config. Empty G3autoName for container %s\n", containerName)
 		ok = false
 	}
 	if licenseInfo.G3autoKey == "" {
-		Config.Logger.Printf("Error in container config. Empty G3autoKey for container %s\n", containerName)
+		Config().Logger.Printf("Error in container config. Empty G3autoKey for container %s\n", containerName)
 		ok = false
 	}
 	if licenseInfo.FilePath == "" {
-		Config.Logger.Printf("Error in container config. Empty FilePath for container %s\n", containerName)
+		Config().Logger.Printf("Error in container config. Empty FilePath for container %s\n", containerName)
 		ok = false
 	}
 	if licenseInfo.WorkspaceFlavor == "" {
-		Config.Logger.Printf("Error in container config. Empty WorkspaceFlavor for container %s\n", containerName)
+		Config().Logger.Printf("Error in container config. Empty WorkspaceFlavor for container %s\n", containerName)
 		ok = false
 	}
 	if ok {
@@ -88,7 +89,9 @@ var validateContainerLicenseInfo = func(containerName string, licenseInfo Licens
 
 func getItemsFromQuery(dbconfig *DbConfig, queryInput *dynamodb.QueryInput) ([]map[string]*dynamodb.AttributeValue, error) {
 	// Get items from a db query
+	start := time.Now()
 	queryOutput, err := dbconfig.DynamoDb.Query(queryInput)
+	observeExternalRequest("dynamodb", "query_license_user_maps", start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -96,7 +99,9 @@ func getItemsFromQuery(dbconfig *DbConfig, queryInput *dynamodb.QueryInput) ([]m
 	// If the query result is paginated then get the rest of the items
 	for queryOutput.LastEvaluatedKey != nil {
 		queryInput.ExclusiveStartKey = queryOutput.LastEvaluatedKey
+		start = time.Now()
 		queryOutput, err = dbconfig.DynamoDb.Query(queryInput)
+		observeExternalRequest("dynamodb", "query_license_user_maps", start, err)
 		if err != nil {
 			return nil, err
 		}
@@ -112,11 +117,11 @@ var getActiveGen3LicenseUserMaps = func(dbconfig *DbConfig, container Container)
 	targetEnvironment := os.Getenv("GEN3_ENDPOINT")
 	err = validateContainerLicenseInfo(container.Name, container.License)
 	if err != nil {
-		Config.Logger.Printf("Gen3License table info for container is not configured or is misconfigured.")
+		Config().Logger.Printf("Gen3License table info for container is not configured or is misconfigured.")
 		return emptyList, nil
 	}
-	if Config.Config.LicenseUserMapsTable == "" || Config.Config.LicenseUserMapsGSI == "" {
-		Config.Logger.Printf("Gen3License table info is not configured.")
+	if Config().Config.LicenseUserMapsTable == "" || Config().Config.LicenseUserMapsGSI == "" {
+		Config().Logger.Printf("Gen3License table info is not configured.")
 		return emptyList, nil
 	}
 
@@ -126,12 +131,12 @@ var getActiveGen3LicenseUserMaps = func(dbconfig *DbConfig, container Container)
 	filt := expression.Name("licenseType").Equal(expression.Value(container.License.LicenseType))
 	expr, err := expression.NewBuilder().WithKeyCondition(expression.KeyAnd(keyEx1, keyEx2)).WithFilter(filt).Build()
 	if err != nil {
-		Config.Logger.Printf("Error in building expression for query: %s", err)
+		Config().Logger.Printf("Error in building expression for query: %s", err)
 		return emptyList, err
 	}
 	queryUserMapsInput := &dynamodb.QueryInput{
-		TableName:                 aws.String(Config.Config.LicenseUserMapsTable),
-		IndexName:                 aws.String(Config.Config.LicenseUserMapsGSI),
+		TableName:                 aws.String(Config().Config.LicenseUserMapsTable),
+		IndexName:                 aws.String(Config().Config.LicenseUserMapsGSI),
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
 		KeyConditionExpression:    expr.KeyCondition(),
@@ -139,7 +144,7 @@ var getActiveGen3LicenseUserMaps = func(dbconfig *DbConfig, container Container)
 	}
 	licenseUserMapItems, err := getItemsFromQuery(dbconfig, queryUserMapsInput)
 	if err != nil {
-		Config.Logger.Printf("Error in active user query: %s", err)
+		Config().Logger.Printf("Error in active user query: %s", err)
 		return emptyList, err
 	}
 
@@ -147,10 +152,10 @@ var getActiveGen3LicenseUserMaps = func(dbconfig *DbConfig, container Container)
 	var gen3LicenseUsers []Gen3LicenseUserMap
 	err = dynamodbattribute.UnmarshalListOfMaps(licenseUserMapItems, &gen3LicenseUsers)
 	if err != nil {
-		Config.Logger.Printf("Error in unmarshalling active gen3 license user maps: %s", err)
+		Config().Logger.Printf("Error in unmarshalling active gen3 license user maps: %s", err)
 		return emptyList, err
 	}
-	Config.Logger.Printf("Debug: active gen3 license user maps %v", gen3LicenseUsers)
+	Config().Logger.Printf("Debug: active gen3 license user maps %v", gen3LicenseUsers)
 	return gen3LicenseUsers, nil
 }
 
@@ -159,8 +164,8 @@ var getLicenseUserMapsForUser = func(dbconfig *DbConfig, userId string) (gen3Lic
 	emptyList := []Gen3LicenseUserMap{}
 
 	targetEnvironment := os.Getenv("GEN3_ENDPOINT")
-	if Config.Config.LicenseUserMapsTable == "" || Config.Config.LicenseUserMapsGSI == "" {
-		Config.Logger.Printf("Gen3License table info is not configured.")
+	if Config().Config.LicenseUserMapsTable == "" || Config().Config.LicenseUserMapsGSI == "" {
+		Config().Logger.Printf("Gen3License table info is not configured.")
 		return emptyList, nil
 	}
 
@@ -170,12 +175,12 @@ var getLicenseUserMapsForUser = func(dbconfig *DbConfig, userId string) (gen3Lic
 	filt := expression.Name("userId").Equal(expression.Value(userId))
 	expr, err := expression.NewBuilder().WithKeyCondition(expression.KeyAnd(keyEx1, keyEx2)).WithFilter(filt).Build()
 	if err != nil {
-		Config.Logger.Printf("Error in building expression for query: %s", err)
+		Config().Logger.Printf("Error in building expression for query: %s", err)
 		return emptyList, err
 	}
 	queryUserMapsInput := &dynamodb.QueryInput{
-		TableName:                 aws.String(Config.Config.LicenseUserMapsTable),
-		IndexName:                 aws.String(Config.Config.LicenseUserMapsGSI),
+		TableName:                 aws.String(Config().Config.LicenseUserMapsTable),
+		IndexName:                 aws.String(Config().Config.LicenseUserMapsGSI),
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
 		KeyConditionExpression:    expr.KeyCondition(),
@@ -183,7 +188,7 @@ var getLicenseUserMapsForUser = func(dbconfig *DbConfig, userId string) (gen3Lic
 	}
 	licenseUserMapItems, err := getItemsFromQuery(dbconfig, queryUserMapsInput)
 	if err != nil {
-		Config.Logger.Printf("Error in items for user query: %s", err)
+		Config().Logger.Printf("Error in items for user query: %s", err)
 		return emptyList, err
 	}
 
@@ -191,10 +196,10 @@ var getLicenseUserMapsForUser = func(dbconfig *DbConfig, userId string) (gen3Lic
 	var gen3LicenseUsers []Gen3LicenseUserMap
 	err = dynamodbattribute.UnmarshalListOfMaps(licenseUserMapItems, &gen3LicenseUsers)
 	if err != nil {
-		Config.Logger.Printf("Error in unmarshalling gen3 license user maps for user: %s", err)
+		Config().Logger.Printf("Error in unmarshalling gen3 license user maps for user: %s", err)
 		return emptyList, err
 	}
-	Config.Logger.Printf("Debug: gen3 license user maps for user %v", gen3LicenseUsers)
+	Config().Logger.Printf("Debug: gen3 license user maps for user %v", gen3LicenseUsers)
 	return gen3LicenseUsers, nil
 }
 
@@ -213,7 +218,7 @@ func getNextLicenseId(activeGen3LicenseUserMaps []Gen3LicenseUserMap, maxLicense
 			}
 		}
 		if !idInUsedIds {
-			Config.Logger.Printf("Next available license id: %d", i)
+			Config().Logger.Printf("Next available license id: %d", i)
 			return i
 		}
 	}
@@ -221,7 +226,7 @@ func getNextLicenseId(activeGen3LicenseUserMaps []Gen3LicenseUserMap, maxLicense
 	return 0
 }
 
-var createGen3LicenseUserMap = func(dbconfig *DbConfig, userId string, licenseId int, container Container) (gen3LicenseUserMap Gen3LicenseUserMap, err error) {
+var createGen3LicenseUserMap = func(dbconfig *DbConfig, userId string, workspaceId string, licenseId int, container Container) (gen3LicenseUserMap Gen3LicenseUserMap, err error) {
 	// Create a new user-license object and put in table
 
 	targetEnvironment := os.Getenv("GEN3_ENDPOINT")
@@ -236,6 +241,7 @@ var createGen3LicenseUserMap = func(dbconfig *DbConfig, userId string, licenseId
 	newItem.ItemId = itemId
 	newItem.Environment = targetEnvironment
 	newItem.UserId = userId
+	newItem.WorkspaceId = workspaceId
 	newItem.LicenseId = licenseId
 	newItem.IsActive = "True"
 	newItem.FirstUsedTimestamp = currentUnixTime
@@ -244,16 +250,18 @@ var createGen3LicenseUserMap = func(dbconfig *DbConfig, userId string, licenseId
 	// marshall Gen3LicenseUserMap into dynamodb item
 	item, err := dynamodbattribute.MarshalMap(newItem)
 	if err != nil {
-		Config.Logger.Printf("Error: could not marshal new item: %s", err)
+		Config().Logger.Printf("Error: could not marshal new item: %s", err)
 		return newItem, err
 	}
 	// put item
+	start := time.Now()
 	_, err = dbconfig.DynamoDb.PutItem(&dynamodb.PutItemInput{
-		TableName: aws.String(Config.Config.LicenseUserMapsTable),
+		TableName: aws.String(Config().Config.LicenseUserMapsTable),
 		Item:      item,
 	})
+	observeExternalRequest("dynamodb", "put_license_user_map", start, err)
 	if err != nil {
-		Config.Logger.Printf("Error: could not add item to table: %s", err)
+		Config().Logger.Printf("Error: could not add item to table: %s", err)
 		return newItem, err
 	}
 	// Return the new gen3-user-license item that we created; DynamoDB:putItem does not return new items.
@@ -278,7 +286,7 @@ var setGen3LicenseUserInactive = func(dbconfig *DbConfig, itemId string) (Gen3Li
 				N: aws.String(strconv.Itoa(currentUnixTime)),
 			},
 		},
-		TableName: aws.String(Config.Config.LicenseUserMapsTable),
+		TableName: aws.String(Config().Config.LicenseUserMapsTable),
 		// Use the composite primary key: itemId, environment
 		Key: map[string]*dynamodb.AttributeValue{
 			"itemId": {
@@ -294,16 +302,18 @@ var setGen3LicenseUserInactive = func(dbconfig *DbConfig, itemId string) (Gen3Li
 		UpdateExpression: aws.String("set isActive = :active, lastUsedTimestamp = :currentTime"),
 	}
 
+	start := time.Now()
 	res, err := dbconfig.DynamoDb.UpdateItem(input)
+	observeExternalRequest("dynamodb", "update_license_user_map", start, err)
 	if err != nil {
-		Config.Logger.Printf("Error: could not update item in table: %s", err)
+		Config().Logger.Printf("Error: could not update item in table: %s", err)
 		return Gen3LicenseUserMap{}, err
 	}
 
 	var updatedItem Gen3LicenseUserMap
 	err = dynamodbattribute.UnmarshalMap(res.Attributes, &updatedItem)
 	if err != nil {
-		Config.Logger.Printf("Error: could not unmarshal updated item: %s", err)
+		Config().Logger.Printf("Error: could not unmarshal updated item: %s", err)
 		return Gen3LicenseUserMap{}, err
 	}
 
@@ -316,7 +326,7 @@ func getLicenseFilePathConfigs() ([]LicenseInfo, error) {
 	var config LicenseInfo
 	var filePathConfigs []LicenseInfo
 
-	for _, v := range Config.ContainersMap {
+	for _, v := range Config().ContainersMap {
 		if v.License.Enabled {
 			err := validateContainerLicenseInfo(v.Name, v.License)
 			if err != nil {
@@ -348,24 +358,24 @@ var getKubeClientSet = func() (clientset kubernetes.Interface, err error) {
 		// out of cluster, eg local
 		config, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
 		if err != nil {
-			Config.Logger.Printf("Error: Could not build config for out of cluster client, %s", err)
+			Config().Logger.Printf("Error: Could not build config for out of cluster client, %s", err)
 			return nil, err
 		}
 		clientset, err = kubernetes.NewForConfig(config)
 		if err != nil {
-			Config.Logger.Printf("Error: Could not create clientset for out of cluster client, %s", err)
+			Config().Logger.Printf("Error: Could not create clientset for out of cluster client, %s", err)
 			return nil, err
 		}
 	} else {
 		// in cluster
 		config, err := rest.InClusterConfig()
 		if err != nil {
-			Config.Logger.Printf("Error: Could not build config for in cluster client, %s", err)
+			Config().Logger.Printf("Error: Could not build config for in cluster client, %s", err)
 			return nil, err
 		}
 		clientset, err = kubernetes.NewForConfig(config)
 		if err != nil {
-			Config.Logger.Printf("Error: Could not create clientset for in cluster client, %s", err)
+			Config().Logger.Printf("Error: Could not create clientset for in cluster client, %s", err)
 			return nil, err
 		}
 	}
@@ -374,42 +384,42 @@ var getKubeClientSet = func() (clientset kubernetes.Interface, err error) {
 
 }
 
-var getLicenseString = func(Config *FullHatcheryConfig, hash string) (string, error) {
+var getLicenseString = func(hatchConfig *FullHatcheryConfig, hash string) (string, error) {
 	// get the file_path and fileId from config
-	containersMap, ok := Config.ContainersMap[hash]
+	containersMap, ok := hatchConfig.ContainersMap[hash]
 	if !ok {
-		Config.Logger.Printf("unable to find hash in Config: %v", hash)
+		hatchConfig.Logger.Printf("unable to find hash in Config: %v", hash)
 		return "", errors.New("unable to find hash in Config:" + hash)
 	}
 	file_path := containersMap.License.FilePath
 
 	filePathConfigs, err := getLicenseFilePathConfigs()
 	if err != nil {
-		Config.Logger.Printf("unable to get filepaths from config: %v", err)
+		hatchConfig.Logger.Printf("unable to get filepaths from config: %v", err)
 		return "", err
 	}
 	g3autoName, g3autoKey, ok := getG3autoInfoForFilepath(file_path, filePathConfigs)
 	if !ok {
-		Config.Logger.Printf("could not get g3auto name and key for file-path '%s'", file_path)
+		hatchConfig.Logger.Printf("could not get g3auto name and key for file-path '%s'", file_path)
 		return "", err
 	}
 	// get license data from kubernetes secret
 	clientset, err := getKubeClientSet()
 	if err != nil {
-		Config.Logger.Printf("unable to get kube client set: %v", err)
+		hatchConfig.Logger.Printf("unable to get kube client set: %v", err)
 		return "", err
 	}
 	licenseValue, err := getLicenseFromKubernetes(clientset, g3autoName, g3autoKey)
 	if err != nil {
-		Config.Logger.Printf("unable to get license from kubernetes: %v", err)
+		hatchConfig.Logger.Printf("unable to get license from kubernetes: %v", err)
 		return "", err
 	}
 	licenseData := map[string]string{
-		Config.ContainersMap[hash].License.G3autoKey: licenseValue,
+		hatchConfig.ContainersMap[hash].License.G3autoKey: licenseValue,
 	}
 	licenseString, err := json.Marshal(licenseData)
 	if err != nil {
-		Config.Logger.Printf("unable to marshall license: %v", err)
+		hatchConfig.Logger.Printf("unable to marshall license: %v", err)
 		return "", err
 	}
 
@@ -421,10 +431,10 @@ var getLicenseFromKubernetes = func(clientset kubernetes.Interface, g3autoName s
 	var namespace string
 	var ok bool
 
-	if namespace, ok = Config.Config.Sidecar.Env["NAMESPACE"]; ok {
-		Config.Logger.Printf("Searching configured namespace for g3auto secret: %s", namespace)
+	if namespace, ok = Config().Config.Sidecar.Env["NAMESPACE"]; ok {
+		Config().Logger.Printf("Searching configured namespace for g3auto secret: %s", namespace)
 	} else {
-		Config.Logger.Printf("Error: namespace is not configured. Will try 'default'")
+		Config().Logger.Printf("Error: namespace is not configured. Will try 'default'")
 		namespace = "default"
 	}
 
@@ -432,7 +442,7 @@ var getLicenseFromKubernetes = func(clientset kubernetes.Interface, g3autoName s
 	secretsClient := clientset.CoreV1().Secrets(namespace)
 	secret, err := secretsClient.Get(context.TODO(), g3autoName, metaV1.GetOptions{})
 	if err != nil {
-		Config.Logger.Printf("Error: could not get secret from kubernetes: %s", err)
+		Config().Logger.Printf("Error: could not get secret from kubernetes: %s", err)
 		return "", err
 	}
 	licenseString = string(secret.Data[g3autoKey])
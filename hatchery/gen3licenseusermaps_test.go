@@ -168,8 +168,8 @@ func Test_GetActiveGen3LicenseUserMaps(t *testing.T) {
 		Name:    "container-name",
 		License: licenseInfo,
 	}
-	Config.Config.LicenseUserMapsTable = "test_license_user_maps"
-	Config.Config.LicenseUserMapsGSI = "test_gsi"
+	Config().Config.LicenseUserMapsTable = "test_license_user_maps"
+	Config().Config.LicenseUserMapsGSI = "test_gsi"
 
 	// getActiveGen3LicenseUserMaps
 	for _, testcase := range testCases {
@@ -258,7 +258,7 @@ func Test_CreateGen3LicenseUserMap(t *testing.T) {
 	t.Logf("Testing CreateGen3LicenseUserMap")
 
 	/* Act */
-	newItem, err := createGen3LicenseUserMap(dbconfig, itemId, licenseId, mockContainer)
+	newItem, err := createGen3LicenseUserMap(dbconfig, itemId, "", licenseId, mockContainer)
 	if nil != err {
 		t.Errorf("failed to put item, got: %v", err)
 	}
@@ -723,7 +723,7 @@ func Test_GetLicenseString(t *testing.T) {
 
 	for _, testcase := range testCases {
 		t.Logf("Testing getLicenseString when %s", testcase.name)
-		Config.ContainersMap = testcase.mockContainersMap
+		Config().ContainersMap = testcase.mockContainersMap
 
 		fakeClientset := fake.NewSimpleClientset(kubeSecrets...)
 		getKubeClientSet = func() (clientset kubernetes.Interface, err error) {
@@ -733,7 +733,7 @@ func Test_GetLicenseString(t *testing.T) {
 			return testSecret, nil
 		}
 
-		got, err := getLicenseString(Config, test_hash)
+		got, err := getLicenseString(Config(), test_hash)
 
 		/* Assert */
 		if got != testcase.want {
@@ -12,6 +12,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"text/template"
 	"time"
 
@@ -23,16 +24,44 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-// Config package-global shared hatchery config
-var Config *FullHatcheryConfig
+// activeConfig is the package-global shared hatchery config. It is replaced,
+// never modified, when the config file is reloaded
+var activeConfig atomic.Pointer[FullHatcheryConfig]
+
+// Config returns the active hatchery config. Code that needs a consistent view
+// of the config, e.g. for the whole launch of a workspace, should call it once
+func Config() *FullHatcheryConfig {
+	return activeConfig.Load()
+}
+
+// SetConfig atomically replaces the active hatchery config
+func SetConfig(config *FullHatcheryConfig) {
+	activeConfig.Store(config)
+}
 
 type containerOption struct {
 	Name          string `json:"name"`
 	CPULimit      string `json:"cpu-limit"`
 	MemoryLimit   string `json:"memory-limit"`
 	ID            string `json:"id"`
+	Revision      string `json:"revision"`
 	GPU           bool   `json:"gpu"`
 	IdleTimeLimit int    `json:"idle-time-limit"`
+	// only set for GPU containers
+	GPUCount        int    `json:"gpu-count,omitempty"`
+	GPUResourceName string `json:"gpu-resource-name,omitempty"`
+	GPUModel        string `json:"gpu-model,omitempty"`
+	// the sizes users can choose from when launching the container
+	Sizes            *ContainerSizes `json:"sizes,omitempty"`
+	Description      string          `json:"description,omitempty"`
+	LogoURL          string          `json:"logo-url,omitempty"`
+	Categories       []string        `json:"categories,omitempty"`
+	DocumentationURL string          `json:"documentation-url,omitempty"`
+	Version          string          `json:"version,omitempty"`
+	Deprecated       bool            `json:"deprecated"`
+	Beta             bool            `json:"beta"`
+	// not set if no pricing is configured
+	EstimatedHourlyCost *float64 `json:"estimated-hourly-cost,omitempty"`
 }
 
 type TextOutput struct {
@@ -47,6 +76,19 @@ func RegisterHatchery() {
 	http.HandleFunc("/launch", launch)
 	http.HandleFunc("/terminate", terminate)
 	http.HandleFunc("/status", status)
+	http.HandleFunc("/status/stream", statusStream)
+	http.HandleFunc("/workspaces", workspaces)
+	http.HandleFunc("/storage", storage)
+	http.HandleFunc("/storage/expand", expandStorage)
+	http.HandleFunc("/storage/snapshots", storageSnapshots)
+	http.HandleFunc("/storage/snapshots/delete", deleteStorageSnapshot)
+	http.HandleFunc("/storage/snapshots/restore", restoreStorageSnapshot)
+	http.HandleFunc("/admin/workspaces", requireAdmin(adminWorkspaces))
+	http.HandleFunc("/admin/terminate", requireAdmin(adminTerminate))
+	http.HandleFunc("/admin/terminations", requireAdmin(adminTerminationHistory))
+	http.HandleFunc("/admin/config", requireAdmin(configReloadStatus))
+	http.HandleFunc("/admin/storage/orphaned", requireAdmin(adminOrphanedClaims))
+	http.HandleFunc("/audit", requireAdmin(auditEvents))
 	http.HandleFunc("/options", options)
 	http.HandleFunc("/mount-files", mountFiles)
 	http.HandleFunc("/paymodels", paymodels)
@@ -67,8 +109,8 @@ func home(w http.ResponseWriter, r *http.Request) {
 	<body>`
 	fmt.Fprintln(w, htmlHeader)
 
-	for k, v := range Config.ContainersMap {
-		fmt.Fprintf(w, "<h1><a href=\"%s/launch?hash=%s\">Launch %s - %s CPU - %s Memory</a></h1>", Config.Config.SubDir, k, v.Name, v.CPULimit, v.MemoryLimit)
+	for k, v := range Config().ContainersMap {
+		fmt.Fprintf(w, "<h1><a href=\"%s/launch?hash=%s\">Launch %s - %s CPU - %s Memory</a></h1>", Config().Config.SubDir, k, v.Name, v.CPULimit, v.MemoryLimit)
 	}
 
 	htmlFooter := `</body>
@@ -82,8 +124,10 @@ type PodCostInfo struct {
 	Runtime    string  `json:"runtime"`
 	CPUCores   float64 `json:"cpu_cores"`
 	MemoryGB   float64 `json:"memory_gb"`
+	GPUs       float64 `json:"gpus"`
 	CPUCost    float64 `json:"cpu_cost"`
 	MemoryCost float64 `json:"memory_cost"`
+	GPUCost    float64 `json:"gpu_cost"`
 	TotalCost  float64 `json:"total_cost"`
 }
 
@@ -112,7 +156,7 @@ func allCosts(w http.ResponseWriter, r *http.Request) {
 
 	// Get all pods in user namespace
 	ctx := context.TODO()
-	pods, err := clientset.CoreV1().Pods(Config.Config.UserNamespace).List(ctx, metav1.ListOptions{})
+	pods, err := clientset.CoreV1().Pods(Config().Config.UserNamespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to get pods: %v", err), http.StatusInternalServerError)
 		return
@@ -133,24 +177,31 @@ func allCosts(w http.ResponseWriter, r *http.Request) {
 		runtime := now.Sub(startTime)
 		runtimeHours := runtime.Hours()
 
-		var cpuTotal, memoryTotal float64
-		var cpuCost, memoryCost float64
+		var cpuTotal, memoryTotal, gpuTotal float64
+		var cpuCost, memoryCost, gpuCost float64
+		gpuModel := podGPUModel(&pod)
 
-		// Sum up all container requests
+		// Sum up all container requests, or limits
 		for _, container := range pod.Spec.Containers {
+			billed := billedResources(container.Resources)
 			// CPU
-			if cpuRequest := container.Resources.Requests.Cpu(); cpuRequest != nil {
+			if cpuRequest := billed.Cpu(); cpuRequest != nil {
 				cpuCores := float64(cpuRequest.MilliValue()) / 1000.0
 				cpuTotal += cpuCores
-				cpuCost += cpuCores * Config.Config.Pricing.Cpu * runtimeHours
+				cpuCost += cpuCores * Config().Config.Pricing.Cpu * runtimeHours
 			}
 
 			// Memory
-			if memRequest := container.Resources.Requests.Memory(); memRequest != nil {
+			if memRequest := billed.Memory(); memRequest != nil {
 				memoryGB := float64(memRequest.Value()) / (1024 * 1024 * 1024)
 				memoryTotal += memoryGB
-				memoryCost += memoryGB * Config.Config.Pricing.Memory * runtimeHours
+				memoryCost += memoryGB * Config().Config.Pricing.Memory * runtimeHours
 			}
+
+			// GPUs
+			gpus, gpuHourlyCost := gpuRequestsHourlyCost(billed, gpuModel)
+			gpuTotal += gpus
+			gpuCost += gpuHourlyCost * runtimeHours
 		}
 
 		podCost := PodCostInfo{
@@ -159,9 +210,11 @@ func allCosts(w http.ResponseWriter, r *http.Request) {
 			Runtime:    runtime.String(),
 			CPUCores:   cpuTotal,
 			MemoryGB:   memoryTotal,
+			GPUs:       gpuTotal,
 			CPUCost:    cpuCost,
 			MemoryCost: memoryCost,
-			TotalCost:  cpuCost + memoryCost,
+			GPUCost:    gpuCost,
+			TotalCost:  cpuCost + memoryCost + gpuCost,
 		}
 
 		podCosts = append(podCosts, podCost)
@@ -169,7 +222,7 @@ func allCosts(w http.ResponseWriter, r *http.Request) {
 	}
 
 	summary := CostSummary{
-		Namespace: Config.Config.UserNamespace,
+		Namespace: Config().Config.UserNamespace,
 		TotalPods: len(podCosts),
 		TotalCost: totalCost,
 		Pods:      podCosts,
@@ -199,7 +252,7 @@ func timeTracker(w http.ResponseWriter, r *http.Request) {
 func getCurrentUserName(r *http.Request) (userName string) {
 	user := r.Header.Get("REMOTE_USER")
 	if user == "" {
-		Config.Logger.Print("Warning: No username in header REMOTE_USER!")
+		Config().Logger.Print("Warning: No username in header REMOTE_USER!")
 	}
 
 	// escape username to sanitize input from http header
@@ -209,22 +262,30 @@ func getCurrentUserName(r *http.Request) (userName string) {
 	return user
 }
 
-var getWorkspaceStatus = func(ctx context.Context, userName string, accessToken string) (*WorkspaceStatus, error) {
+var getWorkspaceStatus = func(ctx context.Context, userName string, workspaceId string, accessToken string) (*WorkspaceStatus, error) {
 	allpaymodels, err := getPayModelsForUser(userName)
 	if err != nil {
 		return nil, err
 	}
 
+	var status *WorkspaceStatus
 	if allpaymodels == nil {
-		return statusK8sPod(ctx, userName, accessToken, nil)
-	}
-
-	payModel := allpaymodels.CurrentPayModel
-	if payModel != nil && payModel.Ecs {
-		return statusEcs(ctx, userName, accessToken, payModel.AWSAccountId)
+		status, err = statusK8sPod(ctx, userName, workspaceId, accessToken, nil)
+	} else if payModel := allpaymodels.CurrentPayModel; payModel != nil && payModel.Ecs {
+		if workspaceId != "" {
+			// ECS pay models only support the default workspace
+			return &WorkspaceStatus{Status: "Not Found", WorkspaceType: "ECS"}, nil
+		}
+		status, err = statusEcs(ctx, userName, accessToken, payModel.AWSAccountId)
 	} else {
-		return statusK8sPod(ctx, userName, accessToken, payModel)
+		status, err = statusK8sPod(ctx, userName, workspaceId, accessToken, payModel)
+	}
+	if err == nil {
+		ref := WorkspaceRef{UserName: userName, WorkspaceId: workspaceId}
+		observeWorkspaceStatus(ref, status)
+		recordLastActivityTime(ref, status)
 	}
+	return status, err
 }
 
 func paymodels(w http.ResponseWriter, r *http.Request) {
@@ -287,14 +348,14 @@ func setpaymodel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	currentStatus, err := getWorkspaceStatus(r.Context(), userName, getBearerToken(r))
+	currentStatus, err := getWorkspaceStatus(r.Context(), userName, "", getBearerToken(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Do not let users update status when a workpsace session is in progress
-	if currentStatus.Status != "Not Found" {
+	if currentStatus.Status != "Not Found" || hasOtherWorkspaces(r.Context(), userName, "") {
 		http.Error(w, "Can not update paymodel when workspace is running", http.StatusInternalServerError)
 		return
 	}
@@ -304,6 +365,7 @@ func setpaymodel(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordAuditEvent(AuditEvent{Action: auditActionSetPayModel, UserName: userName, Actor: userName, PayModelId: pm.Id})
 	out, err := json.Marshal(pm)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -315,8 +377,13 @@ func setpaymodel(w http.ResponseWriter, r *http.Request) {
 func status(w http.ResponseWriter, r *http.Request) {
 	userName := getCurrentUserName(r)
 	accessToken := getBearerToken(r)
+	workspaceId, err := getWorkspaceId(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	result, err := getWorkspaceStatus(r.Context(), userName, accessToken)
+	result, err := getWorkspaceStatus(r.Context(), userName, workspaceId, accessToken)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -338,14 +405,14 @@ func resetPaymodels(w http.ResponseWriter, r *http.Request) {
 	}
 	userName := getCurrentUserName(r)
 
-	currentStatus, err := getWorkspaceStatus(r.Context(), userName, getBearerToken(r))
+	currentStatus, err := getWorkspaceStatus(r.Context(), userName, "", getBearerToken(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Do not let users update status when a workpsace session is in progress
-	if currentStatus.Status != "Not Found" {
+	if currentStatus.Status != "Not Found" || hasOtherWorkspaces(r.Context(), userName, "") {
 		http.Error(w, "Can not reset paymodels when workspace is running", http.StatusInternalServerError)
 		return
 	}
@@ -355,17 +422,37 @@ func resetPaymodels(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordAuditEvent(AuditEvent{Action: auditActionResetPayModels, UserName: userName, Actor: userName})
 
 	fmt.Fprint(w, "Current Paymodel has been reset")
 }
 
-func getOptionOutputForContainer(containerId string, containerSettings Container) containerOption {
+func getOptionOutputForContainer(hatchConfig *FullHatcheryConfig, containerId string, containerSettings Container) containerOption {
 	c := containerOption{
 		Name:        containerSettings.Name,
 		CPULimit:    containerSettings.CPULimit,
 		MemoryLimit: containerSettings.MemoryLimit,
 		GPU:         containerSettings.GPU,
 		ID:          containerId,
+		Revision:    hatchConfig.ContainerRevisions[containerId],
+		Sizes:       containerSettings.Sizes,
+
+		Description:      containerSettings.Description,
+		LogoURL:          containerSettings.LogoURL,
+		Categories:       containerSettings.Categories,
+		DocumentationURL: containerSettings.DocumentationURL,
+		Version:          containerVersion(containerSettings),
+		Deprecated:       containerSettings.Deprecated,
+		Beta:             containerSettings.Beta,
+	}
+	if isPricingConfigured() {
+		cost := estimateHourlyCost(containerSettings)
+		c.EstimatedHourlyCost = &cost
+	}
+	if containerSettings.GPU {
+		c.GPUCount = containerGPUCount(&containerSettings)
+		c.GPUResourceName = string(containerGPUResourceName(&containerSettings))
+		c.GPUModel = containerSettings.GPUModel
 	}
 	c.IdleTimeLimit = -1
 	for _, arg := range containerSettings.Args {
@@ -382,29 +469,49 @@ func getOptionOutputForContainer(containerId string, containerSettings Container
 	return c
 }
 
+// containerVersion returns the configured version of the container, or the tag of its image
+func containerVersion(containerSettings Container) string {
+	if containerSettings.Version != "" || containerSettings.Image == "" {
+		return containerSettings.Version
+	}
+	image := containerSettings.Image
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+	// the registry may have a port, e.g. `registry:5000/jupyter`
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return "latest"
+}
+
 func options(w http.ResponseWriter, r *http.Request) {
 	userName := getCurrentUserName(r)
 	accessToken := getBearerToken(r)
+	// the config may be reloaded while the options are listed
+	hatchConfig := Config()
 
 	// handle `/options?id=abc` => return the specified option
-	hash := r.URL.Query().Get("id")
-	if hash != "" {
-		containerSettings, ok := Config.ContainersMap[hash]
-		if !ok {
-			http.Error(w, fmt.Sprintf("Invalid 'id' parameter '%s'", hash), http.StatusBadRequest)
+	requestedId := r.URL.Query().Get("id")
+	if requestedId != "" {
+		// legacy ids resolve to the current id of the container
+		hash, ok := hatchConfig.resolveContainerId(requestedId)
+		containerSettings, found := hatchConfig.ContainersMap[hash]
+		if !ok || !found {
+			http.Error(w, fmt.Sprintf("Invalid 'id' parameter '%s'", requestedId), http.StatusBadRequest)
 			return
 		}
-		allowed, err := isUserAuthorizedForContainer(userName, accessToken, Config.ContainersMap[hash])
+		allowed, err := isUserAuthorizedForContainer(userName, accessToken, containerSettings)
 		if err != nil {
-			Config.Logger.Printf("Unable to check if user is authorized to launch this container. Assuming unthorized. Details: %v", err)
+			hatchConfig.Logger.Printf("Unable to check if user is authorized to launch this container. Assuming unthorized. Details: %v", err)
 		}
 		if err != nil || !allowed {
 			// return the same as for an unknown id
-			http.Error(w, fmt.Sprintf("Invalid 'id' parameter '%s'", hash), http.StatusBadRequest)
+			http.Error(w, fmt.Sprintf("Invalid 'id' parameter '%s'", requestedId), http.StatusBadRequest)
 			return
 		}
 
-		out, err := json.Marshal(getOptionOutputForContainer(hash, containerSettings))
+		out, err := json.Marshal(getOptionOutputForContainer(hatchConfig, hash, containerSettings))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -416,7 +523,7 @@ func options(w http.ResponseWriter, r *http.Request) {
 
 	// handle `/options` without `id` parameter => return all available options
 	var options []containerOption
-	for k, v := range Config.ContainersMap {
+	for k, v := range hatchConfig.ContainersMap {
 		// filter out workspace options that the user is not allowed to run
 		allowed, err := isUserAuthorizedForContainer(userName, accessToken, v)
 		if err != nil {
@@ -427,7 +534,7 @@ func options(w http.ResponseWriter, r *http.Request) {
 			continue // do not return containers that the user is not allowed to run
 		}
 
-		c := getOptionOutputForContainer(k, v)
+		c := getOptionOutputForContainer(hatchConfig, k, v)
 		options = append(options, c)
 	}
 
@@ -452,44 +559,13 @@ func getWorkspaceFlavor(container Container) string {
 	}
 }
 
-func launch(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	accessToken := getBearerToken(r)
-
-	hash := r.URL.Query().Get("id")
-	if hash == "" {
-		http.Error(w, "Missing 'id' parameter", http.StatusBadRequest)
-		return
-	}
-	_, ok := Config.ContainersMap[hash]
-	if !ok {
-		http.Error(w, fmt.Sprintf("Invalid 'id' parameter '%s'", hash), http.StatusBadRequest)
-		return
-	}
-
-	userName := getCurrentUserName(r)
-	if userName == "" {
-		http.Error(w, "No username found. Launch forbidden", http.StatusBadRequest)
-		return
-	}
-
-	allowed, err := isUserAuthorizedForContainer(userName, accessToken, Config.ContainersMap[hash])
-	if err != nil {
-		Config.Logger.Printf("Unable to check if user is authorized to launch this container. Assuming unthorized. Details: %v", err)
-	}
-	if err != nil || !allowed {
-		// return the same as for an unknown id
-		http.Error(w, fmt.Sprintf("Invalid 'id' parameter '%s'", hash), http.StatusBadRequest)
-		return
-	}
-
+// workspaceEnvVars returns the env vars set in the workspaces launched for the container,
+// for kubernetes and for ECS. The license is only set in kubernetes workspaces
+func workspaceEnvVars(container Container, nextflowKeyId string, nextflowKeySecret string, licenseString string) ([]k8sv1.EnvVar, []EnvVar) {
 	var envVars []k8sv1.EnvVar
 	var envVarsEcs []EnvVar
 
-	workspaceFlavor := getWorkspaceFlavor(Config.ContainersMap[hash])
+	workspaceFlavor := getWorkspaceFlavor(container)
 	envVars = append(
 		envVars,
 		k8sv1.EnvVar{
@@ -505,14 +581,7 @@ func launch(w http.ResponseWriter, r *http.Request) {
 		},
 	)
 
-	if Config.ContainersMap[hash].NextflowConfig.Enabled {
-		Config.Logger.Printf("Info: Nextflow is enabled: creating Nextflow resources in AWS...")
-		nextflowKeyId, nextflowKeySecret, err := createNextflowResources(userName, Config.Config.NextflowGlobalConfig, Config.ContainersMap[hash].NextflowConfig)
-		if err != nil {
-			Config.Logger.Printf("Error creating Nextflow AWS resources in AWS for user '%s': %v", userName, err)
-			http.Error(w, "Unable to create AWS resources for Nextflow", http.StatusInternalServerError)
-			return
-		}
+	if container.NextflowConfig.Enabled {
 		envVars = append(
 			envVars,
 			k8sv1.EnvVar{
@@ -536,93 +605,216 @@ func launch(w http.ResponseWriter, r *http.Request) {
 			},
 		)
 		// TODO do we need to set AWS_DEFAULT_REGION too?
+	}
+
+	if container.License.Enabled {
+		envVars = append(
+			envVars,
+			k8sv1.EnvVar{
+				Name: licenseEnvVarName(container.License),
+				// TODO: add a secret-key in the value string to mimic the g3auto secret
+				Value: licenseString,
+			},
+		)
+	}
+	return envVars, envVarsEcs
+}
+
+func launch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	accessToken := getBearerToken(r)
+	// the config may be reloaded during the launch
+	hatchConfig := Config()
+
+	requestedId := r.URL.Query().Get("id")
+	if requestedId == "" {
+		http.Error(w, "Missing 'id' parameter", http.StatusBadRequest)
+		return
+	}
+	// legacy ids resolve to the current id of the container
+	hash, ok := hatchConfig.resolveContainerId(requestedId)
+	hatchApp, found := hatchConfig.ContainersMap[hash]
+	if !ok || !found {
+		http.Error(w, fmt.Sprintf("Invalid 'id' parameter '%s'", requestedId), http.StatusBadRequest)
+		return
+	}
+
+	workspaceId, err := getWorkspaceId(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userName := getCurrentUserName(r)
+	if userName == "" {
+		http.Error(w, "No username found. Launch forbidden", http.StatusBadRequest)
+		return
+	}
+
+	allowed, err := isUserAuthorizedForContainer(userName, accessToken, hatchApp)
+	if err != nil {
+		hatchConfig.Logger.Printf("Unable to check if user is authorized to launch this container. Assuming unthorized. Details: %v", err)
+	}
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	if err != nil || !allowed {
+		if !dryRun {
+			recordLaunchFailure(hatchApp.Name, backendUnknown, launchFailureUnauthorized)
+		}
+		// return the same as for an unknown id
+		http.Error(w, fmt.Sprintf("Invalid 'id' parameter '%s'", requestedId), http.StatusBadRequest)
+		return
+	}
+
+	if dryRun {
+		dryRunLaunch(w, r, hatchConfig, hatchApp, userName, workspaceId)
+		return
+	}
+
+	err = checkWorkspaceLimit(r.Context(), userName, workspaceId)
+	if err != nil {
+		hatchConfig.Logger.Printf("Launch forbidden for user %s: %v", userName, err)
+		recordLaunchFailure(hatchApp.Name, backendUnknown, launchFailureLimit)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if workspaceId != "" {
+		// check before any resource is created for the workspace
+		payModel, err := getCurrentPayModel(userName)
+		if err == nil && payModel != nil && payModel.Ecs {
+			recordLaunchFailure(hatchApp.Name, backendECS, launchFailureUnsupported)
+			http.Error(w, "Named workspaces are not supported for ECS pay models", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// the size is checked before any resource is created for the workspace
+	var sizePayModel *PayModel
+	if hasWorkspaceSizeParams(r.URL.Query()) || len(hatchConfig.Config.PayModelSizeCaps) > 0 {
+		sizePayModel, err = getCurrentPayModel(userName)
+		if err != nil {
+			hatchConfig.Logger.Printf("Unable to get the current paymodel to check the workspace size: %v", err)
+		}
+	}
+	size, err := resolveWorkspaceSize(hatchConfig, hatchApp, r.URL.Query(), sizePayModel)
+	if err != nil {
+		hatchConfig.Logger.Printf("Launch forbidden for user %s: %v", userName, err)
+		recordLaunchFailure(hatchApp.Name, backendUnknown, launchFailureSize)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+
+	var nextflowKeyId, nextflowKeySecret, licenseString string
+	if hatchApp.NextflowConfig.Enabled {
+		hatchConfig.Logger.Printf("Info: Nextflow is enabled: creating Nextflow resources in AWS...")
+		nextflowKeyId, nextflowKeySecret, err = createNextflowResources(userName, hatchConfig.Config.NextflowGlobalConfig, hatchApp.NextflowConfig)
+		if err != nil {
+			hatchConfig.Logger.Printf("Error creating Nextflow AWS resources in AWS for user '%s': %v", userName, err)
+			recordLaunchFailure(hatchApp.Name, backendUnknown, launchFailureNextflow)
+			http.Error(w, "Unable to create AWS resources for Nextflow", http.StatusInternalServerError)
+			return
+		}
 	} else {
-		Config.Logger.Printf("Debug: Nextflow is not enabled: skipping Nextflow resources creation")
+		hatchConfig.Logger.Printf("Debug: Nextflow is not enabled: skipping Nextflow resources creation")
 	}
 
-	if Config.ContainersMap[hash].License.Enabled {
-		Config.Logger.Printf(
-			"Info: Running licensed workspace: %s", Config.ContainersMap[hash].License.WorkspaceFlavor)
+	if hatchApp.License.Enabled {
+		hatchConfig.Logger.Printf(
+			"Info: Running licensed workspace: %s", hatchApp.License.WorkspaceFlavor)
 		dbconfig := initializeDbConfig()
-		activeGen3LicenseUsers, err := getActiveGen3LicenseUserMaps(dbconfig, Config.ContainersMap[hash])
+		activeGen3LicenseUsers, err := getActiveGen3LicenseUserMaps(dbconfig, hatchApp)
 		if err != nil {
-			Config.Logger.Printf("error when getting active Gen3-licensed users: %s", err.Error())
+			hatchConfig.Logger.Printf("error when getting active Gen3-licensed users: %s", err.Error())
 		}
 		// Check for config max
-		nextLicenseId := getNextLicenseId(activeGen3LicenseUsers, Config.ContainersMap[hash].License.MaxLicenseIds)
+		nextLicenseId := getNextLicenseId(activeGen3LicenseUsers, hatchApp.License.MaxLicenseIds)
 		if nextLicenseId == 0 {
-			Config.Logger.Printf("Error: no available license ids")
+			hatchConfig.Logger.Printf("Error: no available license ids")
+			recordLaunchFailure(hatchApp.Name, backendUnknown, launchFailureLicense)
 			return
 		}
-		newItem, err := createGen3LicenseUserMap(dbconfig, userName, nextLicenseId, Config.ContainersMap[hash])
+		newItem, err := createGen3LicenseUserMap(dbconfig, userName, workspaceId, nextLicenseId, hatchApp)
 		if err != nil {
-			Config.Logger.Printf("error when adding active Gen3-licensed user: %s", err.Error())
+			hatchConfig.Logger.Printf("error when adding active Gen3-licensed user: %s", err.Error())
+		} else {
+			recordAuditEvent(AuditEvent{
+				Action:        auditActionLicenseAllocate,
+				UserName:      userName,
+				Actor:         userName,
+				WorkspaceId:   workspaceId,
+				ContainerName: hatchApp.Name,
+				LicenseType:   newItem.LicenseType,
+				LicenseId:     newItem.LicenseId,
+			})
 		}
-		Config.Logger.Printf("Created new license-user-map item: %v", newItem)
+		hatchConfig.Logger.Printf("Created new license-user-map item: %v", newItem)
 
-		licenseString, err := getLicenseString(Config, hash)
+		licenseString, err = getLicenseString(hatchConfig, hash)
 		if err != nil {
-			Config.Logger.Printf("unable to get license: %v", err)
+			hatchConfig.Logger.Printf("unable to get license: %v", err)
 		}
-		secretName := Config.ContainersMap[hash].License.G3autoName
-		// convert to format suitable for environment var
-		secretName = strings.ReplaceAll(secretName, "-", "_")
-		secretName = strings.ToUpper(secretName)
-		envVars = append(
-			envVars,
-			k8sv1.EnvVar{
-				Name: secretName,
-				// TODO: add a secret-key in the value string to mimic the g3auto secret
-				Value: string(licenseString),
-			},
-		)
 	}
-	// Config.Logger.Printf("EnvVar: %v", envVars)
+	envVars, envVarsEcs := workspaceEnvVars(hatchApp, nextflowKeyId, nextflowKeySecret, licenseString)
+	// hatchConfig.Logger.Printf("EnvVar: %v", envVars)
 	allpaymodels, err := getPayModelsForUser(userName)
 	if err != nil {
-		Config.Logger.Printf("error when getting paymodels for user: %s", err.Error())
+		hatchConfig.Logger.Printf("error when getting paymodels for user: %s", err.Error())
 	}
+	backend := backendLocal
+	launchEvent := AuditEvent{Action: auditActionLaunch, UserName: userName, Actor: userName, WorkspaceId: workspaceId, ContainerName: hatchApp.Name}
 	if allpaymodels == nil { // Commons with no concept of paymodels
-		err = createLocalK8sPod(r.Context(), hash, userName, accessToken, envVars)
+		err = createLocalK8sPod(ctx, hatchConfig, hatchApp, size, userName, workspaceId, accessToken, nil, envVars)
 	} else {
 		payModel := allpaymodels.CurrentPayModel
 		payModelId := ""
 		if payModel != nil && payModel.Id != "" {
 			payModelId = payModel.Id
 		}
-
+		launchEvent.PayModelId = payModelId
 		if payModel == nil {
-			Config.Logger.Printf("Current Paymodel is not set. Launch forbidden for user %s", userName)
+			hatchConfig.Logger.Printf("Current Paymodel is not set. Launch forbidden for user %s", userName)
+			recordLaunchFailure(hatchApp.Name, backendUnknown, launchFailurePayModel)
 			http.Error(w, "Current Paymodel is not set. Launch forbidden", http.StatusInternalServerError)
 			return
 		} else if payModel.Local {
-			err = createLocalK8sPod(r.Context(), hash, userName, accessToken, envVars, payModelId)
+			err = createLocalK8sPod(ctx, hatchConfig, hatchApp, size, userName, workspaceId, accessToken, payModel, envVars, payModelId)
 		} else if payModel.Ecs {
 
 			if payModel.Status != "active" {
 				// send 500 response.
 				// TODO: 403 is the correct code, but it triggers a 302 to the default 403 page in revproxy instead of showing error message.
-				Config.Logger.Printf("Paymodel is not active. Launch forbidden for user %s", userName)
+				hatchConfig.Logger.Printf("Paymodel is not active. Launch forbidden for user %s", userName)
+				recordLaunchFailure(hatchApp.Name, backendECS, launchFailurePayModel)
 				http.Error(w, "Paymodel is not active. Launch forbidden", http.StatusInternalServerError)
 				return
 			}
 
-			Config.Logger.Printf("Launching ECS workspace for user %s", userName)
+			hatchConfig.Logger.Printf("Launching ECS workspace for user %s", userName)
 			// Sending a 200 response straight away, but starting the launch in a goroutine
 			// TODO: Do more sanity checks before returning 200.
 			w.WriteHeader(http.StatusOK)
-			go launchEcsWorkspaceWrapper(userName, hash, accessToken, *payModel, envVarsEcs)
+			recordLaunch(WorkspaceRef{UserName: userName}, hatchApp.Name, backendECS)
+			recordAuditEvent(launchEvent)
+			// the launch outlives the request
+			go launchEcsWorkspaceWrapper(context.WithoutCancel(ctx), hatchConfig, hatchApp, size, userName, accessToken, *payModel, envVarsEcs)
 			fmt.Fprintf(w, "Launch accepted")
 			return
 		} else {
-			err = createExternalK8sPod(r.Context(), hash, userName, accessToken, *payModel, envVars, payModelId)
+			backend = backendExternalEKS
+			err = createExternalK8sPod(ctx, hatchConfig, hatchApp, size, userName, workspaceId, accessToken, *payModel, envVars, payModelId)
 		}
 	}
 	if err != nil {
-		Config.Logger.Printf("error during launch: %-v", err)
+		hatchConfig.Logger.Printf("error during launch: %-v", err)
+		recordLaunchFailure(hatchApp.Name, backend, launchFailureBackend)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordLaunch(WorkspaceRef{UserName: userName, WorkspaceId: workspaceId}, hatchApp.Name, backend)
+	recordAuditEvent(launchEvent)
 	fmt.Fprintf(w, "Success")
 }
 
@@ -637,78 +829,134 @@ func terminate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "No username found. Unable to terminate", http.StatusBadRequest)
 		return
 	}
-	Config.Logger.Printf("Terminating workspace for user %s", userName)
+	workspaceId, err := getWorkspaceId(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := terminateWorkspace(r.Context(), userName, workspaceId, accessToken)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, result)
+}
+
+// terminateWorkspace releases every resource tied to the user's workspace: gen3 licenses,
+// Nextflow resources, the API key and the workspace itself. Once the user's last workspace
+// is gone, the user's current pay model is reset. The audit events are attributed to the
+// actor set with withAuditActor, or to the user by default.
+var terminateWorkspace = func(ctx context.Context, userName string, workspaceId string, accessToken string) (string, error) {
+	Config().Logger.Printf("Terminating workspace '%s' for user %s", workspaceId, userName)
+	actor, reason := auditActorFromContext(ctx, userName)
 
 	// mark any gen3-licensed sessions as inactive
-	Config.Logger.Printf("Checking for gen3 license items for user: %s", userName)
+	Config().Logger.Printf("Checking for gen3 license items for user: %s", userName)
 	dbconfig := initializeDbConfig()
 	activeGen3LicenseUsers, userlicerr := getLicenseUserMapsForUser(dbconfig, userName)
 	if userlicerr != nil {
-		Config.Logger.Printf("Cannot check gen3 license items for user: %s", userlicerr.Error())
+		Config().Logger.Printf("Cannot check gen3 license items for user: %s", userlicerr.Error())
 	}
-	Config.Logger.Printf("Debug: Active gen3 license user maps %v", activeGen3LicenseUsers)
+	Config().Logger.Printf("Debug: Active gen3 license user maps %v", activeGen3LicenseUsers)
 	if len(activeGen3LicenseUsers) == 0 {
-		Config.Logger.Printf("No active gen3 license sessions for user: %s", userName)
+		Config().Logger.Printf("No active gen3 license sessions for user: %s", userName)
 	} else {
 		for _, v := range activeGen3LicenseUsers {
-			if v.UserId == userName {
-				Config.Logger.Printf("Debug: updating gen3 license user map as inactive for itemId %s", v.ItemId)
+			if v.UserId == userName && v.WorkspaceId == workspaceId {
+				Config().Logger.Printf("Debug: updating gen3 license user map as inactive for itemId %s", v.ItemId)
 				_, err := setGen3LicenseUserInactive(dbconfig, v.ItemId)
 				if err != nil {
-					Config.Logger.Printf("cannot set gen3 license for user: %s", err.Error())
+					Config().Logger.Printf("cannot set gen3 license for user: %s", err.Error())
+					continue
 				}
+				recordAuditEvent(AuditEvent{
+					Action:      auditActionLicenseRelease,
+					UserName:    userName,
+					Actor:       actor,
+					WorkspaceId: workspaceId,
+					LicenseType: v.LicenseType,
+					LicenseId:   v.LicenseId,
+					Reason:      reason,
+				})
 			}
 		}
 	}
 
 	// delete nextflow resources. There is no way to know if the actual workspace being
-	// terminated is a nextflow workspace or not, so always attempt to delete
-	Config.Logger.Printf("Info: Deleting Nextflow resources in AWS...")
-	err := cleanUpNextflowResources(userName, nil, nil, nil, nil)
-	if err != nil {
-		Config.Logger.Printf("Unable to delete AWS resources for Nextflow... continuing anyway")
+	// terminated is a nextflow workspace or not, so always attempt to delete. They are
+	// shared by all of the user's workspaces, so only when terminating the last one
+	if !hasOtherWorkspaces(ctx, userName, workspaceId) {
+		Config().Logger.Printf("Info: Deleting Nextflow resources in AWS...")
+		err := cleanUpNextflowResources(userName, nil, nil, nil, nil)
+		if err != nil {
+			Config().Logger.Printf("Unable to delete AWS resources for Nextflow... continuing anyway")
+		}
 	}
 
+	var result string
 	payModel, err := getCurrentPayModel(userName)
 	if err != nil {
-		Config.Logger.Printf("Cannot get current paymodel for user: %s", err.Error())
+		Config().Logger.Printf("Cannot get current paymodel for user: %s", err.Error())
 	}
+	// read before the workspace resources are deleted
+	containerName := getWorkspaceContainerName(ctx, userName, workspaceId)
 	if payModel != nil && payModel.Ecs {
-		_, err = terminateEcsWorkspace(r.Context(), userName, accessToken, payModel.AWSAccountId)
+		if workspaceId != "" {
+			return "", fmt.Errorf("workspace '%s' not found: named workspaces are not supported for ECS pay models", workspaceId)
+		}
+		_, err = terminateEcsWorkspace(ctx, userName, accessToken, payModel.AWSAccountId)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		} else {
-			Config.Logger.Printf("Succesfully terminated all resources related to ECS workspace for user %s", userName)
-			fmt.Fprintf(w, "Terminated ECS workspace")
+			return "", err
 		}
+		Config().Logger.Printf("Succesfully terminated all resources related to ECS workspace for user %s", userName)
+		result = "Terminated ECS workspace"
 	} else {
-		err := deleteK8sPod(r.Context(), userName, accessToken, payModel)
+		err := deleteK8sPod(ctx, userName, workspaceId, accessToken, payModel)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return "", err
 		}
-		Config.Logger.Printf("Terminated workspace for user %s", userName)
-		fmt.Fprintf(w, "Terminated workspace")
+		Config().Logger.Printf("Terminated workspace for user %s", userName)
+		result = "Terminated workspace"
+	}
+	// the routes are in the local cluster whatever the backend
+	if err := deleteWorkspaceRoute(ctx, userName, workspaceId); err != nil {
+		Config().Logger.Printf("Unable to delete the route of workspace %s for user %s: %v", workspaceId, userName, err)
+	}
+	recordTermination(containerName, workspaceBackend(payModel))
+	terminateEvent := AuditEvent{Action: auditActionTerminate, UserName: userName, Actor: actor, WorkspaceId: workspaceId, ContainerName: containerName, Reason: reason}
+	if payModel != nil {
+		terminateEvent.PayModelId = payModel.Id
 	}
+	recordAuditEvent(terminateEvent)
+	forgetPendingLaunch(WorkspaceRef{UserName: userName, WorkspaceId: workspaceId})
 
 	go func() {
+		// The caller's context may be cancelled as soon as we return (eg when the
+		// http response is sent), so the poller runs with its own context
+		pollCtx := context.Background()
 		// Periodically poll for status, until it is set as "Not Found"
 		for {
-			status, err := getWorkspaceStatus(r.Context(), userName, accessToken)
+			status, err := getWorkspaceStatus(pollCtx, userName, workspaceId, accessToken)
 			if err != nil {
-				Config.Logger.Printf("error fetching workspace status for user %s\n err: %s", userName, err)
+				Config().Logger.Printf("error fetching workspace status for user %s\n err: %s", userName, err)
 			}
-			if status.Status == "Not Found" {
+			if status != nil && status.Status == "Not Found" {
 				break
 			}
 			time.Sleep(5 * time.Second)
 		}
-		err = resetCurrentPaymodel(userName)
+		if hasOtherWorkspaces(pollCtx, userName, workspaceId) {
+			// the pay model can't change while other workspaces are running
+			return
+		}
+		err := resetCurrentPaymodel(userName)
 		if err != nil {
-			Config.Logger.Printf("unable to reset current paymodel for current user %s\nerr: %s", userName, err)
+			Config().Logger.Printf("unable to reset current paymodel for current user %s\nerr: %s", userName, err)
 		}
 	}()
+
+	return result, nil
 }
 
 func getBearerToken(r *http.Request) string {
@@ -749,13 +997,13 @@ func createECSCluster(w http.ResponseWriter, r *http.Request) {
 	var reader *strings.Reader
 	if err != nil {
 		reader = strings.NewReader(err.Error())
-		Config.Logger.Printf("Error: %s", err)
+		Config().Logger.Printf("Error: %s", err)
 	} else {
 		reader = strings.NewReader(result.String())
 	}
 	_, err = io.Copy(w, reader)
 	if err != nil {
-		Config.Logger.Printf("Error: %s", err)
+		Config().Logger.Printf("Error: %s", err)
 	}
 }
 
@@ -769,7 +1017,7 @@ var statusEcs = func(ctx context.Context, userName string, accessToken string, a
 	svc := NewSVC(sess, roleARN)
 	result, err := svc.statusEcsWorkspace(ctx, userName, accessToken)
 	if err != nil {
-		Config.Logger.Printf("Error: %s", err)
+		Config().Logger.Printf("Error: %s", err)
 		return nil, err
 	}
 	return result, nil
@@ -777,14 +1025,15 @@ var statusEcs = func(ctx context.Context, userName string, accessToken string, a
 
 // Wrapper function to launch ECS workspace in a goroutine.
 // Terminates workspace if launch fails for whatever reason
-var launchEcsWorkspaceWrapper = func(userName string, hash string, accessToken string, payModel PayModel, envVars []EnvVar) {
-	err := launchEcsWorkspace(userName, hash, accessToken, payModel, envVars)
+var launchEcsWorkspaceWrapper = func(ctx context.Context, hatchConfig *FullHatcheryConfig, hatchApp Container, size WorkspaceSize, userName string, accessToken string, payModel PayModel, envVars []EnvVar) {
+	err := launchEcsWorkspace(ctx, hatchConfig, hatchApp, size, userName, accessToken, payModel, envVars)
 	if err != nil {
-		Config.Logger.Printf("Error: %s", err)
+		hatchConfig.Logger.Printf("Error: %s", err)
+		failPendingLaunch(WorkspaceRef{UserName: userName}, launchFailureBackend)
 		// Terminate ECS workspace if launch fails.
 		_, err = terminateEcsWorkspace(context.Background(), userName, accessToken, payModel.AWSAccountId)
 		if err != nil {
-			Config.Logger.Printf("Error: %s", err)
+			hatchConfig.Logger.Printf("Error: %s", err)
 		}
 	}
 }
@@ -836,24 +1085,24 @@ func mountFiles(w http.ResponseWriter, r *http.Request) {
 func getMountFileContents(fileId string, userName string) (string, error) {
 	filePathConfigs, err := getLicenseFilePathConfigs()
 	if err != nil {
-		Config.Logger.Printf("unable to get filepaths from config: %v", err)
+		Config().Logger.Printf("unable to get filepaths from config: %v", err)
 		return "", err
 	}
 	var licenseFilePaths []string
 	for _, v := range filePathConfigs {
 		licenseFilePaths = append(licenseFilePaths, v.FilePath)
 	}
-	Config.Logger.Printf("Configured License File Paths %v", licenseFilePaths)
+	Config().Logger.Printf("Configured License File Paths %v", licenseFilePaths)
 
 	if fileId == "sample-nextflow-config.txt" {
-		out, err := generateNextflowConfig(Config.Config.NextflowGlobalConfig, userName)
+		out, err := generateNextflowConfig(Config().Config.NextflowGlobalConfig, userName)
 		if err != nil {
-			Config.Logger.Printf("unable to generate Nextflow config: %v", err)
+			Config().Logger.Printf("unable to generate Nextflow config: %v", err)
 		}
 		return out, nil
 		// Skip any license file-paths
 	} else if slices.Contains(licenseFilePaths, fileId) {
-		Config.Logger.Printf("The file_path is not available")
+		Config().Logger.Printf("The file_path is not available")
 		return "The file_path is not available", nil
 	} else {
 		return "", fmt.Errorf("unknown id '%s'", fileId)
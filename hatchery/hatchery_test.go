@@ -2,6 +2,7 @@ package hatchery
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -106,7 +107,7 @@ func Test_GetWorkspaceStatus(t *testing.T) {
 		t.Logf("Testing GetWorkspaceStatus when %s", testcase.name)
 		/* Setup */
 
-		statusK8sPod = func(context.Context, string, string, *PayModel) (*WorkspaceStatus, error) {
+		statusK8sPod = func(context.Context, string, string, string, *PayModel) (*WorkspaceStatus, error) {
 			return mockStatusK8sPod, nil
 		}
 
@@ -118,7 +119,7 @@ func Test_GetWorkspaceStatus(t *testing.T) {
 		}
 		/* Act */
 		ctx := context.Background()
-		got, err := getWorkspaceStatus(ctx, "testUser", "access_token")
+		got, err := getWorkspaceStatus(ctx, "testUser", "", "access_token")
 		if nil != err {
 			t.Errorf("failed to load workspace status, got: %v", err)
 			return
@@ -207,7 +208,7 @@ func Test_SetpaymodelEndpoint(t *testing.T) {
 		t.Logf("Testing SetPaymodels when %s", testcase.name)
 
 		/* Setup */
-		getWorkspaceStatus = func(context.Context, string, string) (*WorkspaceStatus, error) {
+		getWorkspaceStatus = func(context.Context, string, string, string) (*WorkspaceStatus, error) {
 			return testcase.currentStatus, nil
 		}
 		setCurrentPaymodel = func(string, string) (*PayModel, error) {
@@ -322,7 +323,7 @@ func Test_ResetpaymodelsEndpoint(t *testing.T) {
 		t.Logf("Testing ResetPaymodels when %s", testcase.name)
 
 		/* Setup */
-		getWorkspaceStatus = func(ctx context.Context, userName string, accessToken string) (*WorkspaceStatus, error) {
+		getWorkspaceStatus = func(ctx context.Context, userName string, workspaceId string, accessToken string) (*WorkspaceStatus, error) {
 			return testcase.currentStatus, nil
 		}
 		resetCurrentPaymodel = func(string) error {
@@ -538,7 +539,7 @@ func Test_LaunchEndpoint(t *testing.T) {
 		getPayModelsForUser = original_getPayModelsForUser
 	}()
 
-	Config.ContainersMap = map[string]Container{
+	Config().ContainersMap = map[string]Container{
 		"random_id": {
 			Name: "Hatchery test container",
 		},
@@ -556,18 +557,24 @@ func Test_LaunchEndpoint(t *testing.T) {
 			"createExternalK8sPod":      0,
 		}
 
-		createLocalK8sPod = func(ctx context.Context, hash, userName, accessToken string, envVars []k8sv1.EnvVar, payModelId ...string) error {
+		createLocalK8sPod = func(ctx context.Context, hatchConfig *FullHatcheryConfig, hatchApp Container, size WorkspaceSize, userName, workspaceId, accessToken string, payModel *PayModel, envVars []k8sv1.EnvVar, payModelId ...string) error {
 			FuncCounter["createLocalK8sPod"] += 1
+			if hatchConfig != Config() || hatchApp.Name == "" {
+				t.Errorf("\nassertion error while testing `launch`: \nWant:%s\nGot:%+v", "the container resolved from the current config", hatchApp)
+			}
+			if size.MemoryLimit != hatchApp.MemoryLimit {
+				t.Errorf("\nassertion error while testing `launch` size: \nWant:%s\nGot:%+v", hatchApp.MemoryLimit, size)
+			}
 			if testcase.throwError {
 				return errors.New("error creating local k8s pod")
 			}
 			return nil
 		}
-		launchEcsWorkspaceWrapper = func(userName, hash, accessToken string, payModel PayModel, envVars []EnvVar) {
+		launchEcsWorkspaceWrapper = func(ctx context.Context, hatchConfig *FullHatcheryConfig, hatchApp Container, size WorkspaceSize, userName, accessToken string, payModel PayModel, envVars []EnvVar) {
 			FuncCounter["launchEcsWorkspaceWrapper"] += 1
 			waitGroup.Done() // Assertions are blocked until this line is completed
 		}
-		createExternalK8sPod = func(ctx context.Context, hash, userName, accessToken string, payModel PayModel, envVars []k8sv1.EnvVar, payModelId ...string) error {
+		createExternalK8sPod = func(ctx context.Context, hatchConfig *FullHatcheryConfig, hatchApp Container, size WorkspaceSize, userName, workspaceId, accessToken string, payModel PayModel, envVars []k8sv1.EnvVar, payModelId ...string) error {
 			FuncCounter["createExternalK8sPod"] += 1
 			if testcase.throwError {
 				return errors.New("error creating external k8s pod")
@@ -629,7 +636,7 @@ func Test_LaunchEndpoint(t *testing.T) {
 func TestLaunchEndpointAuthorization(t *testing.T) {
 	defer SetupAndTeardownTest()()
 
-	Config.ContainersMap = map[string]Container{
+	Config().ContainersMap = map[string]Container{
 		"container_a": {
 			Name: "Container without authz (accessible by default)",
 		},
@@ -663,7 +670,7 @@ func TestLaunchEndpointAuthorization(t *testing.T) {
 	}
 	// mock the pod launch
 	originalCreateLocalK8sPod := createLocalK8sPod
-	createLocalK8sPod = func(ctx context.Context, hash, userName, accessToken string, envVars []k8sv1.EnvVar, payModelId ...string) error {
+	createLocalK8sPod = func(ctx context.Context, hatchConfig *FullHatcheryConfig, hatchApp Container, size WorkspaceSize, userName, workspaceId, accessToken string, payModel *PayModel, envVars []k8sv1.EnvVar, payModelId ...string) error {
 		return nil
 	}
 	defer func() {
@@ -672,7 +679,7 @@ func TestLaunchEndpointAuthorization(t *testing.T) {
 		createLocalK8sPod = originalCreateLocalK8sPod
 	}()
 
-	for containerId, container := range Config.ContainersMap {
+	for containerId, container := range Config().ContainersMap {
 		t.Logf("Running test case: '%s'", container.Name)
 
 		url := fmt.Sprintf("/launch?id=%s", containerId)
@@ -862,7 +869,7 @@ func Test_TerminateEndpoint(t *testing.T) {
 			"deleteK8sPod":          0,
 			"terminateEcsWorkspace": 0,
 		}
-		deleteK8sPod = func(ctx context.Context, userName, accessToken string, payModelPtr *PayModel) error {
+		deleteK8sPod = func(ctx context.Context, userName, workspaceId, accessToken string, payModelPtr *PayModel) error {
 			FuncCounter["deleteK8sPod"] += 1
 			if testcase.throwError {
 				return errors.New("error deleting k8s pod")
@@ -882,7 +889,7 @@ func Test_TerminateEndpoint(t *testing.T) {
 			return testcase.mockCurrentPayModel, nil
 		}
 
-		getWorkspaceStatus = func(context.Context, string, string) (*WorkspaceStatus, error) {
+		getWorkspaceStatus = func(context.Context, string, string, string) (*WorkspaceStatus, error) {
 			workspaceStatusCallCounter += 1
 			if workspaceTerminationPending {
 				// we assume that the workspace is terminated by the time this fucntion is called again
@@ -960,7 +967,7 @@ func Test_TerminateEndpoint(t *testing.T) {
 func TestOptionsEndpointAuthorization(t *testing.T) {
 	defer SetupAndTeardownTest()()
 
-	Config.ContainersMap = map[string]Container{
+	Config().ContainersMap = map[string]Container{
 		"container_a": {
 			Name: "Container without authz (accessible by default)",
 		},
@@ -1050,6 +1057,95 @@ func TestOptionsEndpointAuthorization(t *testing.T) {
 		t.Errorf("The /options?id endpoint should not have returned an unauthorized container, but it did: %v - %v", w.Code, w.Body)
 		return
 	}
+
+	// `options` endpoint with the legacy id of a container: the current id and revision are returned
+	Config().ContainerRevisions = map[string]string{"container_b": "revision_b"}
+	Config().ContainerAliases = map[string]string{"legacy_b": "container_b"}
+	url = "/options?id=legacy_b"
+	req, err = http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Errorf("Error creating request: %v", err.Error())
+		return
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	option := containerOption{}
+	if w.Code != 200 || json.Unmarshal(w.Body.Bytes(), &option) != nil {
+		t.Errorf("Error when hitting /options?id endpoint with a legacy id: got %v - %v", w.Code, w.Body)
+		return
+	}
+	if option.ID != "container_b" || option.Revision != "revision_b" {
+		t.Errorf("The /options?id endpoint should have returned the current id and revision of the container, but it didn't: %v", w.Body)
+	}
+}
+
+func Test_GetOptionOutputForContainer(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	withTestConfig(t, func(config *FullHatcheryConfig) {
+		config.Config = HatcheryConfig{
+			Sidecar: SidecarContainer{CPULimit: "0.5", MemoryLimit: "1Gi"},
+			Pricing: Pricing{Cpu: 0.1, Memory: 0.05},
+		}
+	})
+
+	container := Container{
+		Name:             "Jupyter",
+		Image:            "quay.io/cdis/jupyter:2024.05",
+		CPULimit:         "1.5",
+		MemoryLimit:      "3Gi",
+		Description:      "Python notebooks",
+		LogoURL:          "https://example.com/jupyter.png",
+		Categories:       []string{"notebooks", "python"},
+		DocumentationURL: "https://example.com/docs",
+		Beta:             true,
+	}
+	option := getOptionOutputForContainer(Config(), "jupyter", container)
+	if option.Description != container.Description || option.LogoURL != container.LogoURL || option.DocumentationURL != container.DocumentationURL ||
+		strings.Join(option.Categories, ",") != "notebooks,python" || !option.Beta || option.Deprecated {
+		t.Errorf("\nassertion error while testing `getOptionOutputForContainer` metadata: \nWant:%+v\nGot:%+v", container, option)
+	}
+	if option.Version != "2024.05" {
+		t.Errorf("\nassertion error while testing `getOptionOutputForContainer` version: \nWant:%s\nGot:%s", "2024.05", option.Version)
+	}
+	// (1.5 + 0.5) cores * $0.10/hour + (3 + 1) GB * $0.05/GB-hour
+	if option.EstimatedHourlyCost == nil || *option.EstimatedHourlyCost != 0.4 {
+		t.Errorf("\nassertion error while testing `getOptionOutputForContainer` cost: \nWant:%v\nGot:%v", 0.4, option.EstimatedHourlyCost)
+	}
+
+	// without pricing, no cost is estimated
+	Config().Config.Pricing = Pricing{}
+	if option := getOptionOutputForContainer(Config(), "jupyter", container); option.EstimatedHourlyCost != nil {
+		t.Errorf("\nassertion error while testing `getOptionOutputForContainer` cost without pricing: \nWant:nil\nGot:%v", *option.EstimatedHourlyCost)
+	}
+
+	// GPUs are priced by model, and shown for GPU containers only
+	Config().Config.Pricing = Pricing{GPU: map[string]float64{"nvidia.com/gpu": 1.0}, GPUModels: map[string]float64{"NVIDIA-A100-SXM4-40GB": 3.0}}
+	if option := getOptionOutputForContainer(Config(), "jupyter", container); option.GPUCount != 0 || option.GPUResourceName != "" || *option.EstimatedHourlyCost != 0 {
+		t.Errorf("\nassertion error while testing `getOptionOutputForContainer` without GPUs: \nGot:%+v", option)
+	}
+	gpuContainer := Container{Name: "PyTorch", Image: "pytorch:2.3", GPU: true, GPUCount: 2, GPUModel: "NVIDIA-A100-SXM4-40GB"}
+	option = getOptionOutputForContainer(Config(), "pytorch", gpuContainer)
+	if option.GPUCount != 2 || option.GPUResourceName != "nvidia.com/gpu" || option.GPUModel != "NVIDIA-A100-SXM4-40GB" {
+		t.Errorf("\nassertion error while testing `getOptionOutputForContainer` GPUs: \nGot:%+v", option)
+	}
+	if option.EstimatedHourlyCost == nil || *option.EstimatedHourlyCost != 6 {
+		t.Errorf("\nassertion error while testing `getOptionOutputForContainer` GPU cost: \nWant:%v\nGot:%v", 6, option.EstimatedHourlyCost)
+	}
+
+	versions := map[string]string{
+		"quay.io/cdis/jupyter:1.0":                 "1.0",
+		"localhost:5000/jupyter":                   "latest",
+		"quay.io/cdis/jupyter@sha256:0123456789ab": "sha256:0123456789ab",
+	}
+	for image, want := range versions {
+		if got := containerVersion(Container{Image: image}); got != want {
+			t.Errorf("\nassertion error while testing `containerVersion` for '%s': \nWant:%s\nGot:%s", image, want, got)
+		}
+	}
+	if got := containerVersion(Container{Image: "quay.io/cdis/jupyter:1.0", Version: "2.0"}); got != "2.0" {
+		t.Errorf("\nassertion error while testing `containerVersion` with a configured version: \nWant:%s\nGot:%s", "2.0", got)
+	}
 }
 
 func TestMountFilesEndpoint(t *testing.T) {
@@ -1081,7 +1177,7 @@ aws {
 		G3autoKey:       "test-g3auto-key",
 		WorkspaceFlavor: "licensed-flavor",
 	}
-	Config.ContainersMap = map[string]Container{
+	Config().ContainersMap = map[string]Container{
 		"container_a": {
 			Name:    "Container with license",
 			License: licenseInfo,
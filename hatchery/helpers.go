@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -18,6 +17,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/imagebuilder"
 	"github.com/aws/aws-sdk-go/service/sts"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 type APIKeyStruct struct {
@@ -29,31 +29,41 @@ type WorkspaceKernelStatusStruct struct {
 	LastActivityTime string `json:"last_activity"`
 }
 
+type AccessTokenStruct struct {
+	AccessToken string `json:"access_token"`
+}
+
 func StrToInt(str string) (string, error) {
 	nonFractionalPart := strings.Split(str, ".")
 	return nonFractionalPart[0], nil
 }
 
+// mem returns the memory quantity in MiB, the unit of ECS task definitions
 func mem(str string) (string, error) {
-	res := regexp.MustCompile(`(\d*)([M|G])ib?`)
-	matches := res.FindStringSubmatch(str)
-	num, err := strconv.Atoi(matches[1])
+	quantity, err := resource.ParseQuantity(str)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("invalid memory '%s': %v", str, err)
 	}
-	if matches[2] == "G" {
-		num = num * 1024
+	// partial MiB are rounded down
+	num := quantity.Value() / (1 << 20)
+	if num < 1 {
+		return "", fmt.Errorf("invalid memory '%s': must be at least 1Mi", str)
 	}
-	return strconv.Itoa(num), nil
+	return strconv.FormatInt(num, 10), nil
 }
 
+// cpu returns the CPU quantity in CPU units, the unit of ECS task definitions: 1024 per vCPU
 func cpu(str string) (string, error) {
-	num, err := strconv.Atoi(str[:strings.IndexByte(str, '.')])
+	quantity, err := resource.ParseQuantity(str)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("invalid CPU '%s': %v", str, err)
+	}
+	// partial CPU units are rounded down
+	num := quantity.MilliValue() * 1024 / 1000
+	if num < 1 {
+		return "", fmt.Errorf("invalid CPU '%s': must be at least 1 CPU unit", str)
 	}
-	num = num * 1024
-	return strconv.Itoa(num), nil
+	return strconv.FormatInt(num, 10), nil
 }
 
 // Escapism escapes characters not allowed into hex with -
@@ -128,7 +138,7 @@ func MakeARequestWithContext(ctx context.Context, method string, apiEndpoint str
 func getFenceURL() string {
 	fenceURL := "http://fence-service/"
 	_, ok := os.LookupEnv("GEN3_ENDPOINT")
-	if ok && !Config.Config.UseInteralServicesURL {
+	if ok && !Config().Config.UseInteralServicesURL {
 		fenceURL = "https://" + os.Getenv("GEN3_ENDPOINT") + "/user/"
 	}
 	return fenceURL
@@ -137,7 +147,7 @@ func getFenceURL() string {
 func getAmbassadorURL() string {
 	ambassadorURL := "http://ambassador-service/"
 	_, ok := os.LookupEnv("GEN3_ENDPOINT")
-	if ok && !Config.Config.UseInteralServicesURL {
+	if ok && !Config().Config.UseInteralServicesURL {
 		ambassadorURL = "https://" + os.Getenv("GEN3_ENDPOINT") + "/lw-workspace/proxy/"
 	}
 	return ambassadorURL
@@ -148,6 +158,7 @@ func getAPIKeyWithContext(ctx context.Context, accessToken string) (apiKey *APIK
 		return nil, errors.New("No valid access token")
 	}
 
+	defer func(start time.Time) { observeExternalRequest("fence", "create_api_key", start, err) }(time.Now())
 	fenceAPIKeyURL := getFenceURL() + "credentials/api/"
 	body := bytes.NewBufferString("{\"scope\": [\"data\", \"user\"]}")
 
@@ -169,11 +180,12 @@ func getAPIKeyWithContext(ctx context.Context, accessToken string) (apiKey *APIK
 	return fenceApiKeyResponse, nil
 }
 
-func deleteAPIKeyWithContext(ctx context.Context, accessToken string, apiKeyID string) error {
+func deleteAPIKeyWithContext(ctx context.Context, accessToken string, apiKeyID string) (err error) {
 	if accessToken == "" {
 		return errors.New("No valid access token")
 	}
 
+	defer func(start time.Time) { observeExternalRequest("fence", "delete_api_key", start, err) }(time.Now())
 	fenceDeleteAPIKeyURL := getFenceURL() + "credentials/api/" + apiKeyID
 	resp, err := MakeARequestWithContext(ctx, "DELETE", fenceDeleteAPIKeyURL, accessToken, "", nil, nil)
 	if err != nil {
@@ -185,12 +197,43 @@ func deleteAPIKeyWithContext(ctx context.Context, accessToken string, apiKeyID s
 	return nil
 }
 
-func getKernelIdleTimeWithContext(ctx context.Context, accessToken string) (lastActivityTime int64, err error) {
+// getAccessTokenFromAPIKeyWithContext exchanges a Gen3 API key for a fresh access token.
+// Used when acting on behalf of a user outside of a request, e.g. by the idle culler
+var getAccessTokenFromAPIKeyWithContext = func(ctx context.Context, apiKey string) (accessToken string, err error) {
+	if apiKey == "" {
+		return "", errors.New("No valid API key")
+	}
+
+	defer func(start time.Time) { observeExternalRequest("fence", "get_access_token", start, err) }(time.Now())
+	fenceAccessTokenURL := getFenceURL() + "credentials/api/access_token"
+	body, err := json.Marshal(map[string]string{"api_key": apiKey})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := MakeARequestWithContext(ctx, "POST", fenceAccessTokenURL, "", "application/json", nil, bytes.NewBuffer(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", errors.New("Error occurred when getting access token from API key with error code " + strconv.Itoa(resp.StatusCode))
+	}
+
+	accessTokenResponse := new(AccessTokenStruct)
+	err = json.NewDecoder(resp.Body).Decode(accessTokenResponse)
+	if err != nil {
+		return "", errors.New("Unable to decode access token response: " + err.Error())
+	}
+	return accessTokenResponse.AccessToken, nil
+}
+
+func getKernelIdleTimeWithContext(ctx context.Context, accessToken string, workspaceId string) (lastActivityTime int64, err error) {
 	if accessToken == "" {
 		return -1, errors.New("No valid access token")
 	}
 
-	workspaceKernelStatusURL := getAmbassadorURL() + "api/status"
+	workspaceKernelStatusURL := getAmbassadorURL() + strings.TrimPrefix(workspaceRoutingPrefix(workspaceId), "/") + "api/status"
 	resp, err := MakeARequestWithContext(ctx, "GET", workspaceKernelStatusURL, accessToken, "", nil, nil)
 	if err != nil {
 		return -1, err
@@ -251,7 +294,7 @@ func getLatestImageBuilderAmi(imageBuilderReaderRoleArn string, imagePipelineArn
 		ImagePipelineArn: aws.String(imagePipelineArn),
 	})
 	if err != nil {
-		Config.Logger.Printf("Error getting '%s' AMIs: %v", imagePipelineArn, err)
+		Config().Logger.Printf("Error getting '%s' AMIs: %v", imagePipelineArn, err)
 		return "", err
 	}
 	imagePipelineImages := listImagePipelineImagesOutput.ImageSummaryList
@@ -263,7 +306,7 @@ func getLatestImageBuilderAmi(imageBuilderReaderRoleArn string, imagePipelineArn
 			NextToken:        listImagePipelineImagesOutput.NextToken,
 		})
 		if err != nil {
-			Config.Logger.Printf("Error getting '%s' AMIs: %v", imagePipelineArn, err)
+			Config().Logger.Printf("Error getting '%s' AMIs: %v", imagePipelineArn, err)
 			return "", err
 		}
 		imagePipelineImages = append(imagePipelineImages, listImagePipelineImagesOutput.ImageSummaryList...)
@@ -287,6 +330,6 @@ func getLatestImageBuilderAmi(imageBuilderReaderRoleArn string, imagePipelineArn
 	}
 
 	ami := latestImage.OutputResources.Amis[0].Image
-	Config.Logger.Printf("Using latest '%s' AMI '%s', created on %s", imagePipelineArn, *ami, latestTimeStamp)
+	Config().Logger.Printf("Using latest '%s' AMI '%s', created on %s", imagePipelineArn, *ami, latestTimeStamp)
 	return *ami, nil
 }
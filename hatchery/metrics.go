@@ -0,0 +1,301 @@
+package hatchery
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Workspace backends, used as the `backend` label of the workspace metrics
+const (
+	backendLocal       = "local"
+	backendExternalEKS = "external-eks"
+	backendECS         = "ecs"
+	backendUnknown     = "unknown"
+)
+
+// Launch failure categories, used as the `category` label of hatchery_launch_failures_total
+const (
+	launchFailureUnauthorized = "unauthorized"
+	launchFailureLimit        = "workspace-limit"
+	launchFailureSize         = "invalid-size"
+	launchFailureUnsupported  = "unsupported"
+	launchFailureNextflow     = "nextflow"
+	launchFailureLicense      = "license"
+	launchFailurePayModel     = "pay-model"
+	launchFailureBackend      = "backend"
+	launchFailureImage        = "image"
+	launchFailureScheduling   = "scheduling"
+	launchFailureWorkspace    = "workspace"
+	launchFailureNotStarted   = "not-started"
+)
+
+// Value of the `container` label when the container of a workspace is not known
+const unknownContainerLabelValue = "unknown"
+
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	launchesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hatchery_launches_total",
+		Help: "Number of workspace launches accepted, by container and backend",
+	}, []string{"container", "backend"})
+	launchFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hatchery_launch_failures_total",
+		Help: "Number of workspace launches that failed, by container, backend and error category",
+	}, []string{"container", "backend", "category"})
+	launchDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hatchery_launch_duration_seconds",
+		Help:    "Time from a workspace launch until the workspace is first reported Running",
+		Buckets: []float64{5, 15, 30, 60, 120, 180, 300, 600, 900, 1800},
+	}, []string{"container", "backend"})
+	terminationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hatchery_terminations_total",
+		Help: "Number of workspaces terminated, by container and backend",
+	}, []string{"container", "backend"})
+	externalRequestDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hatchery_external_request_duration_seconds",
+		Help:    "Latency of the calls to arborist, fence and DynamoDB",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "operation"})
+	externalRequestErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hatchery_external_request_errors_total",
+		Help: "Number of failed calls to arborist, fence and DynamoDB",
+	}, []string{"service", "operation"})
+	costAccruedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hatchery_cost_accrued_dollars_total",
+		Help: "Cost of the terminated workspace pods, as computed by the pod tracker",
+	}, []string{"container"})
+	configReloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hatchery_config_reloads_total",
+		Help: "Number of attempts to reload a changed config file, by result",
+	}, []string{"result"})
+	configLastReloadTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "hatchery_config_last_reload_success_timestamp_seconds",
+		Help: "Time of the last successful config reload",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		launchesTotal,
+		launchFailuresTotal,
+		launchDurationSeconds,
+		terminationsTotal,
+		externalRequestDurationSeconds,
+		externalRequestErrorsTotal,
+		costAccruedTotal,
+		configReloadsTotal,
+		configLastReloadTimestamp,
+		&workspaceCollector{},
+	)
+}
+
+// workspaceBackend returns the backend workspaces run on for the given pay model
+func workspaceBackend(payModel *PayModel) string {
+	if payModel == nil || payModel.Local {
+		return backendLocal
+	}
+	if payModel.Ecs {
+		return backendECS
+	}
+	return backendExternalEKS
+}
+
+func containerLabelValue(containerName string) string {
+	if containerName == "" {
+		return unknownContainerLabelValue
+	}
+	return containerName
+}
+
+// observeExternalRequest records the latency and outcome of a call to another service
+func observeExternalRequest(service string, operation string, start time.Time, err error) {
+	externalRequestDurationSeconds.WithLabelValues(service, operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		externalRequestErrorsTotal.WithLabelValues(service, operation).Inc()
+	}
+}
+
+func recordLaunchFailure(containerName string, backend string, category string) {
+	launchFailuresTotal.WithLabelValues(containerLabelValue(containerName), backend, category).Inc()
+}
+
+func recordTermination(containerName string, backend string) {
+	terminationsTotal.WithLabelValues(containerLabelValue(containerName), backend).Inc()
+}
+
+func recordCostAccrued(containerName string, cost float64) {
+	if cost > 0 {
+		costAccruedTotal.WithLabelValues(containerLabelValue(containerName)).Add(cost)
+	}
+}
+
+// Launches waiting for their workspace to be reported Running. The launch
+// latency is observed the first time the workspace status is read as Running,
+// which the portal does continuously while a workspace is launching
+type pendingLaunch struct {
+	start     time.Time
+	container string
+	backend   string
+}
+
+var pendingLaunches = struct {
+	mu       sync.Mutex
+	launches map[WorkspaceRef]pendingLaunch
+}{launches: make(map[WorkspaceRef]pendingLaunch)}
+
+// Launches that are not reported Running within this time are dropped
+const pendingLaunchTimeout = time.Hour
+
+func recordLaunch(ref WorkspaceRef, containerName string, backend string) {
+	launchesTotal.WithLabelValues(containerLabelValue(containerName), backend).Inc()
+
+	pendingLaunches.mu.Lock()
+	defer pendingLaunches.mu.Unlock()
+	now := time.Now()
+	for pendingRef, launch := range pendingLaunches.launches {
+		if now.Sub(launch.start) > pendingLaunchTimeout {
+			recordLaunchFailure(launch.container, launch.backend, launchFailureNotStarted)
+			delete(pendingLaunches.launches, pendingRef)
+		}
+	}
+	pendingLaunches.launches[ref] = pendingLaunch{start: now, container: containerName, backend: backend}
+}
+
+// observeWorkspaceStatus completes the pending launch of the workspace, if
+// any, once the workspace is Running or has failed to launch
+func observeWorkspaceStatus(ref WorkspaceRef, status *WorkspaceStatus) {
+	if status == nil {
+		return
+	}
+	pendingLaunches.mu.Lock()
+	defer pendingLaunches.mu.Unlock()
+	launch, ok := pendingLaunches.launches[ref]
+	if !ok {
+		return
+	}
+	switch status.Status {
+	case "Running":
+		launchDurationSeconds.WithLabelValues(containerLabelValue(launch.container), launch.backend).Observe(time.Since(launch.start).Seconds())
+	case "Failed", "Stopped":
+		recordLaunchFailure(launch.container, launch.backend, launchFailureCategory(status.Diagnostics))
+	default:
+		return
+	}
+	delete(pendingLaunches.launches, ref)
+}
+
+// failPendingLaunch records the failure of the pending launch of the workspace, if any
+func failPendingLaunch(ref WorkspaceRef, category string) {
+	pendingLaunches.mu.Lock()
+	defer pendingLaunches.mu.Unlock()
+	if launch, ok := pendingLaunches.launches[ref]; ok {
+		recordLaunchFailure(launch.container, launch.backend, category)
+		delete(pendingLaunches.launches, ref)
+	}
+}
+
+// forgetPendingLaunch drops the pending launch of a workspace terminated before it was running
+func forgetPendingLaunch(ref WorkspaceRef) {
+	pendingLaunches.mu.Lock()
+	defer pendingLaunches.mu.Unlock()
+	delete(pendingLaunches.launches, ref)
+}
+
+// launchFailureCategory groups the launch failure diagnostics into a few categories
+func launchFailureCategory(diagnostics *WorkspaceDiagnostics) string {
+	if diagnostics == nil {
+		return launchFailureWorkspace
+	}
+	switch diagnostics.Reason {
+	case "ErrImagePull", "ImagePullBackOff", "ErrImageNeverPull", "InvalidImageName", "CannotPullContainerError":
+		return launchFailureImage
+	case "Unschedulable", "FailedScheduling", "NotTriggerScaleUp":
+		return launchFailureScheduling
+	}
+	return launchFailureWorkspace
+}
+
+// workspaceCollector computes the gauges that reflect the current state of the
+// workspaces when the metrics are scraped
+type workspaceCollector struct{}
+
+var (
+	runningWorkspacesDesc = prometheus.NewDesc(
+		"hatchery_running_workspaces",
+		"Number of workspaces currently running, by container",
+		[]string{"container"}, nil,
+	)
+	licensesInUseDesc = prometheus.NewDesc(
+		"hatchery_licenses_in_use",
+		"Number of license ids currently in use, by license type",
+		[]string{"license_type"}, nil,
+	)
+)
+
+func (c *workspaceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- runningWorkspacesDesc
+	ch <- licensesInUseDesc
+}
+
+func (c *workspaceCollector) Collect(ch chan<- prometheus.Metric) {
+	if Config() == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	services, err := listWorkspaceServices(ctx)
+	if err != nil {
+		Config().Logger.Printf("Metrics: unable to list workspaces: %v", err)
+	} else {
+		counts := make(map[string]int)
+		for _, service := range services {
+			if _, ok := workspaceRefFromAnnotations(service.Annotations); ok {
+				counts[containerLabelValue(service.Annotations[containerNameAnnotation])]++
+			}
+		}
+		for container, count := range counts {
+			ch <- prometheus.MustNewConstMetric(runningWorkspacesDesc, prometheus.GaugeValue, float64(count), container)
+		}
+	}
+
+	licensesInUse := countLicensesInUse()
+	for licenseType, count := range licensesInUse {
+		ch <- prometheus.MustNewConstMetric(licensesInUseDesc, prometheus.GaugeValue, float64(count), licenseType)
+	}
+}
+
+// countLicensesInUse returns the number of active license user maps for
+// each license type used by the configured containers
+var countLicensesInUse = func() map[string]int {
+	counts := make(map[string]int)
+	if Config().Config.LicenseUserMapsTable == "" {
+		return counts
+	}
+	var dbconfig *DbConfig
+	for _, container := range Config().Config.Containers {
+		if !container.License.Enabled {
+			continue
+		}
+		if _, ok := counts[container.License.LicenseType]; ok {
+			continue
+		}
+		if dbconfig == nil {
+			dbconfig = initializeDbConfig()
+		}
+		activeGen3LicenseUsers, err := getActiveGen3LicenseUserMaps(dbconfig, container)
+		if err != nil {
+			Config().Logger.Printf("Metrics: unable to get active gen3 license users for license type %s: %v", container.License.LicenseType, err)
+			continue
+		}
+		counts[container.License.LicenseType] = len(activeGen3LicenseUsers)
+	}
+	return counts
+}
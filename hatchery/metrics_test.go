@@ -0,0 +1,143 @@
+package hatchery
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_WorkspaceBackend(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	testCases := []struct {
+		name     string
+		payModel *PayModel
+		want     string
+	}{
+		{name: "NoPayModel", payModel: nil, want: backendLocal},
+		{name: "Local", payModel: &PayModel{Local: true}, want: backendLocal},
+		{name: "ECS", payModel: &PayModel{Ecs: true}, want: backendECS},
+		{name: "ExternalEKS", payModel: &PayModel{}, want: backendExternalEKS},
+	}
+	for _, testcase := range testCases {
+		if got := workspaceBackend(testcase.payModel); got != testcase.want {
+			t.Errorf("\nassertion error while testing `%s`: \nWant:%s\nGot:%s", testcase.name, testcase.want, got)
+		}
+	}
+}
+
+func histogramSampleCount(t *testing.T, metric prometheus.Metric) uint64 {
+	m := &dto.Metric{}
+	if err := metric.Write(m); err != nil {
+		t.Fatalf("unable to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func Test_LaunchMetrics(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	runningRef := WorkspaceRef{UserName: "metricsUser", WorkspaceId: "running"}
+	failedRef := WorkspaceRef{UserName: "metricsUser", WorkspaceId: "failed"}
+	launchesBefore := testutil.ToFloat64(launchesTotal.WithLabelValues("MetricsContainer", backendLocal))
+	latencyBefore := histogramSampleCount(t, launchDurationSeconds.WithLabelValues("MetricsContainer", backendLocal).(prometheus.Metric))
+	failuresBefore := testutil.ToFloat64(launchFailuresTotal.WithLabelValues("MetricsContainer", backendLocal, launchFailureImage))
+
+	recordLaunch(runningRef, "MetricsContainer", backendLocal)
+	recordLaunch(failedRef, "MetricsContainer", backendLocal)
+
+	// still launching: the launch stays pending
+	observeWorkspaceStatus(runningRef, &WorkspaceStatus{Status: "Launching"})
+	observeWorkspaceStatus(runningRef, &WorkspaceStatus{Status: "Running"})
+	// only the first Running status is observed
+	observeWorkspaceStatus(runningRef, &WorkspaceStatus{Status: "Running"})
+	observeWorkspaceStatus(failedRef, &WorkspaceStatus{Status: "Failed", Diagnostics: &WorkspaceDiagnostics{Reason: "InvalidImageName"}})
+
+	if got := testutil.ToFloat64(launchesTotal.WithLabelValues("MetricsContainer", backendLocal)) - launchesBefore; got != 2 {
+		t.Errorf("\nassertion error while testing `LaunchMetrics` launches: \nWant:%v\nGot:%v", 2, got)
+	}
+	if got := histogramSampleCount(t, launchDurationSeconds.WithLabelValues("MetricsContainer", backendLocal).(prometheus.Metric)) - latencyBefore; got != 1 {
+		t.Errorf("\nassertion error while testing `LaunchMetrics` latency observations: \nWant:%v\nGot:%v", 1, got)
+	}
+	if got := testutil.ToFloat64(launchFailuresTotal.WithLabelValues("MetricsContainer", backendLocal, launchFailureImage)) - failuresBefore; got != 1 {
+		t.Errorf("\nassertion error while testing `LaunchMetrics` failures: \nWant:%v\nGot:%v", 1, got)
+	}
+
+	pendingLaunches.mu.Lock()
+	_, runningPending := pendingLaunches.launches[runningRef]
+	_, failedPending := pendingLaunches.launches[failedRef]
+	pendingLaunches.mu.Unlock()
+	if runningPending || failedPending {
+		t.Errorf("\nassertion error while testing `LaunchMetrics`: completed launches are still pending")
+	}
+}
+
+func Test_LaunchFailureCategory(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	testCases := []struct {
+		reason string
+		want   string
+	}{
+		{reason: "ImagePullBackOff", want: launchFailureImage},
+		{reason: "CannotPullContainerError", want: launchFailureImage},
+		{reason: "NotTriggerScaleUp", want: launchFailureScheduling},
+		{reason: "OOMKilled", want: launchFailureWorkspace},
+	}
+	for _, testcase := range testCases {
+		got := launchFailureCategory(&WorkspaceDiagnostics{Reason: testcase.reason})
+		if got != testcase.want {
+			t.Errorf("\nassertion error while testing `LaunchFailureCategory` for %s: \nWant:%s\nGot:%s", testcase.reason, testcase.want, got)
+		}
+	}
+	if got := launchFailureCategory(nil); got != launchFailureWorkspace {
+		t.Errorf("\nassertion error while testing `LaunchFailureCategory` without diagnostics: \nWant:%s\nGot:%s", launchFailureWorkspace, got)
+	}
+}
+
+func Test_MetricsEndpoint(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalListWorkspaceServices := listWorkspaceServices
+	originalCountLicensesInUse := countLicensesInUse
+	defer func() {
+		listWorkspaceServices = originalListWorkspaceServices
+		countLicensesInUse = originalCountLicensesInUse
+	}()
+
+	listWorkspaceServices = func(ctx context.Context) ([]k8sv1.Service, error) {
+		return []k8sv1.Service{
+			{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"gen3username": "a", containerNameAnnotation: "Jupyter"}}},
+			{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"gen3username": "b", containerNameAnnotation: "Jupyter"}}},
+			{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"gen3username": "c"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "not-a-workspace"}},
+		}, nil
+	}
+	countLicensesInUse = func() map[string]int {
+		return map[string]int{"STATA-HEAL": 3}
+	}
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}).ServeHTTP(w, req)
+	body := w.Body.String()
+
+	want := []string{
+		`hatchery_running_workspaces{container="Jupyter"} 2`,
+		`hatchery_running_workspaces{container="unknown"} 1`,
+		`hatchery_licenses_in_use{license_type="STATA-HEAL"} 3`,
+	}
+	for _, line := range want {
+		if !strings.Contains(body, line) {
+			t.Errorf("\nassertion error while testing `MetricsEndpoint`: \nWant line:%s\nGot:%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package hatchery
+
+import (
+	"context"
+
+	k8sv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	networkingclientv1 "k8s.io/client-go/kubernetes/typed/networking/v1"
+)
+
+// the instance metadata service, which would give workspaces the node's credentials
+const instanceMetadataCIDR = "169.254.169.254/32"
+
+// buildNetworkPolicy builds the NetworkPolicy of the workspace's pod: ingress is only
+// allowed from the proxy, and egress is allowed to the cluster's DNS, to the internet, to
+// the allowed egress and to the container's egress, but not to the instance metadata
+// service or the cluster
+func buildNetworkPolicy(hatchConfig *FullHatcheryConfig, hatchApp *Container, userName string, workspaceId string, pod *k8sv1.Pod, external bool) *networkingv1.NetworkPolicy {
+	networkPolicyConfig := hatchConfig.Config.NetworkPolicy
+	podName := workspaceToResourceName(userName, workspaceId, "pod")
+
+	var ingressPeers []networkingv1.NetworkPolicyPeer
+	if !external {
+		// the proxy runs in the local cluster only
+		ingressPeers = append(ingressPeers, networkingv1.NetworkPolicyPeer{
+			PodSelector:       &metav1.LabelSelector{MatchLabels: networkPolicyConfig.ProxyPodSelector},
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: networkPolicyConfig.ProxyNamespaceSelector},
+		})
+	}
+	for _, cidr := range networkPolicyConfig.IngressCIDRs {
+		ingressPeers = append(ingressPeers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	tcp := k8sv1.ProtocolTCP
+	udp := k8sv1.ProtocolUDP
+	targetPort := intstr.FromInt32(hatchApp.TargetPort)
+	dnsPort := intstr.FromInt32(53)
+
+	egress := []networkingv1.NetworkPolicyEgressRule{
+		{
+			To: []networkingv1.NetworkPolicyPeer{{
+				PodSelector:       &metav1.LabelSelector{MatchLabels: networkPolicyConfig.DNSPodSelector},
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: networkPolicyConfig.DNSNamespaceSelector},
+			}},
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &udp, Port: &dnsPort},
+				{Protocol: &tcp, Port: &dnsPort},
+			},
+		},
+		{
+			To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{
+				CIDR:   "0.0.0.0/0",
+				Except: append([]string{instanceMetadataCIDR}, networkPolicyConfig.ClusterCIDRs...),
+			}}},
+		},
+	}
+	for _, rule := range networkPolicyConfig.AllowedEgress {
+		egress = append(egress, networkPolicyEgressRule(rule))
+	}
+	for _, rule := range hatchApp.NetworkEgress {
+		egress = append(egress, networkPolicyEgressRule(rule))
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        workspaceToResourceName(userName, workspaceId, "netpol"),
+			Namespace:   hatchConfig.Config.UserNamespace,
+			Annotations: pod.Annotations,
+			Labels:      pod.Labels,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": podName}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From:  ingressPeers,
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &targetPort}},
+				},
+			},
+			Egress: egress,
+		},
+	}
+}
+
+func networkPolicyEgressRule(rule NetworkPolicyRule) networkingv1.NetworkPolicyEgressRule {
+	egressRule := networkingv1.NetworkPolicyEgressRule{}
+	for _, cidr := range rule.CIDRs {
+		egressRule.To = append(egressRule.To, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	if rule.PodSelector != nil || rule.NamespaceSelector != nil {
+		peer := networkingv1.NetworkPolicyPeer{}
+		if rule.PodSelector != nil {
+			peer.PodSelector = &metav1.LabelSelector{MatchLabels: rule.PodSelector}
+		}
+		if rule.NamespaceSelector != nil {
+			peer.NamespaceSelector = &metav1.LabelSelector{MatchLabels: rule.NamespaceSelector}
+		}
+		egressRule.To = append(egressRule.To, peer)
+	}
+	for _, port := range rule.Ports {
+		protocol := k8sv1.ProtocolTCP
+		if port.Protocol != "" {
+			protocol = k8sv1.Protocol(port.Protocol)
+		}
+		portNumber := intstr.FromInt32(port.Port)
+		egressRule.Ports = append(egressRule.Ports, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &portNumber})
+	}
+	return egressRule
+}
+
+// getNetworkPolicyClient returns a client for the NetworkPolicies of the cluster the workspace runs in
+var getNetworkPolicyClient = func(ctx context.Context, userName string, payModelPtr *PayModel) (networkingclientv1.NetworkingV1Interface, error) {
+	if payModelPtr != nil && !(*payModelPtr).Local {
+		clientset, err := newEKSKubernetesClientset(ctx, userName, *payModelPtr)
+		if err != nil {
+			return nil, err
+		}
+		return clientset.NetworkingV1(), nil
+	}
+	config, err := GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return clientset.NetworkingV1(), nil
+}
+
+// applyWorkspaceNetworkPolicy creates the NetworkPolicy of the workspace, or replaces
+// the one left over by a workspace that was not cleaned up
+func applyWorkspaceNetworkPolicy(ctx context.Context, client networkingclientv1.NetworkingV1Interface, userName string, networkPolicy *networkingv1.NetworkPolicy) error {
+	networkPolicies := client.NetworkPolicies(networkPolicy.Namespace)
+	_, err := networkPolicies.Create(ctx, networkPolicy, metav1.CreateOptions{})
+	if k8sErrors.IsAlreadyExists(err) {
+		var existing *networkingv1.NetworkPolicy
+		existing, err = networkPolicies.Get(ctx, networkPolicy.Name, metav1.GetOptions{})
+		if err == nil {
+			networkPolicy.ResourceVersion = existing.ResourceVersion
+			_, err = networkPolicies.Update(ctx, networkPolicy, metav1.UpdateOptions{})
+		}
+	}
+	if err != nil {
+		Config().Logger.Printf("Failed to create NetworkPolicy %s for user %s. Error: %v", networkPolicy.Name, userName, err)
+		return err
+	}
+	Config().Logger.Printf("Created NetworkPolicy %s for user %s", networkPolicy.Name, userName)
+	return nil
+}
+
+// createWorkspaceNetworkPolicy creates the NetworkPolicy of the workspace's pod, if enabled
+var createWorkspaceNetworkPolicy = func(ctx context.Context, hatchConfig *FullHatcheryConfig, hatchApp *Container, userName string, workspaceId string, pod *k8sv1.Pod, payModelPtr *PayModel) error {
+	if !hatchConfig.Config.NetworkPolicy.Enabled {
+		return nil
+	}
+	client, err := getNetworkPolicyClient(ctx, userName, payModelPtr)
+	if err != nil {
+		Config().Logger.Printf("Failed to create NetworkPolicy client for user %s. Error: %v", userName, err)
+		return err
+	}
+	external := payModelPtr != nil && !(*payModelPtr).Local
+	return applyWorkspaceNetworkPolicy(ctx, client, userName, buildNetworkPolicy(hatchConfig, hatchApp, userName, workspaceId, pod, external))
+}
+
+// deleteWorkspaceNetworkPolicy deletes the NetworkPolicy of the workspace's pod, if any
+var deleteWorkspaceNetworkPolicy = func(ctx context.Context, userName string, workspaceId string, payModelPtr *PayModel) error {
+	if !Config().Config.NetworkPolicy.Enabled {
+		return nil
+	}
+	client, err := getNetworkPolicyClient(ctx, userName, payModelPtr)
+	if err != nil {
+		return err
+	}
+	name := workspaceToResourceName(userName, workspaceId, "netpol")
+	err = client.NetworkPolicies(Config().Config.UserNamespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package hatchery
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	networkingclientv1 "k8s.io/client-go/kubernetes/typed/networking/v1"
+)
+
+func networkPolicyTestConfig(networkPolicy NetworkPolicyConfig) func(config *FullHatcheryConfig) {
+	return func(config *FullHatcheryConfig) {
+		config.Config.UserNamespace = "jupyter-pods"
+		config.Config.NetworkPolicy = networkPolicy
+	}
+}
+
+func Test_BuildNetworkPolicy(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	withTestConfig(t, networkPolicyTestConfig(NetworkPolicyConfig{
+		Enabled:          true,
+		ProxyPodSelector: map[string]string{"app": "ambassador"},
+		DNSPodSelector:   map[string]string{"k8s-app": "kube-dns"},
+		IngressCIDRs:     []string{"10.10.0.0/16"},
+		ClusterCIDRs:     []string{"172.20.0.0/16"},
+		AllowedEgress:    []NetworkPolicyRule{{NamespaceSelector: map[string]string{"name": "default"}, PodSelector: map[string]string{"app": "fence"}}},
+	}))
+
+	hatchApp := &Container{Name: "Jupyter", TargetPort: 8888, NetworkEgress: []NetworkPolicyRule{
+		{CIDRs: []string{"10.1.2.3/32"}, Ports: []NetworkPolicyPort{{Port: 5432}, {Port: 5353, Protocol: "UDP"}}},
+	}}
+	pod := &k8sv1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "hatchery-frickjack--rstudio"}}}
+
+	networkPolicy := buildNetworkPolicy(Config(), hatchApp, "frickjack", "rstudio", pod, false)
+	if networkPolicy.Name != "netpol-frickjack--rstudio" || networkPolicy.Namespace != "jupyter-pods" {
+		t.Errorf("\nassertion error while testing `buildNetworkPolicy` name: \nWant:%s\nGot:%s/%s", "jupyter-pods/netpol-frickjack--rstudio", networkPolicy.Namespace, networkPolicy.Name)
+	}
+	if got := networkPolicy.Spec.PodSelector.MatchLabels["app"]; got != "hatchery-frickjack--rstudio" {
+		t.Errorf("\nassertion error while testing `buildNetworkPolicy` pod selector: \nWant:%s\nGot:%s", "hatchery-frickjack--rstudio", got)
+	}
+
+	ingress := networkPolicy.Spec.Ingress
+	if len(ingress) != 1 || len(ingress[0].From) != 2 || ingress[0].Ports[0].Port.IntVal != 8888 {
+		t.Fatalf("\nassertion error while testing `buildNetworkPolicy` ingress: \nWant:the proxy and the ingress CIDRs on port 8888\nGot:%+v", ingress)
+	}
+	if proxy := ingress[0].From[0]; proxy.PodSelector.MatchLabels["app"] != "ambassador" || proxy.NamespaceSelector == nil {
+		t.Errorf("\nassertion error while testing `buildNetworkPolicy` proxy: \nWant:ambassador pods in any namespace\nGot:%+v", proxy)
+	}
+	if cidr := ingress[0].From[1].IPBlock; cidr == nil || cidr.CIDR != "10.10.0.0/16" {
+		t.Errorf("\nassertion error while testing `buildNetworkPolicy` ingress CIDRs: \nWant:%s\nGot:%+v", "10.10.0.0/16", cidr)
+	}
+
+	egress := networkPolicy.Spec.Egress
+	if len(egress) != 4 {
+		t.Fatalf("\nassertion error while testing `buildNetworkPolicy` egress: \nWant:4 rules\nGot:%+v", egress)
+	}
+	if dns := egress[0]; len(dns.To) != 1 || dns.To[0].PodSelector.MatchLabels["k8s-app"] != "kube-dns" || dns.To[0].IPBlock != nil {
+		t.Errorf("\nassertion error while testing `buildNetworkPolicy` DNS egress: \nWant:%s\nGot:%+v", "the kube-dns pods only", dns)
+	}
+	except := strings.Join(egress[1].To[0].IPBlock.Except, ",")
+	if except != "169.254.169.254/32,172.20.0.0/16" {
+		t.Errorf("\nassertion error while testing `buildNetworkPolicy` denied egress: \nWant:%s\nGot:%s", "169.254.169.254/32,172.20.0.0/16", except)
+	}
+	if peer := egress[2].To[0]; peer.PodSelector.MatchLabels["app"] != "fence" || peer.NamespaceSelector.MatchLabels["name"] != "default" {
+		t.Errorf("\nassertion error while testing `buildNetworkPolicy` allowed egress: \nGot:%+v", peer)
+	}
+	if ports := egress[3].Ports; len(ports) != 2 || *ports[0].Protocol != k8sv1.ProtocolTCP || *ports[1].Protocol != k8sv1.ProtocolUDP || ports[1].Port.IntVal != 5353 {
+		t.Errorf("\nassertion error while testing `buildNetworkPolicy` container egress: \nGot:%+v", ports)
+	}
+
+	// the proxy is not in external clusters
+	networkPolicy = buildNetworkPolicy(Config(), hatchApp, "frickjack", "", pod, true)
+	if from := networkPolicy.Spec.Ingress[0].From; len(from) != 1 || from[0].IPBlock == nil {
+		t.Errorf("\nassertion error while testing `buildNetworkPolicy` for an external workspace: \nWant:the ingress CIDRs only\nGot:%+v", from)
+	}
+}
+
+func Test_CreateAndDeleteWorkspaceNetworkPolicy(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	withTestConfig(t, networkPolicyTestConfig(NetworkPolicyConfig{Enabled: true, ProxyPodSelector: map[string]string{"app": "ambassador"}}))
+
+	client := fake.NewSimpleClientset().NetworkingV1()
+	originalGetNetworkPolicyClient := getNetworkPolicyClient
+	getNetworkPolicyClient = func(ctx context.Context, userName string, payModelPtr *PayModel) (networkingclientv1.NetworkingV1Interface, error) {
+		return client, nil
+	}
+	defer func() {
+		getNetworkPolicyClient = originalGetNetworkPolicyClient
+	}()
+
+	ctx := context.Background()
+	hatchApp := &Container{Name: "Jupyter", TargetPort: 8888}
+	pod := &k8sv1.Pod{}
+	if err := createWorkspaceNetworkPolicy(ctx, Config(), hatchApp, "frickjack", "", pod, nil); err != nil {
+		t.Fatalf("\nassertion error while testing `createWorkspaceNetworkPolicy`: unexpected error: %v", err)
+	}
+	// a policy left over by a previous workspace is replaced
+	hatchApp.TargetPort = 8787
+	if err := createWorkspaceNetworkPolicy(ctx, Config(), hatchApp, "frickjack", "", pod, nil); err != nil {
+		t.Errorf("\nassertion error while testing `createWorkspaceNetworkPolicy` with an existing policy: unexpected error: %v", err)
+	}
+	networkPolicy, err := client.NetworkPolicies("jupyter-pods").Get(ctx, "netpol-frickjack", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("\nassertion error while testing `createWorkspaceNetworkPolicy`: the policy was not created: %v", err)
+	}
+	if got := networkPolicy.Spec.Ingress[0].Ports[0].Port.IntVal; got != 8787 {
+		t.Errorf("\nassertion error while testing `createWorkspaceNetworkPolicy` replacement: \nWant:%d\nGot:%d", 8787, got)
+	}
+
+	if err := deleteWorkspaceNetworkPolicy(ctx, "frickjack", "", nil); err != nil {
+		t.Errorf("\nassertion error while testing `deleteWorkspaceNetworkPolicy`: unexpected error: %v", err)
+	}
+	if _, err := client.NetworkPolicies("jupyter-pods").Get(ctx, "netpol-frickjack", metav1.GetOptions{}); err == nil {
+		t.Errorf("\nassertion error while testing `deleteWorkspaceNetworkPolicy`: the policy was not deleted")
+	}
+	// deleting a policy that does not exist is not an error
+	if err := deleteWorkspaceNetworkPolicy(ctx, "frickjack", "", nil); err != nil {
+		t.Errorf("\nassertion error while testing `deleteWorkspaceNetworkPolicy` without a policy: unexpected error: %v", err)
+	}
+}
@@ -45,7 +45,7 @@ func createNextflowResources(userName string, nextflowGlobalConfig NextflowGloba
 	if err != nil {
 		return "", "", err
 	}
-	Config.Logger.Printf("AWS account ID: '%v'", awsAccountId)
+	Config().Logger.Printf("AWS account ID: '%v'", awsAccountId)
 	batchSvc := batch.New(sess, &awsConfig)
 	iamSvc := iam.New(sess, &awsConfig)
 	s3Svc := s3.New(sess, &awsConfig)
@@ -117,21 +117,21 @@ func createNextflowResources(userName string, nextflowGlobalConfig NextflowGloba
 	squidInstanceType := "t2.micro"
 	vpcid, subnetids, err := setupVpcAndSquid(ec2Svc, userName, hostname, nextflowConfig.InstanceType, squidInstanceType)
 	if err != nil {
-		Config.Logger.Printf("Unable to setup VPC: %v", err)
+		Config().Logger.Printf("Unable to setup VPC: %v", err)
 		return "", "", err
 	}
 
 	// Create nextflow compute environment if it does not exist
 	batchComputeEnvArn, err := createBatchComputeEnvironment(nextflowGlobalConfig, nextflowConfig, userName, hostname, tagsMap, batchSvc, ec2Svc, iamSvc, *vpcid, *subnetids)
 	if err != nil {
-		Config.Logger.Printf("Error creating compute environment for user %s: %s", userName, err.Error())
+		Config().Logger.Printf("Error creating compute environment for user %s: %s", userName, err.Error())
 		return "", "", err
 	}
 
 	// Create S3 bucket
 	kmsKeyArn, err := createS3bucket(nextflowGlobalConfig, s3Svc, kmsSvc, bucketName, kmsTags)
 	if err != nil {
-		Config.Logger.Printf("Error creating S3 bucket '%s': %v", bucketName, err)
+		Config().Logger.Printf("Error creating S3 bucket '%s': %v", bucketName, err)
 		return "", "", err
 	}
 
@@ -150,13 +150,13 @@ func createNextflowResources(userName string, nextflowGlobalConfig NextflowGloba
 	})
 	if err != nil {
 		if strings.Contains(err.Error(), "Object already exists") {
-			Config.Logger.Printf("Debug: Batch job queue '%s' already exists", batchJobQueueName)
+			Config().Logger.Printf("Debug: Batch job queue '%s' already exists", batchJobQueueName)
 		} else {
-			Config.Logger.Printf("Error creating Batch job queue '%s': %v", batchJobQueueName, err)
+			Config().Logger.Printf("Error creating Batch job queue '%s': %v", batchJobQueueName, err)
 			return "", "", err
 		}
 	} else {
-		Config.Logger.Printf("Created Batch job queue '%s'", batchJobQueueName)
+		Config().Logger.Printf("Created Batch job queue '%s'", batchJobQueueName)
 	}
 
 	// create IAM policy for nextflow-created jobs
@@ -234,25 +234,25 @@ func createNextflowResources(userName string, nextflowGlobalConfig NextflowGloba
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			if aerr.Code() == iam.ErrCodeEntityAlreadyExistsException {
-				Config.Logger.Printf("Debug: role '%s' already exists", roleName)
+				Config().Logger.Printf("Debug: role '%s' already exists", roleName)
 				listRolesResult, err := iamSvc.ListRoles(&iam.ListRolesInput{
 					PathPrefix: pathPrefix,
 				})
 				if err != nil || len(listRolesResult.Roles) == 0 {
-					Config.Logger.Printf("Error getting existing role '%s': %v", roleName, err)
+					Config().Logger.Printf("Error getting existing role '%s': %v", roleName, err)
 					return "", "", err
 				}
 				nextflowJobsRoleArn = *listRolesResult.Roles[0].Arn
 			} else {
-				Config.Logger.Printf("Error creating role '%s': %v", roleName, aerr)
+				Config().Logger.Printf("Error creating role '%s': %v", roleName, aerr)
 				return "", "", err
 			}
 		} else {
-			Config.Logger.Printf("Error creating role '%s': %v", roleName, err)
+			Config().Logger.Printf("Error creating role '%s': %v", roleName, err)
 			return "", "", err
 		}
 	} else {
-		Config.Logger.Printf("Created role '%s'", roleName)
+		Config().Logger.Printf("Created role '%s'", roleName)
 		nextflowJobsRoleArn = *roleResult.Role.Arn
 	}
 
@@ -262,10 +262,10 @@ func createNextflowResources(userName string, nextflowGlobalConfig NextflowGloba
 		RoleName:  &roleName,
 	})
 	if err != nil {
-		Config.Logger.Printf("Error attaching policy '%s' to role '%s': %v", policyName, roleName, err)
+		Config().Logger.Printf("Error attaching policy '%s' to role '%s': %v", policyName, roleName, err)
 		return "", "", err
 	} else {
-		Config.Logger.Printf("Attached policy '%s' to role '%s'", policyName, roleName)
+		Config().Logger.Printf("Attached policy '%s' to role '%s'", policyName, roleName)
 	}
 
 	// create IAM policy for nextflow client
@@ -390,22 +390,22 @@ func createNextflowResources(userName string, nextflowGlobalConfig NextflowGloba
 	})
 	if err != nil {
 		if strings.Contains(err.Error(), "EntityAlreadyExists") {
-			Config.Logger.Printf("Debug: user '%s' already exists", nextflowUserName)
+			Config().Logger.Printf("Debug: user '%s' already exists", nextflowUserName)
 
 			// delete any existing access keys to avoid `LimitExceeded: Cannot exceed
 			// quota for AccessKeysPerUser: 2` error
 			err = deleteUserAccessKeys(nextflowUserName, iamSvc.ListAccessKeys, iamSvc.DeleteAccessKey)
 			if err != nil {
-				Config.Logger.Printf("Unable to delete access keys for user '%s': %v", nextflowUserName, err)
+				Config().Logger.Printf("Unable to delete access keys for user '%s': %v", nextflowUserName, err)
 				return "", "", err
 			}
 
 		} else {
-			Config.Logger.Printf("Error creating user '%s': %v", nextflowUserName, err)
+			Config().Logger.Printf("Error creating user '%s': %v", nextflowUserName, err)
 			return "", "", err
 		}
 	} else {
-		Config.Logger.Printf("Created user '%s'", nextflowUserName)
+		Config().Logger.Printf("Created user '%s'", nextflowUserName)
 	}
 
 	// attach policy to user for nextflow client
@@ -414,10 +414,10 @@ func createNextflowResources(userName string, nextflowGlobalConfig NextflowGloba
 		PolicyArn: &nextflowPolicyArn,
 	})
 	if err != nil {
-		Config.Logger.Printf("Error attaching policy '%s' to user '%s': %v", policyName, nextflowUserName, err)
+		Config().Logger.Printf("Error attaching policy '%s' to user '%s': %v", policyName, nextflowUserName, err)
 		return "", "", err
 	} else {
-		Config.Logger.Printf("Attached policy '%s' to user '%s'", policyName, nextflowUserName)
+		Config().Logger.Printf("Attached policy '%s' to user '%s'", policyName, nextflowUserName)
 	}
 
 	// create access key for the nextflow user
@@ -425,12 +425,12 @@ func createNextflowResources(userName string, nextflowGlobalConfig NextflowGloba
 		UserName: &nextflowUserName,
 	})
 	if err != nil {
-		Config.Logger.Printf("Error creating access key for user '%s': %v", nextflowUserName, err)
+		Config().Logger.Printf("Error creating access key for user '%s': %v", nextflowUserName, err)
 		return "", "", err
 	}
 	keyId := *accessKeyResult.AccessKey.AccessKeyId
 	keySecret := *accessKeyResult.AccessKey.SecretAccessKey
-	Config.Logger.Printf("Created access key '%v' for user '%s'", keyId, nextflowUserName)
+	Config().Logger.Printf("Created access key '%v' for user '%s'", keyId, nextflowUserName)
 
 	return keyId, keySecret, nil
 }
@@ -440,20 +440,20 @@ var getNextflowAwsSettings = func(sess *session.Session, payModel *PayModel, use
 	var awsConfig aws.Config
 	var awsAccountId string
 	if payModel != nil && payModel.Ecs {
-		Config.Logger.Printf("Info: pay model enabled for user '%s': %s Nextflow resources in user's AWS account", userName, action)
+		Config().Logger.Printf("Info: pay model enabled for user '%s': %s Nextflow resources in user's AWS account", userName, action)
 		roleArn := fmt.Sprintf("arn:aws:iam::%s:role/csoc_adminvm", payModel.AWSAccountId)
 		awsConfig = aws.Config{
 			Credentials: stscreds.NewCredentials(sess, roleArn),
 		}
 		awsAccountId = payModel.AWSAccountId
 	} else {
-		Config.Logger.Printf("Info: pay model disabled for user '%s': %s Nextflow resources in main AWS account", userName, action)
+		Config().Logger.Printf("Info: pay model disabled for user '%s': %s Nextflow resources in main AWS account", userName, action)
 		awsConfig = aws.Config{}
-		Config.Logger.Printf("Debug: Getting AWS account ID...")
+		Config().Logger.Printf("Debug: Getting AWS account ID...")
 		var err error
 		awsAccountId, err = getAwsAccountId(sess, &awsConfig)
 		if err != nil {
-			Config.Logger.Printf("Error getting AWS account ID: %v", err)
+			Config().Logger.Printf("Error getting AWS account ID: %v", err)
 			return "", awsConfig, err
 		}
 	}
@@ -506,12 +506,12 @@ func setupVpcAndSquid(ec2Svc *ec2.EC2, userName string, hostname string, compute
 	vpcid := ""
 	// TODO: Check that the VPC is configured correctly, and not just that it exists (MIDRC-748)
 	if len(vpc.Vpcs) == 0 {
-		Config.Logger.Print("Debug: VPC does not exist, creating it now")
+		Config().Logger.Print("Debug: VPC does not exist, creating it now")
 		vpc, err := createVPC(cidrstring, vpcName, ec2Svc)
 		if err != nil {
 			return nil, nil, err
 		}
-		Config.Logger.Printf("Debug: Created VPC '%s'", vpcName)
+		Config().Logger.Printf("Debug: Created VPC '%s'", vpcName)
 
 		vpcid = *vpc.Vpc.VpcId
 	} else {
@@ -557,9 +557,9 @@ func setupVpcAndSquid(ec2Svc *ec2.EC2, userName string, hostname string, compute
 	if err != nil {
 		return nil, nil, err
 	}
-	Config.Logger.Printf("Debug: Created Squid '%s'", *fwSubnetId)
+	Config().Logger.Printf("Debug: Created Squid '%s'", *fwSubnetId)
 
-	Config.Logger.Print("Debug: Nextflow VPC setup complete")
+	Config().Logger.Print("Debug: Nextflow VPC setup complete")
 	return &vpcid, &subnetIds, nil
 }
 
@@ -571,7 +571,7 @@ func ensureLaunchTemplate(ec2Svc *ec2.EC2, userName string, hostname string, job
 
 	launchTemplateName := fmt.Sprintf("%s-nf-%s", hostname, userName)
 
-	Config.Logger.Printf("Debug: Launch template name: %s", launchTemplateName)
+	Config().Logger.Printf("Debug: Launch template name: %s", launchTemplateName)
 
 	// create launch template
 	launchTemplate, err := ec2Svc.DescribeLaunchTemplates(&ec2.DescribeLaunchTemplatesInput{
@@ -582,7 +582,7 @@ func ensureLaunchTemplate(ec2Svc *ec2.EC2, userName string, hostname string, job
 	if err != nil {
 		// If no launch template exists, create it
 		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "InvalidLaunchTemplateName.NotFoundException" {
-			Config.Logger.Printf("Debug: Launch template '%s' does not exist, creating it", launchTemplateName)
+			Config().Logger.Printf("Debug: Launch template '%s' does not exist, creating it", launchTemplateName)
 			launchTemplate, err := ec2Svc.CreateLaunchTemplate(&ec2.CreateLaunchTemplateInput{
 				LaunchTemplateName: aws.String(launchTemplateName),
 				LaunchTemplateData: &ec2.RequestLaunchTemplateData{ // if changed, need to update launch template and compute env
@@ -590,13 +590,13 @@ func ensureLaunchTemplate(ec2Svc *ec2.EC2, userName string, hostname string, job
 				},
 			})
 			if err != nil {
-				Config.Logger.Printf("Error creating launch template '%s': %v", launchTemplateName, err)
+				Config().Logger.Printf("Error creating launch template '%s': %v", launchTemplateName, err)
 				return nil, err
 			}
-			Config.Logger.Printf("Debug: Created launch template '%s'", launchTemplateName)
+			Config().Logger.Printf("Debug: Created launch template '%s'", launchTemplateName)
 			return launchTemplate.LaunchTemplate.LaunchTemplateName, nil
 		} else {
-			Config.Logger.Printf("Error describing launch template '%s': %v", launchTemplateName, err)
+			Config().Logger.Printf("Error describing launch template '%s': %v", launchTemplateName, err)
 		}
 		return nil, err
 	}
@@ -604,7 +604,7 @@ func ensureLaunchTemplate(ec2Svc *ec2.EC2, userName string, hostname string, job
 	if len(launchTemplate.LaunchTemplates) == 1 {
 		// TODO: Make sure user data in the existing launch template matches the user data
 		// we want (MIDRC-749)
-		Config.Logger.Printf("Debug: Launch template '%s' already exists", launchTemplateName)
+		Config().Logger.Printf("Debug: Launch template '%s' already exists", launchTemplateName)
 		return launchTemplate.LaunchTemplates[0].LaunchTemplateName, nil
 	}
 	return nil, fmt.Errorf("more than one launch template with the same name exist: %v", launchTemplate.LaunchTemplates)
@@ -614,7 +614,7 @@ func ensureLaunchTemplate(ec2Svc *ec2.EC2, userName string, hostname string, job
 func createBatchComputeEnvironment(nextflowGlobalConfig NextflowGlobalConfig, nextflowConfig NextflowConfig, userName string, hostname string, tagsMap map[string]*string, batchSvc *batch.Batch, ec2Svc *ec2.EC2, iamSvc *iam.IAM, vpcid string, subnetids []string) (string, error) {
 	instanceProfileArn, err := createEcsInstanceProfile(iamSvc, fmt.Sprintf("%s-nf-ecsInstanceRole", hostname))
 	if err != nil {
-		Config.Logger.Printf("Unable to create ECS instance profile: %s", err.Error())
+		Config().Logger.Printf("Unable to create ECS instance profile: %s", err.Error())
 		return "", err
 	}
 
@@ -657,7 +657,7 @@ func createBatchComputeEnvironment(nextflowGlobalConfig NextflowGlobalConfig, ne
 
 	var batchComputeEnvArn string
 	if len(batchComputeEnv.ComputeEnvironments) > 0 {
-		Config.Logger.Printf("Debug: Batch compute environment '%s' already exists, updating it", batchComputeEnvName)
+		Config().Logger.Printf("Debug: Batch compute environment '%s' already exists, updating it", batchComputeEnvName)
 		batchComputeEnvArn = *batchComputeEnv.ComputeEnvironments[0].ComputeEnvironmentArn
 
 		// wait for the compute env to be ready to be updated
@@ -701,11 +701,11 @@ func createBatchComputeEnvironment(nextflowGlobalConfig NextflowGlobalConfig, ne
 			},
 		})
 		if err != nil {
-			Config.Logger.Printf("Unable to update Batch compute environment '%s': %v", batchComputeEnvName, err)
+			Config().Logger.Printf("Unable to update Batch compute environment '%s': %v", batchComputeEnvName, err)
 			return "", err
 		}
 	} else { // compute environment does not exist, create it
-		Config.Logger.Printf("Debug: Batch compute environment '%s' does not exist, creating it", batchComputeEnvName)
+		Config().Logger.Printf("Debug: Batch compute environment '%s' does not exist, creating it", batchComputeEnvName)
 		subnets := []*string{}
 		for _, subnet := range subnetids {
 			s := subnet
@@ -755,7 +755,7 @@ func createBatchComputeEnvironment(nextflowGlobalConfig NextflowGlobalConfig, ne
 			return "", err
 		}
 
-		Config.Logger.Printf("Debug: Created AWS Batch compute environment '%s'", batchComputeEnvName)
+		Config().Logger.Printf("Debug: Created AWS Batch compute environment '%s'", batchComputeEnvName)
 		batchComputeEnvArn = *batchComputeEnvResult.ComputeEnvironmentArn
 	}
 
@@ -784,17 +784,17 @@ func waitForBatchComputeEnvironment(batchComputeEnvName string, batchSvc *batch.
 		compEnvStatus = *batchComputeEnvs.ComputeEnvironments[0].Status
 		// possible statuses: CREATING | UPDATING | DELETING | DELETED | VALID | INVALID
 		if compEnvStatus == "VALID" {
-			Config.Logger.Print("Debug: Compute environment is ready")
+			Config().Logger.Print("Debug: Compute environment is ready")
 			break
 		}
 		if !mustBeValid && compEnvStatus == "INVALID" {
-			Config.Logger.Printf("Debug: Compute environment is %s and can't be used, but can be updated", compEnvStatus)
+			Config().Logger.Printf("Debug: Compute environment is %s and can't be used, but can be updated", compEnvStatus)
 			break
 		}
 		if i == maxIter {
 			return fmt.Errorf("compute environment is not ready after %v seconds. Exiting", maxIter*iterDelaySecs)
 		}
-		Config.Logger.Printf("Info: Compute environment is %s, waiting %vs and checking again", compEnvStatus, iterDelaySecs)
+		Config().Logger.Printf("Info: Compute environment is %s, waiting %vs and checking again", compEnvStatus, iterDelaySecs)
 		time.Sleep(time.Duration(iterDelaySecs) * time.Second)
 	}
 	return nil
@@ -802,14 +802,14 @@ func waitForBatchComputeEnvironment(batchComputeEnvName string, batchSvc *batch.
 
 // Create IAM role for AWS Batch compute environment
 func createEcsInstanceProfile(iamSvc *iam.IAM, name string) (*string, error) {
-	Config.Logger.Printf("Debug: Creating ECS instance profile '%s'", name)
+	Config().Logger.Printf("Debug: Creating ECS instance profile '%s'", name)
 
 	instanceProfile, err := iamSvc.GetInstanceProfile(&iam.GetInstanceProfileInput{
 		InstanceProfileName: aws.String(name),
 	})
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == iam.ErrCodeNoSuchEntityException {
-			Config.Logger.Printf("Debug: Instance profile '%s' does not exist, creating it", name)
+			Config().Logger.Printf("Debug: Instance profile '%s' does not exist, creating it", name)
 			_, err = iamSvc.CreateInstanceProfile(&iam.CreateInstanceProfileInput{
 				InstanceProfileName: aws.String(name),
 			})
@@ -828,7 +828,7 @@ func createEcsInstanceProfile(iamSvc *iam.IAM, name string) (*string, error) {
 	}
 
 	// Create the IAM role
-	Config.Logger.Printf("Debug: Creating IAM role '%s'", name)
+	Config().Logger.Printf("Debug: Creating IAM role '%s'", name)
 	rolePolicy := `{
 		"Version": "2012-10-17",
 		"Statement": [
@@ -845,10 +845,10 @@ func createEcsInstanceProfile(iamSvc *iam.IAM, name string) (*string, error) {
 	})
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == iam.ErrCodeEntityAlreadyExistsException {
-			Config.Logger.Printf("Debug: Role '%s' already exists, assuming it is already linked to instance profile and continuing", name)
+			Config().Logger.Printf("Debug: Role '%s' already exists, assuming it is already linked to instance profile and continuing", name)
 			return instanceProfile.InstanceProfile.Arn, nil
 		} else {
-			Config.Logger.Printf("Unable to create IAM role '%s': %v", name, err)
+			Config().Logger.Printf("Unable to create IAM role '%s': %v", name, err)
 			return nil, err
 		}
 	}
@@ -867,11 +867,11 @@ func createEcsInstanceProfile(iamSvc *iam.IAM, name string) (*string, error) {
 		RoleName:            aws.String(name),
 	})
 	if err != nil {
-		Config.Logger.Printf("Unable to add role '%s' to instance profile '%s': %s", name, name, err.Error())
+		Config().Logger.Printf("Unable to add role '%s' to instance profile '%s': %s", name, name, err.Error())
 		return nil, err
 	}
 
-	Config.Logger.Printf("Info: Set up ECS instance profile '%s'", name)
+	Config().Logger.Printf("Info: Set up ECS instance profile '%s'", name)
 	return instanceProfile.InstanceProfile.Arn, nil
 }
 
@@ -889,10 +889,10 @@ func createS3bucket(nextflowGlobalConfig NextflowGlobalConfig, s3Svc *s3.S3, kms
 	if err != nil {
 		// no need to check for a specific "bucket already exists" error since
 		// `s3Svc.CreateBucket` does not error when the bucket exists
-		Config.Logger.Printf("Error creating S3 bucket '%s': %v", bucketName, err)
+		Config().Logger.Printf("Error creating S3 bucket '%s': %v", bucketName, err)
 		return "", err
 	}
-	Config.Logger.Printf("INFO: Created S3 bucket '%s'", bucketName)
+	Config().Logger.Printf("INFO: Created S3 bucket '%s'", bucketName)
 
 	// set up KMS encryption on the bucket.
 	// the only way to check if the KMS key has already been created is to use an alias
@@ -903,7 +903,7 @@ func createS3bucket(nextflowGlobalConfig NextflowGlobalConfig, s3Svc *s3.S3, kms
 	var kmsKeyArn *string
 	if err == nil {
 		kmsKeyArn = kmsDescribeKeyOutput.KeyMetadata.Arn
-		Config.Logger.Printf("DEBUG: Existing KMS key: '%s' - '%s'", kmsKeyAlias, *kmsKeyArn)
+		Config().Logger.Printf("DEBUG: Existing KMS key: '%s' - '%s'", kmsKeyAlias, *kmsKeyArn)
 	} else {
 		// if the KMS key doesn't exist, create it
 		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NotFoundException" {
@@ -911,28 +911,28 @@ func createS3bucket(nextflowGlobalConfig NextflowGlobalConfig, s3Svc *s3.S3, kms
 				Tags: kmsTags,
 			})
 			if err != nil {
-				Config.Logger.Printf("Error creating KMS key: %v", err)
+				Config().Logger.Printf("Error creating KMS key: %v", err)
 				return "", err
 			}
 			kmsKeyArn = kmsCreateKeyOutput.KeyMetadata.Arn
-			Config.Logger.Printf("INFO: Created KMS key: '%s'", *kmsKeyArn)
+			Config().Logger.Printf("INFO: Created KMS key: '%s'", *kmsKeyArn)
 
 			_, err = kmsSvc.CreateAlias(&kms.CreateAliasInput{
 				AliasName:   &kmsKeyAlias,
 				TargetKeyId: kmsKeyArn,
 			})
 			if err != nil {
-				Config.Logger.Printf("Error creating KMS key alias: %v", err)
+				Config().Logger.Printf("Error creating KMS key alias: %v", err)
 				return "", err
 			}
-			Config.Logger.Printf("INFO: Created KMS key alias: '%s'", kmsKeyAlias)
+			Config().Logger.Printf("INFO: Created KMS key alias: '%s'", kmsKeyAlias)
 		} else {
-			Config.Logger.Printf("Error describing existing KMS key '%s': %v", kmsKeyAlias, err)
+			Config().Logger.Printf("Error describing existing KMS key '%s': %v", kmsKeyAlias, err)
 			return "", err
 		}
 	}
 
-	Config.Logger.Printf("DEBUG: Setting KMS encryption on bucket '%s'", bucketName)
+	Config().Logger.Printf("DEBUG: Setting KMS encryption on bucket '%s'", bucketName)
 	_, err = s3Svc.PutBucketEncryption(&s3.PutBucketEncryptionInput{
 		Bucket: &bucketName,
 		ServerSideEncryptionConfiguration: &s3.ServerSideEncryptionConfiguration{
@@ -948,11 +948,11 @@ func createS3bucket(nextflowGlobalConfig NextflowGlobalConfig, s3Svc *s3.S3, kms
 		},
 	})
 	if err != nil {
-		Config.Logger.Printf("Unable to set bucket encryption: %v", err)
+		Config().Logger.Printf("Unable to set bucket encryption: %v", err)
 		return "", err
 	}
 
-	Config.Logger.Printf("DEBUG: Enforcing KMS encryption through bucket policy")
+	Config().Logger.Printf("DEBUG: Enforcing KMS encryption through bucket policy")
 	_, err = s3Svc.PutBucketPolicy(&s3.PutBucketPolicyInput{
 		Bucket: &bucketName,
 		Policy: aws.String(fmt.Sprintf(`{
@@ -974,7 +974,7 @@ func createS3bucket(nextflowGlobalConfig NextflowGlobalConfig, s3Svc *s3.S3, kms
 		}`, bucketName, *kmsKeyArn)),
 	})
 	if err != nil {
-		Config.Logger.Printf("Unable to set bucket policy: %v", err)
+		Config().Logger.Printf("Unable to set bucket policy: %v", err)
 		return "", err
 	}
 
@@ -982,7 +982,7 @@ func createS3bucket(nextflowGlobalConfig NextflowGlobalConfig, s3Svc *s3.S3, kms
 	if expirationDays <= 0 {
 		expirationDays = 30
 	}
-	Config.Logger.Printf("DEBUG: Setting bucket objects expiration to %d days", expirationDays)
+	Config().Logger.Printf("DEBUG: Setting bucket objects expiration to %d days", expirationDays)
 	_, err = s3Svc.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
 		Bucket: &bucketName,
 		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{
@@ -1000,11 +1000,11 @@ func createS3bucket(nextflowGlobalConfig NextflowGlobalConfig, s3Svc *s3.S3, kms
 		},
 	})
 	if err != nil {
-		Config.Logger.Printf("Unable to set lifecycle configuration: %v", err)
+		Config().Logger.Printf("Unable to set lifecycle configuration: %v", err)
 		return "", err
 	}
 
-	Config.Logger.Printf("DEBUG: Done setting up S3 bucket!")
+	Config().Logger.Printf("DEBUG: Done setting up S3 bucket!")
 	return *kmsKeyArn, nil
 }
 
@@ -1019,14 +1019,14 @@ func setupSquid(hostname string, userName string, cidrstring string, ec2svc *ec2
 
 	// create subnet
 	subnetName := fmt.Sprintf("%s-nf-subnet-fw-%s", hostname, userName)
-	Config.Logger.Printf("Debug: Creating subnet '%s' with name '%s'", subnet, subnetName)
+	Config().Logger.Printf("Debug: Creating subnet '%s' with name '%s'", subnet, subnetName)
 	subnetId, err := setupSubnet(subnetName, subnetString, vpcid, ec2svc, instanceType)
 	if err != nil {
 		return nil, err
 	}
 
 	// add route to internet gateway
-	Config.Logger.Printf("Debug: Creating route to internet '%s' in route table '%s'", *igw, *fwRouteTableId)
+	Config().Logger.Printf("Debug: Creating route to internet '%s' in route table '%s'", *igw, *fwRouteTableId)
 	_, err = ec2svc.CreateRoute(&ec2.CreateRouteInput{
 		DestinationCidrBlock: aws.String("0.0.0.0/0"),
 		GatewayId:            igw,
@@ -1044,14 +1044,14 @@ func setupSquid(hostname string, userName string, cidrstring string, ec2svc *ec2
 	if err != nil {
 		return nil, err
 	}
-	Config.Logger.Printf("Debug: Associated route table '%s' to subnet '%s'", *fwRouteTableId, *subnetId)
+	Config().Logger.Printf("Debug: Associated route table '%s' to subnet '%s'", *fwRouteTableId, *subnetId)
 
 	squidInstanceId, err := launchSquidInstance(hostname, userName, ec2svc, subnetId, vpcid, subnetString, instanceType)
 	if err != nil {
 		return nil, err
 	}
 
-	Config.Logger.Printf("Debug: Will add route to Squid '%s' in route table '%s'", *squidInstanceId, *routeTableId)
+	Config().Logger.Printf("Debug: Will add route to Squid '%s' in route table '%s'", *squidInstanceId, *routeTableId)
 	// add or replace route to squid
 	_, err = ec2svc.CreateRoute(&ec2.CreateRouteInput{
 		DestinationCidrBlock: aws.String("0.0.0.0/0"),
@@ -1065,7 +1065,7 @@ func setupSquid(hostname string, userName string, cidrstring string, ec2svc *ec2
 			// waits until the instance is ready.
 			if aerr.Code() == "RouteAlreadyExists" {
 				// the route already exists, replace it
-				Config.Logger.Print("Debug: Route already exists, replacing it")
+				Config().Logger.Print("Debug: Route already exists, replacing it")
 				_, err = ec2svc.ReplaceRoute(&ec2.ReplaceRouteInput{
 					DestinationCidrBlock: aws.String("0.0.0.0/0"),
 					InstanceId:           squidInstanceId,
@@ -1082,7 +1082,7 @@ func setupSquid(hostname string, userName string, cidrstring string, ec2svc *ec2
 		}
 	}
 
-	Config.Logger.Printf("Debug: Created route to Squid '%s' in route table '%s'", *squidInstanceId, *routeTableId)
+	Config().Logger.Printf("Debug: Created route to Squid '%s' in route table '%s'", *squidInstanceId, *routeTableId)
 	return subnetId, nil
 }
 
@@ -1109,7 +1109,7 @@ func setupSubnet(subnetName string, cidr string, vpcid string, ec2Svc *ec2.EC2,
 		return nil, err
 	}
 	if len(exsubnet.Subnets) > 0 {
-		Config.Logger.Printf("Debug: Subnet '%s' already exists, skipping creation", subnetName)
+		Config().Logger.Printf("Debug: Subnet '%s' already exists, skipping creation", subnetName)
 		return exsubnet.Subnets[0].SubnetId, nil
 	}
 
@@ -1143,7 +1143,7 @@ func setupSubnet(subnetName string, cidr string, vpcid string, ec2Svc *ec2.EC2,
 			return nil, fmt.Errorf("Error describing instance type offerings: %v", err)
 		}
 		if len(result.InstanceTypeOfferings) > 0 {
-			Config.Logger.Printf("Debug: Zone: %v has instance type %v available. Using that for subnet", *zone.ZoneName, instanceType)
+			Config().Logger.Printf("Debug: Zone: %v has instance type %v available. Using that for subnet", *zone.ZoneName, instanceType)
 			selectedZone = *zone.ZoneName
 			break // Exit the loop if we found a suitable zone
 		}
@@ -1154,7 +1154,7 @@ func setupSubnet(subnetName string, cidr string, vpcid string, ec2Svc *ec2.EC2,
 	}
 
 	// create subnet
-	Config.Logger.Printf("Debug: Creating subnet '%v' with name '%s'", cidr, subnetName)
+	Config().Logger.Printf("Debug: Creating subnet '%v' with name '%s'", cidr, subnetName)
 	sn, err := ec2Svc.CreateSubnet(&ec2.CreateSubnetInput{
 		CidrBlock:        aws.String(cidr),
 		VpcId:            aws.String(vpcid),
@@ -1201,7 +1201,7 @@ func setupRouteTable(hostname string, userName string, ec2svc *ec2.EC2, vpcid st
 	}
 
 	if len(exrouteTable.RouteTables) > 0 {
-		Config.Logger.Printf("Debug: Route table '%s' already exists, skipping creation", routeTableName)
+		Config().Logger.Printf("Debug: Route table '%s' already exists, skipping creation", routeTableName)
 		return exrouteTable.RouteTables[0].RouteTableId, nil
 	}
 	routeTable, err := ec2svc.CreateRouteTable(&ec2.CreateRouteTableInput{
@@ -1226,11 +1226,11 @@ func setupRouteTable(hostname string, userName string, ec2svc *ec2.EC2, vpcid st
 	if err != nil {
 		return nil, err
 	}
-	Config.Logger.Printf("Debug: Created route table '%s' with name '%s'", *routeTable.RouteTable.RouteTableId, routeTableName)
+	Config().Logger.Printf("Debug: Created route table '%s' with name '%s'", *routeTable.RouteTable.RouteTableId, routeTableName)
 
 	if routeTableName == fmt.Sprintf("%s-nf-fw-rt-%s", hostname, userName) {
 		// create route
-		Config.Logger.Printf("Debug: Creating route to internet '%s' in route table '%s'", igwid, *routeTable.RouteTable.RouteTableId)
+		Config().Logger.Printf("Debug: Creating route to internet '%s' in route table '%s'", igwid, *routeTable.RouteTable.RouteTableId)
 		_, err = ec2svc.CreateRoute(&ec2.CreateRouteInput{
 			DestinationCidrBlock: aws.String("0.0.0.0/0"),
 			GatewayId:            aws.String(igwid),
@@ -1253,7 +1253,7 @@ func associateRouteTablesToSubnets(ec2svc *ec2.EC2, subnets []string, routeTable
 		if err != nil {
 			return err
 		}
-		Config.Logger.Printf("Debug: Associated route table '%s' to subnet '%s'", routeTableId, subnet)
+		Config().Logger.Printf("Debug: Associated route table '%s' to subnet '%s'", routeTableId, subnet)
 	}
 	return nil
 }
@@ -1358,7 +1358,7 @@ $(command -v docker) run --name squid --restart=always --network=host -d \
 		_, ipnet, _ := net.ParseCIDR(subnet)
 		privateIP := ipnet.IP
 		privateIP[3] += 10
-		Config.Logger.Print("Debug: Private IP: ", privateIP.String())
+		Config().Logger.Print("Debug: Private IP: ", privateIP.String())
 
 		// Get the latest amazonlinux AMI
 		amiId, err := getLatestAmazonLinuxAmi(ec2svc)
@@ -1406,7 +1406,7 @@ $(command -v docker) run --name squid --restart=always --network=host -d \
 			},
 		})
 		if err != nil {
-			Config.Logger.Print("Error launching instance: ", err)
+			Config().Logger.Print("Error launching instance: ", err)
 			return nil, err
 		}
 
@@ -1422,7 +1422,7 @@ $(command -v docker) run --name squid --restart=always --network=host -d \
 			return nil, err
 		}
 
-		Config.Logger.Print("Debug: Launched Squid instance")
+		Config().Logger.Print("Debug: Launched Squid instance")
 		instanceId = *squid.Instances[0].InstanceId
 	}
 
@@ -1437,11 +1437,11 @@ $(command -v docker) run --name squid --restart=always --network=host -d \
 		}
 		instanceState = *exinstance.Reservations[0].Instances[0].State.Name
 		if instanceState == "running" {
-			Config.Logger.Print("Debug: Squid instance is ready")
+			Config().Logger.Print("Debug: Squid instance is ready")
 			break
 		}
 		if instanceState == "stopped" {
-			Config.Logger.Print("Debug: Instance already exists and is stopped, starting it now")
+			Config().Logger.Print("Debug: Instance already exists and is stopped, starting it now")
 			_, err := ec2svc.StartInstances(&ec2.StartInstancesInput{
 				InstanceIds: []*string{
 					&instanceId,
@@ -1454,7 +1454,7 @@ $(command -v docker) run --name squid --restart=always --network=host -d \
 		if i == maxIter {
 			return nil, fmt.Errorf("squid instance is not ready after %v seconds. Exiting", maxIter*iterDelaySecs)
 		}
-		Config.Logger.Printf("Info: Squid instance is %s, waiting %vs and checking again", instanceState, iterDelaySecs)
+		Config().Logger.Printf("Info: Squid instance is %s, waiting %vs and checking again", instanceState, iterDelaySecs)
 		time.Sleep(time.Duration(iterDelaySecs) * time.Second)
 	}
 
@@ -1482,7 +1482,7 @@ func setupFwSecurityGroup(hostname string, userName string, ec2svc *ec2.EC2, vpc
 		return nil, err
 	}
 	if len(exsecurityGroup.SecurityGroups) > 0 {
-		Config.Logger.Printf("Debug: Security group '%s' already exists, skipping creation", sgName)
+		Config().Logger.Printf("Debug: Security group '%s' already exists, skipping creation", sgName)
 		return exsecurityGroup.SecurityGroups[0].GroupId, nil
 	}
 
@@ -1493,7 +1493,7 @@ func setupFwSecurityGroup(hostname string, userName string, ec2svc *ec2.EC2, vpc
 		VpcId:       vpcId,
 	})
 	if err != nil {
-		Config.Logger.Printf("Error creating security group '%s': %v", sgName, err)
+		Config().Logger.Printf("Error creating security group '%s': %v", sgName, err)
 		return nil, err
 	}
 
@@ -1515,7 +1515,7 @@ func setupFwSecurityGroup(hostname string, userName string, ec2svc *ec2.EC2, vpc
 		},
 	})
 	if err != nil {
-		Config.Logger.Print("Error adding ingress rule to security group: ", err)
+		Config().Logger.Print("Error adding ingress rule to security group: ", err)
 		return nil, err
 	}
 
@@ -1542,7 +1542,7 @@ func getLatestAmazonLinuxAmi(ec2svc *ec2.EC2) (*string, error) {
 		},
 	})
 	if err != nil {
-		Config.Logger.Print("Error getting latest amazonlinux AMI: ", err)
+		Config().Logger.Print("Error getting latest amazonlinux AMI: ", err)
 		return nil, err
 	}
 
@@ -1558,7 +1558,7 @@ func getLatestAmazonLinuxAmi(ec2svc *ec2.EC2) (*string, error) {
 			}
 		}
 
-		Config.Logger.Printf("Info: Found latest amazonlinux AMI: '%s'", *latestImage.ImageId)
+		Config().Logger.Printf("Info: Found latest amazonlinux AMI: '%s'", *latestImage.ImageId)
 		return latestImage.ImageId, nil
 	}
 	return nil, errors.New("no amazonlinux AMI found")
@@ -1575,7 +1575,7 @@ func getNextflowInstanceAmi(
 	var err error
 	ami := nextflowConfig.InstanceAmi
 	if ami != "" {
-		Config.Logger.Printf("Using configured 'nextflow.instance-ami' '%s' and ignoring 'nextflow.instance-ami-builder-arn'", ami)
+		Config().Logger.Printf("Using configured 'nextflow.instance-ami' '%s' and ignoring 'nextflow.instance-ami-builder-arn'", ami)
 	} else if nextflowConfig.InstanceAmiBuilderArn != "" {
 		ami, err = getLatestImageBuilderAmi(imageBuilderReaderRoleArn, nextflowConfig.InstanceAmiBuilderArn, imagebuilderListImagePipelineImages)
 		if err != nil {
@@ -1598,7 +1598,7 @@ func cancelBatchJobsInStatus(
 		JobStatus: aws.String(status),
 	})
 	if err != nil {
-		Config.Logger.Printf("Error listing %s jobs in Batch queue '%s': %v", status, batchJobQueueName, err)
+		Config().Logger.Printf("Error listing %s jobs in Batch queue '%s': %v", status, batchJobQueueName, err)
 		return err
 	}
 	runningJobs := listJobsOutput.JobSummaryList
@@ -1609,25 +1609,25 @@ func cancelBatchJobsInStatus(
 			NextToken: listJobsOutput.NextToken,
 		})
 		if err != nil {
-			Config.Logger.Printf("Error listing %s jobs in Batch queue '%s': %v", status, batchJobQueueName, err)
+			Config().Logger.Printf("Error listing %s jobs in Batch queue '%s': %v", status, batchJobQueueName, err)
 			return err
 		}
 		runningJobs = append(runningJobs, listJobsOutput.JobSummaryList...)
 	}
 	if len(runningJobs) == 0 {
-		Config.Logger.Printf("Debug: No %s jobs to cancel", status)
+		Config().Logger.Printf("Debug: No %s jobs to cancel", status)
 	}
 
 	// `TerminateJob` cancels jobs in SUBMITTED, PENDING or RUNNABLE state and terminates jobs
 	// in STARTING or RUNNING state
 	for _, job := range runningJobs {
-		Config.Logger.Printf("Canceling %s job: ID '%s', name '%s'", status, *job.JobId, *job.JobName)
+		Config().Logger.Printf("Canceling %s job: ID '%s', name '%s'", status, *job.JobId, *job.JobName)
 		_, err := batchSvcTerminateJob(&batch.TerminateJobInput{
 			JobId:  job.JobId,
 			Reason: aws.String("User's workspace was terminated"),
 		})
 		if err != nil {
-			Config.Logger.Printf("Error terminating job '%s': %v", *job.JobId, err)
+			Config().Logger.Printf("Error terminating job '%s': %v", *job.JobId, err)
 			return err
 		}
 	}
@@ -1666,7 +1666,7 @@ func cleanUpNextflowResources(
 	if err != nil {
 		return err
 	}
-	Config.Logger.Printf("Debug: AWS account ID: '%v'", awsAccountId)
+	Config().Logger.Printf("Debug: AWS account ID: '%v'", awsAccountId)
 	iamSvc := iam.New(sess, &awsConfig)
 	ec2Svc := ec2.New(sess, &awsConfig)
 	batchSvc := batch.New(sess, &awsConfig)
@@ -1684,13 +1684,13 @@ func cleanUpNextflowResources(
 	}
 	err = deleteUserAccessKeys(nextflowUserName, iamSvcListAccessKeys, iamSvcDeleteAccessKey)
 	if err != nil {
-		Config.Logger.Printf("Unable to delete access keys for user '%s': %v", nextflowUserName, err)
+		Config().Logger.Printf("Unable to delete access keys for user '%s': %v", nextflowUserName, err)
 		return err
 	}
 
 	err = stopSquidInstance(hostname, userName, ec2Svc)
 	if err != nil {
-		Config.Logger.Printf("Warning: Unable to stop Squid instance - continuing: %v", err)
+		Config().Logger.Printf("Warning: Unable to stop Squid instance - continuing: %v", err)
 	}
 
 	// NOTE: This was disabled because researchers may need to keep the intermediary files. Instead of
@@ -1709,9 +1709,9 @@ func cleanUpNextflowResources(
 	// 	Prefix: aws.String("xxx-40uchicago-2eedu/"),
 	// })
 	// if err := s3manager.NewBatchDeleteWithClient(s3Svc).Delete(context.Background(), objectsIter); err != nil {
-	// 	Config.Logger.Printf("Unable to delete objects in bucket '%s' at '%s' - continuing: %v", bucketName, objectsKey, err)
+	// 	Config().Logger.Printf("Unable to delete objects in bucket '%s' at '%s' - continuing: %v", bucketName, objectsKey, err)
 	// } else {
-	// 	Config.Logger.Printf("Debug: Deleted objects in bucket '%s' at '%s'", bucketName, objectsKey)
+	// 	Config().Logger.Printf("Debug: Deleted objects in bucket '%s' at '%s'", bucketName, objectsKey)
 	// }
 
 	// cancel any Batch jobs that are still running (or about to run) for this user
@@ -1726,11 +1726,11 @@ func cleanUpNextflowResources(
 	// through statuses (first submitted, then pending, etc) to ensure all jobs are deleted. Finally cancel any
 	// jobs that reached the "running" status in the meantime. Ignore jobs in "succeeded" or "failed" status.
 	statusToCancel := []string{batch.JobStatusRunning, batch.JobStatusSubmitted, batch.JobStatusPending, batch.JobStatusRunnable, batch.JobStatusStarting, batch.JobStatusRunning}
-	Config.Logger.Printf("Canceling user's jobs in Batch queue '%s'...", batchJobQueueName)
+	Config().Logger.Printf("Canceling user's jobs in Batch queue '%s'...", batchJobQueueName)
 	for _, status := range statusToCancel {
 		err = cancelBatchJobsInStatus(batchJobQueueName, status, batchSvcListJobs, batchSvcTerminateJob)
 		if err != nil {
-			Config.Logger.Printf("Error canceling user's Batch jobs: %v", err)
+			Config().Logger.Printf("Error canceling user's Batch jobs: %v", err)
 			return err
 		}
 	}
@@ -1747,20 +1747,20 @@ func deleteUserAccessKeys(
 		UserName: &nextflowUserName,
 	})
 	if err != nil {
-		Config.Logger.Printf("Unable to list access keys for user '%s': %v", nextflowUserName, err)
+		Config().Logger.Printf("Unable to list access keys for user '%s': %v", nextflowUserName, err)
 		return err
 	}
 	for _, key := range listAccessKeysResult.AccessKeyMetadata {
-		Config.Logger.Printf("Deleting access key '%s' for user '%s'", *key.AccessKeyId, nextflowUserName)
+		Config().Logger.Printf("Deleting access key '%s' for user '%s'", *key.AccessKeyId, nextflowUserName)
 		_, err := iamSvcDeleteAccessKey(&iam.DeleteAccessKeyInput{
 			UserName:    &nextflowUserName,
 			AccessKeyId: key.AccessKeyId,
 		})
 		if err != nil {
-			Config.Logger.Printf("Warning: Unable to delete access key '%s' for user '%s' - continuing: %v", *key.AccessKeyId, nextflowUserName, err)
+			Config().Logger.Printf("Warning: Unable to delete access key '%s' for user '%s' - continuing: %v", *key.AccessKeyId, nextflowUserName, err)
 		}
 	}
-	Config.Logger.Printf("Debug: Deleted all access keys for Nextflow AWS user '%s'", nextflowUserName)
+	Config().Logger.Printf("Debug: Deleted all access keys for Nextflow AWS user '%s'", nextflowUserName)
 	return nil
 }
 
@@ -1788,12 +1788,12 @@ var stopSquidInstance = func(hostname string, userName string, ec2svc *ec2.EC2)
 	if len(exinstance.Reservations) > 0 {
 		// Make sure the instance is stopped
 		if *exinstance.Reservations[0].Instances[0].State.Name == "stopped" {
-			Config.Logger.Print("Debug: Squid instance already stopped, skipping")
+			Config().Logger.Print("Debug: Squid instance already stopped, skipping")
 			return nil
 		}
 
 		// Terminate the instance
-		Config.Logger.Print("Debug: running Squid instance found, terminating it now")
+		Config().Logger.Print("Debug: running Squid instance found, terminating it now")
 		_, err := ec2svc.TerminateInstances(&ec2.TerminateInstancesInput{
 			InstanceIds: []*string{
 				exinstance.Reservations[0].Instances[0].InstanceId,
@@ -1836,12 +1836,12 @@ var generateNextflowConfig = func(nextflowGlobalConfig NextflowGlobalConfig, use
 		PathPrefix: pathPrefix,
 	})
 	if err != nil || len(listRolesResult.Roles) == 0 {
-		Config.Logger.Printf("Error getting role with path prefix '%s', which should already exist: %v", *pathPrefix, err)
+		Config().Logger.Printf("Error getting role with path prefix '%s', which should already exist: %v", *pathPrefix, err)
 		return "", err
 	}
 	nextflowJobsRoleArn := *listRolesResult.Roles[0].Arn
 
-	Config.Logger.Printf("Generating Nextflow configuration with: Batch queue: '%s'. Job role: '%s'. Workdir: '%s'.", batchJobQueueName, nextflowJobsRoleArn, workDir)
+	Config().Logger.Printf("Generating Nextflow configuration with: Batch queue: '%s'. Job role: '%s'. Workdir: '%s'.", batchJobQueueName, nextflowJobsRoleArn, workDir)
 
 	configContents := fmt.Sprintf(
 		`plugins {
@@ -64,7 +64,7 @@ func TestGetNextflowInstanceAmi(t *testing.T) {
 	instanceAmiBuilderArnValue := "instance-ami-builder-arn"
 	builderLatestAmi := "latest-ami"
 
-	Config.ContainersMap = map[string]Container{
+	Config().ContainersMap = map[string]Container{
 		"container_with_instance_ami": {
 			NextflowConfig: NextflowConfig{
 				InstanceAmi:           instanceAmiValue,
@@ -129,7 +129,7 @@ func TestGetNextflowInstanceAmi(t *testing.T) {
 		return &output, nil
 	}
 
-	for containerId, container := range Config.ContainersMap {
+	for containerId, container := range Config().ContainersMap {
 		ami, err := getNextflowInstanceAmi("", container.NextflowConfig, mockedListImagePipelineImages)
 		if containerId == "container_with_neither" {
 			if err == nil {
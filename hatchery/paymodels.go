@@ -3,6 +3,7 @@ package hatchery
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
@@ -19,9 +20,9 @@ var getPayModelTableCreds = func(sess *session.Session) aws.Config {
 	var awsConfig aws.Config
 
 	// Assume a new role if we have a pay model arn
-	if Config.Config.PayModelsDynamodbArn != "" {
+	if Config().Config.PayModelsDynamodbArn != "" {
 		awsConfig = aws.Config{
-			Credentials: stscreds.NewCredentials(sess, Config.Config.PayModelsDynamodbArn),
+			Credentials: stscreds.NewCredentials(sess, Config().Config.PayModelsDynamodbArn),
 		}
 	} else {
 		awsConfig = aws.Config{}
@@ -48,7 +49,7 @@ var payModelsFromDatabase = func(userName string, current bool) (payModels *[]Pa
 	}
 	expr, err := expression.NewBuilder().WithFilter(filt).Build()
 	if err != nil {
-		Config.Logger.Printf("Got error building expression: %s", err)
+		Config().Logger.Printf("Got error building expression: %s", err)
 		return nil, err
 	}
 
@@ -56,11 +57,13 @@ var payModelsFromDatabase = func(userName string, current bool) (payModels *[]Pa
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
 		FilterExpression:          expr.Filter(),
-		TableName:                 aws.String(Config.Config.PayModelsDynamodbTable),
+		TableName:                 aws.String(Config().Config.PayModelsDynamodbTable),
 	}
+	start := time.Now()
 	res, err := dynamodbSvc.Scan(params)
+	observeExternalRequest("dynamodb", "scan_pay_models", start, err)
 	if err != nil {
-		Config.Logger.Printf("Query API call failed: %s", err)
+		Config().Logger.Printf("Query API call failed: %s", err)
 		return nil, err
 	}
 
@@ -68,7 +71,7 @@ var payModelsFromDatabase = func(userName string, current bool) (payModels *[]Pa
 	var payModelMap []PayModel
 	err = dynamodbattribute.UnmarshalListOfMaps(res.Items, &payModelMap)
 	if err != nil {
-		Config.Logger.Printf("Got error unmarshalling paymodels: %s", err)
+		Config().Logger.Printf("Got error unmarshalling paymodels: %s", err)
 		return nil, err
 	}
 
@@ -77,7 +80,7 @@ var payModelsFromDatabase = func(userName string, current bool) (payModels *[]Pa
 
 func payModelFromConfig(userName string) (pm *PayModel, err error) {
 	var payModel PayModel
-	for _, configPaymodel := range Config.PayModelMap {
+	for _, configPaymodel := range Config().PayModelMap {
 		if configPaymodel.User == userName {
 			payModel = configPaymodel
 		}
@@ -92,7 +95,7 @@ var getCurrentPayModel = func(userName string) (result *PayModel, err error) {
 
 	var pm *[]PayModel
 
-	if Config != nil && Config.Config.PayModelsDynamodbTable == "" {
+	if Config() != nil && Config().Config.PayModelsDynamodbTable == "" {
 		pm, err := getDefaultPayModel()
 		if err != nil {
 			return nil, nil
@@ -132,7 +135,7 @@ var getCurrentPayModel = func(userName string) (result *PayModel, err error) {
 	// If exactly one current pay model is found in the database
 	payModel := (*pm)[0]
 	if err != nil {
-		Config.Logger.Printf("Got error unmarshalling: %s", err)
+		Config().Logger.Printf("Got error unmarshalling: %s", err)
 		return nil, err
 	}
 	return &payModel, nil
@@ -140,10 +143,10 @@ var getCurrentPayModel = func(userName string) (result *PayModel, err error) {
 
 var getDefaultPayModel = func() (defaultPaymodel *PayModel, err error) {
 	var pm PayModel
-	if Config.Config.DefaultPayModel == pm {
+	if Config().Config.DefaultPayModel == pm {
 		return nil, fmt.Errorf("no default paymodel set")
 	}
-	return &Config.Config.DefaultPayModel, nil
+	return &Config().Config.DefaultPayModel, nil
 }
 
 var getPayModelsForUser = func(userName string) (result *AllPayModels, err error) {
@@ -153,7 +156,7 @@ var getPayModelsForUser = func(userName string) (result *AllPayModels, err error
 	PayModels := AllPayModels{}
 	var payModelMap *[]PayModel
 
-	if Config.Config.PayModelsDynamodbTable != "" {
+	if Config().Config.PayModelsDynamodbTable != "" {
 		payModelMap, err = payModelsFromDatabase(userName, false)
 		if err != nil {
 			return nil, err
@@ -260,10 +263,12 @@ func updateCurrentPaymodelInDB(userName string, workspaceid string, svc *dynamod
 			},
 		},
 		ReturnValues:     aws.String("ALL_NEW"),
-		TableName:        aws.String(Config.Config.PayModelsDynamodbTable),
+		TableName:        aws.String(Config().Config.PayModelsDynamodbTable),
 		UpdateExpression: aws.String("SET #CPM = :f"),
 	}
+	start := time.Now()
 	_, err = svc.UpdateItem(input)
+	observeExternalRequest("dynamodb", "update_pay_model", start, err)
 	if err != nil {
 		return err
 	}
@@ -294,10 +299,12 @@ func resetCurrentPaymodelInDB(userName string, svc *dynamodb.DynamoDB) error {
 				},
 			},
 			ReturnValues:     aws.String("ALL_NEW"),
-			TableName:        aws.String(Config.Config.PayModelsDynamodbTable),
+			TableName:        aws.String(Config().Config.PayModelsDynamodbTable),
 			UpdateExpression: aws.String("SET #CPM = :f"),
 		}
+		start := time.Now()
 		_, err := svc.UpdateItem(input)
+		observeExternalRequest("dynamodb", "update_pay_model", start, err)
 		if err != nil {
 			return err
 		}
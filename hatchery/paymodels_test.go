@@ -24,7 +24,7 @@ func TestGetDefaultPayModel(t *testing.T) {
 	}
 
 	/* Setup */
-	Config = configWithPayModel
+	SetConfig(configWithPayModel)
 
 	/* Act */
 	got, err := getDefaultPayModel()
@@ -151,7 +151,7 @@ func Test_GetCurrentPayModel(t *testing.T) {
 	for _, testcase := range testCases {
 		t.Logf("Testing GetCurrentPaymodel when %s", testcase.name)
 		/* Setup */
-		Config = testcase.mockConfig
+		SetConfig(testcase.mockConfig)
 		getDefaultPayModel = func() (*PayModel, error) {
 			return testcase.mockDefaultPaymodel, nil
 		}
@@ -324,7 +324,7 @@ func Test_GetPayModelsForUser(t *testing.T) {
 		t.Logf("Testing getPayModelsForUser when %s", testcase.name)
 
 		/* Setup */
-		Config = testcase.mockConfig
+		SetConfig(testcase.mockConfig)
 		getCurrentPayModel = func(username string) (*PayModel, error) {
 			return testcase.mockCurrentPayModel, nil
 		}
@@ -376,7 +376,7 @@ func Test_GetPayModelTableCreds(t *testing.T) {
 		t.Logf("Testing getPayModelTableCreds when %s", testcase.name)
 
 		/* Setup */
-		Config = testcase.mockConfig
+		SetConfig(testcase.mockConfig)
 		//
 		sess := session.Must(session.NewSessionWithOptions(session.Options{
 			Config: aws.Config{
@@ -424,7 +424,7 @@ func Test_GetPayModelTableCredsWithArn(t *testing.T) {
 		t.Logf("Testing getPayModelTableCreds when %s", testcase.name)
 
 		/* Setup */
-		Config = testcase.mockConfig
+		SetConfig(testcase.mockConfig)
 		sess := session.Must(session.NewSessionWithOptions(session.Options{
 			Config: aws.Config{
 				Region: aws.String("us-east-1"),
@@ -494,7 +494,7 @@ func Test_PayModelFromConfig(t *testing.T) {
 		t.Logf("Testing payModelFromConfig when %s", testcase.name)
 
 		/* Setup */
-		Config = testcase.mockConfig
+		SetConfig(testcase.mockConfig)
 
 		/* Act */
 		got, err := payModelFromConfig(testcase.userName)
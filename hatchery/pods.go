@@ -8,6 +8,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	k8sv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -33,21 +34,6 @@ var (
 	falseVal = false
 )
 
-const ambassadorYaml = `---
-apiVersion: ambassador/v1
-kind:  Mapping
-name:  %s
-prefix: /
-headers:
-  remote_user: %s
-service: %s.%s.svc.cluster.local:80
-bypass_auth: true
-timeout_ms: 300000
-use_websocket: true
-rewrite: %s
-tls: %s
-`
-
 type PodConditions struct {
 	Type   string `json:"type"`
 	Status string `json:"status"`
@@ -60,19 +46,20 @@ type ContainerStates struct {
 }
 
 type WorkspaceStatus struct {
-	Status           string            `json:"status"`
-	Conditions       []PodConditions   `json:"conditions"`
-	ContainerStates  []ContainerStates `json:"containerStates"`
-	IdleTimeLimit    int               `json:"idleTimeLimit"`
-	LastActivityTime int64             `json:"lastActivityTime"`
-	WorkspaceType    string            `json:"workspaceType"`
+	Status           string                `json:"status"`
+	Conditions       []PodConditions       `json:"conditions"`
+	ContainerStates  []ContainerStates     `json:"containerStates"`
+	IdleTimeLimit    int                   `json:"idleTimeLimit"`
+	LastActivityTime int64                 `json:"lastActivityTime"`
+	WorkspaceType    string                `json:"workspaceType"`
+	Diagnostics      *WorkspaceDiagnostics `json:"diagnostics,omitempty"`
 }
 
 func getPodClient(ctx context.Context, userName string, payModelPtr *PayModel) (corev1.CoreV1Interface, bool, error) {
 	if payModelPtr != nil && !(*payModelPtr).Local {
 		podClient, err := NewEKSClientset(ctx, userName, *payModelPtr)
 		if err != nil {
-			Config.Logger.Printf("Error fetching EKS kubeconfig: %v", err)
+			Config().Logger.Printf("Error fetching EKS kubeconfig: %v", err)
 			return nil, true, err
 		} else {
 			return podClient, true, nil
@@ -86,13 +73,13 @@ func getLocalPodClient() corev1.CoreV1Interface {
 	// creates the in-cluster config
 	config, err := GetConfig()
 	if err != nil {
-		Config.Logger.Printf("Error creating in-cluster config: %v", err)
+		Config().Logger.Printf("Error creating in-cluster config: %v", err)
 		return nil
 	}
 	// creates the clientset
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		Config.Logger.Printf("Error creating in-cluster clientset: %v", err)
+		Config().Logger.Printf("Error creating in-cluster clientset: %v", err)
 		return nil
 	}
 	// Access jobs. We can't do it all in one line, since we need to receive the
@@ -103,6 +90,23 @@ func getLocalPodClient() corev1.CoreV1Interface {
 
 // Generate EKS kubeconfig using AWS role
 func NewEKSClientset(ctx context.Context, userName string, payModel PayModel) (corev1.CoreV1Interface, error) {
+	clientset, err := newEKSKubernetesClientset(ctx, userName, payModel)
+	if err != nil {
+		return nil, err
+	}
+	return clientset.CoreV1(), nil
+}
+
+func newEKSKubernetesClientset(ctx context.Context, userName string, payModel PayModel) (*kubernetes.Clientset, error) {
+	config, err := newEKSRestConfig(ctx, payModel)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// newEKSRestConfig returns the config of a client of the pay model's EKS cluster
+func newEKSRestConfig(ctx context.Context, payModel PayModel) (*rest.Config, error) {
 	roleARN := "arn:aws:iam::" + payModel.AWSAccountId + ":role/csoc_adminvm"
 	sess := session.Must(session.NewSession(&aws.Config{
 		Region: aws.String(payModel.Region),
@@ -115,7 +119,7 @@ func NewEKSClientset(ctx context.Context, userName string, payModel PayModel) (c
 	}
 	result, err := eksSvc.DescribeClusterWithContext(ctx, input)
 	if err != nil {
-		Config.Logger.Printf("Error calling DescribeCluster: %v", err)
+		Config().Logger.Printf("Error calling DescribeCluster: %v", err)
 		return nil, err
 	}
 	cluster := result.Cluster
@@ -136,19 +140,13 @@ func NewEKSClientset(ctx context.Context, userName string, payModel PayModel) (c
 	if err != nil {
 		return nil, err
 	}
-	clientset, err := kubernetes.NewForConfig(
-		&rest.Config{
-			Host:        aws.StringValue(cluster.Endpoint),
-			BearerToken: tok.Token,
-			TLSClientConfig: rest.TLSClientConfig{
-				CAData: ca,
-			},
+	return &rest.Config{
+		Host:        aws.StringValue(cluster.Endpoint),
+		BearerToken: tok.Token,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: ca,
 		},
-	)
-	if err != nil {
-		return nil, err
-	}
-	return clientset.CoreV1(), nil
+	}, nil
 }
 
 func checkPodReadiness(pod *k8sv1.Pod) bool {
@@ -163,22 +161,22 @@ func checkPodReadiness(pod *k8sv1.Pod) bool {
 	return true
 }
 
-func podStatus(ctx context.Context, userName string, accessToken string, payModelPtr *PayModel) (*WorkspaceStatus, error) {
+func podStatus(ctx context.Context, userName string, workspaceId string, accessToken string, payModelPtr *PayModel) (*WorkspaceStatus, error) {
 	status := WorkspaceStatus{}
 	status.WorkspaceType = "Kubernetes"
 	podClient, isExternalClient, err := getPodClient(ctx, userName, payModelPtr)
 	if err != nil {
-		// Config.Logger.Panic("Error trying to fetch kubeConfig: %v", err)
+		// Config().Logger.Panic("Error trying to fetch kubeConfig: %v", err)
 		status.Status = fmt.Sprintf("%v", err)
 		return &status, err
 	}
 
-	podName := userToResourceName(userName, "pod")
+	podName := workspaceToResourceName(userName, workspaceId, "pod")
 
-	serviceName := userToResourceName(userName, "service")
+	serviceName := workspaceToResourceName(userName, workspaceId, "service")
 
-	pod, err := podClient.Pods(Config.Config.UserNamespace).Get(ctx, podName, metav1.GetOptions{})
-	_, serviceErr := podClient.Services(Config.Config.UserNamespace).Get(ctx, serviceName, metav1.GetOptions{})
+	pod, err := podClient.Pods(Config().Config.UserNamespace).Get(ctx, podName, metav1.GetOptions{})
+	_, serviceErr := podClient.Services(Config().Config.UserNamespace).Get(ctx, serviceName, metav1.GetOptions{})
 	if err != nil {
 		if isExternalClient && serviceErr == nil {
 			// only worry about service if podClient is external EKS
@@ -186,11 +184,11 @@ func podStatus(ctx context.Context, userName string, accessToken string, payMode
 			deleteOptions := metav1.DeleteOptions{
 				PropagationPolicy: &policy,
 			}
-			err := podClient.Services(Config.Config.UserNamespace).Delete(ctx, serviceName, deleteOptions)
+			err := podClient.Services(Config().Config.UserNamespace).Delete(ctx, serviceName, deleteOptions)
 			if err != nil {
-				Config.Logger.Printf("Error deleting service. %s", err)
+				Config().Logger.Printf("Error deleting service. %s", err)
 			}
-			Config.Logger.Printf("Pod has been terminated, but service is still being terminated. Wait for service to be killed.")
+			Config().Logger.Printf("Pod has been terminated, but service is still being terminated. Wait for service to be killed.")
 			// Pod has been terminated, but service is still being terminated. Wait for service to be killed
 			status.Status = "Terminating"
 			return &status, nil
@@ -201,18 +199,33 @@ func podStatus(ctx context.Context, userName string, accessToken string, payMode
 		}
 	}
 
+	status = *workspaceStatusFromPod(ctx, pod, accessToken)
+	if status.Status == "Launching" {
+		applyDiagnostics(&status, diagnoseLaunchingPod(ctx, podClient, pod))
+	}
+	return &status, nil
+}
+
+// workspaceStatusFromPod converts the state of an existing workspace pod into a WorkspaceStatus
+func workspaceStatusFromPod(ctx context.Context, pod *k8sv1.Pod, accessToken string) *WorkspaceStatus {
+	status := WorkspaceStatus{}
+	status.WorkspaceType = "Kubernetes"
+
 	if pod.DeletionTimestamp != nil {
 		status.Status = "Terminating"
-		return &status, nil
+		return &status
 	}
 
 	switch pod.Status.Phase {
 	case "Failed":
-		fallthrough
+		// e.g. evicted or killed for running out of memory
+		status.Status = "Failed"
+		applyDiagnostics(&status, diagnosePod(pod))
 	case "Succeeded":
 		fallthrough
 	case "Unknown":
 		status.Status = "Stopped"
+		applyDiagnostics(&status, diagnosePod(pod))
 	case "Pending":
 		fallthrough
 	case "Running":
@@ -226,7 +239,7 @@ func podStatus(ctx context.Context, userName string, accessToken string, payMode
 						idleTimeLimit, err := strconv.Atoi(argSplit[len(argSplit)-1])
 						if err == nil {
 							status.IdleTimeLimit = idleTimeLimit * 1000
-							lastActivityTime, err := getKernelIdleTimeWithContext(ctx, accessToken)
+							lastActivityTime, err := getKernelIdleTimeWithContext(ctx, accessToken, pod.Annotations[workspaceIdAnnotation])
 							status.LastActivityTime = lastActivityTime
 							if err != nil {
 								log.Println(err.Error())
@@ -253,24 +266,25 @@ func podStatus(ctx context.Context, userName string, accessToken string, payMode
 				containerStates[i].Ready = cs.Ready
 			}
 			status.ContainerStates = containerStates
+			applyDiagnostics(&status, diagnosePod(pod))
 		}
 	default:
-		fmt.Printf("Unknown pod status for %s: %s\n", podName, string(pod.Status.Phase))
+		fmt.Printf("Unknown pod status for %s: %s\n", pod.Name, string(pod.Status.Phase))
 	}
 
-	return &status, nil
+	return &status
 }
 
-var statusK8sPod = func(ctx context.Context, userName string, accessToken string, payModelPtr *PayModel) (*WorkspaceStatus, error) {
-	status, err := podStatus(ctx, userName, accessToken, payModelPtr)
+var statusK8sPod = func(ctx context.Context, userName string, workspaceId string, accessToken string, payModelPtr *PayModel) (*WorkspaceStatus, error) {
+	status, err := podStatus(ctx, userName, workspaceId, accessToken, payModelPtr)
 	if err != nil {
 		status.Status = fmt.Sprintf("%v", err)
-		Config.Logger.Printf("Error getting status: %v", err)
+		Config().Logger.Printf("Error getting status: %v", err)
 	}
 	return status, nil
 }
 
-var deleteK8sPod = func(ctx context.Context, userName string, accessToken string, payModelPtr *PayModel) error {
+var deleteK8sPod = func(ctx context.Context, userName string, workspaceId string, accessToken string, payModelPtr *PayModel) error {
 	podClient, _, err := getPodClient(ctx, userName, payModelPtr)
 	if err != nil {
 		return err
@@ -283,23 +297,15 @@ var deleteK8sPod = func(ctx context.Context, userName string, accessToken string
 		GracePeriodSeconds: &grace,
 	}
 
-	podName := userToResourceName(userName, "pod")
-	pod, err := podClient.Pods(Config.Config.UserNamespace).Get(ctx, podName, metav1.GetOptions{})
+	podName := workspaceToResourceName(userName, workspaceId, "pod")
+	pod, err := podClient.Pods(Config().Config.UserNamespace).Get(ctx, podName, metav1.GetOptions{})
 	if err != nil {
 		return fmt.Errorf("a workspace pod was not found: %s", err)
 	}
-	containers := pod.Spec.Containers
-	var mountedAPIKeyID string
-	for i := range containers {
-		if containers[i].Name == "hatchery-container" {
-			for j := range containers[i].Env {
-				if containers[i].Env[j].Name == "API_KEY_ID" {
-					mountedAPIKeyID = containers[i].Env[j].Value
-					break
-				}
-			}
-			break
-		}
+	mountedAPIKeyID := pod.Annotations[apiKeyIdAnnotation]
+	if mountedAPIKeyID == "" {
+		// workspaces launched before the API key id was recorded on the pod
+		mountedAPIKeyID = getPodEnvVarValue(pod, "API_KEY_ID")
 	}
 	if mountedAPIKeyID != "" {
 		fmt.Printf("Found mounted API key. Attempting to delete API Key with ID %s for user %s\n", mountedAPIKeyID, userName)
@@ -312,18 +318,26 @@ var deleteK8sPod = func(ctx context.Context, userName string, accessToken string
 	}
 
 	fmt.Printf("Attempting to delete pod %s for user %s\n", podName, userName)
-	err = podClient.Pods(Config.Config.UserNamespace).Delete(ctx, podName, deleteOptions)
+	err = podClient.Pods(Config().Config.UserNamespace).Delete(ctx, podName, deleteOptions)
 	if err != nil {
 		fmt.Printf("Error occurred when deleting pod: %s", err)
 	}
+	err = deleteWorkspaceNetworkPolicy(ctx, userName, workspaceId, payModelPtr)
+	if err != nil {
+		fmt.Printf("Error occurred when deleting network policy: %s", err)
+	}
+	err = deleteWorkspaceSecret(ctx, podClient, userName, workspaceId)
+	if err != nil {
+		fmt.Printf("Error occurred when deleting secret: %s", err)
+	}
 
-	serviceName := userToResourceName(userName, "service")
-	_, err = podClient.Services(Config.Config.UserNamespace).Get(ctx, serviceName, metav1.GetOptions{})
+	serviceName := workspaceToResourceName(userName, workspaceId, "service")
+	_, err = podClient.Services(Config().Config.UserNamespace).Get(ctx, serviceName, metav1.GetOptions{})
 	if err != nil {
 		return fmt.Errorf("a workspace service was not found: %s", err)
 	}
 	fmt.Printf("Attempting to delete service %s for user %s\n", serviceName, userName)
-	err = podClient.Services(Config.Config.UserNamespace).Delete(ctx, serviceName, deleteOptions)
+	err = podClient.Services(Config().Config.UserNamespace).Delete(ctx, serviceName, deleteOptions)
 	if err != nil {
 		fmt.Printf("Error occurred when deleting service: %s", err)
 	}
@@ -331,11 +345,30 @@ var deleteK8sPod = func(ctx context.Context, userName string, accessToken string
 	return nil
 }
 
+// getPodEnvVarValue returns the value of the given environment variable
+// in the pod's "hatchery-container", or "" if it is not set
+func getPodEnvVarValue(pod *k8sv1.Pod, envVarName string) string {
+	for _, container := range pod.Spec.Containers {
+		if container.Name != "hatchery-container" {
+			continue
+		}
+		for _, envVar := range container.Env {
+			if envVar.Name == envVarName {
+				return envVar.Value
+			}
+		}
+	}
+	return ""
+}
+
 // userToResourceName is a helper for generating names for
 // different types of kubernetes resources given a user name
 // and a resource type
 func userToResourceName(userName string, resourceType string) string {
-	safeUserName := escapism(userName)
+	return safeNameToResourceName(escapism(userName), resourceType)
+}
+
+func safeNameToResourceName(safeUserName string, resourceType string) string {
 	if resourceType == "pod" {
 		return fmt.Sprintf("hatchery-%s", safeUserName)
 	}
@@ -351,22 +384,24 @@ func userToResourceName(userName string, resourceType string) string {
 
 // buildPod returns a pod ready to pass to the k8s API given
 // a hatchery Container instance, and the name of the user
-// launching the app
-func buildPod(hatchConfig *FullHatcheryConfig, hatchApp *Container, userName string, extraVars []k8sv1.EnvVar, payModelId ...string) (pod *k8sv1.Pod, err error) {
+// and workspace id launching the app
+func buildPod(hatchConfig *FullHatcheryConfig, hatchApp *Container, userName string, workspaceId string, extraVars []k8sv1.EnvVar, payModelId ...string) (pod *k8sv1.Pod, err error) {
 	// Create one if not provided
 	payModelIdValue := uuid.New().String()
 	if len(payModelId) > 0 && payModelId[0] != "" {
 		payModelIdValue = payModelId[0]
 	}
 
-	podName := userToResourceName(userName, "pod")
+	podName := workspaceToResourceName(userName, workspaceId, "pod")
 	labels := make(map[string]string)
 	labels["app"] = podName
 	annotations := make(map[string]string)
 	annotations["gen3username"] = userName
+	annotations[containerNameAnnotation] = hatchApp.Name
 	annotations["bmh_workspace_id"] = payModelIdValue
-	var sideCarRunAsUser int64
-	var sideCarRunAsGroup int64
+	if workspaceId != "" {
+		annotations[workspaceIdAnnotation] = workspaceId
+	}
 	var hostToContainer = k8sv1.MountPropagationHostToContainer
 	var bidirectional = k8sv1.MountPropagationBidirectional
 	var envVars []k8sv1.EnvVar
@@ -384,13 +419,20 @@ func buildPod(hatchConfig *FullHatcheryConfig, hatchApp *Container, userName str
 
 	//hatchConfig.Logger.Printf("environment configured")
 
+	sidecar, err := containerSidecar(&hatchConfig.Config, hatchApp)
+	if err != nil {
+		return nil, err
+	}
+
 	var sidecarEnvVars []k8sv1.EnvVar
-	for key, value := range hatchConfig.Config.Sidecar.Env {
-		envVar := k8sv1.EnvVar{
-			Name:  key,
-			Value: value,
+	if sidecar != nil {
+		for key, value := range sidecar.Env {
+			envVar := k8sv1.EnvVar{
+				Name:  key,
+				Value: value,
+			}
+			sidecarEnvVars = append(sidecarEnvVars, envVar)
 		}
-		sidecarEnvVars = append(sidecarEnvVars, envVar)
 	}
 	for _, value := range extraVars {
 		sidecarEnvVars = append(sidecarEnvVars, value)
@@ -462,7 +504,7 @@ func buildPod(hatchConfig *FullHatcheryConfig, hatchApp *Container, userName str
 	}
 
 	if mountUserVolume {
-		claimName := userToResourceName(userName, "claim")
+		claimName := workspaceToResourceName(userName, workspaceId, "claim")
 		volumes = append(volumes, k8sv1.Volume{
 			Name: "user-data",
 			VolumeSource: k8sv1.VolumeSource{
@@ -473,6 +515,22 @@ func buildPod(hatchConfig *FullHatcheryConfig, hatchApp *Container, userName str
 		})
 	}
 
+	extraVolumes, err := buildExtraVolumes(hatchApp.ExtraVolumes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extra volumes for container '%s': %v", hatchApp.Name, err)
+	}
+	volumes = append(volumes, extraVolumes...)
+
+	securityProfile := containerSecurityProfile(&hatchConfig.Config, hatchApp)
+	if volume := writableVolume(securityProfile); volume != nil {
+		volumes = append(volumes, *volume)
+	}
+
+	initContainers := []k8sv1.Container{}
+	for _, initContainer := range hatchApp.InitContainers {
+		initContainers = append(initContainers, *initContainer.DeepCopy())
+	}
+
 	//hatchConfig.Logger.Printf("volumes configured")
 
 	var pullPolicy k8sv1.PullPolicy
@@ -487,110 +545,91 @@ func buildPod(hatchConfig *FullHatcheryConfig, hatchApp *Container, userName str
 		pullPolicy = k8sv1.PullPolicy(k8sv1.PullIfNotPresent)
 	}
 
-	var volumeMounts = []k8sv1.VolumeMount{
-		{
-			MountPath:        "/data",
-			Name:             "shared-data",
-			MountPropagation: &bidirectional,
+	nodeSelector, tolerations := workspacePlacement(hatchConfig, hatchApp)
+
+	pod = &k8sv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        podName,
+			Namespace:   hatchConfig.Config.UserNamespace,
+			Labels:      labels,
+			Annotations: annotations,
 		},
-		{
-			MountPath: "/.gen3",
-			Name:      "gen3",
+		Spec: k8sv1.PodSpec{
+			SecurityContext:           &securityContext,
+			InitContainers:            initContainers,
+			EnableServiceLinks:        &falseVal,
+			Containers:                []k8sv1.Container{},
+			RestartPolicy:             k8sv1.RestartPolicyNever,
+			ImagePullSecrets:          []k8sv1.LocalObjectReference{},
+			NodeSelector:              nodeSelector,
+			Tolerations:               tolerations,
+			Affinity:                  hatchApp.Affinity,
+			PriorityClassName:         hatchApp.PriorityClassName,
+			TopologySpreadConstraints: hatchApp.TopologySpread,
+			Volumes:                   volumes,
 		},
 	}
 
-	if mountSharedMemory {
-		volumeMounts = append(volumeMounts, k8sv1.VolumeMount{
-			MountPath: "/dev/shm",
-			Name:      "dshm",
-		})
-	}
-
-	tolerations := []k8sv1.Toleration{}
-	nodeSelector := map[string]string{}
-
-	if !Config.Config.SkipNodeSelector {
-		// default (jupyter) placement
-		nodeSelector = map[string]string{
-			"role": "jupyter",
+	if sidecar != nil {
+		// the sidecar mounts the FUSE file systems under /data, or writes the data there
+		// when it is unprivileged
+		var volumeMounts = []k8sv1.VolumeMount{
+			{
+				MountPath: "/data",
+				Name:      "shared-data",
+			},
+			{
+				MountPath: "/.gen3",
+				Name:      "gen3",
+			},
 		}
-		tolerations = []k8sv1.Toleration{
-			{Key: "role", Operator: "Equal", Value: "jupyter", Effect: "NoSchedule"},
+		if propagatesFuseMounts(sidecar) {
+			volumeMounts[0].MountPropagation = &bidirectional
 		}
-	}
 
-	// If GPU requested, override with GPU settings
-	if hatchApp.GPU {
-		nodeSelector = map[string]string{
-			"role": "gpu",
+		if mountSharedMemory {
+			volumeMounts = append(volumeMounts, k8sv1.VolumeMount{
+				MountPath: "/dev/shm",
+				Name:      "dshm",
+			})
 		}
 
-		tolerations = []k8sv1.Toleration{
-			{Key: "role", Operator: "Equal", Value: "gpu", Effect: "NoSchedule"},
-			{Key: "nvidia.com/gpu", Operator: "Exists", Effect: "NoSchedule"},
+		sidecarResourceRequirements, err := sidecarResources(*sidecar)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sidecar resources: %v", err)
 		}
-	}
 
-	pod = &k8sv1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        podName,
-			Namespace:   hatchConfig.Config.UserNamespace,
-			Labels:      labels,
-			Annotations: annotations,
-		},
-		Spec: k8sv1.PodSpec{
-			SecurityContext:    &securityContext,
-			InitContainers:     []k8sv1.Container{},
-			EnableServiceLinks: &falseVal,
-			Containers: []k8sv1.Container{
-				{
-					Name:  "fuse-container",
-					Image: hatchConfig.Config.Sidecar.Image,
-					SecurityContext: &k8sv1.SecurityContext{
-						Privileged: &trueVal,
-						RunAsUser:  &sideCarRunAsUser,
-						RunAsGroup: &sideCarRunAsGroup,
-					},
-					ImagePullPolicy: k8sv1.PullPolicy(k8sv1.PullAlways),
-					Env:             sidecarEnvVars,
-					Command:         hatchConfig.Config.Sidecar.Command,
-					Args:            hatchConfig.Config.Sidecar.Args,
-					VolumeMounts:    volumeMounts,
-					Resources: k8sv1.ResourceRequirements{
-						Limits: k8sv1.ResourceList{
-							k8sv1.ResourceCPU:    resource.MustParse(hatchConfig.Config.Sidecar.CPULimit),
-							k8sv1.ResourceMemory: resource.MustParse(hatchConfig.Config.Sidecar.MemoryLimit),
-						},
-						Requests: k8sv1.ResourceList{
-							k8sv1.ResourceCPU:    resource.MustParse(hatchConfig.Config.Sidecar.CPULimit),
-							k8sv1.ResourceMemory: resource.MustParse(hatchConfig.Config.Sidecar.MemoryLimit),
-						},
-					},
-					Lifecycle: &k8sv1.Lifecycle{
-						PreStop: &k8sv1.LifecycleHandler{
-							Exec: &k8sv1.ExecAction{
-								Command: hatchConfig.Config.Sidecar.LifecyclePreStop,
-							},
-						},
+		pod.Spec.Containers = append(pod.Spec.Containers, k8sv1.Container{
+			Name:            "fuse-container",
+			Image:           sidecar.Image,
+			SecurityContext: sidecarSecurityContext(sidecar),
+			ImagePullPolicy: k8sv1.PullPolicy(k8sv1.PullAlways),
+			Env:             sidecarEnvVars,
+			Command:         sidecar.Command,
+			Args:            sidecar.Args,
+			VolumeMounts:    volumeMounts,
+			Resources:       sidecarResourceRequirements,
+			Lifecycle: &k8sv1.Lifecycle{
+				PreStop: &k8sv1.LifecycleHandler{
+					Exec: &k8sv1.ExecAction{
+						Command: sidecar.LifecyclePreStop,
 					},
 				},
 			},
-			RestartPolicy:    k8sv1.RestartPolicyNever,
-			ImagePullSecrets: []k8sv1.LocalObjectReference{},
-			NodeSelector:     nodeSelector,
-			Tolerations:      tolerations,
-			Volumes:          volumes,
-		},
+		})
 	}
 
 	// some pods (ex - dockstore apps) only have "Friend" containers
 	if hatchApp.Image != "" {
-		var volumeMounts = []k8sv1.VolumeMount{
-			{
-				MountPath:        "/data",
-				Name:             "shared-data",
-				MountPropagation: &hostToContainer,
-			},
+		var volumeMounts []k8sv1.VolumeMount
+		if sidecar != nil {
+			volumeMounts = append(volumeMounts, k8sv1.VolumeMount{
+				MountPath: "/data",
+				Name:      "shared-data",
+			})
+			if propagatesFuseMounts(sidecar) {
+				volumeMounts[0].MountPropagation = &hostToContainer
+			}
 		}
 
 		if hatchApp.Gen3VolumeLocation != "" {
@@ -613,19 +652,16 @@ func buildPod(hatchConfig *FullHatcheryConfig, hatchApp *Container, userName str
 		}
 
 		// Build resource lists
-		resourceLimits := k8sv1.ResourceList{
-			k8sv1.ResourceCPU:    resource.MustParse(hatchApp.CPULimit),
-			k8sv1.ResourceMemory: resource.MustParse(hatchApp.MemoryLimit),
-		}
-		resourceRequests := k8sv1.ResourceList{
-			k8sv1.ResourceCPU:    resource.MustParse(hatchApp.CPULimit),
-			k8sv1.ResourceMemory: resource.MustParse(hatchApp.MemoryLimit),
+		resources, err := containerResources(hatchApp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resources for container '%s': %v", hatchApp.Name, err)
 		}
 
 		// Add GPU resources if requested
 		if hatchApp.GPU {
-			resourceLimits["nvidia.com/gpu"] = resource.MustParse("1")
-			resourceRequests["nvidia.com/gpu"] = resource.MustParse("1")
+			gpuCount := resource.MustParse(strconv.Itoa(containerGPUCount(hatchApp)))
+			resources.Limits[containerGPUResourceName(hatchApp)] = gpuCount
+			resources.Requests[containerGPUResourceName(hatchApp)] = gpuCount
 		}
 
 		pod.Spec.Containers = append(pod.Spec.Containers, k8sv1.Container{
@@ -639,11 +675,8 @@ func buildPod(hatchConfig *FullHatcheryConfig, hatchApp *Container, userName str
 			Command:         hatchApp.Command,
 			Args:            hatchApp.Args,
 			VolumeMounts:    volumeMounts,
-			Resources: k8sv1.ResourceRequirements{
-				Limits:   resourceLimits,
-				Requests: resourceRequests,
-			},
-			Lifecycle: &lifeCycle,
+			Resources:       resources,
+			Lifecycle:       &lifeCycle,
 			ReadinessProbe: &k8sv1.Probe{
 				ProbeHandler: k8sv1.ProbeHandler{
 					HTTPGet: &k8sv1.HTTPGetAction{
@@ -657,131 +690,214 @@ func buildPod(hatchConfig *FullHatcheryConfig, hatchApp *Container, userName str
 
 	pod.Spec.Containers = append(pod.Spec.Containers, hatchApp.Friends...)
 	//hatchConfig.Logger.Printf("friends added")
+	for _, containers := range [][]k8sv1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			if err := applySecurityProfile(&containers[i], securityProfile); err != nil {
+				return nil, fmt.Errorf("invalid security profile for container '%s': %v", hatchApp.Name, err)
+			}
+		}
+	}
+	if err := mountExtraVolumes(pod, hatchApp.ExtraVolumes); err != nil {
+		return nil, err
+	}
 	return pod, nil
 }
 
-var createLocalK8sPod = func(ctx context.Context, hash string, userName string, accessToken string, envVars []k8sv1.EnvVar, payModelId ...string) error {
+// localWorkspaceEnvVars are the env vars added to workspaces running in the local cluster
+func localWorkspaceEnvVars(apiKey *APIKeyStruct) []k8sv1.EnvVar {
+	return []k8sv1.EnvVar{
+		{
+			Name:  "API_KEY",
+			Value: apiKey.APIKey,
+		},
+		{
+			Name:  "API_KEY_ID",
+			Value: apiKey.KeyID,
+		},
+	}
+}
+
+// externalWorkspaceEnvVars are the env vars added to workspaces running in an external cluster
+func externalWorkspaceEnvVars(apiKey *APIKeyStruct, accessToken string) []k8sv1.EnvVar {
+	return []k8sv1.EnvVar{
+		{
+			Name:  "WTS_OVERRIDE_URL",
+			Value: "https://" + os.Getenv("GEN3_ENDPOINT") + "/wts",
+		},
+		{
+			Name:  "API_KEY",
+			Value: apiKey.APIKey,
+		},
+		{
+			Name:  "API_KEY_ID",
+			Value: apiKey.KeyID,
+		},
+		// TODO: still mounting access token for now, remove this when fully switched to use API key
+		{
+			Name:  "ACCESS_TOKEN",
+			Value: accessToken,
+		},
+	}
+}
+
+// buildPVC builds the claim for the user volume of the workspace's pod
+func buildPVC(userName string, workspaceId string, pod *k8sv1.Pod, volume UserVolumeConfig) *k8sv1.PersistentVolumeClaim {
+	// the claim outlives the pod and its API key
+	annotations := make(map[string]string)
+	for key, value := range pod.Annotations {
+		if key != apiKeyIdAnnotation {
+			annotations[key] = value
+		}
+	}
+	accessMode := k8sv1.ReadWriteOnce
+	if volume.AccessMode != "" {
+		accessMode = k8sv1.PersistentVolumeAccessMode(volume.AccessMode)
+	}
+	var storageClassName *string
+	if volume.StorageClass != "" {
+		storageClassName = &volume.StorageClass
+	}
+	return &k8sv1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        workspaceToResourceName(userName, workspaceId, "claim"),
+			Annotations: annotations,
+			Labels:      pod.Labels,
+		},
+		Spec: k8sv1.PersistentVolumeClaimSpec{
+			AccessModes:      []k8sv1.PersistentVolumeAccessMode{accessMode},
+			StorageClassName: storageClassName,
+			Resources: k8sv1.VolumeResourceRequirements{
+				Requests: k8sv1.ResourceList{
+					k8sv1.ResourceStorage: resource.MustParse(volume.Size),
+				},
+			},
+		},
+	}
+}
+
+// buildWorkspaceService builds the service routing traffic to the workspace's pod, in the
+// cluster the pod runs in. In an external cluster, the service is exposed on a node port
+func buildWorkspaceService(hatchApp *Container, userName string, workspaceId string, external bool) *k8sv1.Service {
+	podName := workspaceToResourceName(userName, workspaceId, "pod")
+	serviceName := workspaceToResourceName(userName, workspaceId, "service")
+	labelsService := make(map[string]string)
+	labelsService["app"] = podName
+	annotationsService := make(map[string]string)
+	annotationsService["gen3username"] = userName
+	annotationsService[containerNameAnnotation] = hatchApp.Name
+	if workspaceId != "" {
+		annotationsService[workspaceIdAnnotation] = workspaceId
+	}
+	for key, value := range currentWorkspaceRouter().serviceAnnotations(localWorkspaceRoute(hatchApp, userName, workspaceId)) {
+		annotationsService[key] = value
+	}
+	serviceType := k8sv1.ServiceTypeClusterIP
+	if external {
+		annotationsService["service.beta.kubernetes.io/aws-load-balancer-internal"] = "true"
+		serviceType = k8sv1.ServiceTypeNodePort
+	}
+
+	return &k8sv1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        serviceName,
+			Namespace:   Config().Config.UserNamespace,
+			Labels:      labelsService,
+			Annotations: annotationsService,
+		},
+		Spec: k8sv1.ServiceSpec{
+			Type:     serviceType,
+			Selector: map[string]string{"app": podName},
+			Ports: []k8sv1.ServicePort{
+				{
+					Name:     podName,
+					Protocol: k8sv1.ProtocolTCP,
+					Port:     80,
+					TargetPort: intstr.IntOrString{
+						Type:   intstr.Int,
+						IntVal: hatchApp.TargetPort,
+					},
+				},
+			},
+		},
+	}
+}
+
+var createLocalK8sPod = func(ctx context.Context, hatchConfig *FullHatcheryConfig, hatchApp Container, size WorkspaceSize, userName string, workspaceId string, accessToken string, payModel *PayModel, envVars []k8sv1.EnvVar, payModelId ...string) error {
 	// Set default if not provided
 	payModelIdValue := ""
 	if len(payModelId) > 0 && payModelId[0] != "" {
 		payModelIdValue = payModelId[0]
 	}
 
-	hatchApp := Config.ContainersMap[hash]
-	Config.Logger.Printf("Creating a Local K8s Pod")
+	hatchApp = workspaceContainer(hatchConfig, hatchApp, size, payModel)
+	hatchConfig.Logger.Printf("Creating a Local K8s Pod")
 
 	apiKey, err := getAPIKeyWithContext(ctx, accessToken)
 	if err != nil {
-		Config.Logger.Printf("Failed to get API key for user '%v', Error: %v", userName, err)
+		hatchConfig.Logger.Printf("Failed to get API key for user '%v', Error: %v", userName, err)
 		return err
 	}
-	Config.Logger.Printf("Created API key for user %v, key ID: %v", userName, apiKey.KeyID)
+	hatchConfig.Logger.Printf("Created API key for user %v, key ID: %v", userName, apiKey.KeyID)
 
 	var extraVars []k8sv1.EnvVar
 	extraVars = append(extraVars, envVars...)
+	extraVars = append(extraVars, localWorkspaceEnvVars(apiKey)...)
 
-	extraVars = append(extraVars, k8sv1.EnvVar{
-		Name:  "API_KEY",
-		Value: apiKey.APIKey,
-	})
-	extraVars = append(extraVars, k8sv1.EnvVar{
-		Name:  "API_KEY_ID",
-		Value: apiKey.KeyID,
-	})
-
-	pod, err := buildPod(Config, &hatchApp, userName, extraVars, payModelIdValue)
+	pod, err := buildPod(hatchConfig, &hatchApp, userName, workspaceId, extraVars, payModelIdValue)
 	if err != nil {
-		Config.Logger.Printf("Failed to configure pod for launch for user %v, Error: %v", userName, err)
+		hatchConfig.Logger.Printf("Failed to configure pod for launch for user %v, Error: %v", userName, err)
+		return err
+	}
+	secret, err := buildWorkspaceSecret(&hatchApp, userName, workspaceId, pod, apiKey)
+	if err != nil {
+		hatchConfig.Logger.Printf("Failed to configure the credentials for launch for user %v, Error: %v", userName, err)
 		return err
 	}
-	podName := userToResourceName(userName, "pod")
 	podClient, _, err := getPodClient(ctx, userName, nil)
 	if err != nil {
-		Config.Logger.Panicf("Error in createLocalK8sPod: %v", err)
+		hatchConfig.Logger.Panicf("Error in createLocalK8sPod: %v", err)
+		return err
+	}
+	err = createWorkspaceNetworkPolicy(ctx, hatchConfig, &hatchApp, userName, workspaceId, pod, nil)
+	if err != nil {
+		return err
+	}
+	err = createWorkspaceSecret(ctx, podClient, userName, secret)
+	if err != nil {
 		return err
 	}
 	// a null image indicates a dockstore app - always mount user volume
-	mountUserVolume := hatchApp.UserVolumeLocation != ""
-	if mountUserVolume {
-		claimName := userToResourceName(userName, "claim")
-
-		_, err := podClient.PersistentVolumeClaims(Config.Config.UserNamespace).Get(ctx, claimName, metav1.GetOptions{})
+	if hatchApp.UserVolumeLocation != "" {
+		err = createUserVolume(ctx, podClient, userName, workspaceId, pod, workspaceUserVolume(hatchConfig, &hatchApp, size, payModel), nil, time.Now())
 		if err != nil {
-			Config.Logger.Printf("Creating PersistentVolumeClaim %s.\n", claimName)
-			pvc := &k8sv1.PersistentVolumeClaim{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:        claimName,
-					Annotations: pod.Annotations,
-					Labels:      pod.Labels,
-				},
-				Spec: k8sv1.PersistentVolumeClaimSpec{
-					AccessModes: []k8sv1.PersistentVolumeAccessMode{k8sv1.ReadWriteOnce},
-					Resources: k8sv1.VolumeResourceRequirements{
-						Requests: k8sv1.ResourceList{
-							k8sv1.ResourceStorage: resource.MustParse(Config.Config.UserVolumeSize),
-						},
-					},
-				},
-			}
-			_, err := podClient.PersistentVolumeClaims(Config.Config.UserNamespace).Create(ctx, pvc, metav1.CreateOptions{})
-			if err != nil {
-				Config.Logger.Printf("Failed to create PVC %s. Error: %s\n", claimName, err)
-				return err
-			}
+			return err
 		}
 	}
 
-	_, err = podClient.Pods(Config.Config.UserNamespace).Create(ctx, pod, metav1.CreateOptions{})
+	createdPod, err := podClient.Pods(hatchConfig.Config.UserNamespace).Create(ctx, pod, metav1.CreateOptions{})
 	if err != nil {
-		Config.Logger.Printf("Failed to launch pod %s for user %s. Image: %s, CPU %s, Memory %s. Error: %s\n", hatchApp.Name, userName, hatchApp.Image, hatchApp.CPULimit, hatchApp.MemoryLimit, err)
+		hatchConfig.Logger.Printf("Failed to launch pod %s for user %s. Image: %s, CPU %s, Memory %s. Error: %s\n", hatchApp.Name, userName, hatchApp.Image, hatchApp.CPULimit, hatchApp.MemoryLimit, err)
 		return err
 	}
+	setWorkspaceSecretOwner(ctx, podClient, userName, secret.Name, createdPod)
 
-	Config.Logger.Printf("Launched pod %s for user %s. Image: %s, CPU %s, Memory %s\n", hatchApp.Name, userName, hatchApp.Image, hatchApp.CPULimit, hatchApp.MemoryLimit)
-
-	serviceName := userToResourceName(userName, "service")
-	labelsService := make(map[string]string)
-	labelsService["app"] = podName
-	annotationsService := make(map[string]string)
-	annotationsService["getambassador.io/config"] = fmt.Sprintf(ambassadorYaml, userToResourceName(userName, "mapping"), userName, serviceName, Config.Config.UserNamespace, hatchApp.PathRewrite, hatchApp.UseTLS)
+	hatchConfig.Logger.Printf("Launched pod %s for user %s. Image: %s, CPU %s, Memory %s\n", hatchApp.Name, userName, hatchApp.Image, hatchApp.CPULimit, hatchApp.MemoryLimit)
 
-	_, err = podClient.Services(Config.Config.UserNamespace).Get(ctx, serviceName, metav1.GetOptions{})
+	service := buildWorkspaceService(&hatchApp, userName, workspaceId, false)
+	serviceName := service.Name
+	_, err = podClient.Services(hatchConfig.Config.UserNamespace).Get(ctx, serviceName, metav1.GetOptions{})
 	if err == nil {
 		policy := metav1.DeletePropagationBackground
 		deleteOptions := metav1.DeleteOptions{
 			PropagationPolicy: &policy,
 		}
-		err = podClient.Services(Config.Config.UserNamespace).Delete(ctx, serviceName, deleteOptions)
+		err = podClient.Services(hatchConfig.Config.UserNamespace).Delete(ctx, serviceName, deleteOptions)
 		if err != nil {
 			fmt.Printf("Error occurred when deleting service: %s", err)
 		}
 	}
 
-	service := &k8sv1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        serviceName,
-			Namespace:   Config.Config.UserNamespace,
-			Labels:      labelsService,
-			Annotations: annotationsService,
-		},
-		Spec: k8sv1.ServiceSpec{
-			Type:     k8sv1.ServiceTypeClusterIP,
-			Selector: map[string]string{"app": podName},
-			Ports: []k8sv1.ServicePort{
-				{
-					Name:     podName,
-					Protocol: k8sv1.ProtocolTCP,
-					Port:     80,
-					TargetPort: intstr.IntOrString{
-						Type:   intstr.Int,
-						IntVal: hatchApp.TargetPort,
-					},
-				},
-			},
-		},
-	}
-
-	_, err = podClient.Services(Config.Config.UserNamespace).Create(ctx, service, metav1.CreateOptions{})
+	_, err = podClient.Services(hatchConfig.Config.UserNamespace).Create(ctx, service, metav1.CreateOptions{})
 	if err != nil {
 		fmt.Printf("Failed to launch service %s for user %s forwarding port %d. Error: %s\n", serviceName, userName, hatchApp.TargetPort, err)
 		return err
@@ -789,119 +905,87 @@ var createLocalK8sPod = func(ctx context.Context, hash string, userName string,
 
 	fmt.Printf("Launched service %s for user %s forwarding port %d\n", serviceName, userName, hatchApp.TargetPort)
 
-	return nil
+	return createWorkspaceRoute(ctx, localWorkspaceRoute(&hatchApp, userName, workspaceId))
 }
 
-var createExternalK8sPod = func(ctx context.Context, hash string, userName string, accessToken string, payModel PayModel, envVars []k8sv1.EnvVar, payModelId ...string) error {
+var createExternalK8sPod = func(ctx context.Context, hatchConfig *FullHatcheryConfig, hatchApp Container, size WorkspaceSize, userName string, workspaceId string, accessToken string, payModel PayModel, envVars []k8sv1.EnvVar, payModelId ...string) error {
 	payModelIdValue := uuid.New().String()
 	if len(payModelId) > 0 && payModelId[0] != "" {
 		payModelIdValue = payModelId[0]
 	}
-	hatchApp := Config.ContainersMap[hash]
-	Config.Logger.Printf("Creating a External K8s Pod")
+	hatchApp = workspaceContainer(hatchConfig, hatchApp, size, &payModel)
+	hatchConfig.Logger.Printf("Creating a External K8s Pod")
 	podClient, err := NewEKSClientset(ctx, userName, payModel)
 	if err != nil {
-		Config.Logger.Printf("Failed to create pod client for user %v, Error: %v", userName, err)
+		hatchConfig.Logger.Printf("Failed to create pod client for user %v, Error: %v", userName, err)
 		return err
 	}
 
 	apiKey, err := getAPIKeyWithContext(ctx, accessToken)
 	if err != nil {
-		Config.Logger.Printf("Failed to get API key for user '%v', Error: %v", userName, err)
+		hatchConfig.Logger.Printf("Failed to get API key for user '%v', Error: %v", userName, err)
 		return err
 	}
-	Config.Logger.Printf("Created API key for user %v, key ID: %v", userName, apiKey.KeyID)
+	hatchConfig.Logger.Printf("Created API key for user %v, key ID: %v", userName, apiKey.KeyID)
 
 	// Check if NS exists in external cluster, if not create it.
-	ns, err := podClient.Namespaces().Get(ctx, Config.Config.UserNamespace, metav1.GetOptions{})
+	ns, err := podClient.Namespaces().Get(ctx, hatchConfig.Config.UserNamespace, metav1.GetOptions{})
 	if err != nil {
 		nsName := &k8sv1.Namespace{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: Config.Config.UserNamespace,
+				Name: hatchConfig.Config.UserNamespace,
 			},
 		}
 		_, err = podClient.Namespaces().Create(ctx, nsName, metav1.CreateOptions{})
 		if err != nil {
-			Config.Logger.Printf("Error occurred when creating namespace: %s", err)
+			hatchConfig.Logger.Printf("Error occurred when creating namespace: %s", err)
 		} else {
-			Config.Logger.Printf("Namespace created: %v", ns)
+			hatchConfig.Logger.Printf("Namespace created: %v", ns)
 		}
 	}
 
 	var extraVars []k8sv1.EnvVar
 	extraVars = append(extraVars, envVars...)
+	extraVars = append(extraVars, externalWorkspaceEnvVars(apiKey, accessToken)...)
 
-	extraVars = append(extraVars, k8sv1.EnvVar{
-		Name:  "WTS_OVERRIDE_URL",
-		Value: "https://" + os.Getenv("GEN3_ENDPOINT") + "/wts",
-	})
-	extraVars = append(extraVars, k8sv1.EnvVar{
-		Name:  "API_KEY",
-		Value: apiKey.APIKey,
-	})
-	extraVars = append(extraVars, k8sv1.EnvVar{
-		Name:  "API_KEY_ID",
-		Value: apiKey.KeyID,
-	})
-	// TODO: still mounting access token for now, remove this when fully switched to use API key
-	extraVars = append(extraVars, k8sv1.EnvVar{
-		Name:  "ACCESS_TOKEN",
-		Value: accessToken,
-	})
-
-	pod, err := buildPod(Config, &hatchApp, userName, extraVars, payModelIdValue)
+	pod, err := buildPod(hatchConfig, &hatchApp, userName, workspaceId, extraVars, payModelIdValue)
+	if err != nil {
+		hatchConfig.Logger.Printf("Failed to configure pod for launch for user %v, Error: %v", userName, err)
+		return err
+	}
+	secret, err := buildWorkspaceSecret(&hatchApp, userName, workspaceId, pod, apiKey)
+	if err != nil {
+		hatchConfig.Logger.Printf("Failed to configure the credentials for launch for user %v, Error: %v", userName, err)
+		return err
+	}
+	err = createWorkspaceNetworkPolicy(ctx, hatchConfig, &hatchApp, userName, workspaceId, pod, &payModel)
+	if err != nil {
+		return err
+	}
+	err = createWorkspaceSecret(ctx, podClient, userName, secret)
 	if err != nil {
-		Config.Logger.Printf("Failed to configure pod for launch for user %v, Error: %v", userName, err)
 		return err
 	}
-	podName := userToResourceName(userName, "pod")
 	// a null image indicates a dockstore app - always mount user volume
-	mountUserVolume := hatchApp.UserVolumeLocation != ""
-	if mountUserVolume {
-		claimName := userToResourceName(userName, "claim")
-
-		_, err := podClient.PersistentVolumeClaims(Config.Config.UserNamespace).Get(ctx, claimName, metav1.GetOptions{})
+	if hatchApp.UserVolumeLocation != "" {
+		err = createUserVolume(ctx, podClient, userName, workspaceId, pod, workspaceUserVolume(hatchConfig, &hatchApp, size, &payModel), &payModel, time.Now())
 		if err != nil {
-			Config.Logger.Printf("Creating PersistentVolumeClaim %s.\n", claimName)
-			pvc := &k8sv1.PersistentVolumeClaim{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:        claimName,
-					Annotations: pod.Annotations,
-					Labels:      pod.Labels,
-				},
-				Spec: k8sv1.PersistentVolumeClaimSpec{
-					AccessModes: []k8sv1.PersistentVolumeAccessMode{k8sv1.ReadWriteOnce},
-					Resources: k8sv1.VolumeResourceRequirements{
-						Requests: k8sv1.ResourceList{
-							k8sv1.ResourceStorage: resource.MustParse(Config.Config.UserVolumeSize),
-						},
-					},
-				},
-			}
-
-			_, err := podClient.PersistentVolumeClaims(Config.Config.UserNamespace).Create(ctx, pvc, metav1.CreateOptions{})
-			if err != nil {
-				Config.Logger.Printf("Failed to create PVC %s. Error: %s\n", claimName, err)
-				return err
-			}
+			return err
 		}
 	}
 
-	_, err = podClient.Pods(Config.Config.UserNamespace).Create(ctx, pod, metav1.CreateOptions{})
+	createdPod, err := podClient.Pods(hatchConfig.Config.UserNamespace).Create(ctx, pod, metav1.CreateOptions{})
 	if err != nil {
-		Config.Logger.Printf("Failed to launch pod %s for user %s. Image: %s, CPU %s, Memory %s. Error: %s\n", hatchApp.Name, userName, hatchApp.Image, hatchApp.CPULimit, hatchApp.MemoryLimit, err)
+		hatchConfig.Logger.Printf("Failed to launch pod %s for user %s. Image: %s, CPU %s, Memory %s. Error: %s\n", hatchApp.Name, userName, hatchApp.Image, hatchApp.CPULimit, hatchApp.MemoryLimit, err)
 		return err
 	}
+	setWorkspaceSecretOwner(ctx, podClient, userName, secret.Name, createdPod)
 
-	Config.Logger.Printf("Launched pod %s for user %s. Image: %s, CPU %s, Memory %s\n", hatchApp.Name, userName, hatchApp.Image, hatchApp.CPULimit, hatchApp.MemoryLimit)
+	hatchConfig.Logger.Printf("Launched pod %s for user %s. Image: %s, CPU %s, Memory %s\n", hatchApp.Name, userName, hatchApp.Image, hatchApp.CPULimit, hatchApp.MemoryLimit)
 
-	serviceName := userToResourceName(userName, "service")
-	labelsService := make(map[string]string)
-	labelsService["app"] = podName
-	annotationsService := make(map[string]string)
-	annotationsService["getambassador.io/config"] = fmt.Sprintf(ambassadorYaml, userToResourceName(userName, "mapping"), userName, serviceName, Config.Config.UserNamespace, hatchApp.PathRewrite, hatchApp.UseTLS)
-	annotationsService["service.beta.kubernetes.io/aws-load-balancer-internal"] = "true"
-	_, err = podClient.Services(Config.Config.UserNamespace).Get(ctx, serviceName, metav1.GetOptions{})
+	service := buildWorkspaceService(&hatchApp, userName, workspaceId, true)
+	serviceName := service.Name
+	_, err = podClient.Services(hatchConfig.Config.UserNamespace).Get(ctx, serviceName, metav1.GetOptions{})
 	if err == nil {
 		// This probably happened as the result of some error... there was no pod but was a service
 		// Lets just clean it up and proceed
@@ -909,50 +993,26 @@ var createExternalK8sPod = func(ctx context.Context, hash string, userName strin
 		deleteOptions := metav1.DeleteOptions{
 			PropagationPolicy: &policy,
 		}
-		err = podClient.Services(Config.Config.UserNamespace).Delete(ctx, serviceName, deleteOptions)
+		err = podClient.Services(hatchConfig.Config.UserNamespace).Delete(ctx, serviceName, deleteOptions)
 		if err != nil {
 			fmt.Printf("Error occurred when deleting service: %s", err)
 		}
 	}
 
-	service := &k8sv1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        serviceName,
-			Namespace:   Config.Config.UserNamespace,
-			Labels:      labelsService,
-			Annotations: annotationsService,
-		},
-		Spec: k8sv1.ServiceSpec{
-			Type:     k8sv1.ServiceTypeNodePort,
-			Selector: map[string]string{"app": podName},
-			Ports: []k8sv1.ServicePort{
-				{
-					Name:     podName,
-					Protocol: k8sv1.ProtocolTCP,
-					Port:     80,
-					TargetPort: intstr.IntOrString{
-						Type:   intstr.Int,
-						IntVal: hatchApp.TargetPort,
-					},
-				},
-			},
-		},
-	}
-
-	_, err = podClient.Services(Config.Config.UserNamespace).Create(ctx, service, metav1.CreateOptions{})
+	_, err = podClient.Services(hatchConfig.Config.UserNamespace).Create(ctx, service, metav1.CreateOptions{})
 	if err != nil {
 		fmt.Printf("Failed to launch service %s for user %s forwarding port %d. Error: %s\n", serviceName, userName, hatchApp.TargetPort, err)
 		return err
 	}
 
-	Config.Logger.Printf("Launched service %s for user %s forwarding port %d\n", serviceName, userName, hatchApp.TargetPort)
+	hatchConfig.Logger.Printf("Launched service %s for user %s forwarding port %d\n", serviceName, userName, hatchApp.TargetPort)
 
 	nodes, _ := podClient.Nodes().List(context.TODO(), metav1.ListOptions{
 		LabelSelector: "role=jupyter",
 	})
 	NodeIP := nodes.Items[0].Status.Addresses[0].Address
 
-	err = createLocalService(ctx, userName, hash, NodeIP, payModel)
+	err = createLocalService(ctx, hatchConfig, userName, workspaceId, &hatchApp, NodeIP, payModel)
 	if err != nil {
 		fmt.Println(err.Error())
 		return err
@@ -963,45 +1023,39 @@ var createExternalK8sPod = func(ctx context.Context, hash string, userName strin
 
 // Creates a local service that portal can reach
 // and route traffic to pod in external cluster.
-func createLocalService(ctx context.Context, userName string, hash string, serviceURL string, payModel PayModel) error {
-	const localAmbassadorYaml = `---
-apiVersion: ambassador/v1
-kind:  Mapping
-name:  %s
-prefix: /
-headers:
-  remote_user: %s
-service: %s:%d
-bypass_auth: true
-timeout_ms: 300000
-use_websocket: true
-rewrite: %s
-tls: %s
-`
-	hatchApp := Config.ContainersMap[hash]
-
-	serviceName := userToResourceName(userName, "service")
+func createLocalService(ctx context.Context, hatchConfig *FullHatcheryConfig, userName string, workspaceId string, hatchApp *Container, serviceURL string, payModel PayModel) error {
+
+	serviceName := workspaceToResourceName(userName, workspaceId, "service")
 	NodePort := int32(80)
 	if !payModel.Ecs {
 		externalPodClient, err := NewEKSClientset(ctx, userName, payModel)
 		if err != nil {
 			return err
 		}
-		service, err := externalPodClient.Services(Config.Config.UserNamespace).Get(ctx, serviceName, metav1.GetOptions{})
+		service, err := externalPodClient.Services(hatchConfig.Config.UserNamespace).Get(ctx, serviceName, metav1.GetOptions{})
 		NodePort = service.Spec.Ports[0].NodePort
 		if err != nil {
 			return err
 		}
 	}
-	podName := userToResourceName(userName, "pod")
+	podName := workspaceToResourceName(userName, workspaceId, "pod")
 
 	labelsService := make(map[string]string)
 	labelsService["app"] = podName
 	annotationsService := make(map[string]string)
-	annotationsService["getambassador.io/config"] = fmt.Sprintf(localAmbassadorYaml, userToResourceName(userName, "mapping"), userName, serviceURL, NodePort, hatchApp.PathRewrite, hatchApp.UseTLS)
+	annotationsService["gen3username"] = userName
+	annotationsService[containerNameAnnotation] = hatchApp.Name
+	if workspaceId != "" {
+		annotationsService[workspaceIdAnnotation] = workspaceId
+	}
+	route := externalWorkspaceRoute(hatchApp, userName, workspaceId, serviceURL, NodePort)
+	router := currentWorkspaceRouter()
+	for key, value := range router.serviceAnnotations(route) {
+		annotationsService[key] = value
+	}
 
 	localPodClient := getLocalPodClient()
-	_, err := localPodClient.Services(Config.Config.UserNamespace).Get(ctx, serviceName, metav1.GetOptions{})
+	_, err := localPodClient.Services(hatchConfig.Config.UserNamespace).Get(ctx, serviceName, metav1.GetOptions{})
 	if err == nil {
 		// This probably happened as the result of some error... there was no pod but was a service
 		// Lets just clean it up and proceed
@@ -1009,7 +1063,7 @@ tls: %s
 		deleteOptions := metav1.DeleteOptions{
 			PropagationPolicy: &policy,
 		}
-		err = localPodClient.Services(Config.Config.UserNamespace).Delete(ctx, serviceName, deleteOptions)
+		err = localPodClient.Services(hatchConfig.Config.UserNamespace).Delete(ctx, serviceName, deleteOptions)
 		if err != nil {
 			fmt.Printf("Error occurred when deleting service: %s", err)
 		}
@@ -1018,7 +1072,7 @@ tls: %s
 	localService := &k8sv1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:        serviceName,
-			Namespace:   Config.Config.UserNamespace,
+			Namespace:   hatchConfig.Config.UserNamespace,
 			Labels:      labelsService,
 			Annotations: annotationsService,
 		},
@@ -1039,12 +1093,23 @@ tls: %s
 		},
 	}
 
-	_, err = localPodClient.Services(Config.Config.UserNamespace).Create(ctx, localService, metav1.CreateOptions{})
+	if router.backendIsService() {
+		// the route objects send the requests to the service, which forwards them out of the cluster
+		localService.Spec = k8sv1.ServiceSpec{
+			Type:         k8sv1.ServiceTypeExternalName,
+			ExternalName: serviceURL,
+			Ports: []k8sv1.ServicePort{
+				{Name: podName, Protocol: k8sv1.ProtocolTCP, Port: NodePort},
+			},
+		}
+	}
+
+	_, err = localPodClient.Services(hatchConfig.Config.UserNamespace).Create(ctx, localService, metav1.CreateOptions{})
 	if err != nil {
 		fmt.Printf("Failed to launch local service %s for user %s forwarding port %d. Error: %s\n", serviceName, userName, hatchApp.TargetPort, err)
 		return err
 	}
 
-	Config.Logger.Printf("Launched local service %s for user %s forwarding port %d\n", serviceName, userName, hatchApp.TargetPort)
-	return nil
+	hatchConfig.Logger.Printf("Launched local service %s for user %s forwarding port %d\n", serviceName, userName, hatchApp.TargetPort)
+	return createWorkspaceRoute(ctx, route)
 }
@@ -20,7 +20,7 @@ func TestBuildPodFromJSON(t *testing.T) {
 		return
 	}
 	app := &config.Config.Containers[numApps-3]
-	pod, err := buildPod(config, app, "frickjack", nil)
+	pod, err := buildPod(config, app, "frickjack", "", nil)
 
 	if nil != err {
 		t.Errorf("failed to build a pod - %v", err)
@@ -53,7 +53,7 @@ func TestBuildPodFromDockstore(t *testing.T) {
 		return
 	}
 	app := &config.Config.Containers[numApps-2]
-	pod, err := buildPod(config, app, "frickjack", nil)
+	pod, err := buildPod(config, app, "frickjack", "", nil)
 
 	if nil != err {
 		t.Errorf("failed to build a pod - %v", err)
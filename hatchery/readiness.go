@@ -0,0 +1,197 @@
+package hatchery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// How long each dependency has to respond to the readiness check
+var readinessCheckTimeout = 5 * time.Second
+
+// DependencyCheck checks that hatchery can reach one of its dependencies
+type DependencyCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// DependencyStatus is the result of a DependencyCheck
+type DependencyStatus struct {
+	Name      string `json:"name"`
+	Healthy   bool   `json:"healthy"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+}
+
+// ReadinessReport is returned by the deep status check
+type ReadinessReport struct {
+	Healthy      bool               `json:"healthy"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+// getDependencyChecks returns the checks for the dependencies hatchery is configured to use
+var getDependencyChecks = func() []DependencyCheck {
+	checks := []DependencyCheck{
+		{Name: "kubernetes", Check: checkKubernetes},
+		{Name: "arborist", Check: checkArborist},
+		{Name: "fence", Check: checkFence},
+	}
+	if Config().Config.PayModelsDynamodbTable != "" {
+		checks = append(checks, DependencyCheck{Name: "pay-models-dynamodb-table", Check: checkPayModelsTable})
+	}
+	if Config().Config.LicenseUserMapsTable != "" {
+		checks = append(checks, DependencyCheck{Name: "license-user-maps-dynamodb-table", Check: checkLicenseUserMapsTable})
+	}
+	if Config().Config.Audit.Sink == auditSinkDynamodb {
+		checks = append(checks, DependencyCheck{Name: "audit-dynamodb-table", Check: checkAuditTable})
+	}
+	return checks
+}
+
+// runDependencyChecks runs the checks concurrently, each with its own timeout
+func runDependencyChecks(ctx context.Context, checks []DependencyCheck) ReadinessReport {
+	report := ReadinessReport{Healthy: true, Dependencies: make([]DependencyStatus, len(checks))}
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check DependencyCheck) {
+			defer wg.Done()
+			report.Dependencies[i] = runDependencyCheck(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, dependency := range report.Dependencies {
+		if !dependency.Healthy {
+			report.Healthy = false
+		}
+	}
+	return report
+}
+
+func runDependencyCheck(ctx context.Context, check DependencyCheck) (status DependencyStatus) {
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	status.Name = check.Name
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			// some clients panic instead of returning an error when they are misconfigured
+			if r := recover(); r != nil {
+				result <- fmt.Errorf("%v", r)
+			}
+		}()
+		result <- check.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", readinessCheckTimeout)
+	}
+	status.LatencyMs = time.Since(start).Milliseconds()
+	status.Healthy = err == nil
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
+
+func checkKubernetes(ctx context.Context) error {
+	podClient := getLocalPodClient()
+	if podClient == nil {
+		return errors.New("unable to get local pod client")
+	}
+	_, err := podClient.Pods(Config().Config.UserNamespace).List(ctx, metav1.ListOptions{Limit: 1})
+	return err
+}
+
+// checkReachable returns an error if the service can't be reached or fails
+// with a server error. Client errors are expected since no credentials are sent
+func checkReachable(ctx context.Context, method string, url string, body *bytes.Buffer) error {
+	resp, err := MakeARequestWithContext(ctx, method, url, "", "application/json", nil, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%s returned status code %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+func checkArborist(ctx context.Context) error {
+	return checkReachable(ctx, "POST", arboristAuthRequestURL, bytes.NewBufferString("{}"))
+}
+
+func checkFence(ctx context.Context) error {
+	return checkReachable(ctx, "GET", getFenceURL()+"credentials/api/", nil)
+}
+
+func checkPayModelsTable(ctx context.Context) error {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{
+			Region: aws.String("us-east-1"),
+		},
+	}))
+	payModelTableConfig := getPayModelTableCreds(sess)
+	dynamodbSvc := dynamodb.New(sess, &payModelTableConfig)
+	_, err := dynamodbSvc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(Config().Config.PayModelsDynamodbTable),
+	})
+	return err
+}
+
+func checkLicenseUserMapsTable(ctx context.Context) error {
+	dbconfig := initializeDbConfig()
+	_, err := dbconfig.DynamoDb.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(Config().Config.LicenseUserMapsTable),
+	})
+	return err
+}
+
+func checkAuditTable(ctx context.Context) error {
+	// the audit settings are only read at startup
+	if auditor == nil {
+		return errors.New("the audit log is not initialized")
+	}
+	sink, ok := auditor.sink.(*dynamodbAuditSink)
+	if !ok {
+		return errors.New("the audit log does not use the dynamodb sink")
+	}
+	_, err := sink.client.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(sink.table),
+	})
+	return err
+}
+
+// systemReady reports the status of each of hatchery's dependencies,
+// and returns 503 if any of them is unhealthy
+func systemReady(w http.ResponseWriter, r *http.Request) {
+	report := runDependencyChecks(r.Context(), getDependencyChecks())
+	out, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Healthy {
+		Config().Logger.Printf("Readiness check failed: %s", string(out))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprint(w, string(out))
+}
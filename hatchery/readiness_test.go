@@ -0,0 +1,99 @@
+package hatchery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_RunDependencyChecks(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalTimeout := readinessCheckTimeout
+	defer func() {
+		readinessCheckTimeout = originalTimeout
+	}()
+	readinessCheckTimeout = 50 * time.Millisecond
+
+	checks := []DependencyCheck{
+		{Name: "healthy", Check: func(ctx context.Context) error { return nil }},
+		{Name: "failing", Check: func(ctx context.Context) error { return errors.New("connection refused") }},
+		{Name: "slow", Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}},
+		{Name: "panicking", Check: func(ctx context.Context) error { panic("not in a cluster") }},
+	}
+	report := runDependencyChecks(context.Background(), checks)
+
+	if report.Healthy {
+		t.Errorf("\nassertion error while testing `RunDependencyChecks`: \nWant:unhealthy\nGot:healthy")
+	}
+	want := map[string]bool{"healthy": true, "failing": false, "slow": false, "panicking": false}
+	if len(report.Dependencies) != len(want) {
+		t.Fatalf("\nassertion error while testing `RunDependencyChecks`: \nWant %d dependencies\nGot:%+v", len(want), report.Dependencies)
+	}
+	for _, dependency := range report.Dependencies {
+		if dependency.Healthy != want[dependency.Name] {
+			t.Errorf("\nassertion error while testing `RunDependencyChecks` for %s: \nWant healthy:%v\nGot:%+v", dependency.Name, want[dependency.Name], dependency)
+		}
+		if !dependency.Healthy && dependency.Error == "" {
+			t.Errorf("\nassertion error while testing `RunDependencyChecks` for %s: missing error", dependency.Name)
+		}
+	}
+}
+
+func Test_SystemStatusDeep(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalGetDependencyChecks := getDependencyChecks
+	defer func() {
+		getDependencyChecks = originalGetDependencyChecks
+	}()
+
+	testCases := []struct {
+		name     string
+		checkErr error
+		want     int
+	}{
+		{name: "AllHealthy", checkErr: nil, want: http.StatusOK},
+		{name: "DependencyDown", checkErr: errors.New("connection refused"), want: http.StatusServiceUnavailable},
+	}
+	for _, testcase := range testCases {
+		t.Logf("Testing SystemStatusDeep when %s", testcase.name)
+		getDependencyChecks = func() []DependencyCheck {
+			return []DependencyCheck{
+				{Name: "kubernetes", Check: func(ctx context.Context) error { return nil }},
+				{Name: "arborist", Check: func(ctx context.Context) error { return testcase.checkErr }},
+			}
+		}
+
+		req := httptest.NewRequest("GET", "/_status?deep=true", nil)
+		w := httptest.NewRecorder()
+		systemStatus(w, req)
+
+		if w.Code != testcase.want {
+			t.Errorf("\nassertion error while testing `%s`: \nWant:%d\nGot:%d", testcase.name, testcase.want, w.Code)
+		}
+		report := ReadinessReport{}
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Errorf("\nassertion error while testing `%s`: unable to decode report %q: %v", testcase.name, w.Body.String(), err)
+		}
+		if report.Healthy != (testcase.checkErr == nil) || len(report.Dependencies) != 2 {
+			t.Errorf("\nassertion error while testing `%s` report: \nGot:%+v", testcase.name, report)
+		}
+	}
+
+	// the shallow check does not look at the dependencies
+	req := httptest.NewRequest("GET", "/_status", nil)
+	w := httptest.NewRecorder()
+	systemStatus(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "Healthy" {
+		t.Errorf("\nassertion error while testing `SystemStatus`: \nWant:%d Healthy\nGot:%d %s", http.StatusOK, w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,182 @@
+package hatchery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Results of a config reload, used as the `result` label of hatchery_config_reloads_total
+const (
+	configReloadSuccess = "success"
+	configReloadFailure = "failure"
+)
+
+// ConfigReloadStatus is returned by the /admin/config endpoint
+type ConfigReloadStatus struct {
+	Enabled       bool       `json:"enabled"`
+	ConfigPath    string     `json:"configPath,omitempty"`
+	Reloads       int        `json:"reloads"`
+	Failures      int        `json:"failures"`
+	LastReload    *time.Time `json:"lastReload,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+}
+
+// ConfigReloader periodically checks the config file and the dockstore compose
+// files it refers to, and swaps in the new config when they change. A new config
+// that fails to load or validate is rejected and the running one is kept
+type ConfigReloader struct {
+	path     string
+	interval time.Duration
+
+	mu          sync.Mutex
+	fingerprint string
+	status      ConfigReloadStatus
+
+	// Control channels
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// The reloader started by main, if any
+var configReloader *ConfigReloader
+
+// NewConfigReloader creates a reloader for the config file that was loaded at startup
+func NewConfigReloader(path string, interval time.Duration) *ConfigReloader {
+	fingerprint, err := configFingerprint(path)
+	if err != nil {
+		Config().Logger.Printf("Unable to read the config files for the config reloader: %v", err)
+	}
+	reloader := &ConfigReloader{
+		path:        path,
+		interval:    interval,
+		fingerprint: fingerprint,
+		status:      ConfigReloadStatus{Enabled: true, ConfigPath: path},
+		stopCh:      make(chan struct{}),
+		doneCh:      make(chan struct{}),
+	}
+	configReloader = reloader
+	return reloader
+}
+
+// Start runs the reload loop until Stop is called or ctx is cancelled
+func (r *ConfigReloader) Start(ctx context.Context) {
+	log.Printf("Starting config reloader for %s with interval: %s", r.path, r.interval)
+	defer close(r.doneCh)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stopCh:
+			log.Println("Config reloader stopped")
+			return
+		case <-ctx.Done():
+			log.Println("Config reloader stopped")
+			return
+		case <-ticker.C:
+			r.reloadIfChanged()
+		}
+	}
+}
+
+// Stop gracefully shuts down the reloader
+func (r *ConfigReloader) Stop() {
+	close(r.stopCh)
+	<-r.doneCh
+}
+
+// Status returns a copy of the reload counters and last error
+func (r *ConfigReloader) Status() ConfigReloadStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// reloadIfChanged loads and activates the config if any of its files changed since
+// the last attempt. It returns true if a new config was activated
+func (r *ConfigReloader) reloadIfChanged() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fingerprint, err := configFingerprint(r.path)
+	if err != nil {
+		// the files may be in the middle of being replaced: try again at the next tick
+		Config().Logger.Printf("Unable to read the config files, not reloading: %v", err)
+		return false
+	}
+	if fingerprint == r.fingerprint {
+		return false
+	}
+	// the same broken files are only reported once
+	r.fingerprint = fingerprint
+
+	now := time.Now()
+	newConfig, err := LoadConfig(r.path, Config().Logger)
+	if err != nil {
+		Config().Logger.Printf("Rejected the new config, keeping the running one: %v", err)
+		r.status.Failures++
+		r.status.LastError = err.Error()
+		r.status.LastErrorTime = &now
+		configReloadsTotal.WithLabelValues(configReloadFailure).Inc()
+		return false
+	}
+	newConfig.inheritContainerAliases(Config())
+	SetConfig(newConfig)
+	Config().Logger.Printf("Reloaded the config from %s: %d containers", r.path, len(newConfig.ContainersMap))
+	r.status.Reloads++
+	r.status.LastReload = &now
+	configReloadsTotal.WithLabelValues(configReloadSuccess).Inc()
+	configLastReloadTimestamp.SetToCurrentTime()
+	return true
+}
+
+// configFingerprint hashes the config file and the `more-configs` files it refers to
+func configFingerprint(path string) (string, error) {
+	hash := sha256.New()
+	plan, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	hash.Write(plan)
+
+	moreConfigs := struct {
+		MoreConfigs []AppConfigInfo `json:"more-configs"`
+	}{}
+	// an invalid file is still fingerprinted so that LoadConfig reports the error
+	if json.Unmarshal(plan, &moreConfigs) == nil {
+		for _, info := range moreConfigs.MoreConfigs {
+			// a missing file is reported by LoadConfig
+			content, err := os.ReadFile(info.Path)
+			fmt.Fprintf(hash, "\n%s:%x:%v", info.Path, sha256.Sum256(content), err != nil)
+		}
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// configReloadStatus reports the config reload counters and the last reload error
+func configReloadStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	status := ConfigReloadStatus{}
+	if configReloader != nil {
+		status = configReloader.Status()
+	}
+	out, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, string(out))
+}
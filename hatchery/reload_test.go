@@ -0,0 +1,84 @@
+package hatchery
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ConfigReloader(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalConfig := Config()
+	originalConfigReloader := configReloader
+	defer func() {
+		SetConfig(originalConfig)
+		configReloader = originalConfigReloader
+	}()
+
+	writeConfig := func(path string, containerNames ...string) {
+		containers := []string{}
+		for _, name := range containerNames {
+			containers = append(containers, `{"name": "`+name+`", "image": "quay.io/cdis/jupyter:master", "cpu-limit": "1.0", "memory-limit": "512Mi", "target-port": 8888}`)
+		}
+		content := `{"sidecar": {"cpu-limit": "0.1", "memory-limit": "256Mi"}, "containers": [` + strings.Join(containers, ",") + `]}`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("unable to write config: %v", err)
+		}
+	}
+	configPath := filepath.Join(t.TempDir(), "hatchery.json")
+	writeConfig(configPath, "Jupyter")
+	config, err := LoadConfig(configPath, originalConfig.Logger)
+	if err != nil {
+		t.Fatalf("unable to load config: %v", err)
+	}
+	SetConfig(config)
+
+	reloader := NewConfigReloader(configPath, time.Minute)
+	if reloader.reloadIfChanged() {
+		t.Errorf("\nassertion error while testing `ConfigReloader` without changes: \nWant:no reload\nGot:reload")
+	}
+
+	// a new container is picked up
+	writeConfig(configPath, "Jupyter", "R Studio")
+	if !reloader.reloadIfChanged() {
+		t.Errorf("\nassertion error while testing `ConfigReloader` with a new container: \nWant:reload\nGot:no reload")
+	}
+	if got := len(Config().ContainersMap); got != 2 {
+		t.Errorf("\nassertion error while testing `ConfigReloader` containers: \nWant:%d\nGot:%d", 2, got)
+	}
+
+	// an invalid config is rejected and the running one is kept
+	runningConfig := Config()
+	if err := os.WriteFile(configPath, []byte(`{"containers": [{"name": "Broken", "image": "a", "cpu-limit": "one"}]}`), 0644); err != nil {
+		t.Fatalf("unable to write config: %v", err)
+	}
+	if reloader.reloadIfChanged() {
+		t.Errorf("\nassertion error while testing `ConfigReloader` with an invalid config: \nWant:no reload\nGot:reload")
+	}
+	if Config() != runningConfig {
+		t.Errorf("\nassertion error while testing `ConfigReloader`: the running config was replaced by an invalid one")
+	}
+	// the same invalid config is only reported once
+	reloader.reloadIfChanged()
+
+	status := reloader.Status()
+	if status.Reloads != 1 || status.Failures != 1 || !strings.Contains(status.LastError, "$.containers[0].cpu-limit") {
+		t.Errorf("\nassertion error while testing `ConfigReloader` status: \nWant:1 reload, 1 failure\nGot:%+v", status)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/config", nil)
+	w := httptest.NewRecorder()
+	configReloadStatus(w, req)
+	got := ConfigReloadStatus{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unable to decode status %q: %v", w.Body.String(), err)
+	}
+	if !got.Enabled || got.Reloads != 1 || got.Failures != 1 || got.LastError != status.LastError {
+		t.Errorf("\nassertion error while testing `configReloadStatus`: \nWant:%+v\nGot:%+v", status, got)
+	}
+}
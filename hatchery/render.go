@@ -0,0 +1,203 @@
+package hatchery
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"sigs.k8s.io/yaml"
+)
+
+// Replaces secret values (API keys, tokens, licenses) in rendered workspaces
+const redactedValue = "<redacted>"
+
+// Replaces the values only known once a launch has created the AWS resources
+const createdAtLaunchValue = "<created at launch>"
+
+var redactedAPIKey = &APIKeyStruct{APIKey: redactedValue, KeyID: redactedValue}
+
+// RenderWorkspace returns the YAML manifests of the resources a launch of the
+// container would create, without creating anything. Secrets are redacted.
+// The user's authorization to launch the container is not checked
+func RenderWorkspace(containerName string, userName string, workspaceId string, payModel *PayModel) ([]byte, error) {
+	hatchConfig := Config()
+	for _, container := range hatchConfig.ContainersMap {
+		if container.Name == containerName {
+			return renderWorkspace(hatchConfig, container, userName, workspaceId, payModel, defaultWorkspaceSize(hatchConfig, container, payModel))
+		}
+	}
+	return nil, fmt.Errorf("no container named '%s' in the config", containerName)
+}
+
+// renderWorkspace goes through the same steps as a launch on the pay model's backend:
+// the Pod, Service and PVC for kubernetes, or the task definition for ECS
+func renderWorkspace(hatchConfig *FullHatcheryConfig, hatchApp Container, userName string, workspaceId string, payModel *PayModel, size WorkspaceSize) ([]byte, error) {
+	applyWorkspaceSize(&hatchApp, size)
+	applyPayModelScheduling(hatchConfig, &hatchApp, payModel)
+	if err := validateWorkspaceId(workspaceId); err != nil {
+		return nil, err
+	}
+	nextflowKey := ""
+	if hatchApp.NextflowConfig.Enabled {
+		nextflowKey = redactedValue
+	}
+	licenseString := ""
+	if hatchApp.License.Enabled {
+		licenseString = redactedValue
+	}
+	envVars, envVarsEcs := workspaceEnvVars(hatchApp, nextflowKey, nextflowKey, licenseString)
+
+	if payModel != nil && payModel.Ecs {
+		if workspaceId != "" {
+			return nil, fmt.Errorf("named workspaces are not supported for ECS pay models")
+		}
+		return renderEcsWorkspace(hatchConfig, &hatchApp, userName, *payModel, envVarsEcs)
+	}
+
+	external := payModel != nil && !payModel.Local
+	payModelId := ""
+	if payModel != nil {
+		payModelId = payModel.Id
+	}
+	if external {
+		envVars = append(envVars, externalWorkspaceEnvVars(redactedAPIKey, redactedValue)...)
+	} else {
+		envVars = append(envVars, localWorkspaceEnvVars(redactedAPIKey)...)
+	}
+	pod, err := buildPod(hatchConfig, &hatchApp, userName, workspaceId, envVars, payModelId)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := buildWorkspaceSecret(&hatchApp, userName, workspaceId, pod, redactedAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	pod.TypeMeta.APIVersion = "v1"
+	pod.TypeMeta.Kind = "Pod"
+	secret.TypeMeta.APIVersion = "v1"
+	secret.TypeMeta.Kind = "Secret"
+	// the redacted values are more readable than their base64 encoding
+	secret.StringData = map[string]string{}
+	for key, value := range secret.Data {
+		secret.StringData[key] = string(value)
+	}
+	secret.Data = nil
+	objects := []interface{}{pod, secret}
+
+	service := buildWorkspaceService(&hatchApp, userName, workspaceId, external)
+	service.TypeMeta.APIVersion = "v1"
+	service.TypeMeta.Kind = "Service"
+	objects = append(objects, service)
+
+	// the routes of external workspaces depend on the node port, only known once the service is created
+	if !external {
+		routeObjects, err := currentWorkspaceRouter().routeObjects(localWorkspaceRoute(&hatchApp, userName, workspaceId))
+		if err != nil {
+			return nil, err
+		}
+		for _, routeObject := range routeObjects {
+			objects = append(objects, routeObject.object)
+		}
+	}
+
+	// a null image indicates a dockstore app - always mount user volume
+	if hatchApp.UserVolumeLocation != "" {
+		volume := userVolumeConfig(hatchConfig, &hatchApp, payModel)
+		volume.Size = size.VolumeSize
+		pvc := buildPVC(userName, workspaceId, pod, volume)
+		pvc.Namespace = hatchConfig.Config.UserNamespace
+		pvc.TypeMeta.APIVersion = "v1"
+		pvc.TypeMeta.Kind = "PersistentVolumeClaim"
+		objects = append(objects, pvc)
+	}
+
+	if hatchConfig.Config.NetworkPolicy.Enabled {
+		networkPolicy := buildNetworkPolicy(hatchConfig, &hatchApp, userName, workspaceId, pod, external)
+		networkPolicy.TypeMeta.APIVersion = "networking.k8s.io/v1"
+		networkPolicy.TypeMeta.Kind = "NetworkPolicy"
+		objects = append(objects, networkPolicy)
+	}
+
+	return marshalManifests(objects)
+}
+
+// renderEcsWorkspace returns the task definition that CreateTaskDefinition would register
+func renderEcsWorkspace(hatchConfig *FullHatcheryConfig, hatchApp *Container, userName string, payModel PayModel, envVars []EnvVar) ([]byte, error) {
+	mem, err := mem(hatchApp.MemoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	cpu, err := cpu(hatchApp.CPULimit)
+	if err != nil {
+		return nil, err
+	}
+	envVars = ecsWorkspaceEnvVars(hatchApp, envVars, redactedAPIKey, redactedValue)
+	volumes := &EFS{FileSystemId: createdAtLaunchValue, AccessPointId: createdAtLaunchValue}
+	taskRole := fmt.Sprintf("arn:aws:iam::%s:role/%s", payModel.AWSAccountId, userToResourceName(userName, "pod"))
+	taskDef, err := buildEcsTaskDefinition(hatchConfig, hatchApp, userName, payModel, cpu, mem, envVars, taskRole, volumes)
+	if err != nil {
+		return nil, err
+	}
+
+	var prismaDefender *ecs.ContainerDefinition
+	if hatchConfig.Config.PrismaConfig.Enable {
+		defender := prismaDefenderContainerDefinition(redactedValue, createdAtLaunchValue, userName)
+		prismaDefender = &defender
+	}
+	input := taskDef.registerTaskDefinitionInput(userName, fmt.Sprintf("/hatchery/%s/", payModel.AWSAccountId), prismaDefender)
+
+	// serialized the same way as the ECS API request
+	out, err := jsonutil.BuildJSON(input)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.JSONToYAML(out)
+}
+
+func marshalManifests(objects []interface{}) ([]byte, error) {
+	var out bytes.Buffer
+	for i, object := range objects {
+		manifest, err := yaml.Marshal(object)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		out.Write(manifest)
+	}
+	return out.Bytes(), nil
+}
+
+// dryRunLaunch writes the resources a launch would create for the user, without creating anything
+func dryRunLaunch(w http.ResponseWriter, r *http.Request, hatchConfig *FullHatcheryConfig, hatchApp Container, userName string, workspaceId string) {
+	allpaymodels, err := getPayModelsForUser(userName)
+	if err != nil {
+		hatchConfig.Logger.Printf("error when getting paymodels for user: %s", err.Error())
+	}
+	var payModel *PayModel
+	if allpaymodels != nil { // Commons with no concept of paymodels launch locally
+		payModel = allpaymodels.CurrentPayModel
+		if payModel == nil {
+			http.Error(w, "Current Paymodel is not set. Launch forbidden", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	size, err := resolveWorkspaceSize(hatchConfig, hatchApp, r.URL.Query(), payModel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out, err := renderWorkspace(hatchConfig, hatchApp, userName, workspaceId, payModel, size)
+	if err != nil {
+		hatchConfig.Logger.Printf("error during dry-run launch: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(out)
+}
@@ -0,0 +1,146 @@
+package hatchery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	k8sv1 "k8s.io/api/core/v1"
+)
+
+// loadRenderTestConfig replaces the config with the test config, until the end of the test
+func loadRenderTestConfig(t *testing.T) {
+	t.Helper()
+	config, err := LoadConfig("../testData/testConfig.json", Config().Logger)
+	if err != nil {
+		t.Fatalf("failed to load config, got: %v", err)
+	}
+	withTestConfig(t, func(testConfig *FullHatcheryConfig) {
+		*testConfig = *config
+	})
+}
+
+func Test_RenderWorkspace(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	loadRenderTestConfig(t)
+
+	testCases := []struct {
+		name          string
+		containerName string
+		payModel      *PayModel
+		want          []string
+		notWant       []string
+	}{
+		{
+			name:          "LocalWithUserVolume",
+			containerName: "R Studio",
+			payModel:      nil,
+			want:          []string{"kind: Pod", "kind: Service", "kind: PersistentVolumeClaim", "claimName: claim-frickjack", "type: ClusterIP", "kind: Secret", "name: API_KEY\n      valueFrom:\n        secretKeyRef:\n          key: API_KEY\n          name: credentials-frickjack", "API_KEY: <redacted>"},
+			notWant:       []string{"ACCESS_TOKEN"},
+		},
+		{
+			name:          "LocalLicensed",
+			containerName: "(Generic, Limited Gen3-licensed) Stata Notebook",
+			payModel:      &PayModel{Id: "pm-1", Local: true},
+			want:          []string{"kind: Pod", "TEST_LICENSE_G3AUTO: <redacted>", "bmh_workspace_id: pm-1"},
+			notWant:       []string{"kind: PersistentVolumeClaim"},
+		},
+		{
+			name:          "ExternalEKS",
+			containerName: "R Studio",
+			payModel:      &PayModel{Id: "pm-2"},
+			want:          []string{"type: NodePort", "ACCESS_TOKEN: <redacted>", "aws-load-balancer-internal"},
+		},
+		{
+			name:          "ECS",
+			containerName: "R Studio",
+			payModel:      &PayModel{Id: "pm-3", Ecs: true, AWSAccountId: "123456789012"},
+			want:          []string{"containerDefinitions:", "taskRoleArn: arn:aws:iam::123456789012:role/hatchery-frickjack", "fileSystemId: <created at launch>", "name: ACCESS_TOKEN\n    value: <redacted>"},
+			notWant:       []string{"kind: Pod"},
+		},
+	}
+	for _, testcase := range testCases {
+		out, err := RenderWorkspace(testcase.containerName, "frickjack", "", testcase.payModel)
+		if err != nil {
+			t.Errorf("\nassertion error while testing `%s`: unexpected error: %v", testcase.name, err)
+			continue
+		}
+		for _, want := range testcase.want {
+			if !strings.Contains(string(out), want) {
+				t.Errorf("\nassertion error while testing `%s`: \nWant:%q\nGot:%s", testcase.name, want, out)
+			}
+		}
+		for _, notWant := range testcase.notWant {
+			if strings.Contains(string(out), notWant) {
+				t.Errorf("\nassertion error while testing `%s`: \nDid not want:%q\nGot:%s", testcase.name, notWant, out)
+			}
+		}
+	}
+
+	if _, err := RenderWorkspace("R Studio", "frickjack", "rstudio", &PayModel{Ecs: true}); err == nil {
+		t.Errorf("\nassertion error while testing `RenderWorkspace`: named ECS workspaces should not be rendered")
+	}
+	if _, err := RenderWorkspace("Unknown", "frickjack", "", nil); err == nil {
+		t.Errorf("\nassertion error while testing `RenderWorkspace`: unknown containers should not be rendered")
+	}
+}
+
+func Test_LaunchDryRun(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	loadRenderTestConfig(t)
+
+	originalIsUserAuthorizedForContainer := isUserAuthorizedForContainer
+	originalGetPayModelsForUser := getPayModelsForUser
+	originalCreateLocalK8sPod := createLocalK8sPod
+	defer func() {
+		isUserAuthorizedForContainer = originalIsUserAuthorizedForContainer
+		getPayModelsForUser = originalGetPayModelsForUser
+		createLocalK8sPod = originalCreateLocalK8sPod
+	}()
+	isUserAuthorizedForContainer = func(userName string, accessToken string, container Container) (bool, error) {
+		return container.Name != "R Studio", nil
+	}
+	getPayModelsForUser = func(userName string) (result *AllPayModels, err error) {
+		return nil, nil
+	}
+	createLocalK8sPod = func(ctx context.Context, hatchConfig *FullHatcheryConfig, hatchApp Container, size WorkspaceSize, userName, workspaceId, accessToken string, payModel *PayModel, envVars []k8sv1.EnvVar, payModelId ...string) error {
+		t.Errorf("a dry-run launch should not create the pod")
+		return nil
+	}
+
+	hashes := map[string]string{}
+	for hash, container := range Config().ContainersMap {
+		hashes[container.Name] = hash
+	}
+
+	req := httptest.NewRequest("POST", "/launch?dryRun=true&id="+hashes["Jupyter - Python/R"], nil)
+	req.Header.Set("REMOTE_USER", "frickjack")
+	w := httptest.NewRecorder()
+	launch(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "kind: Pod") {
+		t.Errorf("\nassertion error while testing `LaunchDryRun`: \nWant:%d and a pod manifest\nGot:%d %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/yaml" {
+		t.Errorf("\nassertion error while testing `LaunchDryRun` content type: \nWant:application/yaml\nGot:%s", contentType)
+	}
+
+	// the size must be one the container allows
+	req = httptest.NewRequest("POST", "/launch?dryRun=true&cpuLimit=64&id="+hashes["Jupyter - Python/R"], nil)
+	req.Header.Set("REMOTE_USER", "frickjack")
+	w = httptest.NewRecorder()
+	launch(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("\nassertion error while testing `LaunchDryRun` with an invalid size: \nWant:%d\nGot:%d", http.StatusBadRequest, w.Code)
+	}
+
+	// the user must be authorized to launch the container
+	req = httptest.NewRequest("POST", "/launch?dryRun=true&id="+hashes["R Studio"], nil)
+	req.Header.Set("REMOTE_USER", "frickjack")
+	w = httptest.NewRecorder()
+	launch(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("\nassertion error while testing `LaunchDryRun` for an unauthorized container: \nWant:%d\nGot:%d", http.StatusBadRequest, w.Code)
+	}
+}
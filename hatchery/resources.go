@@ -0,0 +1,99 @@
+package hatchery
+
+import (
+	"fmt"
+
+	k8sv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Bases of the cost of workspaces, in `pricing.basis`
+const (
+	pricingBasisRequest = "request"
+	pricingBasisLimit   = "limit"
+)
+
+// resourceRequest returns the request of a resource: the configured request, else the limit
+// scaled by the ratio, else the limit. Requests are never greater than the limit, e.g. when
+// a larger size was chosen
+func resourceRequest(limit string, request string, ratio float64) (resource.Quantity, error) {
+	limitQuantity, err := resource.ParseQuantity(limit)
+	if err != nil {
+		return limitQuantity, fmt.Errorf("invalid limit '%s': %v", limit, err)
+	}
+	if request != "" {
+		requestQuantity, err := resource.ParseQuantity(request)
+		if err != nil {
+			return requestQuantity, fmt.Errorf("invalid request '%s': %v", request, err)
+		}
+		if requestQuantity.Cmp(limitQuantity) > 0 {
+			return limitQuantity, nil
+		}
+		return requestQuantity, nil
+	}
+	if ratio > 0 && ratio < 1 {
+		return *resource.NewMilliQuantity(int64(float64(limitQuantity.MilliValue())*ratio), limitQuantity.Format), nil
+	}
+	return limitQuantity, nil
+}
+
+// containerResources returns the limits and requests of the container's main container
+func containerResources(hatchApp *Container) (k8sv1.ResourceRequirements, error) {
+	return buildResourceRequirements(hatchApp.CPULimit, hatchApp.CPURequest, hatchApp.MemoryLimit, hatchApp.MemoryRequest, hatchApp.RequestRatio)
+}
+
+// sidecarResources returns the limits and requests of the fuse sidecar
+func sidecarResources(sidecar SidecarContainer) (k8sv1.ResourceRequirements, error) {
+	return buildResourceRequirements(sidecar.CPULimit, sidecar.CPURequest, sidecar.MemoryLimit, sidecar.MemoryRequest, sidecar.RequestRatio)
+}
+
+func buildResourceRequirements(cpuLimit string, cpuRequest string, memoryLimit string, memoryRequest string, ratio float64) (k8sv1.ResourceRequirements, error) {
+	cpu, err := resourceRequest(cpuLimit, cpuRequest, ratio)
+	if err != nil {
+		return k8sv1.ResourceRequirements{}, fmt.Errorf("cpu: %v", err)
+	}
+	memory, err := resourceRequest(memoryLimit, memoryRequest, ratio)
+	if err != nil {
+		return k8sv1.ResourceRequirements{}, fmt.Errorf("memory: %v", err)
+	}
+	// scaled memory is rounded to whole bytes
+	memory.RoundUp(0)
+	return k8sv1.ResourceRequirements{
+		Limits: k8sv1.ResourceList{
+			k8sv1.ResourceCPU:    resource.MustParse(cpuLimit),
+			k8sv1.ResourceMemory: resource.MustParse(memoryLimit),
+		},
+		Requests: k8sv1.ResourceList{
+			k8sv1.ResourceCPU:    cpu,
+			k8sv1.ResourceMemory: memory,
+		},
+	}, nil
+}
+
+// hasResourceRequests returns true if requests are configured separately from the limits
+func hasResourceRequests(cpuRequest string, memoryRequest string, ratio float64) bool {
+	return cpuRequest != "" || memoryRequest != "" || (ratio > 0 && ratio < 1)
+}
+
+// ecsReservations returns the requests as the CPU units (1024 per core) and the
+// soft memory limit (MiB) that ECS containers reserve
+func ecsReservations(resources k8sv1.ResourceRequirements) (int64, int64) {
+	cpu := resources.Requests[k8sv1.ResourceCPU]
+	memory := resources.Requests[k8sv1.ResourceMemory]
+	return cpu.MilliValue() * 1024 / 1000, memory.Value() / (1024 * 1024)
+}
+
+// billedResources returns the resources workspaces are charged for: their requests, or
+// their limits if `pricing.basis` is "limit"
+func billedResources(resources k8sv1.ResourceRequirements) k8sv1.ResourceList {
+	billed := k8sv1.ResourceList{}
+	for name, quantity := range resources.Requests {
+		billed[name] = quantity
+	}
+	if Config().Config.Pricing.Basis == pricingBasisLimit {
+		for name, quantity := range resources.Limits {
+			billed[name] = quantity
+		}
+	}
+	return billed
+}
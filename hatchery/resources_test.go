@@ -0,0 +1,129 @@
+package hatchery
+
+import (
+	"testing"
+
+	k8sv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func Test_ResourceRequest(t *testing.T) {
+	testCases := []struct {
+		name    string
+		limit   string
+		request string
+		ratio   float64
+		want    string
+	}{
+		{name: "Default", limit: "2", want: "2"},
+		{name: "Request", limit: "2", request: "500m", want: "500m"},
+		{name: "RequestGreaterThanLimit", limit: "1Gi", request: "2Gi", want: "1Gi"},
+		{name: "Ratio", limit: "2", ratio: 0.25, want: "500m"},
+		{name: "RequestOverRatio", limit: "2", request: "1", ratio: 0.25, want: "1"},
+		{name: "WholeRatio", limit: "2", ratio: 1, want: "2"},
+	}
+	for _, testcase := range testCases {
+		t.Logf("Testing resourceRequest when %s", testcase.name)
+		got, err := resourceRequest(testcase.limit, testcase.request, testcase.ratio)
+		if err != nil {
+			t.Errorf("\nassertion error while testing `%s`: unexpected error: %v", testcase.name, err)
+			continue
+		}
+		if got.Cmp(resource.MustParse(testcase.want)) != 0 {
+			t.Errorf("\nassertion error while testing `%s`: \nWant:%s\nGot:%s", testcase.name, testcase.want, got.String())
+		}
+	}
+
+	if _, err := resourceRequest("1", "one", 0); err == nil {
+		t.Errorf("\nassertion error while testing `resourceRequest` with an invalid request: \nWant:an error\nGot:nil")
+	}
+}
+
+func Test_BuildPodResourceRequests(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	loadRenderTestConfig(t)
+	withTestConfig(t, func(config *FullHatcheryConfig) {
+		config.Config.Sidecar.CPURequest = "50m"
+		config.Config.Sidecar.RequestRatio = 0.5
+	})
+
+	hatchApp := &Container{Name: "Jupyter", Image: "jupyter", CPULimit: "2", MemoryLimit: "4Gi", CPURequest: "500m", RequestRatio: 0.5}
+	pod, err := buildPod(Config(), hatchApp, "frickjack", "", nil)
+	if err != nil {
+		t.Fatalf("failed to build a pod - %v", err)
+	}
+
+	resources := pod.Spec.Containers[1].Resources
+	if got := resources.Requests[k8sv1.ResourceCPU]; got.String() != "500m" {
+		t.Errorf("\nassertion error while testing `buildPod` CPU request: \nWant:%s\nGot:%s", "500m", got.String())
+	}
+	if got := resources.Requests[k8sv1.ResourceMemory]; got.String() != "2Gi" {
+		t.Errorf("\nassertion error while testing `buildPod` memory request: \nWant:%s\nGot:%s", "2Gi", got.String())
+	}
+	if got := resources.Limits[k8sv1.ResourceCPU]; got.String() != "2" {
+		t.Errorf("\nassertion error while testing `buildPod` CPU limit: \nWant:%s\nGot:%s", "2", got.String())
+	}
+
+	sidecarRequests := pod.Spec.Containers[0].Resources.Requests
+	sidecarMemoryLimit := resource.MustParse(Config().Config.Sidecar.MemoryLimit)
+	if got := sidecarRequests[k8sv1.ResourceCPU]; got.String() != "50m" {
+		t.Errorf("\nassertion error while testing `buildPod` sidecar CPU request: \nWant:%s\nGot:%s", "50m", got.String())
+	}
+	if got := sidecarRequests[k8sv1.ResourceMemory]; got.Value() != sidecarMemoryLimit.Value()/2 {
+		t.Errorf("\nassertion error while testing `buildPod` sidecar memory request: \nWant:%d\nGot:%d", sidecarMemoryLimit.Value()/2, got.Value())
+	}
+}
+
+func Test_BilledResources(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	resources, err := buildResourceRequirements("2", "500m", "4Gi", "1Gi", 0)
+	if err != nil {
+		t.Fatalf("failed to build the resources - %v", err)
+	}
+
+	billed := billedResources(resources)
+	if got := billed[k8sv1.ResourceCPU]; got.String() != "500m" {
+		t.Errorf("\nassertion error while testing `billedResources` on requests: \nWant:%s\nGot:%s", "500m", got.String())
+	}
+
+	withTestConfig(t, func(config *FullHatcheryConfig) {
+		config.Config.Pricing.Basis = pricingBasisLimit
+	})
+	billed = billedResources(resources)
+	if got := billed[k8sv1.ResourceMemory]; got.String() != "4Gi" {
+		t.Errorf("\nassertion error while testing `billedResources` on limits: \nWant:%s\nGot:%s", "4Gi", got.String())
+	}
+}
+
+func Test_BuildEcsTaskDefinitionReservations(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	loadRenderTestConfig(t)
+
+	payModel := PayModel{AWSAccountId: "123456789012"}
+	hatchApp := &Container{Name: "Jupyter", Image: "jupyter", CPULimit: "1", MemoryLimit: "2Gi"}
+	taskDef, err := buildEcsTaskDefinition(Config(), hatchApp, "frickjack", payModel, "1024", "2048", nil, "", &EFS{})
+	if err != nil {
+		t.Fatalf("failed to build a task definition - %v", err)
+	}
+	if taskDef.CpuReservation != 0 || taskDef.MemoryReservation != 0 || taskDef.SidecarContainer.MemoryReservation != nil {
+		t.Errorf("\nassertion error while testing `buildEcsTaskDefinition` without requests: \nWant:no reservations\nGot:%d %d", taskDef.CpuReservation, taskDef.MemoryReservation)
+	}
+
+	hatchApp.RequestRatio = 0.5
+	taskDef, err = buildEcsTaskDefinition(Config(), hatchApp, "frickjack", payModel, "1024", "2048", nil, "", &EFS{})
+	if err != nil {
+		t.Fatalf("failed to build a task definition - %v", err)
+	}
+	if taskDef.CpuReservation != 512 || taskDef.MemoryReservation != 1024 {
+		t.Errorf("\nassertion error while testing `buildEcsTaskDefinition` reservations: \nWant:512 1024\nGot:%d %d", taskDef.CpuReservation, taskDef.MemoryReservation)
+	}
+	// the task is sized on the limits
+	if taskDef.Cpu != "1024" || taskDef.Memory != "2048" {
+		t.Errorf("\nassertion error while testing `buildEcsTaskDefinition` task size: \nWant:1024 2048\nGot:%s %s", taskDef.Cpu, taskDef.Memory)
+	}
+
+	hatchApp.CPURequest = "lots"
+	if _, err := buildEcsTaskDefinition(Config(), hatchApp, "frickjack", payModel, "1024", "2048", nil, "", &EFS{}); err == nil {
+		t.Errorf("\nassertion error while testing `buildEcsTaskDefinition` invalid requests: \nWant:%s\nGot:%v", "an error", err)
+	}
+}
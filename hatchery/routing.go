@@ -0,0 +1,410 @@
+package hatchery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Routing types, selected with `routing.type`
+const (
+	routingAmbassadorAnnotation = "ambassador-annotation"
+	routingEmissaryMapping      = "emissary-mapping"
+	routingIngress              = "ingress"
+	routingGatewayHTTPRoute     = "gateway-httproute"
+)
+
+// Requests are forwarded to workspaces for up to 5 minutes, and websockets are upgraded
+const routeTimeoutMs = 300000
+
+const ambassadorYaml = `---
+apiVersion: ambassador/v1
+kind:  Mapping
+name:  %s
+prefix: %s
+headers:
+  remote_user: %s
+service: %s:%d
+bypass_auth: true
+timeout_ms: 300000
+use_websocket: true
+rewrite: %s
+tls: %s
+`
+
+var (
+	emissaryMappingResource = schema.GroupVersionResource{Group: "getambassador.io", Version: "v3alpha1", Resource: "mappings"}
+	ingressResource         = schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}
+	httpRouteResource       = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+)
+
+// workspaceRoute routes the requests of the workspace's user to the workspace.
+// The backend is the workspace service for workspaces in the local cluster, and the
+// node port or load balancer of workspaces running in an external cluster or in ECS
+type workspaceRoute struct {
+	Name          string
+	Namespace     string
+	UserName      string
+	WorkspaceId   string
+	ContainerName string
+	Prefix        string
+	ServiceName   string
+	BackendHost   string
+	BackendPort   int32
+	External      bool
+	PathRewrite   string
+	UseTLS        string
+}
+
+// localWorkspaceRoute returns the route to the workspace service in the local cluster
+func localWorkspaceRoute(hatchApp *Container, userName string, workspaceId string) workspaceRoute {
+	serviceName := workspaceToResourceName(userName, workspaceId, "service")
+	return workspaceRoute{
+		Name:          workspaceToResourceName(userName, workspaceId, "mapping"),
+		Namespace:     Config().Config.UserNamespace,
+		UserName:      userName,
+		WorkspaceId:   workspaceId,
+		ContainerName: hatchApp.Name,
+		Prefix:        workspaceRoutingPrefix(workspaceId),
+		ServiceName:   serviceName,
+		BackendHost:   fmt.Sprintf("%s.%s.svc.cluster.local", serviceName, Config().Config.UserNamespace),
+		BackendPort:   80,
+		PathRewrite:   hatchApp.PathRewrite,
+		UseTLS:        hatchApp.UseTLS,
+	}
+}
+
+// externalWorkspaceRoute returns the route to a workspace running outside of the local cluster
+func externalWorkspaceRoute(hatchApp *Container, userName string, workspaceId string, host string, port int32) workspaceRoute {
+	route := localWorkspaceRoute(hatchApp, userName, workspaceId)
+	route.BackendHost = host
+	route.BackendPort = port
+	route.External = true
+	return route
+}
+
+// rewrite returns the prefix the route's prefix is replaced with, "/" by default like in ambassador
+func (route workspaceRoute) rewrite() string {
+	if route.PathRewrite == "" {
+		return "/"
+	}
+	return route.PathRewrite
+}
+
+// servicePort returns the port of the service the route objects send the requests to
+func (route workspaceRoute) servicePort() int32 {
+	if route.External {
+		// the ExternalName service forwards to the node port or load balancer as is
+		return route.BackendPort
+	}
+	return 80
+}
+
+func (route workspaceRoute) annotations() map[string]string {
+	annotations := map[string]string{
+		"gen3username":          route.UserName,
+		containerNameAnnotation: route.ContainerName,
+	}
+	if route.WorkspaceId != "" {
+		annotations[workspaceIdAnnotation] = route.WorkspaceId
+	}
+	return annotations
+}
+
+// routeObject is an object created in the local cluster to route requests to a workspace
+type routeObject struct {
+	resource schema.GroupVersionResource
+	object   *unstructured.Unstructured
+}
+
+// workspaceRouter configures the ingress controller to route the requests of users to
+// their workspaces. The route always matches the `remote_user` header set by revproxy,
+// since every workspace of the same name is served under the same prefix
+type workspaceRouter interface {
+	// serviceAnnotations are added to the workspace service, for routers configured by annotations
+	serviceAnnotations(route workspaceRoute) map[string]string
+	// routeObjects are created alongside the workspace service
+	routeObjects(route workspaceRoute) ([]routeObject, error)
+	// resources are the kinds of objects created by the router, to delete them
+	resources() []schema.GroupVersionResource
+	// backendIsService is true if the route objects can only send requests to a service: the
+	// service of a workspace running outside of the cluster is then an ExternalName service
+	backendIsService() bool
+}
+
+// currentWorkspaceRouter returns the router selected in the config
+func currentWorkspaceRouter() workspaceRouter {
+	return newWorkspaceRouter(Config().Config.Routing)
+}
+
+func newWorkspaceRouter(config RoutingConfig) workspaceRouter {
+	switch config.Type {
+	case routingEmissaryMapping:
+		return emissaryMappingRouter{config: config}
+	case routingIngress:
+		return ingressRouter{config: config}
+	case routingGatewayHTTPRoute:
+		return httpRouteRouter{config: config}
+	default:
+		return ambassadorAnnotationRouter{}
+	}
+}
+
+// ambassadorAnnotationRouter adds an `ambassador/v1` Mapping to the `getambassador.io/config`
+// annotation of the workspace service. It is only read by ambassador and Emissary 1.x
+type ambassadorAnnotationRouter struct{}
+
+func (ambassadorAnnotationRouter) serviceAnnotations(route workspaceRoute) map[string]string {
+	return map[string]string{
+		"getambassador.io/config": fmt.Sprintf(ambassadorYaml, route.Name, route.Prefix, route.UserName, route.BackendHost, route.BackendPort, route.PathRewrite, route.UseTLS),
+	}
+}
+
+func (ambassadorAnnotationRouter) routeObjects(route workspaceRoute) ([]routeObject, error) {
+	return nil, nil
+}
+
+func (ambassadorAnnotationRouter) resources() []schema.GroupVersionResource {
+	return nil
+}
+
+func (ambassadorAnnotationRouter) backendIsService() bool {
+	return false
+}
+
+// emissaryMappingRouter creates a `getambassador.io/v3alpha1` Mapping for Emissary 2.x and 3.x
+type emissaryMappingRouter struct {
+	config RoutingConfig
+}
+
+func (emissaryMappingRouter) serviceAnnotations(route workspaceRoute) map[string]string {
+	return nil
+}
+
+func (r emissaryMappingRouter) routeObjects(route workspaceRoute) ([]routeObject, error) {
+	hostname := r.config.Hostname
+	if hostname == "" {
+		hostname = "*"
+	}
+	service := fmt.Sprintf("%s:%d", route.BackendHost, route.BackendPort)
+	// `tls: true` of ambassador/v1 mappings is replaced by the service scheme
+	if route.UseTLS == "true" {
+		service = "https://" + service
+	}
+	mapping := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "getambassador.io/v3alpha1",
+		"kind":       "Mapping",
+		"metadata":   routeObjectMeta(route),
+		"spec": map[string]interface{}{
+			"hostname":      hostname,
+			"prefix":        route.Prefix,
+			"headers":       map[string]interface{}{"remote_user": route.UserName},
+			"service":       service,
+			"bypass_auth":   true,
+			"timeout_ms":    int64(routeTimeoutMs),
+			"allow_upgrade": []interface{}{"websocket"},
+			"rewrite":       route.rewrite(),
+		},
+	}}
+	return []routeObject{{resource: emissaryMappingResource, object: mapping}}, nil
+}
+
+func (emissaryMappingRouter) resources() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{emissaryMappingResource}
+}
+
+func (emissaryMappingRouter) backendIsService() bool {
+	return false
+}
+
+// ingressRouter creates a networking/v1 Ingress. Ingresses can't match headers, rewrite
+// paths or set timeouts: this is done with the controller-specific `ingress-annotations`
+type ingressRouter struct {
+	config RoutingConfig
+}
+
+func (ingressRouter) serviceAnnotations(route workspaceRoute) map[string]string {
+	return nil
+}
+
+func (r ingressRouter) routeObjects(route workspaceRoute) ([]routeObject, error) {
+	pathType := networkingv1.PathTypePrefix
+	annotations := route.annotations()
+	replacer := strings.NewReplacer("{{username}}", route.UserName, "{{prefix}}", route.Prefix, "{{rewrite}}", route.rewrite())
+	for key, value := range r.config.IngressAnnotations {
+		annotations[key] = replacer.Replace(value)
+	}
+	ingress := &networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        route.Name,
+			Namespace:   route.Namespace,
+			Annotations: annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{
+				Host: r.config.Hostname,
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     route.Prefix,
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+							Name: route.ServiceName,
+							Port: networkingv1.ServiceBackendPort{Number: route.servicePort()},
+						}},
+					}},
+				}},
+			}},
+		},
+	}
+	if r.config.IngressClassName != "" {
+		ingress.Spec.IngressClassName = &r.config.IngressClassName
+	}
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(ingress)
+	if err != nil {
+		return nil, err
+	}
+	return []routeObject{{resource: ingressResource, object: &unstructured.Unstructured{Object: object}}}, nil
+}
+
+func (ingressRouter) resources() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{ingressResource}
+}
+
+func (ingressRouter) backendIsService() bool {
+	return true
+}
+
+// httpRouteRouter creates a Gateway API `gateway.networking.k8s.io/v1` HTTPRoute attached to
+// the configured gateway. Websockets are upgraded by default by Gateway API implementations
+type httpRouteRouter struct {
+	config RoutingConfig
+}
+
+func (httpRouteRouter) serviceAnnotations(route workspaceRoute) map[string]string {
+	return nil
+}
+
+func (r httpRouteRouter) routeObjects(route workspaceRoute) ([]routeObject, error) {
+	parentRef := map[string]interface{}{"name": r.config.GatewayName}
+	if r.config.GatewayNamespace != "" {
+		parentRef["namespace"] = r.config.GatewayNamespace
+	}
+	rule := map[string]interface{}{
+		"matches": []interface{}{map[string]interface{}{
+			"path":    map[string]interface{}{"type": "PathPrefix", "value": route.Prefix},
+			"headers": []interface{}{map[string]interface{}{"type": "Exact", "name": "remote_user", "value": route.UserName}},
+		}},
+		"backendRefs": []interface{}{map[string]interface{}{"name": route.ServiceName, "port": int64(route.servicePort())}},
+		"timeouts":    map[string]interface{}{"request": fmt.Sprintf("%ds", routeTimeoutMs/1000)},
+	}
+	if route.rewrite() != route.Prefix {
+		rule["filters"] = []interface{}{map[string]interface{}{
+			"type": "URLRewrite",
+			"urlRewrite": map[string]interface{}{
+				"path": map[string]interface{}{"type": "ReplacePrefixMatch", "replacePrefixMatch": route.rewrite()},
+			},
+		}}
+	}
+	spec := map[string]interface{}{
+		"parentRefs": []interface{}{parentRef},
+		"rules":      []interface{}{rule},
+	}
+	if r.config.Hostname != "" {
+		spec["hostnames"] = []interface{}{r.config.Hostname}
+	}
+	httpRoute := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "HTTPRoute",
+		"metadata":   routeObjectMeta(route),
+		"spec":       spec,
+	}}
+	return []routeObject{{resource: httpRouteResource, object: httpRoute}}, nil
+}
+
+func (httpRouteRouter) resources() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{httpRouteResource}
+}
+
+func (httpRouteRouter) backendIsService() bool {
+	return true
+}
+
+func routeObjectMeta(route workspaceRoute) map[string]interface{} {
+	annotations := map[string]interface{}{}
+	for key, value := range route.annotations() {
+		annotations[key] = value
+	}
+	return map[string]interface{}{
+		"name":        route.Name,
+		"namespace":   route.Namespace,
+		"annotations": annotations,
+	}
+}
+
+var getLocalDynamicClient = func() (dynamic.Interface, error) {
+	config, err := GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(config)
+}
+
+// createWorkspaceRoute creates or replaces the route objects of the workspace in the local cluster
+var createWorkspaceRoute = func(ctx context.Context, route workspaceRoute) error {
+	objects, err := currentWorkspaceRouter().routeObjects(route)
+	if err != nil || len(objects) == 0 {
+		return err
+	}
+	client, err := getLocalDynamicClient()
+	if err != nil {
+		return err
+	}
+	for _, routeObject := range objects {
+		resourceClient := client.Resource(routeObject.resource).Namespace(route.Namespace)
+		_, err := resourceClient.Create(ctx, routeObject.object, metav1.CreateOptions{})
+		if k8sErrors.IsAlreadyExists(err) {
+			// left over by a workspace that was not cleaned up
+			var existing *unstructured.Unstructured
+			existing, err = resourceClient.Get(ctx, routeObject.object.GetName(), metav1.GetOptions{})
+			if err == nil {
+				routeObject.object.SetResourceVersion(existing.GetResourceVersion())
+				_, err = resourceClient.Update(ctx, routeObject.object, metav1.UpdateOptions{})
+			}
+		}
+		if err != nil {
+			Config().Logger.Printf("Failed to create %s %s for user %s. Error: %v", routeObject.object.GetKind(), route.Name, route.UserName, err)
+			return err
+		}
+		Config().Logger.Printf("Created %s %s for user %s", routeObject.object.GetKind(), route.Name, route.UserName)
+	}
+	return nil
+}
+
+// deleteWorkspaceRoute deletes the route objects of the workspace from the local cluster
+var deleteWorkspaceRoute = func(ctx context.Context, userName string, workspaceId string) error {
+	resources := currentWorkspaceRouter().resources()
+	if len(resources) == 0 {
+		return nil
+	}
+	client, err := getLocalDynamicClient()
+	if err != nil {
+		return err
+	}
+	name := workspaceToResourceName(userName, workspaceId, "mapping")
+	for _, resource := range resources {
+		err := client.Resource(resource).Namespace(Config().Config.UserNamespace).Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package hatchery
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/yaml"
+)
+
+func routingTestConfig(routing RoutingConfig) func(config *FullHatcheryConfig) {
+	return func(config *FullHatcheryConfig) {
+		config.Config.UserNamespace = "jupyter-pods"
+		config.Config.Routing = routing
+	}
+}
+
+func Test_AmbassadorAnnotationRouter(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	withTestConfig(t, routingTestConfig(RoutingConfig{}))
+
+	hatchApp := &Container{Name: "Jupyter", PathRewrite: "/lw-workspace/proxy/", UseTLS: "false"}
+	// the same mappings as before routers could be configured
+	want := `---
+apiVersion: ambassador/v1
+kind:  Mapping
+name:  frickjack--rstudio-mapping
+prefix: /@rstudio/
+headers:
+  remote_user: frickjack
+service: h-frickjack--rstudio-s.jupyter-pods.svc.cluster.local:80
+bypass_auth: true
+timeout_ms: 300000
+use_websocket: true
+rewrite: /lw-workspace/proxy/
+tls: false
+`
+	got := currentWorkspaceRouter().serviceAnnotations(localWorkspaceRoute(hatchApp, "frickjack", "rstudio"))["getambassador.io/config"]
+	if got != want {
+		t.Errorf("\nassertion error while testing `ambassadorAnnotationRouter`: \nWant:%s\nGot:%s", want, got)
+	}
+
+	got = currentWorkspaceRouter().serviceAnnotations(externalWorkspaceRoute(hatchApp, "frickjack", "", "10.0.0.1", 30080))["getambassador.io/config"]
+	if !strings.Contains(got, "prefix: /\n") || !strings.Contains(got, "service: 10.0.0.1:30080\n") {
+		t.Errorf("\nassertion error while testing `ambassadorAnnotationRouter` for an external workspace: \nGot:%s", got)
+	}
+	if service := buildWorkspaceService(hatchApp, "frickjack", "rstudio", false); service.Annotations["getambassador.io/config"] != want {
+		t.Errorf("\nassertion error while testing `buildWorkspaceService` annotations: \nWant:%s\nGot:%s", want, service.Annotations["getambassador.io/config"])
+	}
+}
+
+func Test_WorkspaceRouters(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	hatchApp := &Container{Name: "Jupyter", PathRewrite: "/lw-workspace/proxy/", UseTLS: "false"}
+	testCases := []struct {
+		name     string
+		routing  RoutingConfig
+		external bool
+		want     []string
+	}{
+		{
+			name:    "EmissaryMapping",
+			routing: RoutingConfig{Type: "emissary-mapping"},
+			want: []string{
+				"apiVersion: getambassador.io/v3alpha1", "kind: Mapping", "name: frickjack--rstudio-mapping", "hostname: '*'", "prefix: /@rstudio/",
+				"remote_user: frickjack", "service: h-frickjack--rstudio-s.jupyter-pods.svc.cluster.local:80", "timeout_ms: 300000",
+				"allow_upgrade:\n  - websocket", "rewrite: /lw-workspace/proxy/", "bypass_auth: true",
+			},
+		},
+		{
+			name:     "EmissaryMappingExternal",
+			routing:  RoutingConfig{Type: "emissary-mapping", Hostname: "example.com"},
+			external: true,
+			want:     []string{"hostname: example.com", "service: 10.0.0.1:30080"},
+		},
+		{
+			name: "Ingress",
+			routing: RoutingConfig{Type: "ingress", IngressClassName: "haproxy", Hostname: "example.com", IngressAnnotations: map[string]string{
+				"haproxy.org/route-acl":    "req.hdr(remote_user) -m str {{username}}",
+				"haproxy.org/path-rewrite": "{{prefix}}(.*) {{rewrite}}\\1",
+			}},
+			want: []string{
+				"kind: Ingress", "ingressClassName: haproxy", "host: example.com", "path: /@rstudio/", "pathType: Prefix",
+				"name: h-frickjack--rstudio-s", "number: 80", "haproxy.org/route-acl: req.hdr(remote_user) -m str frickjack",
+				`haproxy.org/path-rewrite: /@rstudio/(.*) /lw-workspace/proxy/\1`, "gen3username: frickjack",
+			},
+		},
+		{
+			name:    "HTTPRoute",
+			routing: RoutingConfig{Type: "gateway-httproute", GatewayName: "gen3", GatewayNamespace: "gateways"},
+			want: []string{
+				"apiVersion: gateway.networking.k8s.io/v1", "kind: HTTPRoute", "name: gen3", "namespace: gateways", "type: PathPrefix",
+				"value: /@rstudio/", "name: remote_user", "value: frickjack", "type: Exact", "port: 80", "request: 300s",
+				"type: URLRewrite", "replacePrefixMatch: /lw-workspace/proxy/",
+			},
+		},
+		{
+			name:     "HTTPRouteExternal",
+			routing:  RoutingConfig{Type: "gateway-httproute", GatewayName: "gen3"},
+			external: true,
+			want:     []string{"name: h-frickjack--rstudio-s", "port: 30080"},
+		},
+	}
+	for _, testcase := range testCases {
+		t.Run(testcase.name, func(t *testing.T) {
+			withTestConfig(t, routingTestConfig(testcase.routing))
+			router := currentWorkspaceRouter()
+			route := localWorkspaceRoute(hatchApp, "frickjack", "rstudio")
+			if testcase.external {
+				route = externalWorkspaceRoute(hatchApp, "frickjack", "rstudio", "10.0.0.1", 30080)
+			}
+			if annotations := router.serviceAnnotations(route); len(annotations) != 0 {
+				t.Errorf("\nassertion error while testing `%s`: the service should not be annotated\nGot:%v", testcase.name, annotations)
+			}
+			objects, err := router.routeObjects(route)
+			if err != nil || len(objects) != 1 {
+				t.Fatalf("\nassertion error while testing `%s`: \nWant:1 object\nGot:%v %v", testcase.name, objects, err)
+			}
+			out, err := yaml.Marshal(objects[0].object)
+			if err != nil {
+				t.Errorf("\nassertion error while testing `%s`: unable to marshal the route: %v", testcase.name, err)
+			}
+			for _, want := range testcase.want {
+				if !strings.Contains(string(out), want) {
+					t.Errorf("\nassertion error while testing `%s`: \nWant:%q\nGot:%s", testcase.name, want, out)
+				}
+			}
+		})
+	}
+}
+
+func Test_CreateAndDeleteWorkspaceRoute(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	withTestConfig(t, routingTestConfig(RoutingConfig{Type: "gateway-httproute", GatewayName: "gen3"}))
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		httpRouteResource: "HTTPRouteList",
+	})
+	originalGetLocalDynamicClient := getLocalDynamicClient
+	getLocalDynamicClient = func() (dynamic.Interface, error) {
+		return client, nil
+	}
+	defer func() {
+		getLocalDynamicClient = originalGetLocalDynamicClient
+	}()
+
+	ctx := context.Background()
+	hatchApp := &Container{Name: "Jupyter", PathRewrite: "/lw-workspace/proxy/"}
+	if err := createWorkspaceRoute(ctx, localWorkspaceRoute(hatchApp, "frickjack", "")); err != nil {
+		t.Fatalf("\nassertion error while testing `createWorkspaceRoute`: unexpected error: %v", err)
+	}
+	// a route left over by a previous workspace is replaced
+	if err := createWorkspaceRoute(ctx, localWorkspaceRoute(hatchApp, "frickjack", "")); err != nil {
+		t.Errorf("\nassertion error while testing `createWorkspaceRoute` with an existing route: unexpected error: %v", err)
+	}
+	routes := client.Resource(httpRouteResource).Namespace("jupyter-pods")
+	if _, err := routes.Get(ctx, "frickjack-mapping", metav1.GetOptions{}); err != nil {
+		t.Errorf("\nassertion error while testing `createWorkspaceRoute`: the route was not created: %v", err)
+	}
+
+	if err := deleteWorkspaceRoute(ctx, "frickjack", ""); err != nil {
+		t.Errorf("\nassertion error while testing `deleteWorkspaceRoute`: unexpected error: %v", err)
+	}
+	if _, err := routes.Get(ctx, "frickjack-mapping", metav1.GetOptions{}); err == nil {
+		t.Errorf("\nassertion error while testing `deleteWorkspaceRoute`: the route was not deleted")
+	}
+	// deleting a route that does not exist is not an error
+	if err := deleteWorkspaceRoute(ctx, "frickjack", ""); err != nil {
+		t.Errorf("\nassertion error while testing `deleteWorkspaceRoute` without a route: unexpected error: %v", err)
+	}
+}
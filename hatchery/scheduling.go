@@ -0,0 +1,89 @@
+package hatchery
+
+import (
+	"strings"
+
+	k8sv1 "k8s.io/api/core/v1"
+)
+
+// applyPayModelScheduling replaces the container's scheduling settings with the ones
+// configured for the type of the pay model, if any
+func applyPayModelScheduling(hatchConfig *FullHatcheryConfig, hatchApp *Container, payModel *PayModel) {
+	if payModel == nil {
+		return
+	}
+	scheduling, ok := hatchConfig.Config.PayModelScheduling[payModel.Name]
+	if !ok {
+		return
+	}
+	if scheduling.NodeSelector != nil {
+		hatchApp.NodeSelector = scheduling.NodeSelector
+	}
+	if scheduling.Tolerations != nil {
+		hatchApp.Tolerations = scheduling.Tolerations
+	}
+	if scheduling.Affinity != nil {
+		hatchApp.Affinity = scheduling.Affinity
+	}
+	if scheduling.PriorityClassName != "" {
+		hatchApp.PriorityClassName = scheduling.PriorityClassName
+	}
+	if scheduling.TopologySpread != nil {
+		hatchApp.TopologySpread = scheduling.TopologySpread
+	}
+}
+
+// workspacePlacement returns the node selector and tolerations of the container's
+// workspaces: `role: jupyter` nodes, `role: gpu` nodes for GPU workspaces, unless
+// the container's scheduling settings replace them. GPU workspaces are also placed
+// on the nodes of the container's GPU model
+func workspacePlacement(hatchConfig *FullHatcheryConfig, hatchApp *Container) (map[string]string, []k8sv1.Toleration) {
+	tolerations := []k8sv1.Toleration{}
+	nodeSelector := map[string]string{}
+
+	if !hatchConfig.Config.SkipNodeSelector {
+		// default (jupyter) placement
+		nodeSelector = map[string]string{
+			"role": "jupyter",
+		}
+		tolerations = []k8sv1.Toleration{
+			{Key: "role", Operator: "Equal", Value: "jupyter", Effect: "NoSchedule"},
+		}
+	}
+
+	// If GPU requested, override with GPU settings
+	if hatchApp.GPU {
+		nodeSelector = map[string]string{
+			"role": "gpu",
+		}
+
+		tolerations = []k8sv1.Toleration{
+			{Key: "role", Operator: "Equal", Value: "gpu", Effect: "NoSchedule"},
+			{Key: "nvidia.com/gpu", Operator: "Exists", Effect: "NoSchedule"},
+		}
+		// nodes with other GPUs are tainted with their resource name, e.g. `amd.com/gpu`
+		if resourceName := string(containerGPUResourceName(hatchApp)); !strings.HasPrefix(resourceName, "nvidia.com/") {
+			tolerations = append(tolerations, k8sv1.Toleration{Key: resourceName, Operator: "Exists", Effect: "NoSchedule"})
+		}
+	}
+
+	if hatchApp.NodeSelector != nil {
+		nodeSelector = hatchApp.NodeSelector
+	}
+	if hatchApp.Tolerations != nil {
+		tolerations = hatchApp.Tolerations
+	}
+	if hatchApp.GPU && hatchApp.GPUModel != "" {
+		// copied so that the container's node selector is not modified
+		label := hatchConfig.Config.GPUModelNodeLabel
+		if label == "" {
+			label = defaultGPUModelNodeLabel
+		}
+		withModel := map[string]string{label: hatchApp.GPUModel}
+		for key, value := range nodeSelector {
+			withModel[key] = value
+		}
+		nodeSelector = withModel
+	}
+	return nodeSelector, tolerations
+}
@@ -0,0 +1,120 @@
+package hatchery
+
+import (
+	"testing"
+
+	k8sv1 "k8s.io/api/core/v1"
+)
+
+func Test_BuildPodScheduling(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	withTestConfig(t, func(config *FullHatcheryConfig) {
+		config.Config.Sidecar = SidecarContainer{CPULimit: "0.1", MemoryLimit: "256Mi"}
+		config.Config.PayModelScheduling = map[string]WorkspaceScheduling{
+			"Direct Pay": {
+				NodeSelector: map[string]string{"role": "spot"},
+				Tolerations:  []k8sv1.Toleration{{Key: "spot", Operator: "Exists", Effect: "NoSchedule"}},
+			},
+		}
+	})
+
+	highMem := WorkspaceScheduling{
+		NodeSelector:      map[string]string{"role": "highmem"},
+		PriorityClassName: "workspaces",
+		Affinity: &k8sv1.Affinity{NodeAffinity: &k8sv1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &k8sv1.NodeSelector{NodeSelectorTerms: []k8sv1.NodeSelectorTerm{{
+				MatchExpressions: []k8sv1.NodeSelectorRequirement{{Key: "node.kubernetes.io/instance-type", Operator: "In", Values: []string{"r5.4xlarge"}}},
+			}}},
+		}},
+		TopologySpread: []k8sv1.TopologySpreadConstraint{{MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: k8sv1.ScheduleAnyway}},
+	}
+	testCases := []struct {
+		name             string
+		hatchApp         Container
+		payModel         *PayModel
+		wantNodeSelector string
+		wantTolerations  int
+	}{
+		{
+			name:             "Default",
+			hatchApp:         Container{Name: "Jupyter"},
+			wantNodeSelector: "jupyter",
+			wantTolerations:  1,
+		},
+		{
+			name:             "GPU",
+			hatchApp:         Container{Name: "GPU", GPU: true},
+			wantNodeSelector: "gpu",
+			wantTolerations:  2,
+		},
+		{
+			name:             "ContainerNodeSelector",
+			hatchApp:         Container{Name: "High memory", GPU: true, WorkspaceScheduling: highMem},
+			wantNodeSelector: "highmem",
+			// the default tolerations are kept when the container does not set any
+			wantTolerations: 2,
+		},
+		{
+			name:             "PayModel",
+			hatchApp:         Container{Name: "High memory", WorkspaceScheduling: highMem},
+			payModel:         &PayModel{Name: "Direct Pay"},
+			wantNodeSelector: "spot",
+			wantTolerations:  1,
+		},
+		{
+			name:             "OtherPayModel",
+			hatchApp:         Container{Name: "Jupyter"},
+			payModel:         &PayModel{Name: "Trial Workspace"},
+			wantNodeSelector: "jupyter",
+			wantTolerations:  1,
+		},
+	}
+	for _, testcase := range testCases {
+		t.Logf("Testing buildPod scheduling when %s", testcase.name)
+		hatchApp := testcase.hatchApp
+		applyPayModelScheduling(Config(), &hatchApp, testcase.payModel)
+		pod, err := buildPod(Config(), &hatchApp, "frickjack", "", nil)
+		if err != nil {
+			t.Errorf("failed to build a pod - %v", err)
+			continue
+		}
+		if got := pod.Spec.NodeSelector["role"]; got != testcase.wantNodeSelector {
+			t.Errorf("\nassertion error while testing `%s` node selector: \nWant:%s\nGot:%s", testcase.name, testcase.wantNodeSelector, got)
+		}
+		if got := len(pod.Spec.Tolerations); got != testcase.wantTolerations {
+			t.Errorf("\nassertion error while testing `%s` tolerations: \nWant:%d\nGot:%v", testcase.name, testcase.wantTolerations, pod.Spec.Tolerations)
+		}
+		if hatchApp.Affinity != pod.Spec.Affinity || pod.Spec.PriorityClassName != hatchApp.PriorityClassName || len(pod.Spec.TopologySpreadConstraints) != len(hatchApp.TopologySpread) {
+			t.Errorf("\nassertion error while testing `%s`: the affinity, priority class and topology spread were not set\nGot:%+v", testcase.name, pod.Spec)
+		}
+	}
+}
+
+func Test_WorkspaceContainerPayModelScheduling(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	loadRenderTestConfig(t)
+
+	withTestConfig(t, func(config *FullHatcheryConfig) {
+		config.Config.PayModelScheduling = map[string]WorkspaceScheduling{
+			"Direct Pay": {PriorityClassName: "paid-workspaces"},
+		}
+	})
+
+	hash := ""
+	for id, container := range Config().ContainersMap {
+		if container.Name == "DockstoreTest" {
+			hash = id
+		}
+	}
+	// dockstore apps are scheduled with the settings of their `more-configs` entry
+	size := defaultWorkspaceSize(Config(), Config().ContainersMap[hash], nil)
+	hatchApp := workspaceContainer(Config(), Config().ContainersMap[hash], size, &PayModel{Name: "Direct Pay"})
+	if hatchApp.NodeSelector["role"] != "dockstore" || len(hatchApp.Tolerations) != 1 || hatchApp.PriorityClassName != "paid-workspaces" {
+		t.Errorf("\nassertion error while testing `workspaceContainer` scheduling: \nWant:%s\nGot:%+v", "the dockstore node pool and the pay model's priority class", hatchApp.WorkspaceScheduling)
+	}
+	hatchApp = workspaceContainer(Config(), Config().ContainersMap[hash], size, nil)
+	if hatchApp.PriorityClassName != "" {
+		t.Errorf("\nassertion error while testing `workspaceContainer` without a pay model: \nWant:%s\nGot:%s", "", hatchApp.PriorityClassName)
+	}
+}
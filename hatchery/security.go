@@ -0,0 +1,137 @@
+package hatchery
+
+import (
+	"fmt"
+	"strings"
+
+	k8sv1 "k8s.io/api/core/v1"
+)
+
+// podSecurityRestricted is the `pod-security-standard` which refuses the containers whose
+// pods the Pod Security Admission "restricted" level would reject
+const podSecurityRestricted = "restricted"
+
+// writableVolumeName is the emptyDir mounted at the `writable-paths` of the security profile
+const writableVolumeName = "writable"
+
+// localhostProfilePrefix prefixes the seccomp and AppArmor profiles loaded on the nodes
+const localhostProfilePrefix = "Localhost/"
+
+// containerSecurityProfile returns the security profile of the container's workspaces,
+// or nil if the containers are not hardened
+func containerSecurityProfile(config *HatcheryConfig, hatchApp *Container) *SecurityProfile {
+	if hatchApp.SecurityProfile != nil {
+		return hatchApp.SecurityProfile
+	}
+	return config.SecurityProfile
+}
+
+// buildSeccompProfile translates "RuntimeDefault", "Unconfined" or "Localhost/<profile>"
+func buildSeccompProfile(value string) (*k8sv1.SeccompProfile, error) {
+	switch {
+	case value == string(k8sv1.SeccompProfileTypeRuntimeDefault), value == string(k8sv1.SeccompProfileTypeUnconfined):
+		return &k8sv1.SeccompProfile{Type: k8sv1.SeccompProfileType(value)}, nil
+	case strings.HasPrefix(value, localhostProfilePrefix) && len(value) > len(localhostProfilePrefix):
+		localhostProfile := strings.TrimPrefix(value, localhostProfilePrefix)
+		return &k8sv1.SeccompProfile{Type: k8sv1.SeccompProfileTypeLocalhost, LocalhostProfile: &localhostProfile}, nil
+	}
+	return nil, fmt.Errorf("invalid seccomp profile '%s': must be one of 'RuntimeDefault', 'Unconfined' or 'Localhost/<profile>'", value)
+}
+
+// buildAppArmorProfile translates "RuntimeDefault", "Unconfined" or "Localhost/<profile>"
+func buildAppArmorProfile(value string) (*k8sv1.AppArmorProfile, error) {
+	switch {
+	case value == string(k8sv1.AppArmorProfileTypeRuntimeDefault), value == string(k8sv1.AppArmorProfileTypeUnconfined):
+		return &k8sv1.AppArmorProfile{Type: k8sv1.AppArmorProfileType(value)}, nil
+	case strings.HasPrefix(value, localhostProfilePrefix) && len(value) > len(localhostProfilePrefix):
+		localhostProfile := strings.TrimPrefix(value, localhostProfilePrefix)
+		return &k8sv1.AppArmorProfile{Type: k8sv1.AppArmorProfileTypeLocalhost, LocalhostProfile: &localhostProfile}, nil
+	}
+	return nil, fmt.Errorf("invalid AppArmor profile '%s': must be one of 'RuntimeDefault', 'Unconfined' or 'Localhost/<profile>'", value)
+}
+
+// writableVolume is the scratch space mounted at the writable paths of a read-only root
+// file system, or nil if not needed
+func writableVolume(profile *SecurityProfile) *k8sv1.Volume {
+	if profile == nil || !profile.ReadOnlyRootFilesystem || len(profile.WritablePaths) == 0 {
+		return nil
+	}
+	return &k8sv1.Volume{
+		Name:         writableVolumeName,
+		VolumeSource: k8sv1.VolumeSource{EmptyDir: &k8sv1.EmptyDirVolumeSource{}},
+	}
+}
+
+// applySecurityProfile hardens the security context of a container of the pod. The security
+// context and mounts of the friends and init containers are shared with the config, so
+// they are copied rather than updated
+func applySecurityProfile(container *k8sv1.Container, profile *SecurityProfile) error {
+	if profile == nil {
+		return nil
+	}
+	securityContext := &k8sv1.SecurityContext{}
+	if container.SecurityContext != nil {
+		// the API server rejects privileged containers without privilege escalation
+		if container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+			return nil
+		}
+		securityContext = container.SecurityContext.DeepCopy()
+	}
+
+	if profile.Seccomp != "" {
+		seccompProfile, err := buildSeccompProfile(profile.Seccomp)
+		if err != nil {
+			return err
+		}
+		securityContext.SeccompProfile = seccompProfile
+	}
+	if profile.AppArmor != "" {
+		appArmorProfile, err := buildAppArmorProfile(profile.AppArmor)
+		if err != nil {
+			return err
+		}
+		securityContext.AppArmorProfile = appArmorProfile
+	}
+	if len(profile.DropCapabilities) > 0 || len(profile.AddCapabilities) > 0 {
+		if securityContext.Capabilities == nil {
+			securityContext.Capabilities = &k8sv1.Capabilities{}
+		}
+		securityContext.Capabilities.Drop = appendCapabilities(securityContext.Capabilities.Drop, profile.DropCapabilities)
+		securityContext.Capabilities.Add = appendCapabilities(securityContext.Capabilities.Add, profile.AddCapabilities)
+	}
+	if !profile.AllowPrivilegeEscalation {
+		securityContext.AllowPrivilegeEscalation = &falseVal
+	}
+	if profile.RunAsNonRoot {
+		securityContext.RunAsNonRoot = &trueVal
+	}
+	if profile.ReadOnlyRootFilesystem {
+		securityContext.ReadOnlyRootFilesystem = &trueVal
+		volumeMounts := append([]k8sv1.VolumeMount{}, container.VolumeMounts...)
+		for i, path := range profile.WritablePaths {
+			volumeMounts = append(volumeMounts, k8sv1.VolumeMount{
+				Name:      writableVolumeName,
+				MountPath: path,
+				SubPath:   fmt.Sprint(i),
+			})
+		}
+		container.VolumeMounts = volumeMounts
+	}
+	container.SecurityContext = securityContext
+	return nil
+}
+
+// appendCapabilities adds the capabilities which are not in the list yet
+func appendCapabilities(capabilities []k8sv1.Capability, names []string) []k8sv1.Capability {
+	result := append([]k8sv1.Capability{}, capabilities...)
+	for _, name := range names {
+		found := false
+		for _, capability := range result {
+			found = found || string(capability) == name
+		}
+		if !found {
+			result = append(result, k8sv1.Capability(name))
+		}
+	}
+	return result
+}
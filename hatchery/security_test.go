@@ -0,0 +1,101 @@
+package hatchery
+
+import (
+	"testing"
+
+	k8sv1 "k8s.io/api/core/v1"
+)
+
+func Test_BuildPodWithSecurityProfile(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	loadRenderTestConfig(t)
+
+	withTestConfig(t, func(config *FullHatcheryConfig) {
+		config.Config.SidecarProfiles = map[string]SidecarContainer{
+			"unprivileged": {CPULimit: "0.1", MemoryLimit: "256Mi", Unprivileged: true},
+		}
+		config.Config.SecurityProfile = &SecurityProfile{
+			Seccomp:                "RuntimeDefault",
+			AppArmor:               "Localhost/workspace",
+			DropCapabilities:       []string{"ALL"},
+			AddCapabilities:        []string{"NET_BIND_SERVICE"},
+			RunAsNonRoot:           true,
+			ReadOnlyRootFilesystem: true,
+			WritablePaths:          []string{"/tmp", "/home/jovyan/.cache"},
+		}
+	})
+
+	friendSecurityContext := &k8sv1.SecurityContext{Capabilities: &k8sv1.Capabilities{Drop: []k8sv1.Capability{"NET_RAW"}}}
+	hatchApp := &Container{
+		Name:           "Jupyter",
+		Image:          "jupyter",
+		CPULimit:       "1",
+		MemoryLimit:    "1Gi",
+		InitContainers: []k8sv1.Container{{Name: "seed-notebooks", Image: "jupyter"}},
+		Friends: []k8sv1.Container{
+			{Name: "viewer", Image: "viewer", SecurityContext: friendSecurityContext},
+			{Name: "firefox", Image: "firefox", SecurityContext: &k8sv1.SecurityContext{Privileged: &trueVal}},
+		},
+	}
+	pod, err := buildPod(Config(), hatchApp, "frickjack", "", nil)
+	if err != nil {
+		t.Fatalf("failed to build a pod - %v", err)
+	}
+
+	for _, name := range []string{"seed-notebooks", "hatchery-container", "viewer"} {
+		securityContext := podContainer(pod, name).SecurityContext
+		if securityContext == nil || securityContext.SeccompProfile == nil || securityContext.SeccompProfile.Type != k8sv1.SeccompProfileTypeRuntimeDefault ||
+			securityContext.AppArmorProfile == nil || *securityContext.AppArmorProfile.LocalhostProfile != "workspace" ||
+			*securityContext.AllowPrivilegeEscalation || !*securityContext.RunAsNonRoot || !*securityContext.ReadOnlyRootFilesystem {
+			t.Errorf("\nassertion error while testing `applySecurityProfile` %s: \nWant:%s\nGot:%+v", name, "a hardened container", securityContext)
+			continue
+		}
+		writablePaths := map[string]bool{}
+		for _, mount := range podContainer(pod, name).VolumeMounts {
+			if mount.Name == writableVolumeName {
+				writablePaths[mount.MountPath] = true
+			}
+		}
+		if !writablePaths["/tmp"] || !writablePaths["/home/jovyan/.cache"] {
+			t.Errorf("\nassertion error while testing `applySecurityProfile` writable paths of %s: \nWant:%v\nGot:%v", name, Config().Config.SecurityProfile.WritablePaths, writablePaths)
+		}
+	}
+	if capabilities := podContainer(pod, "viewer").SecurityContext.Capabilities; len(capabilities.Drop) != 2 || len(capabilities.Add) != 1 {
+		t.Errorf("\nassertion error while testing `applySecurityProfile` capabilities: \nWant:%s\nGot:%+v", "NET_RAW and ALL dropped", capabilities)
+	}
+	// the config is left untouched for the next launch
+	if len(friendSecurityContext.Capabilities.Drop) != 1 || friendSecurityContext.SeccompProfile != nil {
+		t.Errorf("\nassertion error while testing `applySecurityProfile` config: \nWant:%s\nGot:%+v", "NET_RAW", friendSecurityContext)
+	}
+
+	// privileged containers are left as they are
+	for _, name := range []string{"fuse-container", "firefox"} {
+		if securityContext := podContainer(pod, name).SecurityContext; securityContext.AllowPrivilegeEscalation != nil || securityContext.SeccompProfile != nil {
+			t.Errorf("\nassertion error while testing `applySecurityProfile` privileged %s: \nWant:%s\nGot:%+v", name, "no hardening", securityContext)
+		}
+	}
+
+	hatchApp.Sidecar = "unprivileged"
+	pod, err = buildPod(Config(), hatchApp, "frickjack", "", nil)
+	if err != nil {
+		t.Fatalf("failed to build a pod - %v", err)
+	}
+	if securityContext := podContainer(pod, "fuse-container").SecurityContext; securityContext.SeccompProfile == nil || !*securityContext.RunAsNonRoot {
+		t.Errorf("\nassertion error while testing `applySecurityProfile` unprivileged sidecar: \nWant:%s\nGot:%+v", "a hardened sidecar", securityContext)
+	}
+
+	// the container's profile replaces the global one
+	hatchApp.SecurityProfile = &SecurityProfile{AllowPrivilegeEscalation: true}
+	pod, err = buildPod(Config(), hatchApp, "frickjack", "", nil)
+	if err != nil {
+		t.Fatalf("failed to build a pod - %v", err)
+	}
+	if securityContext := podContainer(pod, "hatchery-container").SecurityContext; securityContext.SeccompProfile != nil || securityContext.AllowPrivilegeEscalation != nil {
+		t.Errorf("\nassertion error while testing `containerSecurityProfile` container profile: \nWant:%s\nGot:%+v", "no hardening", securityContext)
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == writableVolumeName {
+			t.Errorf("\nassertion error while testing `writableVolume`: \nWant:%s\nGot:%+v", "no writable volume", volume)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package hatchery
+
+import (
+	"fmt"
+
+	k8sv1 "k8s.io/api/core/v1"
+)
+
+// noSidecar is the `sidecar` of the containers whose workspaces run without sidecar
+const noSidecar = "none"
+
+// containerSidecar returns the sidecar of the container's workspaces: the global `sidecar`
+// unless the container selects one of the `sidecar-profiles`, or nil without sidecar
+func containerSidecar(config *HatcheryConfig, hatchApp *Container) (*SidecarContainer, error) {
+	switch hatchApp.Sidecar {
+	case "":
+		return &config.Sidecar, nil
+	case noSidecar:
+		return nil, nil
+	}
+	sidecar, ok := config.SidecarProfiles[hatchApp.Sidecar]
+	if !ok {
+		return nil, fmt.Errorf("unknown sidecar profile '%s'", hatchApp.Sidecar)
+	}
+	return &sidecar, nil
+}
+
+// propagatesFuseMounts returns true if the FUSE mounts of the sidecar under /data reach the
+// workspace containers, which requires a privileged sidecar
+func propagatesFuseMounts(sidecar *SidecarContainer) bool {
+	return sidecar != nil && !sidecar.Unprivileged
+}
+
+// sidecarSecurityContext runs the sidecar as root with the privileges FUSE requires, or
+// as the pod's user without privileges
+func sidecarSecurityContext(sidecar *SidecarContainer) *k8sv1.SecurityContext {
+	if sidecar.Unprivileged {
+		return &k8sv1.SecurityContext{
+			Privileged:               &falseVal,
+			AllowPrivilegeEscalation: &falseVal,
+		}
+	}
+	var sideCarRunAsUser int64
+	var sideCarRunAsGroup int64
+	return &k8sv1.SecurityContext{
+		Privileged: &trueVal,
+		RunAsUser:  &sideCarRunAsUser,
+		RunAsGroup: &sideCarRunAsGroup,
+	}
+}
@@ -0,0 +1,107 @@
+package hatchery
+
+import (
+	"testing"
+
+	k8sv1 "k8s.io/api/core/v1"
+)
+
+func Test_BuildPodWithSidecarProfiles(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	loadRenderTestConfig(t)
+
+	withTestConfig(t, func(config *FullHatcheryConfig) {
+		config.Config.SidecarProfiles = map[string]SidecarContainer{
+			"unprivileged": {CPULimit: "0.1", MemoryLimit: "256Mi", Image: "quay.io/example/download-sidecar:1.0", Unprivileged: true},
+		}
+	})
+
+	dataMount := func(container *k8sv1.Container) *k8sv1.VolumeMount {
+		for i := range container.VolumeMounts {
+			if container.VolumeMounts[i].Name == "shared-data" {
+				return &container.VolumeMounts[i]
+			}
+		}
+		return nil
+	}
+
+	// the global sidecar is privileged, and propagates the FUSE mounts
+	hatchApp := &Container{Name: "Jupyter", Image: "jupyter", CPULimit: "1", MemoryLimit: "1Gi"}
+	pod, err := buildPod(Config(), hatchApp, "frickjack", "", nil)
+	if err != nil {
+		t.Fatalf("failed to build a pod - %v", err)
+	}
+	sidecar := podContainer(pod, "fuse-container")
+	if sidecar == nil || sidecar.Image != Config().Config.Sidecar.Image || !*sidecar.SecurityContext.Privileged {
+		t.Errorf("\nassertion error while testing `buildPod` default sidecar: \nWant:%s\nGot:%+v", Config().Config.Sidecar.Image, sidecar)
+	}
+	if mount := dataMount(podContainer(pod, "hatchery-container")); mount == nil || mount.MountPropagation == nil || *mount.MountPropagation != k8sv1.MountPropagationHostToContainer {
+		t.Errorf("\nassertion error while testing `buildPod` default sidecar /data: \nWant:%s\nGot:%+v", k8sv1.MountPropagationHostToContainer, mount)
+	}
+
+	// an unprivileged sidecar writes to /data without mount propagation
+	hatchApp.Sidecar = "unprivileged"
+	pod, err = buildPod(Config(), hatchApp, "frickjack", "", nil)
+	if err != nil {
+		t.Fatalf("failed to build a pod - %v", err)
+	}
+	sidecar = podContainer(pod, "fuse-container")
+	if sidecar == nil || sidecar.Image != "quay.io/example/download-sidecar:1.0" || *sidecar.SecurityContext.Privileged ||
+		*sidecar.SecurityContext.AllowPrivilegeEscalation || sidecar.SecurityContext.RunAsUser != nil {
+		t.Errorf("\nassertion error while testing `buildPod` unprivileged sidecar: \nWant:%s\nGot:%+v", "an unprivileged sidecar", sidecar)
+	}
+	for _, container := range []string{"fuse-container", "hatchery-container"} {
+		if mount := dataMount(podContainer(pod, container)); mount == nil || mount.MountPropagation != nil {
+			t.Errorf("\nassertion error while testing `buildPod` unprivileged sidecar /data of %s: \nWant:%s\nGot:%+v", container, "no propagation", mount)
+		}
+	}
+
+	// without sidecar, the workspace container has no /data
+	hatchApp.Sidecar = noSidecar
+	pod, err = buildPod(Config(), hatchApp, "frickjack", "", nil)
+	if err != nil {
+		t.Fatalf("failed to build a pod - %v", err)
+	}
+	if len(pod.Spec.Containers) != 1 || pod.Spec.Containers[0].Name != "hatchery-container" {
+		t.Errorf("\nassertion error while testing `buildPod` without sidecar: \nWant:%s\nGot:%+v", "hatchery-container", pod.Spec.Containers)
+	}
+	if mount := dataMount(&pod.Spec.Containers[0]); mount != nil {
+		t.Errorf("\nassertion error while testing `buildPod` without sidecar /data: \nWant:%v\nGot:%+v", nil, mount)
+	}
+
+	hatchApp.Sidecar = "privileged"
+	if _, err := buildPod(Config(), hatchApp, "frickjack", "", nil); err == nil {
+		t.Errorf("\nassertion error while testing `buildPod` unknown sidecar profile: \nWant:%s\nGot:%v", "an error", err)
+	}
+}
+
+func Test_BuildEcsTaskDefinitionSidecar(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	loadRenderTestConfig(t)
+
+	payModel := PayModel{AWSAccountId: "123456789012"}
+	hatchApp := &Container{Name: "Jupyter", Image: "jupyter", CPULimit: "1", MemoryLimit: "2Gi"}
+	taskDef, err := buildEcsTaskDefinition(Config(), hatchApp, "frickjack", payModel, "1024", "2048", nil, "", &EFS{})
+	if err != nil {
+		t.Fatalf("failed to build a task definition - %v", err)
+	}
+	input := taskDef.registerTaskDefinitionInput("frickjack", "/hatchery/123456789012/", nil)
+	if len(input.ContainerDefinitions) != 2 || *input.ContainerDefinitions[1].Name != "sidecar-container" {
+		t.Errorf("\nassertion error while testing `registerTaskDefinitionInput` sidecar: \nWant:%s\nGot:%v", "sidecar-container", input.ContainerDefinitions)
+	}
+
+	hatchApp.Sidecar = noSidecar
+	taskDef, err = buildEcsTaskDefinition(Config(), hatchApp, "frickjack", payModel, "1024", "2048", nil, "", &EFS{})
+	if err != nil {
+		t.Fatalf("failed to build a task definition - %v", err)
+	}
+	input = taskDef.registerTaskDefinitionInput("frickjack", "/hatchery/123456789012/", nil)
+	if taskDef.SidecarContainer != nil || len(input.ContainerDefinitions) != 1 {
+		t.Errorf("\nassertion error while testing `registerTaskDefinitionInput` without sidecar: \nWant:%d\nGot:%v", 1, input.ContainerDefinitions)
+	}
+
+	hatchApp.Sidecar = "privileged"
+	if _, err := buildEcsTaskDefinition(Config(), hatchApp, "frickjack", payModel, "1024", "2048", nil, "", &EFS{}); err == nil {
+		t.Errorf("\nassertion error while testing `buildEcsTaskDefinition` unknown sidecar profile: \nWant:%s\nGot:%v", "an error", err)
+	}
+}
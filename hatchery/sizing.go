@@ -0,0 +1,225 @@
+package hatchery
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	k8sv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Query parameters of `/launch` to choose the size of the workspace
+const (
+	sizeParam        = "size"
+	cpuLimitParam    = "cpuLimit"
+	memoryLimitParam = "memoryLimit"
+	gpuCountParam    = "gpuCount"
+	volumeSizeParam  = "volumeSize"
+)
+
+// hasWorkspaceSizeParams returns true if a size was chosen in the `/launch` parameters
+func hasWorkspaceSizeParams(params url.Values) bool {
+	for _, param := range []string{sizeParam, cpuLimitParam, memoryLimitParam, gpuCountParam, volumeSizeParam} {
+		if params.Get(param) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// workspaceContainer returns the config of the container, resized to the size chosen for the workspace
+// and scheduled for its pay model
+func workspaceContainer(hatchConfig *FullHatcheryConfig, hatchApp Container, size WorkspaceSize, payModel *PayModel) Container {
+	applyWorkspaceSize(&hatchApp, size)
+	applyPayModelScheduling(hatchConfig, &hatchApp, payModel)
+	return hatchApp
+}
+
+func applyWorkspaceSize(hatchApp *Container, size WorkspaceSize) {
+	hatchApp.CPULimit = size.CPULimit
+	hatchApp.MemoryLimit = size.MemoryLimit
+	hatchApp.GPU = size.GPUCount > 0
+	hatchApp.GPUCount = size.GPUCount
+}
+
+// containerGPUCount returns the number of GPUs of the container's workspaces
+func containerGPUCount(hatchApp *Container) int {
+	if !hatchApp.GPU {
+		return 0
+	}
+	if hatchApp.GPUCount > 0 {
+		return hatchApp.GPUCount
+	}
+	return 1
+}
+
+const (
+	// defaultGPUResourceName is the extended resource of NVIDIA GPUs
+	defaultGPUResourceName = "nvidia.com/gpu"
+	// defaultGPUModelNodeLabel is set by the NVIDIA GPU feature discovery
+	defaultGPUModelNodeLabel = "nvidia.com/gpu.product"
+)
+
+// containerGPUResourceName returns the extended resource requested for the GPUs of the container's workspaces
+func containerGPUResourceName(hatchApp *Container) k8sv1.ResourceName {
+	if hatchApp.GPUResourceName != "" {
+		return k8sv1.ResourceName(hatchApp.GPUResourceName)
+	}
+	return defaultGPUResourceName
+}
+
+// defaultWorkspaceSize is the size of workspaces launched with the pay model without choosing a size
+func defaultWorkspaceSize(hatchConfig *FullHatcheryConfig, hatchApp Container, payModel *PayModel) WorkspaceSize {
+	size := WorkspaceSize{
+		CPULimit:    hatchApp.CPULimit,
+		MemoryLimit: hatchApp.MemoryLimit,
+		GPUCount:    containerGPUCount(&hatchApp),
+	}
+	if hatchApp.UserVolumeLocation != "" {
+		size.VolumeSize = userVolumeConfig(hatchConfig, &hatchApp, payModel).Size
+	}
+	return size
+}
+
+// resolveWorkspaceSize returns the size of the workspace from the tier and resources chosen in the
+// `/launch` parameters, within the container's ranges and the caps of the pay model
+func resolveWorkspaceSize(hatchConfig *FullHatcheryConfig, hatchApp Container, params url.Values, payModel *PayModel) (WorkspaceSize, error) {
+	size := defaultWorkspaceSize(hatchConfig, hatchApp, payModel)
+	sizes := hatchApp.Sizes
+	if sizes == nil {
+		sizes = &ContainerSizes{}
+	}
+
+	chosen := false
+	if tierName := params.Get(sizeParam); tierName != "" {
+		found := false
+		for _, tier := range sizes.Tiers {
+			if tier.Name == tierName {
+				size = mergeWorkspaceSize(size, tier)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return size, fmt.Errorf("unknown size '%s' for container '%s'", tierName, hatchApp.Name)
+		}
+		chosen = true
+	}
+
+	for _, quantityParam := range []struct {
+		name   string
+		bounds *QuantityRange
+		value  *string
+	}{
+		{cpuLimitParam, sizes.CPULimit, &size.CPULimit},
+		{memoryLimitParam, sizes.MemoryLimit, &size.MemoryLimit},
+		{volumeSizeParam, sizes.VolumeSize, &size.VolumeSize},
+	} {
+		value := params.Get(quantityParam.name)
+		if value == "" {
+			continue
+		}
+		if err := checkQuantityInRange(quantityParam.name, value, quantityParam.bounds); err != nil {
+			return size, err
+		}
+		*quantityParam.value = value
+		chosen = true
+	}
+
+	if value := params.Get(gpuCountParam); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return size, fmt.Errorf("invalid '%s' '%s': %v", gpuCountParam, value, err)
+		}
+		if sizes.GPUCount == nil {
+			return size, fmt.Errorf("'%s' can't be chosen for this container", gpuCountParam)
+		}
+		if count < sizes.GPUCount.Min || count > sizes.GPUCount.Max {
+			return size, fmt.Errorf("'%s' must be between %d and %d", gpuCountParam, sizes.GPUCount.Min, sizes.GPUCount.Max)
+		}
+		size.GPUCount = count
+		chosen = true
+	}
+
+	if payModel != nil {
+		if chosen && payModel.Ecs && size.GPUCount > 0 {
+			return size, fmt.Errorf("GPUs are not supported for ECS pay models")
+		}
+		if payModel.Ecs {
+			// checked before the launch, which is asynchronous for ECS
+			if _, err := cpu(size.CPULimit); err != nil {
+				return size, err
+			}
+			if _, err := mem(size.MemoryLimit); err != nil {
+				return size, err
+			}
+		}
+		if err := checkPayModelSizeCaps(hatchConfig, size, payModel); err != nil {
+			return size, err
+		}
+	}
+	return size, nil
+}
+
+// mergeWorkspaceSize returns the size with the resources set in the tier replaced
+func mergeWorkspaceSize(size WorkspaceSize, tier WorkspaceSizeTier) WorkspaceSize {
+	size.Name = tier.Name
+	if tier.CPULimit != "" {
+		size.CPULimit = tier.CPULimit
+	}
+	if tier.MemoryLimit != "" {
+		size.MemoryLimit = tier.MemoryLimit
+	}
+	if tier.VolumeSize != "" {
+		size.VolumeSize = tier.VolumeSize
+	}
+	if tier.GPUCount != nil {
+		size.GPUCount = *tier.GPUCount
+	}
+	return size
+}
+
+func checkQuantityInRange(name string, value string, bounds *QuantityRange) error {
+	if bounds == nil {
+		return fmt.Errorf("'%s' can't be chosen for this container", name)
+	}
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return fmt.Errorf("invalid '%s' '%s': %v", name, value, err)
+	}
+	// the bounds are checked when the config is loaded
+	if quantity.Cmp(resource.MustParse(bounds.Min)) < 0 || quantity.Cmp(resource.MustParse(bounds.Max)) > 0 {
+		return fmt.Errorf("'%s' must be between %s and %s", name, bounds.Min, bounds.Max)
+	}
+	return nil
+}
+
+// checkPayModelSizeCaps checks the size against the caps of the pay model type, if any
+func checkPayModelSizeCaps(hatchConfig *FullHatcheryConfig, size WorkspaceSize, payModel *PayModel) error {
+	caps, ok := hatchConfig.Config.PayModelSizeCaps[payModel.Name]
+	if !ok {
+		return nil
+	}
+	for _, quantity := range []struct {
+		name  string
+		value string
+		cap   string
+	}{
+		{"cpu-limit", size.CPULimit, caps.CPULimit},
+		{"memory-limit", size.MemoryLimit, caps.MemoryLimit},
+		{"volume-size", size.VolumeSize, caps.VolumeSize},
+	} {
+		if quantity.cap == "" || quantity.value == "" {
+			continue
+		}
+		value := resource.MustParse(quantity.value)
+		if value.Cmp(resource.MustParse(quantity.cap)) > 0 {
+			return fmt.Errorf("the %s of workspaces is capped at %s for '%s' pay models", quantity.name, quantity.cap, payModel.Name)
+		}
+	}
+	if caps.GPUCount != nil && size.GPUCount > *caps.GPUCount {
+		return fmt.Errorf("the gpu-count of workspaces is capped at %d for '%s' pay models", *caps.GPUCount, payModel.Name)
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package hatchery
+
+import (
+	"net/url"
+	"testing"
+
+	k8sv1 "k8s.io/api/core/v1"
+)
+
+func Test_ResolveWorkspaceSize(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	trialGPUCap := 0
+	oneGPU := 1
+	withTestConfig(t, func(config *FullHatcheryConfig) {
+		config.Config = HatcheryConfig{
+			UserVolumeSize: "10Gi",
+			PayModelSizeCaps: map[string]WorkspaceSizeCaps{
+				"Trial Workspace": {CPULimit: "2", MemoryLimit: "4Gi", GPUCount: &trialGPUCap},
+			},
+		}
+	})
+
+	hatchApp := Container{
+		Name:               "Jupyter",
+		CPULimit:           "1.0",
+		MemoryLimit:        "2Gi",
+		UserVolumeLocation: "/home/jovyan/pd",
+		Sizes: &ContainerSizes{
+			Tiers: []WorkspaceSizeTier{
+				{Name: "large", CPULimit: "4", MemoryLimit: "16Gi"},
+				{Name: "gpu", CPULimit: "4", MemoryLimit: "16Gi", GPUCount: &oneGPU},
+			},
+			CPULimit:    &QuantityRange{Min: "0.5", Max: "8"},
+			MemoryLimit: &QuantityRange{Min: "1Gi", Max: "32Gi"},
+			GPUCount:    &CountRange{Min: 0, Max: 2},
+			VolumeSize:  &QuantityRange{Min: "10Gi", Max: "100Gi"},
+		},
+	}
+	trial := &PayModel{Name: "Trial Workspace"}
+	ecs := &PayModel{Name: "Direct Pay", Ecs: true}
+
+	testCases := []struct {
+		name      string
+		hatchApp  Container
+		params    url.Values
+		payModel  *PayModel
+		want      WorkspaceSize
+		wantError bool
+	}{
+		{
+			name:     "Default",
+			hatchApp: hatchApp,
+			params:   url.Values{},
+			want:     WorkspaceSize{CPULimit: "1.0", MemoryLimit: "2Gi", VolumeSize: "10Gi"},
+		},
+		{
+			name:     "DefaultGPU",
+			hatchApp: Container{Name: "GPU", CPULimit: "1.0", MemoryLimit: "2Gi", GPU: true},
+			params:   url.Values{},
+			want:     WorkspaceSize{CPULimit: "1.0", MemoryLimit: "2Gi", GPUCount: 1},
+		},
+		{
+			name:     "Tier",
+			hatchApp: hatchApp,
+			params:   url.Values{"size": {"gpu"}},
+			want:     WorkspaceSize{Name: "gpu", CPULimit: "4", MemoryLimit: "16Gi", GPUCount: 1, VolumeSize: "10Gi"},
+		},
+		{
+			// the GPU of the container is kept by the tiers which don't set one
+			name:     "TierWithoutGPUCount",
+			hatchApp: Container{Name: "GPU", CPULimit: "1.0", MemoryLimit: "2Gi", GPU: true, Sizes: hatchApp.Sizes},
+			params:   url.Values{"size": {"large"}},
+			want:     WorkspaceSize{Name: "large", CPULimit: "4", MemoryLimit: "16Gi", GPUCount: 1},
+		},
+		{
+			name:     "TierWithResource",
+			hatchApp: hatchApp,
+			params:   url.Values{"size": {"large"}, "volumeSize": {"50Gi"}, "gpuCount": {"2"}},
+			want:     WorkspaceSize{Name: "large", CPULimit: "4", MemoryLimit: "16Gi", GPUCount: 2, VolumeSize: "50Gi"},
+		},
+		{
+			name:     "Resources",
+			hatchApp: hatchApp,
+			params:   url.Values{"cpuLimit": {"500m"}, "memoryLimit": {"32Gi"}},
+			want:     WorkspaceSize{CPULimit: "500m", MemoryLimit: "32Gi", VolumeSize: "10Gi"},
+		},
+		{
+			name:      "UnknownTier",
+			hatchApp:  hatchApp,
+			params:    url.Values{"size": {"huge"}},
+			wantError: true,
+		},
+		{
+			name:      "OutOfRange",
+			hatchApp:  hatchApp,
+			params:    url.Values{"memoryLimit": {"64Gi"}},
+			wantError: true,
+		},
+		{
+			name:      "InvalidQuantity",
+			hatchApp:  hatchApp,
+			params:    url.Values{"cpuLimit": {"lots"}},
+			wantError: true,
+		},
+		{
+			name:      "GPUCountOutOfRange",
+			hatchApp:  hatchApp,
+			params:    url.Values{"gpuCount": {"3"}},
+			wantError: true,
+		},
+		{
+			name:      "NoSizes",
+			hatchApp:  Container{Name: "Fixed", CPULimit: "1.0", MemoryLimit: "2Gi"},
+			params:    url.Values{"cpuLimit": {"2"}},
+			wantError: true,
+		},
+		{
+			name:     "WithinPayModelCaps",
+			hatchApp: hatchApp,
+			params:   url.Values{"cpuLimit": {"2"}, "memoryLimit": {"4Gi"}},
+			payModel: trial,
+			want:     WorkspaceSize{CPULimit: "2", MemoryLimit: "4Gi", VolumeSize: "10Gi"},
+		},
+		{
+			name:      "AbovePayModelCaps",
+			hatchApp:  hatchApp,
+			params:    url.Values{"size": {"large"}},
+			payModel:  trial,
+			wantError: true,
+		},
+		{
+			name:      "AbovePayModelGPUCap",
+			hatchApp:  hatchApp,
+			params:    url.Values{"gpuCount": {"1"}},
+			payModel:  trial,
+			wantError: true,
+		},
+		{
+			name:      "GPUOnECS",
+			hatchApp:  hatchApp,
+			params:    url.Values{"size": {"gpu"}},
+			payModel:  ecs,
+			wantError: true,
+		},
+		{
+			name:     "QuantitiesOnECS",
+			hatchApp: hatchApp,
+			params:   url.Values{"cpuLimit": {"500m"}, "memoryLimit": {"1.5Gi"}},
+			payModel: ecs,
+			want:     WorkspaceSize{CPULimit: "500m", MemoryLimit: "1.5Gi", VolumeSize: "10Gi"},
+		},
+		{
+			name:      "MemoryBelowOneMiBOnECS",
+			hatchApp:  Container{Name: "Tiny", CPULimit: "1", MemoryLimit: "512Ki"},
+			params:    url.Values{},
+			payModel:  ecs,
+			wantError: true,
+		},
+	}
+	for _, testcase := range testCases {
+		t.Logf("Testing resolveWorkspaceSize when %s", testcase.name)
+		got, err := resolveWorkspaceSize(Config(), testcase.hatchApp, testcase.params, testcase.payModel)
+		if testcase.wantError {
+			if err == nil {
+				t.Errorf("\nassertion error while testing `%s`: \nWant:an error\nGot:%+v", testcase.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("\nassertion error while testing `%s`: unexpected error: %v", testcase.name, err)
+			continue
+		}
+		if got != testcase.want {
+			t.Errorf("\nassertion error while testing `%s`: \nWant:%+v\nGot:%+v", testcase.name, testcase.want, got)
+		}
+	}
+}
+
+func Test_EcsQuantities(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	testCases := []struct {
+		quantity  string
+		convert   func(string) (string, error)
+		want      string
+		wantError bool
+	}{
+		{quantity: "2Gi", convert: mem, want: "2048"},
+		{quantity: "1.5Gi", convert: mem, want: "1536"},
+		{quantity: "0.5Gi", convert: mem, want: "512"},
+		{quantity: "2G", convert: mem, want: "1907"},
+		{quantity: "536870912", convert: mem, want: "512"},
+		{quantity: "512Ki", convert: mem, wantError: true},
+		{quantity: "lots", convert: mem, wantError: true},
+		{quantity: "1", convert: cpu, want: "1024"},
+		{quantity: "1.5", convert: cpu, want: "1536"},
+		{quantity: "500m", convert: cpu, want: "512"},
+		{quantity: "lots", convert: cpu, wantError: true},
+	}
+	for _, testcase := range testCases {
+		got, err := testcase.convert(testcase.quantity)
+		if testcase.wantError {
+			if err == nil {
+				t.Errorf("\nassertion error while testing `%s`: \nWant:an error\nGot:%s", testcase.quantity, got)
+			}
+			continue
+		}
+		if err != nil || got != testcase.want {
+			t.Errorf("\nassertion error while testing `%s`: \nWant:%s\nGot:%s (%v)", testcase.quantity, testcase.want, got, err)
+		}
+	}
+}
+
+func Test_BuildPodWithWorkspaceSize(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	loadRenderTestConfig(t)
+
+	hash := ""
+	for id, container := range Config().ContainersMap {
+		if container.Name == "R Studio" {
+			hash = id
+		}
+	}
+	size := WorkspaceSize{CPULimit: "4", MemoryLimit: "16Gi", GPUCount: 2, VolumeSize: "50Gi"}
+	hatchApp := workspaceContainer(Config(), Config().ContainersMap[hash], size, nil)
+	pod, err := buildPod(Config(), &hatchApp, "frickjack", "", nil)
+	if err != nil {
+		t.Fatalf("failed to build a pod - %v", err)
+	}
+	var resources = pod.Spec.Containers[0].Resources
+	for _, container := range pod.Spec.Containers {
+		if container.Name == "hatchery-container" {
+			resources = container.Resources
+		}
+	}
+	if got := resources.Limits["nvidia.com/gpu"]; got.Value() != 2 {
+		t.Errorf("\nassertion error while testing `buildPod` GPU count: \nWant:%d\nGot:%v", 2, got.String())
+	}
+	if got := resources.Limits.Memory(); got.String() != "16Gi" {
+		t.Errorf("\nassertion error while testing `buildPod` memory: \nWant:%s\nGot:%s", "16Gi", got.String())
+	}
+
+	pvc := buildPVC("frickjack", "", pod, workspaceUserVolume(Config(), &hatchApp, size, nil))
+	if got := pvc.Spec.Resources.Requests.Storage(); got.String() != "50Gi" {
+		t.Errorf("\nassertion error while testing `buildPVC` size: \nWant:%s\nGot:%s", "50Gi", got.String())
+	}
+	pvc = buildPVC("frickjack", "", pod, workspaceUserVolume(Config(), &hatchApp, WorkspaceSize{}, nil))
+	if got := pvc.Spec.Resources.Requests.Storage(); got.String() != Config().Config.UserVolumeSize {
+		t.Errorf("\nassertion error while testing `buildPVC` default size: \nWant:%s\nGot:%s", Config().Config.UserVolumeSize, got.String())
+	}
+}
+
+func Test_BuildPodGPUs(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	loadRenderTestConfig(t)
+
+	testCases := []struct {
+		name             string
+		hatchApp         Container
+		wantResource     k8sv1.ResourceName
+		wantModel        string
+		wantToleration   string
+		wantTolerations  int
+		wantNodeSelector int
+	}{
+		{
+			name:             "Default",
+			hatchApp:         Container{Name: "GPU", Image: "pytorch", CPULimit: "1", MemoryLimit: "1Gi", GPU: true},
+			wantResource:     "nvidia.com/gpu",
+			wantTolerations:  2,
+			wantNodeSelector: 1,
+		},
+		{
+			name:             "MIGSliceOnModel",
+			hatchApp:         Container{Name: "MIG", Image: "pytorch", CPULimit: "1", MemoryLimit: "1Gi", GPU: true, GPUResourceName: "nvidia.com/mig-1g.5gb", GPUModel: "NVIDIA-A100-SXM4-40GB"},
+			wantResource:     "nvidia.com/mig-1g.5gb",
+			wantModel:        "NVIDIA-A100-SXM4-40GB",
+			wantTolerations:  2,
+			wantNodeSelector: 2,
+		},
+		{
+			name:             "AMD",
+			hatchApp:         Container{Name: "ROCm", Image: "rocm", CPULimit: "1", MemoryLimit: "1Gi", GPU: true, GPUCount: 4, GPUResourceName: "amd.com/gpu"},
+			wantResource:     "amd.com/gpu",
+			wantToleration:   "amd.com/gpu",
+			wantTolerations:  3,
+			wantNodeSelector: 1,
+		},
+	}
+	for _, testcase := range testCases {
+		t.Logf("Testing buildPod GPUs when %s", testcase.name)
+		pod, err := buildPod(Config(), &testcase.hatchApp, "frickjack", "", nil)
+		if err != nil {
+			t.Errorf("failed to build a pod - %v", err)
+			continue
+		}
+		resources := pod.Spec.Containers[1].Resources
+		if got := resources.Limits[testcase.wantResource]; got.Value() != int64(containerGPUCount(&testcase.hatchApp)) {
+			t.Errorf("\nassertion error while testing `%s` GPU resource: \nWant:%d %s\nGot:%v", testcase.name, containerGPUCount(&testcase.hatchApp), testcase.wantResource, resources.Limits)
+		}
+		if got := pod.Spec.NodeSelector[defaultGPUModelNodeLabel]; got != testcase.wantModel || len(pod.Spec.NodeSelector) != testcase.wantNodeSelector {
+			t.Errorf("\nassertion error while testing `%s` node selector: \nWant:%s\nGot:%v", testcase.name, testcase.wantModel, pod.Spec.NodeSelector)
+		}
+		if len(pod.Spec.Tolerations) != testcase.wantTolerations || (testcase.wantToleration != "" && pod.Spec.Tolerations[testcase.wantTolerations-1].Key != testcase.wantToleration) {
+			t.Errorf("\nassertion error while testing `%s` tolerations: \nGot:%v", testcase.name, pod.Spec.Tolerations)
+		}
+	}
+}
@@ -0,0 +1,681 @@
+package hatchery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	k8sv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+var volumeSnapshotResource = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshots"}
+
+// Records why a snapshot was taken: on demand, on schedule, or before a restore replaced the volume
+const snapshotTypeAnnotation = "gen3snapshottype"
+
+// Records on a snapshot that the volume it was taken of is replaced by a volume restored from
+// it. The replaced volume is deleted, so the pending restore can't be recorded on the volume
+const restorePendingAnnotation = "gen3restorepending"
+
+const (
+	snapshotTypeOnDemand   = "on-demand"
+	snapshotTypeScheduled  = "scheduled"
+	snapshotTypePreRestore = "pre-restore"
+)
+
+// UserVolumeSnapshot is a snapshot of a user volume, or a backup of the EFS file system of ECS workspaces
+type UserVolumeSnapshot struct {
+	Name         string    `json:"name"`
+	WorkspaceId  string    `json:"workspaceId"`
+	ClaimName    string    `json:"claimName,omitempty"`
+	Type         string    `json:"type"`
+	CreationTime time.Time `json:"creationTime"`
+	ReadyToUse   bool      `json:"readyToUse"`
+	RestoreSize  string    `json:"restoreSize,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	// the volume is restored from the snapshot once the replaced volume is deleted
+	RestorePending bool `json:"restorePending,omitempty"`
+}
+
+var errSnapshotsDisabled = errors.New("snapshots are not enabled")
+
+// errSnapshotNotFound is returned for snapshots that do not exist, or belong to another user
+var errSnapshotNotFound = errors.New("the snapshot was not found")
+
+// errWorkspaceRunning is returned when restoring the volume of a running workspace
+var errWorkspaceRunning = errors.New("the workspace must be terminated before restoring its volume")
+
+// getSnapshotClient returns a client for the VolumeSnapshots of the cluster the user's workspaces run in
+var getSnapshotClient = func(ctx context.Context, userName string, payModel *PayModel) (dynamic.Interface, error) {
+	if payModel != nil && !payModel.Local {
+		config, err := newEKSRestConfig(ctx, *payModel)
+		if err != nil {
+			return nil, err
+		}
+		return dynamic.NewForConfig(config)
+	}
+	return getLocalDynamicClient()
+}
+
+// buildVolumeSnapshot builds a snapshot of the claim, named after the claim's workspace and the time
+func buildVolumeSnapshot(claim *k8sv1.PersistentVolumeClaim, snapshotType string, now time.Time) *unstructured.Unstructured {
+	ref, _ := workspaceRefFromAnnotations(claim.Annotations)
+	annotations := map[string]interface{}{snapshotTypeAnnotation: snapshotType}
+	for key, value := range claim.Annotations {
+		if key != lastLaunchAnnotation {
+			annotations[key] = value
+		}
+	}
+	labels := map[string]interface{}{}
+	for key, value := range claim.Labels {
+		labels[key] = value
+	}
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{"persistentVolumeClaimName": claim.Name},
+	}
+	if Config().Config.Snapshots.VolumeSnapshotClass != "" {
+		spec["volumeSnapshotClassName"] = Config().Config.Snapshots.VolumeSnapshotClass
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshot",
+		"metadata": map[string]interface{}{
+			"name":        workspaceToResourceName(ref.UserName, ref.WorkspaceId, "snapshot") + "-" + now.UTC().Format("20060102150405"),
+			"namespace":   claim.Namespace,
+			"annotations": annotations,
+			"labels":      labels,
+		},
+		"spec": spec,
+	}}
+}
+
+func describeVolumeSnapshot(snapshot *unstructured.Unstructured) UserVolumeSnapshot {
+	ref, _ := workspaceRefFromAnnotations(snapshot.GetAnnotations())
+	result := UserVolumeSnapshot{
+		Name:           snapshot.GetName(),
+		WorkspaceId:    ref.WorkspaceId,
+		Type:           snapshot.GetAnnotations()[snapshotTypeAnnotation],
+		CreationTime:   snapshot.GetCreationTimestamp().UTC(),
+		RestorePending: snapshot.GetAnnotations()[restorePendingAnnotation] != "",
+	}
+	result.ClaimName, _, _ = unstructured.NestedString(snapshot.Object, "spec", "source", "persistentVolumeClaimName")
+	result.ReadyToUse, _, _ = unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+	result.RestoreSize, _, _ = unstructured.NestedString(snapshot.Object, "status", "restoreSize")
+	result.Error, _, _ = unstructured.NestedString(snapshot.Object, "status", "error", "message")
+	// the time the storage system took the snapshot
+	if creationTime, _, _ := unstructured.NestedString(snapshot.Object, "status", "creationTime"); creationTime != "" {
+		if parsed, err := time.Parse(time.RFC3339, creationTime); err == nil {
+			result.CreationTime = parsed.UTC()
+		}
+	}
+	return result
+}
+
+// listVolumeSnapshots returns the snapshots of the user's volumes, by workspace and from the oldest
+func listVolumeSnapshots(ctx context.Context, client dynamic.Interface, userName string) ([]UserVolumeSnapshot, error) {
+	snapshots, err := client.Resource(volumeSnapshotResource).Namespace(Config().Config.UserNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	result := []UserVolumeSnapshot{}
+	for i := range snapshots.Items {
+		ref, ok := workspaceRefFromAnnotations(snapshots.Items[i].GetAnnotations())
+		if !ok || (userName != "" && ref.UserName != userName) {
+			continue
+		}
+		result = append(result, describeVolumeSnapshot(&snapshots.Items[i]))
+	}
+	sortVolumeSnapshots(result)
+	return result, nil
+}
+
+func sortVolumeSnapshots(snapshots []UserVolumeSnapshot) {
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if snapshots[i].WorkspaceId != snapshots[j].WorkspaceId {
+			return snapshots[i].WorkspaceId < snapshots[j].WorkspaceId
+		}
+		// snapshots without a creation time were just requested
+		if snapshots[i].CreationTime.IsZero() != snapshots[j].CreationTime.IsZero() {
+			return snapshots[j].CreationTime.IsZero()
+		}
+		if !snapshots[i].CreationTime.Equal(snapshots[j].CreationTime) {
+			return snapshots[i].CreationTime.Before(snapshots[j].CreationTime)
+		}
+		// the names end with the time the snapshots were requested
+		return snapshots[i].Name < snapshots[j].Name
+	})
+}
+
+// getUserVolumeSnapshot returns the user's snapshot with this name
+func getUserVolumeSnapshot(ctx context.Context, client dynamic.Interface, userName string, name string) (*unstructured.Unstructured, error) {
+	snapshot, err := client.Resource(volumeSnapshotResource).Namespace(Config().Config.UserNamespace).Get(ctx, name, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return nil, errSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ref, ok := workspaceRefFromAnnotations(snapshot.GetAnnotations()); !ok || ref.UserName != userName {
+		return nil, errSnapshotNotFound
+	}
+	return snapshot, nil
+}
+
+// createVolumeSnapshot takes a snapshot of the user volume of the workspace. On-demand snapshots are
+// limited to `max-snapshots` per volume
+func createVolumeSnapshot(ctx context.Context, podClient corev1.CoreV1Interface, client dynamic.Interface, userName string, workspaceId string, snapshotType string, now time.Time) (*UserVolumeSnapshot, error) {
+	claimName := workspaceToResourceName(userName, workspaceId, "claim")
+	claim, err := podClient.PersistentVolumeClaims(Config().Config.UserNamespace).Get(ctx, claimName, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return nil, errUserVolumeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshotType == snapshotTypeOnDemand {
+		snapshots, err := listVolumeSnapshots(ctx, client, userName)
+		if err != nil {
+			return nil, err
+		}
+		count := 0
+		for _, snapshot := range snapshots {
+			if snapshot.ClaimName == claimName && snapshot.Type == snapshotTypeOnDemand {
+				count++
+			}
+		}
+		if max := Config().Config.Snapshots.MaxSnapshots; count >= max {
+			return nil, fmt.Errorf("the volume already has %d snapshots: delete one before taking a new one", max)
+		}
+	}
+
+	snapshot, err := client.Resource(volumeSnapshotResource).Namespace(claim.Namespace).Create(ctx, buildVolumeSnapshot(claim, snapshotType, now), metav1.CreateOptions{})
+	if err != nil {
+		Config().Logger.Printf("Failed to create a snapshot of PVC %s for user %s. Error: %v", claimName, userName, err)
+		return nil, err
+	}
+	Config().Logger.Printf("Created %s VolumeSnapshot %s of PVC %s for user %s", snapshotType, snapshot.GetName(), claimName, userName)
+	result := describeVolumeSnapshot(snapshot)
+	return &result, nil
+}
+
+// deleteVolumeSnapshot deletes the user's snapshot with this name
+func deleteVolumeSnapshot(ctx context.Context, client dynamic.Interface, userName string, name string) error {
+	if _, err := getUserVolumeSnapshot(ctx, client, userName, name); err != nil {
+		return err
+	}
+	err := client.Resource(volumeSnapshotResource).Namespace(Config().Config.UserNamespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return err
+	}
+	Config().Logger.Printf("Deleted VolumeSnapshot %s for user %s", name, userName)
+	return nil
+}
+
+func snapshotDataSource(snapshotName string) *k8sv1.TypedLocalObjectReference {
+	apiGroup := volumeSnapshotResource.Group
+	return &k8sv1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     "VolumeSnapshot",
+		Name:     snapshotName,
+	}
+}
+
+// errUserVolumeDeleting is returned when launching a workspace whose volume is being replaced
+var errUserVolumeDeleting = errors.New("the user volume is being replaced by a restored volume: try again in a moment")
+
+// restoreVolumeSnapshot restores the user volume the snapshot was taken of. An existing volume is
+// snapshotted and deleted, and replaced by a volume restored from the snapshot once it is
+// deleted: right away if the deletion completes, otherwise at the next launch of the workspace.
+// Returns the restored volume, or nil if the restore is pending
+func restoreVolumeSnapshot(ctx context.Context, podClient corev1.CoreV1Interface, client dynamic.Interface, userName string, name string, payModel *PayModel, now time.Time) (*k8sv1.PersistentVolumeClaim, error) {
+	snapshot, err := getUserVolumeSnapshot(ctx, client, userName, name)
+	if err != nil {
+		return nil, err
+	}
+	described := describeVolumeSnapshot(snapshot)
+	if !described.ReadyToUse {
+		return nil, fmt.Errorf("the snapshot %s is not ready to use", name)
+	}
+	ref, _ := workspaceRefFromAnnotations(snapshot.GetAnnotations())
+	namespace := Config().Config.UserNamespace
+
+	// the volume can't be replaced while a workspace uses it
+	podName := workspaceToResourceName(userName, ref.WorkspaceId, "pod")
+	if _, err := podClient.Pods(namespace).Get(ctx, podName, metav1.GetOptions{}); err == nil {
+		return nil, errWorkspaceRunning
+	} else if !k8sErrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	claims := podClient.PersistentVolumeClaims(namespace)
+	claim, err := claims.Get(ctx, described.ClaimName, metav1.GetOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return nil, err
+	}
+	replaced := err == nil && claim.DeletionTimestamp == nil
+	// the current data can be restored too, until the next restore
+	if replaced {
+		preRestore, err := createVolumeSnapshot(ctx, podClient, client, userName, ref.WorkspaceId, snapshotTypePreRestore, now)
+		if err != nil {
+			return nil, err
+		}
+		snapshots, err := listVolumeSnapshots(ctx, client, userName)
+		if err != nil {
+			return nil, err
+		}
+		for _, snapshot := range snapshots {
+			if snapshot.ClaimName == claim.Name && snapshot.Type == snapshotTypePreRestore && snapshot.Name != preRestore.Name && snapshot.Name != name {
+				if err := deleteVolumeSnapshot(ctx, client, userName, snapshot.Name); err != nil {
+					Config().Logger.Printf("Failed to delete VolumeSnapshot %s for user %s. Error: %v", snapshot.Name, userName, err)
+				}
+			}
+		}
+	}
+
+	// recorded before the volume is deleted, so the restore happens even if it can't be created now
+	if err := setPendingRestore(ctx, client, userName, described.ClaimName, name); err != nil {
+		return nil, err
+	}
+	if replaced {
+		Config().Logger.Printf("Deleting PVC %s to restore it from VolumeSnapshot %s for user %s", claim.Name, name, userName)
+		if err := claims.Delete(ctx, claim.Name, metav1.DeleteOptions{}); err != nil && !k8sErrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	// the annotations of the claim were copied to the snapshot
+	source := &k8sv1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}, Labels: snapshot.GetLabels()}}
+	for key, value := range snapshot.GetAnnotations() {
+		if key != snapshotTypeAnnotation && key != restorePendingAnnotation {
+			source.Annotations[key] = value
+		}
+	}
+	hatchApp, _ := containerByName(source.Annotations[containerNameAnnotation])
+	pvc := buildPVC(userName, ref.WorkspaceId, source, userVolumeConfig(Config(), hatchApp, payModel))
+	restored, err := createRestoredClaim(ctx, claims, client, pvc, described)
+	if k8sErrors.IsAlreadyExists(err) {
+		Config().Logger.Printf("PVC %s will be restored from VolumeSnapshot %s at the next launch for user %s", described.ClaimName, name, userName)
+		return nil, nil
+	}
+	return restored, err
+}
+
+// setPendingRestore records the snapshot the claim is restored from, replacing the restores
+// requested before
+func setPendingRestore(ctx context.Context, client dynamic.Interface, userName string, claimName string, name string) error {
+	snapshots, err := listVolumeSnapshots(ctx, client, userName)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.ClaimName != claimName || (snapshot.Name != name && !snapshot.RestorePending) {
+			continue
+		}
+		if err := updateRestorePending(ctx, client, snapshot.Name, snapshot.Name == name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func updateRestorePending(ctx context.Context, client dynamic.Interface, name string, pending bool) error {
+	snapshots := client.Resource(volumeSnapshotResource).Namespace(Config().Config.UserNamespace)
+	snapshot, err := snapshots.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	annotations := snapshot.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if pending {
+		annotations[restorePendingAnnotation] = "true"
+	} else {
+		delete(annotations, restorePendingAnnotation)
+	}
+	snapshot.SetAnnotations(annotations)
+	_, err = snapshots.Update(ctx, snapshot, metav1.UpdateOptions{})
+	return err
+}
+
+// createRestoredClaim creates the claim restored from the snapshot, large enough for the
+// snapshot, and clears the pending restore
+func createRestoredClaim(ctx context.Context, claims corev1.PersistentVolumeClaimInterface, client dynamic.Interface, pvc *k8sv1.PersistentVolumeClaim, snapshot UserVolumeSnapshot) (*k8sv1.PersistentVolumeClaim, error) {
+	pvc.Spec.DataSource = snapshotDataSource(snapshot.Name)
+	if restoreSize, err := resource.ParseQuantity(snapshot.RestoreSize); err == nil && restoreSize.Cmp(pvc.Spec.Resources.Requests[k8sv1.ResourceStorage]) > 0 {
+		pvc.Spec.Resources.Requests[k8sv1.ResourceStorage] = restoreSize
+	}
+	restored, err := claims.Create(ctx, pvc, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	Config().Logger.Printf("Restored PVC %s from VolumeSnapshot %s", pvc.Name, snapshot.Name)
+	if err := updateRestorePending(ctx, client, snapshot.Name, false); err != nil {
+		// the claim exists, so the restore is not repeated unless the claim is deleted
+		Config().Logger.Printf("Failed to clear the pending restore of VolumeSnapshot %s. Error: %v", snapshot.Name, err)
+	}
+	return restored, nil
+}
+
+// restorePendingSnapshot creates the claim from the snapshot a restore was requested from,
+// if any. Returns whether the claim was created
+func restorePendingSnapshot(ctx context.Context, claims corev1.PersistentVolumeClaimInterface, userName string, payModel *PayModel, pvc *k8sv1.PersistentVolumeClaim) (bool, error) {
+	if !Config().Config.Snapshots.Enabled {
+		return false, nil
+	}
+	client, err := getSnapshotClient(ctx, userName, payModel)
+	if err != nil {
+		return false, err
+	}
+	snapshots, err := listVolumeSnapshots(ctx, client, userName)
+	if err != nil {
+		return false, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.ClaimName == pvc.Name && snapshot.RestorePending {
+			_, err := createRestoredClaim(ctx, claims, client, pvc, snapshot)
+			return err == nil, err
+		}
+	}
+	return false, nil
+}
+
+// SnapshotScheduler periodically snapshots the user volumes of the local cluster
+type SnapshotScheduler struct {
+	interval time.Duration
+
+	// Control channels
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// NewSnapshotScheduler creates a scheduler that snapshots the user volumes every interval
+func NewSnapshotScheduler(interval time.Duration) *SnapshotScheduler {
+	return &SnapshotScheduler{
+		interval: interval,
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+// Start runs the snapshot loop until Stop is called or ctx is cancelled
+func (ss *SnapshotScheduler) Start(ctx context.Context) {
+	log.Printf("Starting snapshot scheduler with interval: %s", ss.interval)
+	defer close(ss.doneCh)
+
+	ticker := time.NewTicker(ss.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ss.stopCh:
+			log.Println("Snapshot scheduler stopped")
+			return
+		case <-ctx.Done():
+			log.Println("Snapshot scheduler stopped")
+			return
+		case <-ticker.C:
+			podClient := getLocalPodClient()
+			client, err := getLocalDynamicClient()
+			if podClient == nil || err != nil {
+				Config().Logger.Printf("Snapshot scheduler: unable to get the local clients: %v", err)
+				continue
+			}
+			snapshotUserVolumes(ctx, podClient, client, time.Now())
+		}
+	}
+}
+
+// Stop gracefully shuts down the scheduler
+func (ss *SnapshotScheduler) Stop() {
+	close(ss.stopCh)
+	<-ss.doneCh
+}
+
+// snapshotUserVolumes takes a scheduled snapshot of the user volumes, except those that were not
+// used since their last scheduled snapshot, and deletes the scheduled snapshots beyond the
+// `schedule-retention`. Returns the names of the claims that were snapshotted
+func snapshotUserVolumes(ctx context.Context, podClient corev1.CoreV1Interface, client dynamic.Interface, now time.Time) []string {
+	namespace := Config().Config.UserNamespace
+	claims, err := podClient.PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		Config().Logger.Printf("Snapshot scheduler: unable to list the user volumes: %v", err)
+		return nil
+	}
+	usedClaims, err := listUsedClaims(ctx, podClient, namespace)
+	if err != nil {
+		Config().Logger.Printf("Snapshot scheduler: unable to list the workspaces: %v", err)
+		return nil
+	}
+	snapshots, err := listVolumeSnapshots(ctx, client, "")
+	if err != nil {
+		Config().Logger.Printf("Snapshot scheduler: unable to list the snapshots: %v", err)
+		return nil
+	}
+	scheduled := map[string][]UserVolumeSnapshot{}
+	for _, snapshot := range snapshots {
+		if snapshot.Type == snapshotTypeScheduled {
+			scheduled[snapshot.ClaimName] = append(scheduled[snapshot.ClaimName], snapshot)
+		}
+	}
+
+	snapshotted := []string{}
+	for _, claim := range claims.Items {
+		ref, ok := workspaceRefFromAnnotations(claim.Annotations)
+		if !ok {
+			continue
+		}
+		claimSnapshots := scheduled[claim.Name]
+		if len(claimSnapshots) > 0 && !usedClaims[claim.Name] {
+			lastLaunch, err := time.Parse(time.RFC3339, claim.Annotations[lastLaunchAnnotation])
+			if err == nil && claimSnapshots[len(claimSnapshots)-1].CreationTime.After(lastLaunch) {
+				continue
+			}
+		}
+		snapshot, err := createVolumeSnapshot(ctx, podClient, client, ref.UserName, ref.WorkspaceId, snapshotTypeScheduled, now)
+		if err != nil {
+			Config().Logger.Printf("Snapshot scheduler: unable to snapshot PVC %s: %v", claim.Name, err)
+			continue
+		}
+		snapshotted = append(snapshotted, claim.Name)
+
+		claimSnapshots = append(claimSnapshots, *snapshot)
+		for len(claimSnapshots) > Config().Config.Snapshots.ScheduleRetention {
+			if err := deleteVolumeSnapshot(ctx, client, ref.UserName, claimSnapshots[0].Name); err != nil {
+				Config().Logger.Printf("Snapshot scheduler: unable to delete VolumeSnapshot %s: %v", claimSnapshots[0].Name, err)
+			}
+			claimSnapshots = claimSnapshots[1:]
+		}
+	}
+	return snapshotted
+}
+
+// getEcsStorageCreds returns the credentials of the AWS account of the ECS pay model
+var getEcsStorageCreds = func(payModel *PayModel) *CREDS {
+	roleARN := "arn:aws:iam::" + payModel.AWSAccountId + ":role/csoc_adminvm"
+	sess := session.Must(session.NewSession(&aws.Config{
+		// TODO: Make this configurable
+		Region: aws.String("us-east-1"),
+	}))
+	svc := NewSVC(sess, roleARN)
+	return &svc
+}
+
+// snapshotRequest holds what the snapshot handlers need about the current user
+type snapshotRequest struct {
+	userName  string
+	payModel  *PayModel
+	podClient corev1.CoreV1Interface
+	client    dynamic.Interface
+}
+
+// newSnapshotRequest returns the user's pay model, and the clients for the user's volumes unless
+// the pay model is an ECS one
+func newSnapshotRequest(r *http.Request) (*snapshotRequest, int, error) {
+	if !Config().Config.Snapshots.Enabled {
+		return nil, http.StatusNotFound, errSnapshotsDisabled
+	}
+	request := &snapshotRequest{userName: getCurrentUserName(r)}
+	payModel, err := getCurrentPayModel(request.userName)
+	if err != nil {
+		Config().Logger.Printf("error when getting the pay model of user %s: %v", request.userName, err)
+	}
+	request.payModel = payModel
+	if payModel != nil && payModel.Ecs {
+		return request, 0, nil
+	}
+	request.podClient, err = getUserVolumeClient(r.Context(), request.userName, payModel)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	request.client, err = getSnapshotClient(r.Context(), request.userName, payModel)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return request, 0, nil
+}
+
+func snapshotErrorStatus(err error) int {
+	switch err {
+	case errUserVolumeNotFound, errSnapshotNotFound:
+		return http.StatusNotFound
+	case errWorkspaceRunning:
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+func writeSnapshotResponse(w http.ResponseWriter, result interface{}) {
+	out, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(out))
+}
+
+// storageSnapshots lists the snapshots of the user's volumes, and takes one on POST
+func storageSnapshots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	request, status, err := newSnapshotRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	ecs := request.payModel != nil && request.payModel.Ecs
+
+	if r.Method == "GET" {
+		var result []UserVolumeSnapshot
+		if ecs {
+			result, err = getEcsStorageCreds(request.payModel).listEFSBackups(request.userName)
+		} else {
+			result, err = listVolumeSnapshots(r.Context(), request.client, request.userName)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeSnapshotResponse(w, result)
+		return
+	}
+
+	workspaceId, err := getWorkspaceId(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var result *UserVolumeSnapshot
+	if ecs {
+		result, err = getEcsStorageCreds(request.payModel).backupEFSFileSystem(request.userName, request.payModel.AWSAccountId)
+	} else {
+		result, err = createVolumeSnapshot(r.Context(), request.podClient, request.client, request.userName, workspaceId, snapshotTypeOnDemand, time.Now())
+	}
+	if err != nil {
+		http.Error(w, err.Error(), snapshotErrorStatus(err))
+		return
+	}
+	writeSnapshotResponse(w, result)
+}
+
+func deleteStorageSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "Missing 'name' argument", http.StatusBadRequest)
+		return
+	}
+	request, status, err := newSnapshotRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if request.payModel != nil && request.payModel.Ecs {
+		err = getEcsStorageCreds(request.payModel).deleteEFSBackup(request.userName, name)
+	} else {
+		err = deleteVolumeSnapshot(r.Context(), request.client, request.userName, name)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), snapshotErrorStatus(err))
+		return
+	}
+	fmt.Fprintf(w, "Deleted snapshot %s", name)
+}
+
+func restoreStorageSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "Missing 'name' argument", http.StatusBadRequest)
+		return
+	}
+	request, status, err := newSnapshotRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if request.payModel != nil && request.payModel.Ecs {
+		err = getEcsStorageCreds(request.payModel).restoreEFSBackup(request.userName, request.payModel.AWSAccountId, name)
+		if err != nil {
+			http.Error(w, err.Error(), snapshotErrorStatus(err))
+			return
+		}
+		fmt.Fprintf(w, "Restoring snapshot %s: the restored file system is used from the next launch after the restore completes", name)
+		return
+	}
+
+	claim, err := restoreVolumeSnapshot(r.Context(), request.podClient, request.client, request.userName, name, request.payModel, time.Now())
+	if err != nil {
+		Config().Logger.Printf("Failed to restore VolumeSnapshot %s for user %s: %v", name, request.userName, err)
+		http.Error(w, err.Error(), snapshotErrorStatus(err))
+		return
+	}
+	if claim != nil {
+		fmt.Fprintf(w, "Restored snapshot %s into %s", name, claim.Name)
+		return
+	}
+	fmt.Fprintf(w, "Snapshot %s will be restored at the next launch", name)
+}
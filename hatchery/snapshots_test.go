@@ -0,0 +1,254 @@
+package hatchery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func snapshotTestConfig(snapshots SnapshotConfig) func(config *FullHatcheryConfig) {
+	return func(config *FullHatcheryConfig) {
+		userVolumeTestConfig(UserVolumeConfig{}, nil, Container{Name: "Jupyter"})(config)
+		config.Config.Snapshots = snapshots
+	}
+}
+
+func testVolumeSnapshot(userName string, workspaceId string, name string, snapshotType string, creationTime time.Time) *unstructured.Unstructured {
+	annotations := map[string]interface{}{"gen3username": userName, snapshotTypeAnnotation: snapshotType, containerNameAnnotation: "Jupyter"}
+	if workspaceId != "" {
+		annotations[workspaceIdAnnotation] = workspaceId
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshot",
+		"metadata": map[string]interface{}{
+			"name":              name,
+			"namespace":         "jupyter-pods",
+			"annotations":       annotations,
+			"creationTimestamp": creationTime.Format(time.RFC3339),
+		},
+		"spec": map[string]interface{}{
+			"source": map[string]interface{}{"persistentVolumeClaimName": workspaceToResourceName(userName, workspaceId, "claim")},
+		},
+		"status": map[string]interface{}{"readyToUse": true, "restoreSize": "20Gi"},
+	}}
+}
+
+func newSnapshotClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		volumeSnapshotResource: "VolumeSnapshotList",
+	}, objects...)
+}
+
+func Test_CreateVolumeSnapshot(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	withTestConfig(t, snapshotTestConfig(SnapshotConfig{Enabled: true, VolumeSnapshotClass: "csi-aws-vsc", MaxSnapshots: 1}))
+
+	ctx := context.Background()
+	podClient := fake.NewSimpleClientset(testUserVolumeClaim("frickjack", "", "Jupyter", "10Gi", "2026-10-01T12:00:00Z")).CoreV1()
+	client := newSnapshotClient()
+	now := time.Date(2026, 10, 17, 8, 30, 0, 0, time.UTC)
+
+	snapshot, err := createVolumeSnapshot(ctx, podClient, client, "frickjack", "", snapshotTypeOnDemand, now)
+	if err != nil {
+		t.Fatalf("\nassertion error while testing `createVolumeSnapshot`: unexpected error: %v", err)
+	}
+	if snapshot.Name != "snapshot-frickjack-20261017083000" || snapshot.ClaimName != "claim-frickjack" || snapshot.Type != snapshotTypeOnDemand {
+		t.Errorf("\nassertion error while testing `createVolumeSnapshot`: \nWant:%s of %s\nGot:%+v", "snapshot-frickjack-20261017083000", "claim-frickjack", snapshot)
+	}
+	created, err := client.Resource(volumeSnapshotResource).Namespace("jupyter-pods").Get(ctx, snapshot.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("\nassertion error while testing `createVolumeSnapshot`: the VolumeSnapshot was not created: %v", err)
+	}
+	if class, _, _ := unstructured.NestedString(created.Object, "spec", "volumeSnapshotClassName"); class != "csi-aws-vsc" {
+		t.Errorf("\nassertion error while testing `createVolumeSnapshot` class: \nWant:%s\nGot:%s", "csi-aws-vsc", class)
+	}
+	if _, ok := created.GetAnnotations()[lastLaunchAnnotation]; ok {
+		t.Errorf("\nassertion error while testing `createVolumeSnapshot`: the last launch of the volume should not be copied")
+	}
+
+	// on-demand snapshots are limited, scheduled ones are not
+	if _, err := createVolumeSnapshot(ctx, podClient, client, "frickjack", "", snapshotTypeOnDemand, now.Add(time.Hour)); err == nil {
+		t.Errorf("\nassertion error while testing `createVolumeSnapshot` beyond max-snapshots: \nWant:an error\nGot:nil")
+	}
+	if _, err := createVolumeSnapshot(ctx, podClient, client, "frickjack", "", snapshotTypeScheduled, now.Add(time.Hour)); err != nil {
+		t.Errorf("\nassertion error while testing `createVolumeSnapshot` scheduled: unexpected error: %v", err)
+	}
+	if _, err := createVolumeSnapshot(ctx, podClient, client, "frickjack", "rstudio", snapshotTypeOnDemand, now); err != errUserVolumeNotFound {
+		t.Errorf("\nassertion error while testing `createVolumeSnapshot` without a volume: \nWant:%v\nGot:%v", errUserVolumeNotFound, err)
+	}
+}
+
+func Test_ListAndDeleteVolumeSnapshots(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	withTestConfig(t, snapshotTestConfig(SnapshotConfig{Enabled: true}))
+
+	ctx := context.Background()
+	client := newSnapshotClient(
+		testVolumeSnapshot("frickjack", "rstudio", "snapshot-frickjack--rstudio-20261001000000", snapshotTypeOnDemand, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)),
+		testVolumeSnapshot("frickjack", "", "snapshot-frickjack-20261002000000", snapshotTypeScheduled, time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)),
+		testVolumeSnapshot("frickjack", "", "snapshot-frickjack-20261001000000", snapshotTypeOnDemand, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)),
+		testVolumeSnapshot("someone-else", "", "snapshot-someone-else-20261001000000", snapshotTypeOnDemand, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)),
+	)
+
+	snapshots, err := listVolumeSnapshots(ctx, client, "frickjack")
+	if err != nil {
+		t.Fatalf("\nassertion error while testing `listVolumeSnapshots`: unexpected error: %v", err)
+	}
+	want := []string{"snapshot-frickjack-20261001000000", "snapshot-frickjack-20261002000000", "snapshot-frickjack--rstudio-20261001000000"}
+	if len(snapshots) != len(want) {
+		t.Fatalf("\nassertion error while testing `listVolumeSnapshots`: \nWant:%v\nGot:%+v", want, snapshots)
+	}
+	for i, snapshot := range snapshots {
+		if snapshot.Name != want[i] {
+			t.Errorf("\nassertion error while testing `listVolumeSnapshots` order: \nWant:%v\nGot:%+v", want, snapshots)
+			break
+		}
+	}
+
+	if err := deleteVolumeSnapshot(ctx, client, "frickjack", "snapshot-someone-else-20261001000000"); err != errSnapshotNotFound {
+		t.Errorf("\nassertion error while testing `deleteVolumeSnapshot` of another user: \nWant:%v\nGot:%v", errSnapshotNotFound, err)
+	}
+	if err := deleteVolumeSnapshot(ctx, client, "frickjack", "snapshot-frickjack-20261001000000"); err != nil {
+		t.Errorf("\nassertion error while testing `deleteVolumeSnapshot`: unexpected error: %v", err)
+	}
+	if snapshots, _ := listVolumeSnapshots(ctx, client, "frickjack"); len(snapshots) != 2 {
+		t.Errorf("\nassertion error while testing `deleteVolumeSnapshot`: the VolumeSnapshot was not deleted\nGot:%+v", snapshots)
+	}
+}
+
+func Test_RestoreVolumeSnapshot(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	withTestConfig(t, snapshotTestConfig(SnapshotConfig{Enabled: true, MaxSnapshots: 5}))
+
+	ctx := context.Background()
+	runningPod := &k8sv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "hatchery-frickjack", Namespace: "jupyter-pods"}}
+	clientset := fake.NewSimpleClientset(testUserVolumeClaim("frickjack", "", "Jupyter", "10Gi", "2026-10-01T12:00:00Z"), runningPod)
+	podClient := clientset.CoreV1()
+	client := newSnapshotClient(
+		testVolumeSnapshot("frickjack", "", "snapshot-frickjack-20261001000000", snapshotTypeOnDemand, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)),
+		testVolumeSnapshot("frickjack", "rstudio", "snapshot-frickjack--rstudio-20261001000000", snapshotTypeOnDemand, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)),
+	)
+	now := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+
+	if _, err := restoreVolumeSnapshot(ctx, podClient, client, "frickjack", "snapshot-frickjack-20261001000000", nil, now); err != errWorkspaceRunning {
+		t.Errorf("\nassertion error while testing `restoreVolumeSnapshot` of a running workspace: \nWant:%v\nGot:%v", errWorkspaceRunning, err)
+	}
+	if err := podClient.Pods("jupyter-pods").Delete(ctx, runningPod.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete the pod - %v", err)
+	}
+
+	// the existing volume is snapshotted, and replaced by the restored volume
+	claim, err := restoreVolumeSnapshot(ctx, podClient, client, "frickjack", "snapshot-frickjack-20261001000000", nil, now)
+	if err != nil {
+		t.Fatalf("\nassertion error while testing `restoreVolumeSnapshot`: unexpected error: %v", err)
+	}
+	if claim == nil || claim.Spec.DataSource == nil || claim.Spec.DataSource.Name != "snapshot-frickjack-20261001000000" || claim.Spec.DataSource.Kind != "VolumeSnapshot" {
+		t.Errorf("\nassertion error while testing `restoreVolumeSnapshot`: \nWant:%s\nGot:%+v", "snapshot-frickjack-20261001000000", claim)
+	}
+	if _, err := getUserVolumeSnapshot(ctx, client, "frickjack", "snapshot-frickjack-20261017000000"); err != nil {
+		t.Errorf("\nassertion error while testing `restoreVolumeSnapshot`: the volume was not snapshotted before the restore: %v", err)
+	}
+	if snapshot, _ := getUserVolumeSnapshot(ctx, client, "frickjack", "snapshot-frickjack-20261001000000"); describeVolumeSnapshot(snapshot).RestorePending {
+		t.Errorf("\nassertion error while testing `restoreVolumeSnapshot`: the restore is still pending\nGot:%v", snapshot.GetAnnotations())
+	}
+
+	// a volume that is still being deleted is restored at the next launch
+	deletionTime := metav1.NewTime(now)
+	claim.DeletionTimestamp = &deletionTime
+	if _, err := podClient.PersistentVolumeClaims("jupyter-pods").Update(ctx, claim, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update the PVC - %v", err)
+	}
+	claim, err = restoreVolumeSnapshot(ctx, podClient, client, "frickjack", "snapshot-frickjack-20261001000000", nil, now)
+	if err != nil || claim != nil {
+		t.Fatalf("\nassertion error while testing `restoreVolumeSnapshot` of a volume being deleted: \nWant:%s\nGot:%+v, %v", "a pending restore", claim, err)
+	}
+	pod := &k8sv1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"gen3username": "frickjack"}}}
+	if err := createUserVolume(ctx, podClient, "frickjack", "", pod, UserVolumeConfig{Size: "10Gi"}, nil, now.Add(time.Hour)); err != errUserVolumeDeleting {
+		t.Errorf("\nassertion error while testing `createUserVolume` of a volume being deleted: \nWant:%v\nGot:%v", errUserVolumeDeleting, err)
+	}
+	if err := podClient.PersistentVolumeClaims("jupyter-pods").Delete(ctx, "claim-frickjack", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete the PVC - %v", err)
+	}
+	originalGetSnapshotClient := getSnapshotClient
+	defer func() { getSnapshotClient = originalGetSnapshotClient }()
+	getSnapshotClient = func(ctx context.Context, userName string, payModel *PayModel) (dynamic.Interface, error) {
+		return client, nil
+	}
+	if err := createUserVolume(ctx, podClient, "frickjack", "", pod, UserVolumeConfig{Size: "10Gi"}, nil, now.Add(time.Hour)); err != nil {
+		t.Fatalf("\nassertion error while testing `createUserVolume` with a restore: unexpected error: %v", err)
+	}
+	restored, _ := podClient.PersistentVolumeClaims("jupyter-pods").Get(ctx, "claim-frickjack", metav1.GetOptions{})
+	if restored.Spec.DataSource == nil || restored.Spec.DataSource.Name != "snapshot-frickjack-20261001000000" || restored.Annotations[lastLaunchAnnotation] == "" {
+		t.Errorf("\nassertion error while testing `createUserVolume` with a restore: \nWant:%s\nGot:%+v", "snapshot-frickjack-20261001000000", restored)
+	}
+	if snapshot, _ := getUserVolumeSnapshot(ctx, client, "frickjack", "snapshot-frickjack-20261001000000"); describeVolumeSnapshot(snapshot).RestorePending {
+		t.Errorf("\nassertion error while testing `createUserVolume` with a restore: the restore is still pending\nGot:%v", snapshot.GetAnnotations())
+	}
+
+	// a volume that no longer exists is restored right away, large enough for the snapshot
+	claim, err = restoreVolumeSnapshot(ctx, podClient, client, "frickjack", "snapshot-frickjack--rstudio-20261001000000", nil, now)
+	if err != nil {
+		t.Fatalf("\nassertion error while testing `restoreVolumeSnapshot` without a volume: unexpected error: %v", err)
+	}
+	if size := claim.Spec.Resources.Requests[k8sv1.ResourceStorage]; claim.Name != "claim-frickjack--rstudio" || claim.Spec.DataSource == nil || size.String() != "20Gi" {
+		t.Errorf("\nassertion error while testing `restoreVolumeSnapshot` without a volume: \nWant:%s of %s\nGot:%+v", "claim-frickjack--rstudio", "20Gi", claim)
+	}
+
+	if _, err := restoreVolumeSnapshot(ctx, podClient, client, "someone-else", "snapshot-frickjack-20261001000000", nil, now); err != errSnapshotNotFound {
+		t.Errorf("\nassertion error while testing `restoreVolumeSnapshot` of another user: \nWant:%v\nGot:%v", errSnapshotNotFound, err)
+	}
+}
+
+func Test_SnapshotUserVolumes(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	withTestConfig(t, snapshotTestConfig(SnapshotConfig{Enabled: true, ScheduleIntervalHours: 24, ScheduleRetention: 2}))
+
+	ctx := context.Background()
+	usingPod := &k8sv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "hatchery-active", Namespace: "jupyter-pods"},
+		Spec: k8sv1.PodSpec{Volumes: []k8sv1.Volume{{
+			Name:         "user-data",
+			VolumeSource: k8sv1.VolumeSource{PersistentVolumeClaim: &k8sv1.PersistentVolumeClaimVolumeSource{ClaimName: "claim-active"}},
+		}}},
+	}
+	podClient := fake.NewSimpleClientset(
+		testUserVolumeClaim("active", "", "Jupyter", "10Gi", "2026-10-01T00:00:00Z"),
+		testUserVolumeClaim("idle", "", "Jupyter", "10Gi", "2026-10-01T00:00:00Z"),
+		testUserVolumeClaim("new", "", "Jupyter", "10Gi", ""),
+		usingPod,
+	).CoreV1()
+	client := newSnapshotClient(
+		testVolumeSnapshot("active", "", "snapshot-active-20261015000000", snapshotTypeScheduled, time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)),
+		testVolumeSnapshot("active", "", "snapshot-active-20261016000000", snapshotTypeScheduled, time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)),
+		testVolumeSnapshot("active", "", "snapshot-active-20261010000000", snapshotTypeOnDemand, time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)),
+		testVolumeSnapshot("idle", "", "snapshot-idle-20261016000000", snapshotTypeScheduled, time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)),
+	)
+
+	snapshotted := snapshotUserVolumes(ctx, podClient, client, time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC))
+	// the idle volume did not change since its last snapshot
+	if len(snapshotted) != 2 || snapshotted[0] != "claim-active" || snapshotted[1] != "claim-new" {
+		t.Errorf("\nassertion error while testing `snapshotUserVolumes`: \nWant:%v\nGot:%v", []string{"claim-active", "claim-new"}, snapshotted)
+	}
+
+	snapshots, _ := listVolumeSnapshots(ctx, client, "active")
+	want := []string{"snapshot-active-20261010000000", "snapshot-active-20261016000000", "snapshot-active-20261017000000"}
+	if len(snapshots) != len(want) {
+		t.Fatalf("\nassertion error while testing `snapshotUserVolumes` retention: \nWant:%v\nGot:%+v", want, snapshots)
+	}
+	for i, snapshot := range snapshots {
+		if snapshot.Name != want[i] {
+			t.Errorf("\nassertion error while testing `snapshotUserVolumes` retention: \nWant:%v\nGot:%+v", want, snapshots)
+			break
+		}
+	}
+}
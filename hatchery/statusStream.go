@@ -0,0 +1,201 @@
+package hatchery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// How often to poll ECS for status changes, since ECS has no watch API
+var statusStreamEcsPollInterval = 5 * time.Second
+
+// How often to send a comment line so that proxies don't close idle streams
+var statusStreamKeepAliveInterval = 30 * time.Second
+
+// How long to wait before re-establishing a pod watch that failed
+var statusStreamRetryInterval = 5 * time.Second
+
+// statusStream pushes the user's WorkspaceStatus as Server-Sent Events every
+// time it changes, until the client disconnects. Kubernetes workspaces are
+// backed by a watch on the user's pod, ECS workspaces by polling DescribeServices
+func statusStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userName := getCurrentUserName(r)
+	accessToken := getBearerToken(r)
+	workspaceId, err := getWorkspaceId(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// the pay model is only looked up once for the lifetime of the stream
+	payModel, err := getCurrentPayModel(userName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	stream := &statusEventStream{w: w, flusher: flusher, ref: WorkspaceRef{UserName: userName, WorkspaceId: workspaceId}}
+	if payModel != nil && payModel.Ecs {
+		if workspaceId != "" {
+			// ECS pay models only support the default workspace
+			stream.send(&WorkspaceStatus{Status: "Not Found", WorkspaceType: "ECS"})
+			<-r.Context().Done()
+			return
+		}
+		streamEcsStatus(r.Context(), stream, userName, accessToken, payModel)
+	} else {
+		streamK8sPodStatus(r.Context(), stream, userName, workspaceId, accessToken, payModel)
+	}
+}
+
+// statusEventStream writes WorkspaceStatus events, skipping the ones
+// identical to the previously sent status
+type statusEventStream struct {
+	w          http.ResponseWriter
+	flusher    http.Flusher
+	ref        WorkspaceRef
+	lastStatus string
+}
+
+func (s *statusEventStream) send(status *WorkspaceStatus) {
+	if status == nil {
+		return
+	}
+	out, err := json.Marshal(status)
+	if err != nil {
+		Config().Logger.Printf("Error encoding workspace status: %v", err)
+		return
+	}
+	if string(out) == s.lastStatus {
+		return
+	}
+	observeWorkspaceStatus(s.ref, status)
+	s.lastStatus = string(out)
+	fmt.Fprintf(s.w, "event: status\ndata: %s\n\n", out)
+	s.flusher.Flush()
+}
+
+func (s *statusEventStream) keepAlive() {
+	fmt.Fprint(s.w, ": keepalive\n\n")
+	s.flusher.Flush()
+}
+
+// watchWorkspacePod watches the user's workspace pod in the local or external cluster
+var watchWorkspacePod = func(ctx context.Context, userName string, workspaceId string, payModelPtr *PayModel) (watch.Interface, error) {
+	podClient, _, err := getPodClient(ctx, userName, payModelPtr)
+	if err != nil {
+		return nil, err
+	}
+	opts := metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", workspaceToResourceName(userName, workspaceId, "pod")).String(),
+	}
+	return podClient.Pods(Config().Config.UserNamespace).Watch(ctx, opts)
+}
+
+func streamK8sPodStatus(ctx context.Context, stream *statusEventStream, userName string, workspaceId string, accessToken string, payModelPtr *PayModel) {
+	keepAlive := time.NewTicker(statusStreamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		// (re)sync the full status, events may have been missed while not watching
+		status, _ := statusK8sPod(ctx, userName, workspaceId, accessToken, payModelPtr)
+		stream.send(status)
+
+		watcher, err := watchWorkspacePod(ctx, userName, workspaceId, payModelPtr)
+		if err != nil {
+			Config().Logger.Printf("Error watching workspace pod for user %s: %v, retrying in %s", userName, err, statusStreamRetryInterval)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(statusStreamRetryInterval):
+				continue
+			}
+		}
+
+		if done := relayPodEvents(ctx, stream, watcher, keepAlive, userName, workspaceId, accessToken, payModelPtr); done {
+			return
+		}
+	}
+}
+
+// relayPodEvents sends a status event for each pod event until the watch
+// closes. Returns true once the client has gone away
+func relayPodEvents(ctx context.Context, stream *statusEventStream, watcher watch.Interface, keepAlive *time.Ticker, userName string, workspaceId string, accessToken string, payModelPtr *PayModel) bool {
+	defer watcher.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case <-keepAlive.C:
+			stream.keepAlive()
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return false
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				pod, ok := event.Object.(*k8sv1.Pod)
+				if !ok {
+					continue
+				}
+				stream.send(workspaceStatusFromPod(ctx, pod, accessToken))
+			case watch.Deleted:
+				// external workspaces are only "Not Found" once their service is gone too
+				status, _ := statusK8sPod(ctx, userName, workspaceId, accessToken, payModelPtr)
+				stream.send(status)
+			case watch.Error:
+				return false
+			}
+		}
+	}
+}
+
+func streamEcsStatus(ctx context.Context, stream *statusEventStream, userName string, accessToken string, payModel *PayModel) {
+	poll := time.NewTicker(statusStreamEcsPollInterval)
+	defer poll.Stop()
+	keepAlive := time.NewTicker(statusStreamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	sendEcsStatus := func() {
+		status, err := statusEcs(ctx, userName, accessToken, payModel.AWSAccountId)
+		if err == nil {
+			stream.send(status)
+		}
+	}
+
+	sendEcsStatus()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			stream.keepAlive()
+		case <-poll.C:
+			sendEcsStatus()
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package hatchery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func Test_StatusStreamMethodNotAllowed(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	req := httptest.NewRequest("POST", "/status/stream", nil)
+	w := httptest.NewRecorder()
+	statusStream(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("\nassertion error while testing `StatusStreamMethodNotAllowed`: \nWant:%d\nGot:%d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func Test_StatusStreamK8sPod(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalGetCurrentPayModel := getCurrentPayModel
+	originalStatusK8sPod := statusK8sPod
+	originalWatchWorkspacePod := watchWorkspacePod
+	defer func() {
+		getCurrentPayModel = originalGetCurrentPayModel
+		statusK8sPod = originalStatusK8sPod
+		watchWorkspacePod = originalWatchWorkspacePod
+	}()
+
+	getCurrentPayModel = func(userName string) (*PayModel, error) {
+		return nil, nil
+	}
+	// first call is the initial sync, the next one comes from the pod deletion
+	statusK8sPodCalls := 0
+	statusK8sPod = func(ctx context.Context, userName string, workspaceId string, accessToken string, payModelPtr *PayModel) (*WorkspaceStatus, error) {
+		statusK8sPodCalls++
+		if statusK8sPodCalls == 1 {
+			return &WorkspaceStatus{Status: "Launching", WorkspaceType: "Kubernetes"}, nil
+		}
+		return &WorkspaceStatus{Status: "Not Found", WorkspaceType: "Kubernetes"}, nil
+	}
+	fakeWatcher := watch.NewFake()
+	watchWorkspacePod = func(ctx context.Context, userName string, workspaceId string, payModelPtr *PayModel) (watch.Interface, error) {
+		return fakeWatcher, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/status/stream", nil).WithContext(ctx)
+	req.Header.Set("REMOTE_USER", "testUser")
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		statusStream(w, req)
+		close(done)
+	}()
+
+	pod := &k8sv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: userToResourceName("testUser", "pod")},
+		Status:     k8sv1.PodStatus{Phase: k8sv1.PodFailed},
+	}
+	fakeWatcher.Add(pod)
+	// an identical status must not be sent twice
+	fakeWatcher.Modify(pod)
+	fakeWatcher.Delete(pod)
+	cancel()
+	<-done
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("\nassertion error while testing `StatusStreamK8sPod` content type: \nWant:%s\nGot:%s", "text/event-stream", contentType)
+	}
+	want := []string{"Launching", "Failed", "Not Found"}
+	events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	if len(events) != len(want) {
+		t.Fatalf("\nassertion error while testing `StatusStreamK8sPod`: \nWant %d events\nGot:%q", len(want), events)
+	}
+	for i, event := range events {
+		if !strings.HasPrefix(event, "event: status\ndata: ") || !strings.Contains(event, `"status":"`+want[i]+`"`) {
+			t.Errorf("\nassertion error while testing `StatusStreamK8sPod` event %d: \nWant status:%s\nGot:%s", i, want[i], event)
+		}
+	}
+}
+
+func Test_StatusStreamEcs(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalGetCurrentPayModel := getCurrentPayModel
+	originalStatusEcs := statusEcs
+	originalPollInterval := statusStreamEcsPollInterval
+	defer func() {
+		getCurrentPayModel = originalGetCurrentPayModel
+		statusEcs = originalStatusEcs
+		statusStreamEcsPollInterval = originalPollInterval
+	}()
+
+	getCurrentPayModel = func(userName string) (*PayModel, error) {
+		return &PayModel{Ecs: true, AWSAccountId: "123456789012"}, nil
+	}
+	statusStreamEcsPollInterval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	statuses := []string{"Launching", "Launching", "Running"}
+	statusEcsCalls := 0
+	statusEcs = func(ctx context.Context, userName string, accessToken string, awsAcctID string) (*WorkspaceStatus, error) {
+		status := statuses[statusEcsCalls]
+		if statusEcsCalls < len(statuses)-1 {
+			statusEcsCalls++
+		} else {
+			cancel()
+		}
+		return &WorkspaceStatus{Status: status, WorkspaceType: "ECS"}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/status/stream", nil).WithContext(ctx)
+	req.Header.Set("REMOTE_USER", "testUser")
+	w := httptest.NewRecorder()
+	statusStream(w, req)
+
+	want := []string{"Launching", "Running"}
+	events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	if len(events) != len(want) {
+		t.Fatalf("\nassertion error while testing `StatusStreamEcs`: \nWant %d events\nGot:%q", len(want), events)
+	}
+	for i, event := range events {
+		if !strings.Contains(event, `"status":"`+want[i]+`"`) {
+			t.Errorf("\nassertion error while testing `StatusStreamEcs` event %d: \nWant status:%s\nGot:%s", i, want[i], event)
+		}
+	}
+}
@@ -0,0 +1,503 @@
+package hatchery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	k8sv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// Records the last launch of a workspace with the user volume, to find the orphaned volumes
+const lastLaunchAnnotation = "gen3lastlaunch"
+
+// UserVolume describes a user volume, as returned by /storage
+type UserVolume struct {
+	WorkspaceId   string `json:"workspaceId"`
+	ClaimName     string `json:"claimName"`
+	Status        string `json:"status"`
+	StorageClass  string `json:"storageClass,omitempty"`
+	RequestedSize string `json:"requestedSize"`
+	Capacity      string `json:"capacity,omitempty"`
+	CapacityBytes int64  `json:"capacityBytes"`
+	// only known while a workspace uses the volume
+	UsedBytes *int64 `json:"usedBytes,omitempty"`
+	// the largest size the volume can be expanded to, if it can be expanded
+	MaxSize    string     `json:"maxSize,omitempty"`
+	LastLaunch *time.Time `json:"lastLaunch,omitempty"`
+}
+
+// OrphanedClaim is a user volume of a user who did not launch a workspace for a while, as returned by /admin/storage/orphaned
+type OrphanedClaim struct {
+	UserName    string    `json:"userName"`
+	WorkspaceId string    `json:"workspaceId"`
+	ClaimName   string    `json:"claimName"`
+	Size        string    `json:"size"`
+	LastLaunch  time.Time `json:"lastLaunch"`
+	// the version of the claim when it was listed, to not delete a claim used since
+	resourceVersion string
+}
+
+// userVolumeConfig returns the settings of the user volumes of the container's workspaces: the
+// global settings, replaced by the container's, replaced by the pay model type's
+func userVolumeConfig(hatchConfig *FullHatcheryConfig, hatchApp *Container, payModel *PayModel) UserVolumeConfig {
+	volume := hatchConfig.Config.UserVolume
+	if volume.Size == "" {
+		volume.Size = hatchConfig.Config.UserVolumeSize
+	}
+	if hatchApp != nil && hatchApp.UserVolume != nil {
+		volume = mergeUserVolumeConfig(volume, *hatchApp.UserVolume)
+	}
+	if payModel != nil {
+		if payModelVolume, ok := hatchConfig.Config.PayModelUserVolume[payModel.Name]; ok {
+			volume = mergeUserVolumeConfig(volume, payModelVolume)
+		}
+	}
+	return volume
+}
+
+func mergeUserVolumeConfig(volume UserVolumeConfig, override UserVolumeConfig) UserVolumeConfig {
+	if override.Size != "" {
+		volume.Size = override.Size
+	}
+	if override.StorageClass != "" {
+		volume.StorageClass = override.StorageClass
+	}
+	if override.AccessMode != "" {
+		volume.AccessMode = override.AccessMode
+	}
+	if override.MaxSize != "" {
+		volume.MaxSize = override.MaxSize
+	}
+	return volume
+}
+
+// workspaceUserVolume returns the settings of the user volume of the workspace being launched,
+// with the size chosen for the workspace
+func workspaceUserVolume(hatchConfig *FullHatcheryConfig, hatchApp *Container, size WorkspaceSize, payModel *PayModel) UserVolumeConfig {
+	volume := userVolumeConfig(hatchConfig, hatchApp, payModel)
+	if size.VolumeSize != "" {
+		volume.Size = size.VolumeSize
+	}
+	return volume
+}
+
+// createUserVolume creates the claim of the workspace's user volume, restored from a snapshot
+// if a restore is pending, or records the launch on the existing claim
+func createUserVolume(ctx context.Context, podClient corev1.CoreV1Interface, userName string, workspaceId string, pod *k8sv1.Pod, volume UserVolumeConfig, payModel *PayModel, now time.Time) error {
+	claims := podClient.PersistentVolumeClaims(Config().Config.UserNamespace)
+	claimName := workspaceToResourceName(userName, workspaceId, "claim")
+	lastLaunch := now.UTC().Format(time.RFC3339)
+
+	claim, err := claims.Get(ctx, claimName, metav1.GetOptions{})
+	if err == nil && claim.DeletionTimestamp != nil {
+		return errUserVolumeDeleting
+	}
+	if err == nil {
+		if claim.Annotations == nil {
+			claim.Annotations = map[string]string{}
+		}
+		claim.Annotations[lastLaunchAnnotation] = lastLaunch
+		if _, err := claims.Update(ctx, claim, metav1.UpdateOptions{}); err != nil {
+			// only the orphaned volume reports depend on it
+			Config().Logger.Printf("Failed to record the launch on PVC %s. Error: %s\n", claimName, err)
+		}
+		return nil
+	}
+
+	pvc := buildPVC(userName, workspaceId, pod, volume)
+	pvc.Annotations[lastLaunchAnnotation] = lastLaunch
+	restored, err := restorePendingSnapshot(ctx, claims, userName, payModel, pvc)
+	if err != nil {
+		Config().Logger.Printf("Failed to restore PVC %s. Error: %s\n", claimName, err)
+		return err
+	}
+	if restored {
+		return nil
+	}
+	Config().Logger.Printf("Creating PersistentVolumeClaim %s.\n", claimName)
+	if _, err := claims.Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+		Config().Logger.Printf("Failed to create PVC %s. Error: %s\n", claimName, err)
+		return err
+	}
+	return nil
+}
+
+// containerByName returns the config of the container with this name, e.g. the container
+// a workspace resource was created for
+func containerByName(containerName string) (*Container, bool) {
+	for _, container := range Config().ContainersMap {
+		if container.Name == containerName {
+			return &container, true
+		}
+	}
+	return nil, false
+}
+
+// claimUserVolumeConfig returns the settings of the user volume of the claim, from the container
+// it was created for
+func claimUserVolumeConfig(claim *k8sv1.PersistentVolumeClaim, payModel *PayModel) UserVolumeConfig {
+	hatchApp, _ := containerByName(claim.Annotations[containerNameAnnotation])
+	return userVolumeConfig(Config(), hatchApp, payModel)
+}
+
+// getUserVolumeUsage returns the bytes used on the volume of the pod, from the stats of its node
+var getUserVolumeUsage = func(ctx context.Context, podClient corev1.CoreV1Interface, pod *k8sv1.Pod, volumeName string) (*int64, error) {
+	if pod.Spec.NodeName == "" {
+		return nil, nil
+	}
+	out, err := podClient.RESTClient().Get().AbsPath("/api/v1/nodes", pod.Spec.NodeName, "proxy/stats/summary").DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	summary := struct {
+		Pods []struct {
+			PodRef struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"podRef"`
+			Volume []struct {
+				Name      string `json:"name"`
+				UsedBytes *int64 `json:"usedBytes"`
+			} `json:"volume"`
+		} `json:"pods"`
+	}{}
+	if err := json.Unmarshal(out, &summary); err != nil {
+		return nil, err
+	}
+	for _, podStats := range summary.Pods {
+		if podStats.PodRef.Name != pod.Name || podStats.PodRef.Namespace != pod.Namespace {
+			continue
+		}
+		for _, volumeStats := range podStats.Volume {
+			if volumeStats.Name == volumeName {
+				return volumeStats.UsedBytes, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func describeUserVolume(ctx context.Context, podClient corev1.CoreV1Interface, claim *k8sv1.PersistentVolumeClaim, payModel *PayModel) UserVolume {
+	ref, _ := workspaceRefFromAnnotations(claim.Annotations)
+	requested := claim.Spec.Resources.Requests[k8sv1.ResourceStorage]
+	volume := UserVolume{
+		WorkspaceId:   ref.WorkspaceId,
+		ClaimName:     claim.Name,
+		Status:        string(claim.Status.Phase),
+		RequestedSize: requested.String(),
+		MaxSize:       claimUserVolumeConfig(claim, payModel).MaxSize,
+	}
+	if claim.Spec.StorageClassName != nil {
+		volume.StorageClass = *claim.Spec.StorageClassName
+	}
+	if capacity, ok := claim.Status.Capacity[k8sv1.ResourceStorage]; ok {
+		volume.Capacity = capacity.String()
+		volume.CapacityBytes = capacity.Value()
+	}
+	if lastLaunch, err := time.Parse(time.RFC3339, claim.Annotations[lastLaunchAnnotation]); err == nil {
+		volume.LastLaunch = &lastLaunch
+	}
+
+	podName := workspaceToResourceName(ref.UserName, ref.WorkspaceId, "pod")
+	pod, err := podClient.Pods(claim.Namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err == nil && pod.Status.Phase == k8sv1.PodRunning {
+		usedBytes, err := getUserVolumeUsage(ctx, podClient, pod, "user-data")
+		if err != nil {
+			Config().Logger.Printf("Unable to get the usage of PVC %s: %v", claim.Name, err)
+		}
+		volume.UsedBytes = usedBytes
+	}
+	return volume
+}
+
+// listUserVolumes returns the user volumes of the user's workspaces
+func listUserVolumes(ctx context.Context, podClient corev1.CoreV1Interface, userName string, payModel *PayModel) ([]UserVolume, error) {
+	claims, err := podClient.PersistentVolumeClaims(Config().Config.UserNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	result := []UserVolume{}
+	for i := range claims.Items {
+		ref, ok := workspaceRefFromAnnotations(claims.Items[i].Annotations)
+		if !ok || ref.UserName != userName {
+			continue
+		}
+		result = append(result, describeUserVolume(ctx, podClient, &claims.Items[i], payModel))
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].WorkspaceId < result[j].WorkspaceId
+	})
+	return result, nil
+}
+
+// errUserVolumeNotFound is returned when expanding a volume that does not exist
+var errUserVolumeNotFound = errors.New("the user volume was not found")
+
+// expandUserVolume requests an online expansion of the user volume of the workspace, up to the
+// max size of its container and the user's pay model. Volumes can't shrink
+func expandUserVolume(ctx context.Context, podClient corev1.CoreV1Interface, userName string, workspaceId string, payModel *PayModel, size string) (*k8sv1.PersistentVolumeClaim, error) {
+	newSize, err := resource.ParseQuantity(size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'size' '%s': %v", size, err)
+	}
+	claims := podClient.PersistentVolumeClaims(Config().Config.UserNamespace)
+	claimName := workspaceToResourceName(userName, workspaceId, "claim")
+	claim, err := claims.Get(ctx, claimName, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return nil, errUserVolumeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	maxSize := claimUserVolumeConfig(claim, payModel).MaxSize
+	if maxSize == "" {
+		return nil, fmt.Errorf("the user volume can't be expanded")
+	}
+	if newSize.Cmp(resource.MustParse(maxSize)) > 0 {
+		return nil, fmt.Errorf("the user volume can't be expanded beyond %s", maxSize)
+	}
+	requested := claim.Spec.Resources.Requests[k8sv1.ResourceStorage]
+	if newSize.Cmp(requested) <= 0 {
+		return nil, fmt.Errorf("the user volume is already %s, and can only grow", requested.String())
+	}
+
+	claim.Spec.Resources.Requests[k8sv1.ResourceStorage] = newSize
+	return claims.Update(ctx, claim, metav1.UpdateOptions{})
+}
+
+// listOrphanedClaims returns the user volumes that no workspace uses, of the users who did not
+// launch a workspace with a user volume for the number of days. The users whose launches were
+// never recorded are skipped, since their volumes may have been used right before the
+// launches were recorded
+func listOrphanedClaims(ctx context.Context, podClient corev1.CoreV1Interface, days int, now time.Time) ([]OrphanedClaim, error) {
+	namespace := Config().Config.UserNamespace
+	claims, err := podClient.PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	usedClaims, err := listUsedClaims(ctx, podClient, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// the last launch of any of the user's workspaces
+	lastLaunches := map[string]time.Time{}
+	for _, claim := range claims.Items {
+		ref, ok := workspaceRefFromAnnotations(claim.Annotations)
+		if !ok {
+			continue
+		}
+		if lastLaunch, err := time.Parse(time.RFC3339, claim.Annotations[lastLaunchAnnotation]); err == nil && lastLaunch.After(lastLaunches[ref.UserName]) {
+			lastLaunches[ref.UserName] = lastLaunch
+		}
+	}
+
+	cutoff := now.AddDate(0, 0, -days)
+	result := []OrphanedClaim{}
+	for _, claim := range claims.Items {
+		ref, ok := workspaceRefFromAnnotations(claim.Annotations)
+		if !ok || usedClaims[claim.Name] {
+			continue
+		}
+		lastLaunch, ok := lastLaunches[ref.UserName]
+		if !ok || lastLaunch.After(cutoff) {
+			continue
+		}
+		size := claim.Spec.Resources.Requests[k8sv1.ResourceStorage]
+		result = append(result, OrphanedClaim{
+			UserName:        ref.UserName,
+			WorkspaceId:     ref.WorkspaceId,
+			ClaimName:       claim.Name,
+			Size:            size.String(),
+			LastLaunch:      lastLaunch.UTC(),
+			resourceVersion: claim.ResourceVersion,
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ClaimName < result[j].ClaimName
+	})
+	return result, nil
+}
+
+// errClaimInUse is returned when an orphaned claim was used again since it was listed
+var errClaimInUse = errors.New("the claim was used since it was listed")
+
+// deleteOrphanedClaim deletes a claim returned by listOrphanedClaims, unless a workspace
+// uses it now. Launches update the claim, so the claim is only deleted if it is unchanged
+// since it was listed
+func deleteOrphanedClaim(ctx context.Context, podClient corev1.CoreV1Interface, orphanedClaim OrphanedClaim) error {
+	namespace := Config().Config.UserNamespace
+	usedClaims, err := listUsedClaims(ctx, podClient, namespace)
+	if err != nil {
+		return err
+	}
+	if usedClaims[orphanedClaim.ClaimName] {
+		return errClaimInUse
+	}
+	resourceVersion := orphanedClaim.resourceVersion
+	err = podClient.PersistentVolumeClaims(namespace).Delete(ctx, orphanedClaim.ClaimName, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &resourceVersion},
+	})
+	if k8sErrors.IsConflict(err) {
+		return errClaimInUse
+	}
+	return err
+}
+
+// listUsedClaims returns the names of the claims used by the pods of the namespace
+func listUsedClaims(ctx context.Context, podClient corev1.CoreV1Interface, namespace string) (map[string]bool, error) {
+	pods, err := podClient.Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	usedClaims := map[string]bool{}
+	for _, pod := range pods.Items {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				usedClaims[volume.PersistentVolumeClaim.ClaimName] = true
+			}
+		}
+	}
+	return usedClaims, nil
+}
+
+// getUserVolumeClient returns a client for the cluster the user's workspaces run in
+var getUserVolumeClient = func(ctx context.Context, userName string, payModel *PayModel) (corev1.CoreV1Interface, error) {
+	podClient, _, err := getPodClient(ctx, userName, payModel)
+	return podClient, err
+}
+
+func storage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userName := getCurrentUserName(r)
+	payModel, err := getCurrentPayModel(userName)
+	if err != nil {
+		Config().Logger.Printf("error when getting the pay model of user %s: %v", userName, err)
+	}
+	podClient, err := getUserVolumeClient(r.Context(), userName, payModel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	result, err := listUserVolumes(r.Context(), podClient, userName, payModel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	out, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(out))
+}
+
+func expandStorage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userName := getCurrentUserName(r)
+	size := r.URL.Query().Get("size")
+	if size == "" {
+		http.Error(w, "Missing 'size' argument", http.StatusBadRequest)
+		return
+	}
+	workspaceId, err := getWorkspaceId(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	payModel, err := getCurrentPayModel(userName)
+	if err != nil {
+		Config().Logger.Printf("error when getting the pay model of user %s: %v", userName, err)
+	}
+	if payModel != nil && payModel.Ecs && workspaceId == "" {
+		http.Error(w, "ECS workspaces store user data on EFS, which grows as needed", http.StatusBadRequest)
+		return
+	}
+	podClient, err := getUserVolumeClient(r.Context(), userName, payModel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	claim, err := expandUserVolume(r.Context(), podClient, userName, workspaceId, payModel, size)
+	if err == errUserVolumeNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		Config().Logger.Printf("Failed to expand the user volume of workspace '%s' for user %s to %s: %v", workspaceId, userName, size, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	Config().Logger.Printf("Requested the expansion of PVC %s for user %s to %s", claim.Name, userName, size)
+
+	out, err := json.Marshal(describeUserVolume(r.Context(), podClient, claim, payModel))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(out))
+}
+
+// adminOrphanedClaims lists the orphaned user volumes of the local cluster, and deletes
+// them on POST. The user data is lost
+func adminOrphanedClaims(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || days < 1 {
+		http.Error(w, "Missing or invalid 'days' argument: must be a positive number of days", http.StatusBadRequest)
+		return
+	}
+	podClient := getLocalPodClient()
+	if podClient == nil {
+		http.Error(w, "unable to get local pod client", http.StatusInternalServerError)
+		return
+	}
+	orphanedClaims, err := listOrphanedClaims(r.Context(), podClient, days, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == "POST" {
+		adminUser := getCurrentUserName(r)
+		deleted := []OrphanedClaim{}
+		for _, orphanedClaim := range orphanedClaims {
+			err := deleteOrphanedClaim(r.Context(), podClient, orphanedClaim)
+			if err != nil && !k8sErrors.IsNotFound(err) {
+				Config().Logger.Printf("Admin %s failed to delete orphaned PVC %s of user %s: %v", adminUser, orphanedClaim.ClaimName, orphanedClaim.UserName, err)
+				continue
+			}
+			Config().Logger.Printf("Admin %s deleted orphaned PVC %s of user %s, last used %s", adminUser, orphanedClaim.ClaimName, orphanedClaim.UserName, orphanedClaim.LastLaunch.Format(time.RFC3339))
+			deleted = append(deleted, orphanedClaim)
+		}
+		orphanedClaims = deleted
+	}
+
+	out, err := json.Marshal(orphanedClaims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(out))
+}
@@ -0,0 +1,275 @@
+package hatchery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	k8sv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+func userVolumeTestConfig(userVolume UserVolumeConfig, payModelUserVolume map[string]UserVolumeConfig, containers ...Container) func(config *FullHatcheryConfig) {
+	return func(config *FullHatcheryConfig) {
+		config.Config.UserNamespace = "jupyter-pods"
+		config.Config.UserVolumeSize = "10Gi"
+		config.Config.UserVolume = userVolume
+		config.Config.PayModelUserVolume = payModelUserVolume
+		config.ContainersMap = map[string]Container{}
+		for _, container := range containers {
+			config.ContainersMap[container.Name] = container
+		}
+	}
+}
+
+func testUserVolumeClaim(userName string, workspaceId string, containerName string, size string, lastLaunch string) *k8sv1.PersistentVolumeClaim {
+	annotations := map[string]string{"gen3username": userName, containerNameAnnotation: containerName}
+	if workspaceId != "" {
+		annotations[workspaceIdAnnotation] = workspaceId
+	}
+	if lastLaunch != "" {
+		annotations[lastLaunchAnnotation] = lastLaunch
+	}
+	return &k8sv1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              workspaceToResourceName(userName, workspaceId, "claim"),
+			Namespace:         "jupyter-pods",
+			Annotations:       annotations,
+			CreationTimestamp: metav1.NewTime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+		},
+		Spec: k8sv1.PersistentVolumeClaimSpec{
+			Resources: k8sv1.VolumeResourceRequirements{
+				Requests: k8sv1.ResourceList{k8sv1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+		Status: k8sv1.PersistentVolumeClaimStatus{
+			Phase:    k8sv1.ClaimBound,
+			Capacity: k8sv1.ResourceList{k8sv1.ResourceStorage: resource.MustParse(size)},
+		},
+	}
+}
+
+func Test_UserVolumeConfig(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	withTestConfig(t, userVolumeTestConfig(
+		UserVolumeConfig{StorageClass: "gp3", MaxSize: "100Gi"},
+		map[string]UserVolumeConfig{"Trial Workspace": {Size: "5Gi", MaxSize: "20Gi"}},
+	))
+
+	hatchApp := &Container{Name: "Jupyter", UserVolume: &UserVolumeConfig{Size: "50Gi", StorageClass: "io2"}}
+	testCases := []struct {
+		name      string
+		hatchApp  *Container
+		payModel  *PayModel
+		wantSize  string
+		wantClass string
+		wantMax   string
+	}{
+		{name: "Global", wantSize: "10Gi", wantClass: "gp3", wantMax: "100Gi"},
+		{name: "Container", hatchApp: hatchApp, wantSize: "50Gi", wantClass: "io2", wantMax: "100Gi"},
+		{name: "PayModel", hatchApp: hatchApp, payModel: &PayModel{Name: "Trial Workspace"}, wantSize: "5Gi", wantClass: "io2", wantMax: "20Gi"},
+		{name: "OtherPayModel", hatchApp: hatchApp, payModel: &PayModel{Name: "Direct Pay"}, wantSize: "50Gi", wantClass: "io2", wantMax: "100Gi"},
+	}
+	for _, testcase := range testCases {
+		t.Logf("Testing userVolumeConfig when %s", testcase.name)
+		got := userVolumeConfig(Config(), testcase.hatchApp, testcase.payModel)
+		if got.Size != testcase.wantSize || got.StorageClass != testcase.wantClass || got.MaxSize != testcase.wantMax {
+			t.Errorf("\nassertion error while testing `%s`: \nWant:%s %s %s\nGot:%+v", testcase.name, testcase.wantSize, testcase.wantClass, testcase.wantMax, got)
+		}
+	}
+}
+
+func Test_CreateUserVolume(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	withTestConfig(t, userVolumeTestConfig(UserVolumeConfig{}, nil))
+
+	ctx := context.Background()
+	podClient := fake.NewSimpleClientset().CoreV1()
+	pod := &k8sv1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{"gen3username": "frickjack", apiKeyIdAnnotation: "key-id"},
+		Labels:      map[string]string{"app": "hatchery-frickjack"},
+	}}
+	volume := UserVolumeConfig{Size: "20Gi", StorageClass: "gp3", AccessMode: "ReadWriteOncePod"}
+	launch := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	if err := createUserVolume(ctx, podClient, "frickjack", "", pod, volume, nil, launch); err != nil {
+		t.Fatalf("\nassertion error while testing `createUserVolume`: unexpected error: %v", err)
+	}
+
+	claim, err := podClient.PersistentVolumeClaims("jupyter-pods").Get(ctx, "claim-frickjack", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("\nassertion error while testing `createUserVolume`: the PVC was not created: %v", err)
+	}
+	if claim.Spec.StorageClassName == nil || *claim.Spec.StorageClassName != "gp3" || claim.Spec.AccessModes[0] != k8sv1.ReadWriteOncePod {
+		t.Errorf("\nassertion error while testing `createUserVolume` storage class and access mode: \nGot:%+v", claim.Spec)
+	}
+	if got := claim.Spec.Resources.Requests[k8sv1.ResourceStorage]; got.String() != "20Gi" {
+		t.Errorf("\nassertion error while testing `createUserVolume` size: \nWant:%s\nGot:%s", "20Gi", got.String())
+	}
+	if _, ok := claim.Annotations[apiKeyIdAnnotation]; ok {
+		t.Errorf("\nassertion error while testing `createUserVolume`: the PVC should not record the API key of the pod")
+	}
+	if _, ok := pod.Annotations[lastLaunchAnnotation]; ok {
+		t.Errorf("\nassertion error while testing `createUserVolume`: the annotations of the pod should not be modified")
+	}
+
+	// the existing volume is kept, and the launch is recorded
+	nextLaunch := launch.AddDate(0, 0, 7)
+	volume.Size = "30Gi"
+	if err := createUserVolume(ctx, podClient, "frickjack", "", pod, volume, nil, nextLaunch); err != nil {
+		t.Fatalf("\nassertion error while testing `createUserVolume` with an existing PVC: unexpected error: %v", err)
+	}
+	claim, _ = podClient.PersistentVolumeClaims("jupyter-pods").Get(ctx, "claim-frickjack", metav1.GetOptions{})
+	if got := claim.Spec.Resources.Requests[k8sv1.ResourceStorage]; got.String() != "20Gi" {
+		t.Errorf("\nassertion error while testing `createUserVolume` with an existing PVC: \nWant:%s\nGot:%s", "20Gi", got.String())
+	}
+	if got := claim.Annotations[lastLaunchAnnotation]; got != nextLaunch.Format(time.RFC3339) {
+		t.Errorf("\nassertion error while testing `createUserVolume` last launch: \nWant:%s\nGot:%s", nextLaunch.Format(time.RFC3339), got)
+	}
+}
+
+func Test_ListUserVolumes(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	withTestConfig(t, userVolumeTestConfig(UserVolumeConfig{MaxSize: "100Gi"}, nil))
+
+	originalGetUserVolumeUsage := getUserVolumeUsage
+	defer func() {
+		getUserVolumeUsage = originalGetUserVolumeUsage
+	}()
+	getUserVolumeUsage = func(ctx context.Context, podClient corev1.CoreV1Interface, pod *k8sv1.Pod, volumeName string) (*int64, error) {
+		usedBytes := int64(1024)
+		return &usedBytes, nil
+	}
+
+	runningPod := &k8sv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "hatchery-frickjack--rstudio", Namespace: "jupyter-pods"},
+		Status:     k8sv1.PodStatus{Phase: k8sv1.PodRunning},
+	}
+	podClient := fake.NewSimpleClientset(
+		testUserVolumeClaim("frickjack", "", "Jupyter", "10Gi", "2026-10-01T12:00:00Z"),
+		testUserVolumeClaim("frickjack", "rstudio", "R Studio", "20Gi", ""),
+		testUserVolumeClaim("someone-else", "", "Jupyter", "10Gi", ""),
+		runningPod,
+	).CoreV1()
+
+	volumes, err := listUserVolumes(context.Background(), podClient, "frickjack", nil)
+	if err != nil {
+		t.Fatalf("\nassertion error while testing `listUserVolumes`: unexpected error: %v", err)
+	}
+	if len(volumes) != 2 || volumes[0].ClaimName != "claim-frickjack" || volumes[1].WorkspaceId != "rstudio" {
+		t.Fatalf("\nassertion error while testing `listUserVolumes`: \nWant:the 2 volumes of the user\nGot:%+v", volumes)
+	}
+	if volumes[0].UsedBytes != nil || volumes[0].LastLaunch == nil || volumes[0].MaxSize != "100Gi" {
+		t.Errorf("\nassertion error while testing `listUserVolumes` without a workspace: \nGot:%+v", volumes[0])
+	}
+	if volumes[1].UsedBytes == nil || *volumes[1].UsedBytes != 1024 || volumes[1].CapacityBytes != 20*1024*1024*1024 {
+		t.Errorf("\nassertion error while testing `listUserVolumes` with a running workspace: \nGot:%+v", volumes[1])
+	}
+}
+
+func Test_ExpandUserVolume(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	withTestConfig(t, userVolumeTestConfig(
+		UserVolumeConfig{},
+		map[string]UserVolumeConfig{"Trial Workspace": {MaxSize: "15Gi"}},
+		Container{Name: "Jupyter", UserVolume: &UserVolumeConfig{MaxSize: "50Gi"}},
+	))
+
+	ctx := context.Background()
+	podClient := fake.NewSimpleClientset(
+		testUserVolumeClaim("frickjack", "", "Jupyter", "10Gi", ""),
+		testUserVolumeClaim("frickjack", "stata", "Stata", "10Gi", ""),
+	).CoreV1()
+
+	testCases := []struct {
+		name        string
+		workspaceId string
+		payModel    *PayModel
+		size        string
+		wantErr     bool
+	}{
+		{name: "InvalidSize", size: "a lot", wantErr: true},
+		{name: "NotFound", workspaceId: "rstudio", size: "20Gi", wantErr: true},
+		{name: "NoMaxSize", workspaceId: "stata", size: "20Gi", wantErr: true},
+		{name: "BeyondPayModelMaxSize", payModel: &PayModel{Name: "Trial Workspace"}, size: "20Gi", wantErr: true},
+		{name: "BeyondMaxSize", size: "60Gi", wantErr: true},
+		{name: "Shrink", size: "5Gi", wantErr: true},
+		{name: "Expand", size: "20Gi"},
+	}
+	for _, testcase := range testCases {
+		t.Logf("Testing expandUserVolume when %s", testcase.name)
+		claim, err := expandUserVolume(ctx, podClient, "frickjack", testcase.workspaceId, testcase.payModel, testcase.size)
+		if testcase.wantErr {
+			if err == nil {
+				t.Errorf("\nassertion error while testing `%s`: \nWant:an error\nGot:nil", testcase.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("\nassertion error while testing `%s`: unexpected error: %v", testcase.name, err)
+			continue
+		}
+		if got := claim.Spec.Resources.Requests[k8sv1.ResourceStorage]; got.String() != testcase.size {
+			t.Errorf("\nassertion error while testing `%s`: \nWant:%s\nGot:%s", testcase.name, testcase.size, got.String())
+		}
+	}
+
+	if _, err := expandUserVolume(ctx, podClient, "frickjack", "rstudio", nil, "20Gi"); err != errUserVolumeNotFound {
+		t.Errorf("\nassertion error while testing `expandUserVolume` without a volume: \nWant:%v\nGot:%v", errUserVolumeNotFound, err)
+	}
+}
+
+func Test_ListOrphanedClaims(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	withTestConfig(t, userVolumeTestConfig(UserVolumeConfig{}, nil))
+
+	usingPod := &k8sv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "hatchery-active", Namespace: "jupyter-pods"},
+		Spec: k8sv1.PodSpec{Volumes: []k8sv1.Volume{{
+			Name:         "user-data",
+			VolumeSource: k8sv1.VolumeSource{PersistentVolumeClaim: &k8sv1.PersistentVolumeClaimVolumeSource{ClaimName: "claim-active"}},
+		}}},
+	}
+	podClient := fake.NewSimpleClientset(
+		testUserVolumeClaim("active", "", "Jupyter", "10Gi", "2026-01-01T00:00:00Z"),
+		testUserVolumeClaim("recent", "", "Jupyter", "10Gi", "2026-10-10T00:00:00Z"),
+		// the workspace was not launched for a while, but the user launched another one
+		testUserVolumeClaim("recent", "rstudio", "RStudio", "10Gi", "2026-01-01T00:00:00Z"),
+		testUserVolumeClaim("gone", "", "Jupyter", "10Gi", "2026-06-01T00:00:00Z"),
+		// created before the launches were recorded
+		testUserVolumeClaim("gone", "rstudio", "RStudio", "10Gi", ""),
+		testUserVolumeClaim("legacy", "", "Jupyter", "10Gi", ""),
+		usingPod,
+	).CoreV1()
+
+	now := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	orphanedClaims, err := listOrphanedClaims(context.Background(), podClient, 30, now)
+	if err != nil {
+		t.Fatalf("\nassertion error while testing `listOrphanedClaims`: unexpected error: %v", err)
+	}
+	if len(orphanedClaims) != 2 || orphanedClaims[0].UserName != "gone" || orphanedClaims[1].UserName != "gone" {
+		t.Fatalf("\nassertion error while testing `listOrphanedClaims`: \nWant:the volumes of 'gone'\nGot:%+v", orphanedClaims)
+	}
+	if !orphanedClaims[1].LastLaunch.Equal(time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("\nassertion error while testing `listOrphanedClaims` last launch of a legacy volume: \nGot:%v", orphanedClaims[1].LastLaunch)
+	}
+
+	// a workspace launched since the volumes were listed keeps its volume
+	launchedPod := usingPod.DeepCopy()
+	launchedPod.Name = "hatchery-gone"
+	launchedPod.Spec.Volumes[0].PersistentVolumeClaim.ClaimName = orphanedClaims[0].ClaimName
+	if _, err := podClient.Pods("jupyter-pods").Create(context.Background(), launchedPod, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create a pod - %v", err)
+	}
+	if err := deleteOrphanedClaim(context.Background(), podClient, orphanedClaims[0]); err != errClaimInUse {
+		t.Errorf("\nassertion error while testing `deleteOrphanedClaim` used claim: \nWant:%v\nGot:%v", errClaimInUse, err)
+	}
+	if err := deleteOrphanedClaim(context.Background(), podClient, orphanedClaims[1]); err != nil {
+		t.Errorf("\nassertion error while testing `deleteOrphanedClaim`: unexpected error: %v", err)
+	}
+	if _, err := podClient.PersistentVolumeClaims("jupyter-pods").Get(context.Background(), orphanedClaims[1].ClaimName, metav1.GetOptions{}); err == nil {
+		t.Errorf("\nassertion error while testing `deleteOrphanedClaim`: \nWant:%s\nGot:%v", "the claim deleted", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/uc-cdis/hatchery/hatchery/version"
 )
 
@@ -16,9 +17,15 @@ type versionSummary struct {
 func RegisterSystem() {
 	http.HandleFunc("/_status", systemStatus)
 	http.HandleFunc("/_version", systemVersion)
+	http.HandleFunc("/_ready", systemReady)
+	http.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
 }
 
 func systemStatus(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("deep") == "true" {
+		systemReady(w, r)
+		return
+	}
 	fmt.Fprintf(w, "Healthy")
 }
 
@@ -1,6 +1,7 @@
 package hatchery
 
 import (
+	"context"
 	"io"
 	"log"
 )
@@ -13,14 +14,30 @@ func SetupAndTeardownTest() func() {
 	*/
 
 	/* setup */
-	if Config == nil {
-		Config = &FullHatcheryConfig{
+	if Config() == nil {
+		SetConfig(&FullHatcheryConfig{
 			// Logger: log.New(os.Stdout, "", log.LstdFlags), // Print all logs (for dev purposes)
 			Logger: log.New(io.Discard, "", log.LstdFlags), // Discard all logs
-		}
+		})
+	}
+	if Config().Logger == nil {
+		// some tests replace the config without setting a logger
+		Config().Logger = log.New(io.Discard, "", log.LstdFlags)
+	}
+
+	// Unit tests don't run in a cluster, so by default the user has no other workspaces
+	originalListWorkspaces := listWorkspaces
+	listWorkspaces = func(ctx context.Context) ([]WorkspaceRef, error) {
+		return []WorkspaceRef{}, nil
+	}
+	originalGetWorkspaceContainerName := getWorkspaceContainerName
+	getWorkspaceContainerName = func(ctx context.Context, userName string, workspaceId string) string {
+		return ""
 	}
 
 	return func() {
 		/* teardown */
+		listWorkspaces = originalListWorkspaces
+		getWorkspaceContainerName = originalGetWorkspaceContainerName
 	}
 }
@@ -0,0 +1,730 @@
+package hatchery
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	k8sv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ConfigError is a problem found in the configuration, at a JSON path such as `$.containers[0].cpu-limit`
+type ConfigError struct {
+	Path    string
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ConfigErrors are all the problems found in a configuration
+type ConfigErrors []ConfigError
+
+func (errs ConfigErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Explicit container ids are used in URLs and AWS tags
+var containerIdRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+type configValidator struct {
+	logger *log.Logger
+	errors ConfigErrors
+}
+
+func (v *configValidator) addf(path string, format string, args ...interface{}) {
+	v.errors = append(v.errors, ConfigError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkQuantity reports quantities that resource.MustParse would panic on when building the pod
+func (v *configValidator) checkQuantity(path string, quantity string) {
+	if quantity == "" {
+		v.addf(path, "is required")
+		return
+	}
+	if _, err := resource.ParseQuantity(quantity); err != nil {
+		v.addf(path, "invalid quantity '%s': %v", quantity, err)
+	}
+}
+
+// validateHatcheryConfig checks the whole configuration and returns every problem found.
+// `containerPaths` are the JSON paths of the containers: dockstore apps are reported
+// at the `more-configs` entry they were loaded from
+func validateHatcheryConfig(logger *log.Logger, config *HatcheryConfig, containerPaths []string) ConfigErrors {
+	v := &configValidator{logger: logger}
+
+	// pods get the fuse sidecar unless their container selects a profile
+	for _, container := range config.Containers {
+		if container.Sidecar == "" {
+			v.validateSidecar("$.sidecar", config.Sidecar)
+			break
+		}
+	}
+	// sorted so that the errors are reported in a stable order
+	profileNames := make([]string, 0, len(config.SidecarProfiles))
+	for name := range config.SidecarProfiles {
+		profileNames = append(profileNames, name)
+	}
+	sort.Strings(profileNames)
+	for _, name := range profileNames {
+		path := "$.sidecar-profiles." + name
+		if name == noSidecar {
+			v.addf(path, "'%s' is reserved for containers without sidecar", noSidecar)
+		}
+		v.validateSidecar(path, config.SidecarProfiles[name])
+	}
+
+	if config.SecurityProfile != nil {
+		v.validateSecurityProfile("$.security-profile", *config.SecurityProfile)
+	}
+	switch config.PodSecurityStandard {
+	case "":
+	case podSecurityRestricted:
+		// the global profile is checked once, for all the containers which use it
+		for _, container := range config.Containers {
+			if container.SecurityProfile != nil {
+				continue
+			}
+			if config.SecurityProfile == nil {
+				v.addf("$.security-profile", "is required by the '%s' pod security standard", podSecurityRestricted)
+			} else {
+				v.validateRestrictedSecurityProfile("$.security-profile", *config.SecurityProfile)
+			}
+			break
+		}
+	default:
+		v.addf("$.pod-security-standard", "invalid standard '%s': must be empty or '%s'", config.PodSecurityStandard, podSecurityRestricted)
+	}
+
+	switch config.Pricing.Basis {
+	case "", pricingBasisRequest, pricingBasisLimit:
+	default:
+		v.addf("$.pricing.basis", "invalid basis '%s': must be one of '%s' or '%s'", config.Pricing.Basis, pricingBasisRequest, pricingBasisLimit)
+	}
+
+	if config.LicenseUserMapsTable != "" && config.LicenseUserMapsGSI == "" {
+		v.addf("$.license-user-maps-global-secondary-index", "'license-user-maps-dynamodb-table' is present but missing 'license-user-maps-global-secondary-index'")
+	}
+
+	usesUserVolume := false
+	// explicit and legacy ids, to the path of their container
+	containerIds := map[string]string{}
+	for i, container := range config.Containers {
+		v.validateContainer(config, containerPaths[i], container)
+		v.validateContainerIds(containerPaths[i], container, containerIds)
+		usesUserVolume = usesUserVolume || container.UserVolumeLocation != ""
+	}
+	if usesUserVolume && config.UserVolume.Size == "" {
+		v.checkQuantity("$.user-volume-size", config.UserVolumeSize)
+	}
+	v.validateUserVolumeConfig("$.user-volume", config.UserVolume)
+
+	// sorted so that the errors are reported in a stable order
+	payModelTypes := make([]string, 0, len(config.PayModelSizeCaps))
+	for payModelType := range config.PayModelSizeCaps {
+		payModelTypes = append(payModelTypes, payModelType)
+	}
+	sort.Strings(payModelTypes)
+	for _, payModelType := range payModelTypes {
+		v.validateSizeCaps(fmt.Sprintf("$.pay-model-size-caps.%s", payModelType), config.PayModelSizeCaps[payModelType])
+	}
+
+	payModelTypes = make([]string, 0, len(config.PayModelScheduling))
+	for payModelType := range config.PayModelScheduling {
+		payModelTypes = append(payModelTypes, payModelType)
+	}
+	sort.Strings(payModelTypes)
+	for _, payModelType := range payModelTypes {
+		v.validateScheduling(fmt.Sprintf("$.pay-model-scheduling.%s", payModelType), config.PayModelScheduling[payModelType])
+	}
+
+	payModelTypes = make([]string, 0, len(config.PayModelUserVolume))
+	for payModelType := range config.PayModelUserVolume {
+		payModelTypes = append(payModelTypes, payModelType)
+	}
+	sort.Strings(payModelTypes)
+	for _, payModelType := range payModelTypes {
+		v.validateUserVolumeConfig(fmt.Sprintf("$.pay-model-user-volume.%s", payModelType), config.PayModelUserVolume[payModelType])
+	}
+
+	v.validateRoutingConfig("$.routing", config.Routing)
+	v.validateNetworkPolicyConfig("$.network-policy", config.NetworkPolicy)
+	if config.Snapshots.ScheduleIntervalHours < 0 {
+		v.addf("$.snapshots.schedule-interval-hours", "invalid interval %d: must not be negative", config.Snapshots.ScheduleIntervalHours)
+	}
+
+	if _, err := newAuditSink(config.Audit); err != nil {
+		v.addf("$.audit", "%v", err)
+	}
+	return v.errors
+}
+
+func (v *configValidator) validateContainer(config *HatcheryConfig, path string, container Container) {
+	if container.Name == "" {
+		v.addf(path+".name", "is required")
+	}
+
+	// some pods (ex - dockstore apps) only have "Friend" containers
+	if container.Image != "" {
+		v.checkQuantity(path+".cpu-limit", container.CPULimit)
+		v.checkQuantity(path+".memory-limit", container.MemoryLimit)
+		v.validateRequests(path, container.CPULimit, container.CPURequest, container.MemoryLimit, container.MemoryRequest, container.RequestRatio)
+		switch container.PullPolicy {
+		case "", "IfNotPresent", "Always", "Never":
+		default:
+			v.addf(path+".pull_policy", "invalid pull policy '%s': must be one of 'IfNotPresent', 'Always' or 'Never'", container.PullPolicy)
+		}
+	}
+
+	if container.UserVolume != nil {
+		v.validateUserVolumeConfig(path+".user-volume", *container.UserVolume)
+	}
+	v.validatePodVolumes(path, container)
+	if _, err := containerSidecar(config, &container); err != nil {
+		v.addf(path+".sidecar", "%v: must be one of the 'sidecar-profiles' or '%s'", err, noSidecar)
+	}
+	if container.SecurityProfile != nil {
+		v.validateSecurityProfile(path+".security-profile", *container.SecurityProfile)
+	}
+	if config.PodSecurityStandard == podSecurityRestricted {
+		v.validateRestrictedContainer(config, path, container)
+	}
+
+	// the workspace service forwards to the target port, which the readiness probe also uses
+	if container.TargetPort < 1 || container.TargetPort > 65535 {
+		v.addf(path+".target-port", "invalid port %d: must be between 1 and 65535", container.TargetPort)
+	}
+	if container.ReadyProbe != "" && !strings.HasPrefix(container.ReadyProbe, "/") {
+		v.addf(path+".ready-probe", "invalid path '%s': must start with '/'", container.ReadyProbe)
+	}
+
+	// catalog links are shown by the portal
+	for _, link := range []struct {
+		field string
+		value string
+	}{
+		{"logo-url", container.LogoURL},
+		{"documentation-url", container.DocumentationURL},
+	} {
+		if link.value == "" {
+			continue
+		}
+		if parsed, err := url.Parse(link.value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			v.addf(path+"."+link.field, "invalid URL '%s': must be an absolute http or https URL", link.value)
+		}
+	}
+	if container.Deprecated && container.Beta {
+		v.addf(path+".beta", "can't be set for a deprecated container")
+	}
+
+	if container.GPUCount < 0 {
+		v.addf(path+".gpu-count", "must not be negative")
+	}
+	if container.GPUResourceName != "" {
+		// extended resources are always prefixed with a domain
+		if errs := validation.IsQualifiedName(container.GPUResourceName); len(errs) > 0 || !strings.Contains(container.GPUResourceName, "/") {
+			v.addf(path+".gpu-resource-name", "invalid extended resource name '%s': must be a domain-prefixed name such as 'nvidia.com/gpu'", container.GPUResourceName)
+		}
+	}
+	if container.GPUModel != "" && !container.GPU {
+		v.addf(path+".gpu-model", "can only be set when 'gpu' is true")
+	}
+	if container.Sizes != nil {
+		v.validateContainerSizes(path+".sizes", *container.Sizes)
+	}
+
+	v.validateScheduling(path, container.WorkspaceScheduling)
+	for i, rule := range container.NetworkEgress {
+		v.validateNetworkPolicyRule(fmt.Sprintf("%s.network-egress[%d]", path, i), rule)
+	}
+
+	if container.NextflowConfig.Enabled {
+		v.validateNextflowConfig(path+".nextflow", container.NextflowConfig)
+	}
+
+	if container.License.Enabled {
+		if config.LicenseUserMapsTable == "" {
+			v.addf(path+".license", "no 'license-user-maps-dynamodb-table' in configuration but license is configured for container '%s'", container.Name)
+		}
+		v.validateLicenseInfo(path+".license", container.License)
+	}
+
+	if err := ValidateAuthzConfig(v.logger, container.Authz); err != nil {
+		v.addf(path+".authz", "%v", err)
+	}
+}
+
+func (v *configValidator) validateContainerIds(path string, container Container, containerIds map[string]string) {
+	if container.ID == "" {
+		if len(container.LegacyIds) > 0 {
+			v.addf(path+".legacy-ids", "can only be set for containers with an explicit 'id'")
+		}
+		return
+	}
+	if !containerIdRegex.MatchString(container.ID) {
+		v.addf(path+".id", "invalid id '%s': must be 1 to 63 letters, digits, '.', '_' or '-'", container.ID)
+	}
+	checkUnique := func(idPath string, id string) {
+		if other, ok := containerIds[id]; ok {
+			v.addf(idPath, "id '%s' is already used by %s", id, other)
+			return
+		}
+		containerIds[id] = path
+	}
+	checkUnique(path+".id", container.ID)
+	for i, legacyId := range container.LegacyIds {
+		checkUnique(fmt.Sprintf("%s.legacy-ids[%d]", path, i), legacyId)
+	}
+}
+
+func (v *configValidator) validateSidecar(path string, sidecar SidecarContainer) {
+	v.checkQuantity(path+".cpu-limit", sidecar.CPULimit)
+	v.checkQuantity(path+".memory-limit", sidecar.MemoryLimit)
+	v.validateRequests(path, sidecar.CPULimit, sidecar.CPURequest, sidecar.MemoryLimit, sidecar.MemoryRequest, sidecar.RequestRatio)
+}
+
+// checkOptionalQuantity reports invalid quantities, if set
+func (v *configValidator) checkOptionalQuantity(path string, quantity string) {
+	if quantity != "" {
+		v.checkQuantity(path, quantity)
+	}
+}
+
+// validateUserVolumeConfig checks the settings of the user volumes. The storage class
+// is only known to the cluster
+func (v *configValidator) validateUserVolumeConfig(path string, volume UserVolumeConfig) {
+	errorCount := len(v.errors)
+	v.checkOptionalQuantity(path+".size", volume.Size)
+	v.checkOptionalQuantity(path+".max-size", volume.MaxSize)
+	if len(v.errors) == errorCount && volume.Size != "" && volume.MaxSize != "" {
+		size := resource.MustParse(volume.Size)
+		if size.Cmp(resource.MustParse(volume.MaxSize)) > 0 {
+			v.addf(path+".size", "invalid size %s: greater than the max-size %s", volume.Size, volume.MaxSize)
+		}
+	}
+	switch k8sv1.PersistentVolumeAccessMode(volume.AccessMode) {
+	case "", k8sv1.ReadWriteOnce, k8sv1.ReadWriteOncePod, k8sv1.ReadWriteMany:
+	default:
+		v.addf(path+".access-mode", "invalid access mode '%s': must be one of '%s', '%s' or '%s'", volume.AccessMode, k8sv1.ReadWriteOnce, k8sv1.ReadWriteOncePod, k8sv1.ReadWriteMany)
+	}
+}
+
+// validatePodVolumes checks the init containers and extra volumes, which the API server
+// would reject when the pod is created
+func (v *configValidator) validatePodVolumes(path string, container Container) {
+	containerNames := map[string]bool{}
+	if container.Sidecar != noSidecar {
+		containerNames["fuse-container"] = true
+	}
+	if container.Image != "" {
+		containerNames["hatchery-container"] = true
+	}
+	for _, friend := range container.Friends {
+		containerNames[friend.Name] = true
+	}
+	for i, initContainer := range container.InitContainers {
+		initPath := fmt.Sprintf("%s.init-containers[%d]", path, i)
+		if initContainer.Name == "" {
+			v.addf(initPath+".name", "is required")
+		} else if containerNames[initContainer.Name] {
+			v.addf(initPath+".name", "container '%s' is already defined", initContainer.Name)
+		}
+		containerNames[initContainer.Name] = true
+		if initContainer.Image == "" {
+			v.addf(initPath+".image", "is required")
+		}
+	}
+
+	volumeNames := map[string]bool{}
+	for _, name := range builtinVolumeNames {
+		volumeNames[name] = true
+	}
+	for i, volume := range container.ExtraVolumes {
+		volumePath := fmt.Sprintf("%s.extra-volumes[%d]", path, i)
+		if errs := validation.IsDNS1123Label(volume.Name); len(errs) > 0 {
+			v.addf(volumePath+".name", "invalid name '%s': %s", volume.Name, strings.Join(errs, ", "))
+		} else if volumeNames[volume.Name] {
+			v.addf(volumePath+".name", "volume '%s' is already defined", volume.Name)
+		}
+		volumeNames[volume.Name] = true
+
+		sources := 0
+		if volume.ConfigMap != "" {
+			sources++
+		}
+		if volume.ServiceAccountToken != nil {
+			sources++
+			// the kubelet refuses tokens expiring in less than 10 minutes
+			if expiration := volume.ServiceAccountToken.ExpirationSeconds; expiration != 0 && expiration < 600 {
+				v.addf(volumePath+".service-account-token.expiration-seconds", "invalid expiration %d: must be at least 600 seconds", expiration)
+			}
+		}
+		if volume.EmptyDir != nil {
+			sources++
+			v.checkOptionalQuantity(volumePath+".empty-dir.size-limit", volume.EmptyDir.SizeLimit)
+			switch k8sv1.StorageMedium(volume.EmptyDir.Medium) {
+			case k8sv1.StorageMediumDefault, k8sv1.StorageMediumMemory:
+			default:
+				v.addf(volumePath+".empty-dir.medium", "invalid medium '%s': must be empty or '%s'", volume.EmptyDir.Medium, k8sv1.StorageMediumMemory)
+			}
+		}
+		if sources != 1 {
+			v.addf(volumePath, "exactly one of 'config-map', 'service-account-token' or 'empty-dir' is required")
+		}
+
+		for j, mount := range volume.Mounts {
+			mountPath := fmt.Sprintf("%s.mounts[%d]", volumePath, j)
+			if !strings.HasPrefix(mount.MountPath, "/") {
+				v.addf(mountPath+".mount-path", "invalid path '%s': must be absolute", mount.MountPath)
+			}
+			containerName := mount.Container
+			if containerName == "" {
+				containerName = "hatchery-container"
+			}
+			if !containerNames[containerName] {
+				v.addf(mountPath+".container", "container '%s' is not in the pod", containerName)
+			}
+		}
+	}
+}
+
+func (v *configValidator) validateSecurityProfile(path string, profile SecurityProfile) {
+	if profile.Seccomp != "" {
+		if _, err := buildSeccompProfile(profile.Seccomp); err != nil {
+			v.addf(path+".seccomp", "%v", err)
+		}
+	}
+	if profile.AppArmor != "" {
+		if _, err := buildAppArmorProfile(profile.AppArmor); err != nil {
+			v.addf(path+".apparmor", "%v", err)
+		}
+	}
+	if len(profile.WritablePaths) > 0 && !profile.ReadOnlyRootFilesystem {
+		v.addf(path+".writable-paths", "can only be set when 'read-only-root-filesystem' is true")
+	}
+	for i, writablePath := range profile.WritablePaths {
+		if !strings.HasPrefix(writablePath, "/") {
+			v.addf(fmt.Sprintf("%s.writable-paths[%d]", path, i), "invalid path '%s': must be absolute", writablePath)
+		}
+	}
+}
+
+// validateRestrictedSecurityProfile checks that the profile meets the Pod Security Admission
+// "restricted" level, see https://kubernetes.io/docs/concepts/security/pod-security-standards/
+func (v *configValidator) validateRestrictedSecurityProfile(path string, profile SecurityProfile) {
+	if profile.Seccomp != string(k8sv1.SeccompProfileTypeRuntimeDefault) && !strings.HasPrefix(profile.Seccomp, localhostProfilePrefix) {
+		v.addf(path+".seccomp", "must be 'RuntimeDefault' or 'Localhost/<profile>' for the '%s' pod security standard", podSecurityRestricted)
+	}
+	if profile.AppArmor == string(k8sv1.AppArmorProfileTypeUnconfined) {
+		v.addf(path+".apparmor", "can't be 'Unconfined' for the '%s' pod security standard", podSecurityRestricted)
+	}
+	dropsAll := false
+	for _, capability := range profile.DropCapabilities {
+		dropsAll = dropsAll || capability == "ALL"
+	}
+	if !dropsAll {
+		v.addf(path+".drop-capabilities", "must include 'ALL' for the '%s' pod security standard", podSecurityRestricted)
+	}
+	v.checkRestrictedCapabilities(path+".add-capabilities", profile.AddCapabilities)
+	if profile.AllowPrivilegeEscalation {
+		v.addf(path+".allow-privilege-escalation", "must be false for the '%s' pod security standard", podSecurityRestricted)
+	}
+	if !profile.RunAsNonRoot {
+		v.addf(path+".run-as-non-root", "must be true for the '%s' pod security standard", podSecurityRestricted)
+	}
+}
+
+// checkRestrictedCapabilities reports the capabilities containers can't add at the "restricted" level
+func (v *configValidator) checkRestrictedCapabilities(path string, capabilities []string) {
+	for _, capability := range capabilities {
+		if capability != "NET_BIND_SERVICE" {
+			v.addf(path, "can only add 'NET_BIND_SERVICE' for the '%s' pod security standard, not '%s'", podSecurityRestricted, capability)
+		}
+	}
+}
+
+// validateRestrictedContainer checks the parts of the container's pods the security profile
+// does not harden: the sidecar, and what the friends and init containers set themselves
+func (v *configValidator) validateRestrictedContainer(config *HatcheryConfig, path string, container Container) {
+	if container.SecurityProfile != nil {
+		v.validateRestrictedSecurityProfile(path+".security-profile", *container.SecurityProfile)
+	}
+	profile := containerSecurityProfile(config, &container)
+	if sidecar, err := containerSidecar(config, &container); err == nil && propagatesFuseMounts(sidecar) {
+		v.addf(path+".sidecar", "the sidecar must be unprivileged or 'none' for the '%s' pod security standard", podSecurityRestricted)
+	}
+	for _, group := range []struct {
+		field      string
+		containers []k8sv1.Container
+	}{
+		{"init-containers", container.InitContainers},
+		{"friends", container.Friends},
+	} {
+		for i, podContainer := range group.containers {
+			containerPath := fmt.Sprintf("%s.%s[%d]", path, group.field, i)
+			for _, port := range podContainer.Ports {
+				if port.HostPort != 0 {
+					v.addf(containerPath+".ports", "host ports are not allowed by the '%s' pod security standard", podSecurityRestricted)
+				}
+			}
+			securityContext := podContainer.SecurityContext
+			if securityContext == nil {
+				continue
+			}
+			if securityContext.Privileged != nil && *securityContext.Privileged {
+				v.addf(containerPath+".securityContext.privileged", "is not allowed by the '%s' pod security standard", podSecurityRestricted)
+			}
+			if securityContext.RunAsUser != nil && *securityContext.RunAsUser == 0 {
+				v.addf(containerPath+".securityContext.runAsUser", "can't be 0 for the '%s' pod security standard", podSecurityRestricted)
+			}
+			if securityContext.Capabilities != nil {
+				capabilities := []string{}
+				for _, capability := range securityContext.Capabilities.Add {
+					capabilities = append(capabilities, string(capability))
+				}
+				v.checkRestrictedCapabilities(containerPath+".securityContext.capabilities.add", capabilities)
+			}
+			// the profile's seccomp profile always replaces the containers'
+			if securityContext.AppArmorProfile != nil && securityContext.AppArmorProfile.Type == k8sv1.AppArmorProfileTypeUnconfined && (profile == nil || profile.AppArmor == "") {
+				v.addf(containerPath+".securityContext.appArmorProfile", "can't be 'Unconfined' for the '%s' pod security standard", podSecurityRestricted)
+			}
+		}
+	}
+}
+
+// validateRequests checks that the requests are not greater than the limits, which the
+// API server would reject
+func (v *configValidator) validateRequests(path string, cpuLimit string, cpuRequest string, memoryLimit string, memoryRequest string, ratio float64) {
+	for _, request := range []struct {
+		field   string
+		limit   string
+		request string
+	}{
+		{"cpu", cpuLimit, cpuRequest},
+		{"memory", memoryLimit, memoryRequest},
+	} {
+		if request.request == "" {
+			continue
+		}
+		errorCount := len(v.errors)
+		v.checkQuantity(path+"."+request.field+"-request", request.request)
+		if len(v.errors) > errorCount {
+			continue
+		}
+		requested := resource.MustParse(request.request)
+		if limit, err := resource.ParseQuantity(request.limit); err == nil && requested.Cmp(limit) > 0 {
+			v.addf(path+"."+request.field+"-request", "invalid request %s: greater than the limit %s", request.request, request.limit)
+		}
+	}
+	if ratio < 0 || ratio > 1 {
+		v.addf(path+".request-ratio", "invalid ratio %v: must be between 0 and 1", ratio)
+	}
+}
+
+func (v *configValidator) validateContainerSizes(path string, sizes ContainerSizes) {
+	tierNames := map[string]bool{}
+	for i, tier := range sizes.Tiers {
+		tierPath := fmt.Sprintf("%s.tiers[%d]", path, i)
+		if tier.Name == "" {
+			v.addf(tierPath+".name", "is required")
+		} else if tierNames[tier.Name] {
+			v.addf(tierPath+".name", "tier '%s' is already defined", tier.Name)
+		}
+		tierNames[tier.Name] = true
+		v.checkOptionalQuantity(tierPath+".cpu-limit", tier.CPULimit)
+		v.checkOptionalQuantity(tierPath+".memory-limit", tier.MemoryLimit)
+		v.checkOptionalQuantity(tierPath+".volume-size", tier.VolumeSize)
+		if tier.GPUCount != nil && *tier.GPUCount < 0 {
+			v.addf(tierPath+".gpu-count", "must not be negative")
+		}
+	}
+	for _, quantityRange := range []struct {
+		field  string
+		bounds *QuantityRange
+	}{
+		{"cpu-limit", sizes.CPULimit},
+		{"memory-limit", sizes.MemoryLimit},
+		{"volume-size", sizes.VolumeSize},
+	} {
+		if quantityRange.bounds != nil {
+			v.validateQuantityRange(path+"."+quantityRange.field, *quantityRange.bounds)
+		}
+	}
+	if sizes.GPUCount != nil && (sizes.GPUCount.Min < 0 || sizes.GPUCount.Min > sizes.GPUCount.Max) {
+		v.addf(path+".gpu-count", "invalid range %d-%d: 'min' must be between 0 and 'max'", sizes.GPUCount.Min, sizes.GPUCount.Max)
+	}
+}
+
+func (v *configValidator) validateQuantityRange(path string, bounds QuantityRange) {
+	errorCount := len(v.errors)
+	v.checkQuantity(path+".min", bounds.Min)
+	v.checkQuantity(path+".max", bounds.Max)
+	if len(v.errors) > errorCount {
+		return
+	}
+	min := resource.MustParse(bounds.Min)
+	if min.Cmp(resource.MustParse(bounds.Max)) > 0 {
+		v.addf(path, "invalid range %s-%s: 'min' is greater than 'max'", bounds.Min, bounds.Max)
+	}
+}
+
+func (v *configValidator) validateSizeCaps(path string, caps WorkspaceSizeCaps) {
+	v.checkOptionalQuantity(path+".cpu-limit", caps.CPULimit)
+	v.checkOptionalQuantity(path+".memory-limit", caps.MemoryLimit)
+	v.checkOptionalQuantity(path+".volume-size", caps.VolumeSize)
+	if caps.GPUCount != nil && *caps.GPUCount < 0 {
+		v.addf(path+".gpu-count", "must not be negative")
+	}
+}
+
+// validateScheduling checks the settings the API server would reject when the pod is created
+func (v *configValidator) validateScheduling(path string, scheduling WorkspaceScheduling) {
+	for i, toleration := range scheduling.Tolerations {
+		tolerationPath := fmt.Sprintf("%s.tolerations[%d]", path, i)
+		switch toleration.Operator {
+		case "", k8sv1.TolerationOpEqual:
+		case k8sv1.TolerationOpExists:
+			if toleration.Value != "" {
+				v.addf(tolerationPath+".value", "must be empty for the 'Exists' operator")
+			}
+		default:
+			v.addf(tolerationPath+".operator", "invalid operator '%s': must be 'Equal' or 'Exists'", toleration.Operator)
+		}
+		switch toleration.Effect {
+		case "", k8sv1.TaintEffectNoSchedule, k8sv1.TaintEffectPreferNoSchedule, k8sv1.TaintEffectNoExecute:
+		default:
+			v.addf(tolerationPath+".effect", "invalid effect '%s': must be one of 'NoSchedule', 'PreferNoSchedule' or 'NoExecute'", toleration.Effect)
+		}
+	}
+	for i, constraint := range scheduling.TopologySpread {
+		constraintPath := fmt.Sprintf("%s.topology-spread[%d]", path, i)
+		if constraint.MaxSkew < 1 {
+			v.addf(constraintPath+".maxSkew", "must be greater than 0")
+		}
+		if constraint.TopologyKey == "" {
+			v.addf(constraintPath+".topologyKey", "is required")
+		}
+		switch constraint.WhenUnsatisfiable {
+		case k8sv1.DoNotSchedule, k8sv1.ScheduleAnyway:
+		default:
+			v.addf(constraintPath+".whenUnsatisfiable", "invalid value '%s': must be 'DoNotSchedule' or 'ScheduleAnyway'", constraint.WhenUnsatisfiable)
+		}
+	}
+}
+
+func (v *configValidator) validateRoutingConfig(path string, routing RoutingConfig) {
+	switch routing.Type {
+	case "", routingAmbassadorAnnotation, routingEmissaryMapping:
+	case routingIngress:
+		// without a header match, users could reach each other's workspaces
+		matchesUser := false
+		for _, value := range routing.IngressAnnotations {
+			matchesUser = matchesUser || strings.Contains(value, "{{username}}")
+		}
+		if !matchesUser {
+			v.addf(path+".ingress-annotations", "must match the 'remote_user' header with an annotation using '{{username}}': ingresses can't match headers")
+		}
+	case routingGatewayHTTPRoute:
+		if routing.GatewayName == "" {
+			v.addf(path+".gateway-name", "is required for '%s' routing", routingGatewayHTTPRoute)
+		}
+	default:
+		v.addf(path+".type", "invalid routing type '%s': must be one of '%s', '%s', '%s' or '%s'", routing.Type, routingAmbassadorAnnotation, routingEmissaryMapping, routingIngress, routingGatewayHTTPRoute)
+	}
+}
+
+func (v *configValidator) validateNetworkPolicyConfig(path string, networkPolicy NetworkPolicyConfig) {
+	if networkPolicy.Enabled && len(networkPolicy.ClusterCIDRs) == 0 {
+		// workspaces could reach every service of the cluster otherwise
+		v.addf(path+".cluster-cidrs", "is required when network policies are enabled")
+	}
+	for i, cidr := range networkPolicy.IngressCIDRs {
+		v.checkCIDR(fmt.Sprintf("%s.ingress-cidrs[%d]", path, i), cidr)
+	}
+	for i, cidr := range networkPolicy.ClusterCIDRs {
+		v.checkCIDR(fmt.Sprintf("%s.cluster-cidrs[%d]", path, i), cidr)
+	}
+	for i, rule := range networkPolicy.AllowedEgress {
+		v.validateNetworkPolicyRule(fmt.Sprintf("%s.allowed-egress[%d]", path, i), rule)
+	}
+}
+
+func (v *configValidator) validateNetworkPolicyRule(path string, rule NetworkPolicyRule) {
+	if len(rule.CIDRs) == 0 && rule.PodSelector == nil && rule.NamespaceSelector == nil {
+		// a rule without peers would allow egress everywhere on its ports, including to the
+		// instance metadata service and the cluster
+		v.addf(path, "at least one of 'cidrs', 'pod-selector' or 'namespace-selector' is required")
+	}
+	for i, cidr := range rule.CIDRs {
+		v.checkCIDR(fmt.Sprintf("%s.cidrs[%d]", path, i), cidr)
+	}
+	for i, port := range rule.Ports {
+		portPath := fmt.Sprintf("%s.ports[%d]", path, i)
+		if port.Port < 1 || port.Port > 65535 {
+			v.addf(portPath+".port", "invalid port %d: must be between 1 and 65535", port.Port)
+		}
+		switch port.Protocol {
+		case "", "TCP", "UDP", "SCTP":
+		default:
+			v.addf(portPath+".protocol", "invalid protocol '%s': must be one of 'TCP', 'UDP' or 'SCTP'", port.Protocol)
+		}
+	}
+}
+
+func (v *configValidator) checkCIDR(path string, cidr string) {
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		v.addf(path, "invalid CIDR '%s': %v", cidr, err)
+	}
+}
+
+func (v *configValidator) validateNextflowConfig(path string, nextflowConfig NextflowConfig) {
+	switch nextflowConfig.ComputeEnvironmentType {
+	case "EC2", "SPOT", "FARGATE", "FARGATE_SPOT":
+	default:
+		v.addf(path+".compute-environment-type", "invalid type '%s': must be one of 'EC2', 'SPOT', 'FARGATE' or 'FARGATE_SPOT'", nextflowConfig.ComputeEnvironmentType)
+	}
+	if nextflowConfig.InstanceType == "" {
+		v.addf(path+".instance-type", "is required")
+	}
+	if nextflowConfig.InstanceAmi == "" && nextflowConfig.InstanceAmiBuilderArn == "" {
+		v.addf(path, "one of 'instance-ami' and 'instance-ami-builder-arn' must be configured")
+	}
+	if nextflowConfig.InstanceMaxVCpus <= 0 {
+		v.addf(path+".instance-max-vcpus", "must be greater than 0")
+	}
+	if nextflowConfig.InstanceMinVCpus < 0 || nextflowConfig.InstanceMinVCpus > nextflowConfig.InstanceMaxVCpus {
+		v.addf(path+".instance-min-vcpus", "must be between 0 and 'instance-max-vcpus'")
+	}
+}
+
+func (v *configValidator) validateLicenseInfo(path string, licenseInfo LicenseInfo) {
+	required := []struct {
+		field string
+		value string
+	}{
+		{"license-type", licenseInfo.LicenseType},
+		{"g3auto-name", licenseInfo.G3autoName},
+		{"g3auto-key", licenseInfo.G3autoKey},
+		{"file-path", licenseInfo.FilePath},
+		{"workspace-flavor", licenseInfo.WorkspaceFlavor},
+	}
+	for _, setting := range required {
+		if setting.value == "" {
+			v.addf(path+"."+setting.field, "is required when the license is enabled")
+		}
+	}
+	if licenseInfo.MaxLicenseIds <= 0 {
+		v.addf(path+".max-license-ids", "must be greater than 0 when the license is enabled")
+	}
+}
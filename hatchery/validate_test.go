@@ -0,0 +1,376 @@
+package hatchery
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+
+	k8sv1 "k8s.io/api/core/v1"
+)
+
+func Test_LoadConfigReportsAllErrors(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	var buf bytes.Buffer
+	config, err := LoadConfig("../testData/testConfigInvalid.json", log.New(&buf, "", 0))
+	if config != nil {
+		t.Errorf("Config load should have failed with config=nil. Got %v", config)
+	}
+	var configErrors ConfigErrors
+	if !errors.As(err, &configErrors) {
+		t.Fatalf("\nassertion error while testing `LoadConfig`: \nWant:ConfigErrors\nGot:%v", err)
+	}
+
+	wantPaths := []string{
+		"$.more-configs[0].name",
+		"$.more-configs[1].path",
+		"$.sidecar.memory-limit",
+		"$.containers[0].cpu-limit",
+		"$.containers[0].pull_policy",
+		"$.containers[0].ready-probe",
+		"$.containers[0].authz",
+		"$.containers[1].target-port",
+		"$.containers[1].nextflow",
+		"$.containers[1].nextflow.instance-max-vcpus",
+		"$.user-volume-size",
+		"$.audit",
+	}
+	gotPaths := []string{}
+	for _, configError := range configErrors {
+		gotPaths = append(gotPaths, configError.Path)
+	}
+	if strings.Join(gotPaths, ",") != strings.Join(wantPaths, ",") {
+		t.Errorf("\nassertion error while testing `LoadConfig` error paths: \nWant:%v\nGot:%v", wantPaths, configErrors)
+	}
+
+	// every error is logged
+	for _, configError := range configErrors {
+		if !strings.Contains(buf.String(), "Error in configuration: "+configError.Error()) {
+			t.Errorf("\nassertion error while testing `LoadConfig` logs: \nWant:%v\nGot:%v", configError, buf.String())
+		}
+	}
+}
+
+func Test_ValidateHatcheryConfig(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	validContainer := Container{
+		Name:        "Jupyter",
+		Image:       "quay.io/cdis/jupyter:master",
+		CPULimit:    "1.0",
+		MemoryLimit: "512Mi",
+		PullPolicy:  "Always",
+		TargetPort:  8888,
+		ReadyProbe:  "/lw-workspace/proxy/",
+	}
+	sidecar := SidecarContainer{CPULimit: "0.1", MemoryLimit: "256Mi"}
+
+	testCases := []struct {
+		name      string
+		config    HatcheryConfig
+		wantPaths []string
+	}{
+		{
+			name:      "Valid",
+			config:    HatcheryConfig{Sidecar: sidecar, Containers: []Container{validContainer}},
+			wantPaths: []string{},
+		},
+		{
+			name:      "NoContainers",
+			config:    HatcheryConfig{},
+			wantPaths: []string{},
+		},
+		{
+			name:      "MissingSidecarQuantities",
+			config:    HatcheryConfig{Containers: []Container{validContainer}},
+			wantPaths: []string{"$.sidecar.cpu-limit", "$.sidecar.memory-limit"},
+		},
+		{
+			name: "DockstoreAppWithoutImage",
+			config: HatcheryConfig{Sidecar: sidecar, Containers: []Container{
+				{Name: "Dockstore", TargetPort: 8787, PullPolicy: "Sometimes"},
+			}},
+			wantPaths: []string{},
+		},
+		{
+			name: "InvalidLicense",
+			config: HatcheryConfig{Sidecar: sidecar, LicenseUserMapsTable: "table", LicenseUserMapsGSI: "index", Containers: []Container{
+				{Name: "Stata", TargetPort: 8888, License: LicenseInfo{Enabled: true, LicenseType: "STATA-HEAL", G3autoName: "stata", G3autoKey: "key", FilePath: "stata.lic"}},
+			}},
+			wantPaths: []string{"$.containers[0].license.workspace-flavor", "$.containers[0].license.max-license-ids"},
+		},
+		{
+			name: "InvalidContainerIds",
+			config: HatcheryConfig{Sidecar: sidecar, Containers: []Container{
+				{ID: "jupyter", Name: "Jupyter", TargetPort: 8888},
+				{ID: "jupyter", LegacyIds: []string{"abc"}, Name: "Jupyter 2", TargetPort: 8888},
+				{ID: "r studio", Name: "R Studio", TargetPort: 8787, LegacyIds: []string{"abc"}},
+				{Name: "Galaxy", TargetPort: 8080, LegacyIds: []string{"def"}},
+			}},
+			wantPaths: []string{"$.containers[1].id", "$.containers[2].id", "$.containers[2].legacy-ids[0]", "$.containers[3].legacy-ids"},
+		},
+		{
+			name: "InvalidSizes",
+			config: HatcheryConfig{Sidecar: sidecar, PayModelSizeCaps: map[string]WorkspaceSizeCaps{"Trial Workspace": {CPULimit: "two"}}, Containers: []Container{
+				{Name: "Jupyter", TargetPort: 8888, GPUCount: -1, Sizes: &ContainerSizes{
+					Tiers:       []WorkspaceSizeTier{{Name: "small", CPULimit: "1"}, {Name: "small", MemoryLimit: "lots"}},
+					CPULimit:    &QuantityRange{Min: "4", Max: "2"},
+					MemoryLimit: &QuantityRange{Min: "1Gi"},
+					GPUCount:    &CountRange{Min: 2, Max: 1},
+				}},
+			}},
+			wantPaths: []string{
+				"$.containers[0].gpu-count",
+				"$.containers[0].sizes.tiers[1].name",
+				"$.containers[0].sizes.tiers[1].memory-limit",
+				"$.containers[0].sizes.cpu-limit",
+				"$.containers[0].sizes.memory-limit.max",
+				"$.containers[0].sizes.gpu-count",
+				"$.pay-model-size-caps.Trial Workspace.cpu-limit",
+			},
+		},
+		{
+			name: "InvalidGPUs",
+			config: HatcheryConfig{Sidecar: sidecar, Containers: []Container{
+				{Name: "MIG", TargetPort: 8888, GPU: true, GPUResourceName: "mig-1g.5gb"},
+				{Name: "A100", TargetPort: 8888, GPUModel: "NVIDIA-A100-SXM4-40GB"},
+			}},
+			wantPaths: []string{"$.containers[0].gpu-resource-name", "$.containers[1].gpu-model"},
+		},
+		{
+			name: "InvalidRequests",
+			config: HatcheryConfig{
+				Sidecar: SidecarContainer{CPULimit: "0.1", MemoryLimit: "256Mi", CPURequest: "0.5", RequestRatio: 2},
+				Pricing: Pricing{Basis: "usage"},
+				Containers: []Container{
+					{Name: "Jupyter", TargetPort: 8888, Image: "jupyter", CPULimit: "1", MemoryLimit: "1Gi", CPURequest: "0.5", MemoryRequest: "512Mi"},
+					{Name: "RStudio", TargetPort: 8787, Image: "rstudio", CPULimit: "1", MemoryLimit: "1Gi", MemoryRequest: "2Gi", RequestRatio: -0.5},
+				},
+			},
+			wantPaths: []string{
+				"$.sidecar.cpu-request",
+				"$.sidecar.request-ratio",
+				"$.pricing.basis",
+				"$.containers[1].memory-request",
+				"$.containers[1].request-ratio",
+			},
+		},
+		{
+			name: "InvalidUserVolumes",
+			config: HatcheryConfig{
+				Sidecar:            sidecar,
+				UserVolume:         UserVolumeConfig{Size: "10Gi", MaxSize: "5Gi", AccessMode: "ReadOnlyMany"},
+				PayModelUserVolume: map[string]UserVolumeConfig{"Trial Workspace": {MaxSize: "lots"}},
+				Containers: []Container{
+					{Name: "Jupyter", TargetPort: 8888, UserVolumeLocation: "/home/jovyan/pd", UserVolume: &UserVolumeConfig{Size: "100 Gi"}},
+				},
+			},
+			wantPaths: []string{
+				"$.containers[0].user-volume.size",
+				"$.user-volume.size",
+				"$.user-volume.access-mode",
+				"$.pay-model-user-volume.Trial Workspace.max-size",
+			},
+		},
+		{
+			name:      "InvalidSnapshotSchedule",
+			config:    HatcheryConfig{Sidecar: sidecar, Snapshots: SnapshotConfig{Enabled: true, ScheduleIntervalHours: -24}},
+			wantPaths: []string{"$.snapshots.schedule-interval-hours"},
+		},
+		{
+			name: "SidecarProfiles",
+			config: HatcheryConfig{
+				SidecarProfiles: map[string]SidecarContainer{
+					"unprivileged": {CPULimit: "0.1", MemoryLimit: "256Mi", Unprivileged: true},
+					"none":         sidecar,
+					"large":        {CPULimit: "lots", MemoryLimit: "1Gi"},
+				},
+				Containers: []Container{
+					{Name: "Dashboard", TargetPort: 8888, Sidecar: "none"},
+					{Name: "Jupyter", TargetPort: 8888, Sidecar: "unprivileged"},
+					{Name: "RStudio", TargetPort: 8787, Sidecar: "privileged"},
+				},
+			},
+			// the global sidecar is not used, so its quantities are not required
+			wantPaths: []string{"$.sidecar-profiles.large.cpu-limit", "$.sidecar-profiles.none", "$.containers[2].sidecar"},
+		},
+		{
+			name: "InvalidSecurityProfile",
+			config: HatcheryConfig{
+				Sidecar:             sidecar,
+				PodSecurityStandard: "baseline",
+				SecurityProfile:     &SecurityProfile{Seccomp: "runtime/default", WritablePaths: []string{"/tmp"}},
+				Containers: []Container{
+					{Name: "Jupyter", TargetPort: 8888, SecurityProfile: &SecurityProfile{AppArmor: "Localhost/", ReadOnlyRootFilesystem: true, WritablePaths: []string{"tmp"}}},
+				},
+			},
+			wantPaths: []string{
+				"$.security-profile.seccomp",
+				"$.security-profile.writable-paths",
+				"$.pod-security-standard",
+				"$.containers[0].security-profile.apparmor",
+				"$.containers[0].security-profile.writable-paths[0]",
+			},
+		},
+		{
+			name: "RestrictedPodSecurity",
+			config: HatcheryConfig{
+				Sidecar:             sidecar,
+				SidecarProfiles:     map[string]SidecarContainer{"unprivileged": {CPULimit: "0.1", MemoryLimit: "256Mi", Unprivileged: true}},
+				PodSecurityStandard: podSecurityRestricted,
+				SecurityProfile:     &SecurityProfile{Seccomp: "RuntimeDefault", DropCapabilities: []string{"ALL"}, RunAsNonRoot: true},
+				Containers: []Container{
+					{Name: "Dashboard", TargetPort: 8888, Sidecar: "none"},
+					{Name: "Jupyter", TargetPort: 8888},
+					{Name: "RStudio", TargetPort: 8787, Sidecar: "unprivileged", SecurityProfile: &SecurityProfile{Seccomp: "Unconfined", AddCapabilities: []string{"SYS_ADMIN"}, AllowPrivilegeEscalation: true}},
+					{Name: "Dockstore", TargetPort: 8787, Sidecar: "none", Friends: []k8sv1.Container{
+						{Name: "firefox", Ports: []k8sv1.ContainerPort{{ContainerPort: 5800, HostPort: 5800}}, SecurityContext: &k8sv1.SecurityContext{
+							Privileged:      &trueVal,
+							Capabilities:    &k8sv1.Capabilities{Add: []k8sv1.Capability{"NET_BIND_SERVICE", "NET_ADMIN"}},
+							AppArmorProfile: &k8sv1.AppArmorProfile{Type: k8sv1.AppArmorProfileTypeUnconfined},
+						}},
+					}},
+				},
+			},
+			wantPaths: []string{
+				"$.containers[1].sidecar",
+				"$.containers[2].security-profile.seccomp",
+				"$.containers[2].security-profile.drop-capabilities",
+				"$.containers[2].security-profile.add-capabilities",
+				"$.containers[2].security-profile.allow-privilege-escalation",
+				"$.containers[2].security-profile.run-as-non-root",
+				"$.containers[3].friends[0].ports",
+				"$.containers[3].friends[0].securityContext.privileged",
+				"$.containers[3].friends[0].securityContext.capabilities.add",
+				"$.containers[3].friends[0].securityContext.appArmorProfile",
+			},
+		},
+		{
+			name: "InvalidInitContainersAndExtraVolumes",
+			config: HatcheryConfig{Sidecar: sidecar, Containers: []Container{
+				{
+					Name: "Jupyter", Image: "jupyter", CPULimit: "1", MemoryLimit: "1Gi", TargetPort: 8888,
+					InitContainers: []k8sv1.Container{{Name: "seed-notebooks", Image: "jupyter"}, {Name: "hatchery-container"}},
+					ExtraVolumes: []ExtraVolume{
+						{Name: "notebooks", ConfigMap: "notebooks", Mounts: []ExtraVolumeMount{{MountPath: "/home/jovyan/examples"}, {Container: "seed-notebooks", MountPath: "/examples"}}},
+						{Name: "user-data", EmptyDir: &EmptyDirVolume{}},
+						{Name: "scratch", EmptyDir: &EmptyDirVolume{SizeLimit: "lots", Medium: "HugePages"}, Mounts: []ExtraVolumeMount{{Container: "viewer", MountPath: "scratch"}}},
+						{Name: "token", ConfigMap: "token", ServiceAccountToken: &ServiceAccountTokenVolume{ExpirationSeconds: 60}},
+					},
+				},
+			}},
+			wantPaths: []string{
+				"$.containers[0].init-containers[1].name",
+				"$.containers[0].init-containers[1].image",
+				"$.containers[0].extra-volumes[1].name",
+				"$.containers[0].extra-volumes[2].empty-dir.size-limit",
+				"$.containers[0].extra-volumes[2].empty-dir.medium",
+				"$.containers[0].extra-volumes[2].mounts[0].mount-path",
+				"$.containers[0].extra-volumes[2].mounts[0].container",
+				"$.containers[0].extra-volumes[3].service-account-token.expiration-seconds",
+				"$.containers[0].extra-volumes[3]",
+			},
+		},
+		{
+			name: "InvalidCatalogMetadata",
+			config: HatcheryConfig{Sidecar: sidecar, Containers: []Container{
+				{Name: "Jupyter", TargetPort: 8888, LogoURL: "/logos/jupyter.png", DocumentationURL: "https://jupyter.org/docs", Deprecated: true, Beta: true},
+			}},
+			wantPaths: []string{"$.containers[0].logo-url", "$.containers[0].beta"},
+		},
+		{
+			name:      "InvalidRoutingType",
+			config:    HatcheryConfig{Routing: RoutingConfig{Type: "nginx"}},
+			wantPaths: []string{"$.routing.type"},
+		},
+		{
+			name:      "IngressWithoutUserMatch",
+			config:    HatcheryConfig{Routing: RoutingConfig{Type: "ingress", IngressAnnotations: map[string]string{"kubernetes.io/ingress.class": "nginx"}}},
+			wantPaths: []string{"$.routing.ingress-annotations"},
+		},
+		{
+			name:      "HTTPRouteWithoutGateway",
+			config:    HatcheryConfig{Routing: RoutingConfig{Type: "gateway-httproute"}},
+			wantPaths: []string{"$.routing.gateway-name"},
+		},
+		{
+			name: "InvalidNetworkPolicy",
+			config: HatcheryConfig{Sidecar: sidecar, NetworkPolicy: NetworkPolicyConfig{
+				Enabled:       true,
+				ClusterCIDRs:  []string{"10.0.0.0/8", "172.20.0.0"},
+				AllowedEgress: []NetworkPolicyRule{{}, {CIDRs: []string{"10.1.2.3/32"}, Ports: []NetworkPolicyPort{{Port: 0}, {Port: 443, Protocol: "HTTP"}}}},
+			}, Containers: []Container{
+				{Name: "Jupyter", TargetPort: 8888, NetworkEgress: []NetworkPolicyRule{{CIDRs: []string{"database"}}}},
+			}},
+			wantPaths: []string{
+				"$.containers[0].network-egress[0].cidrs[0]",
+				"$.network-policy.cluster-cidrs[1]",
+				"$.network-policy.allowed-egress[0]",
+				"$.network-policy.allowed-egress[1].ports[0].port",
+				"$.network-policy.allowed-egress[1].ports[1].protocol",
+			},
+		},
+		{
+			name: "NetworkPolicyPortsOnlyRules",
+			config: HatcheryConfig{Sidecar: sidecar, NetworkPolicy: NetworkPolicyConfig{
+				Enabled:       true,
+				ClusterCIDRs:  []string{"10.0.0.0/8"},
+				AllowedEgress: []NetworkPolicyRule{{Ports: []NetworkPolicyPort{{Port: 80}}}},
+			}, Containers: []Container{
+				{Name: "Jupyter", TargetPort: 8888, NetworkEgress: []NetworkPolicyRule{{Ports: []NetworkPolicyPort{{Port: 5432}}}}},
+			}},
+			wantPaths: []string{
+				"$.containers[0].network-egress[0]",
+				"$.network-policy.allowed-egress[0]",
+			},
+		},
+		{
+			name:      "NetworkPolicyWithoutClusterCIDRs",
+			config:    HatcheryConfig{Sidecar: sidecar, NetworkPolicy: NetworkPolicyConfig{Enabled: true}},
+			wantPaths: []string{"$.network-policy.cluster-cidrs"},
+		},
+		{
+			name: "InvalidScheduling",
+			config: HatcheryConfig{Sidecar: sidecar, PayModelScheduling: map[string]WorkspaceScheduling{
+				"Direct Pay": {Tolerations: []k8sv1.Toleration{{Key: "spot", Operator: "Exists", Value: "true"}}},
+			}, Containers: []Container{
+				{Name: "Jupyter", TargetPort: 8888, WorkspaceScheduling: WorkspaceScheduling{
+					Tolerations:    []k8sv1.Toleration{{Key: "role", Operator: "In", Value: "highmem", Effect: "NoRun"}},
+					TopologySpread: []k8sv1.TopologySpreadConstraint{{MaxSkew: 0, WhenUnsatisfiable: k8sv1.ScheduleAnyway}},
+				}},
+			}},
+			wantPaths: []string{
+				"$.containers[0].tolerations[0].operator",
+				"$.containers[0].tolerations[0].effect",
+				"$.containers[0].topology-spread[0].maxSkew",
+				"$.containers[0].topology-spread[0].topologyKey",
+				"$.pay-model-scheduling.Direct Pay.tolerations[0].value",
+			},
+		},
+		{
+			name: "InvalidNextflow",
+			config: HatcheryConfig{Sidecar: sidecar, Containers: []Container{
+				{Name: "Nextflow", TargetPort: 8888, NextflowConfig: NextflowConfig{Enabled: true, ComputeEnvironmentType: "ON_DEMAND", InstanceAmi: "ami-123", InstanceMinVCpus: 4, InstanceMaxVCpus: 2}},
+			}},
+			wantPaths: []string{"$.containers[0].nextflow.compute-environment-type", "$.containers[0].nextflow.instance-type", "$.containers[0].nextflow.instance-min-vcpus"},
+		},
+	}
+	for _, testcase := range testCases {
+		t.Logf("Testing validateHatcheryConfig when %s", testcase.name)
+		containerPaths := []string{}
+		for i := range testcase.config.Containers {
+			containerPaths = append(containerPaths, fmt.Sprintf("$.containers[%d]", i))
+		}
+		configErrors := validateHatcheryConfig(Config().Logger, &testcase.config, containerPaths)
+		gotPaths := []string{}
+		for _, configError := range configErrors {
+			gotPaths = append(gotPaths, configError.Path)
+		}
+		if strings.Join(gotPaths, ",") != strings.Join(testcase.wantPaths, ",") {
+			t.Errorf("\nassertion error while testing `%s`: \nWant:%v\nGot:%v", testcase.name, testcase.wantPaths, configErrors)
+		}
+	}
+}
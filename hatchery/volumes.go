@@ -0,0 +1,101 @@
+package hatchery
+
+import (
+	"fmt"
+
+	k8sv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// defaultServiceAccountTokenPath is the file of a projected service account token in its volume
+const defaultServiceAccountTokenPath = "token"
+
+// builtinVolumeNames are the volumes buildPod and buildWorkspaceSecret add to every pod,
+// which extra volumes can not shadow
+var builtinVolumeNames = []string{"shared-data", "gen3", "dshm", "user-data", "credentials", writableVolumeName}
+
+// buildExtraVolumes translates the extra volumes of a container to pod volumes
+func buildExtraVolumes(extraVolumes []ExtraVolume) ([]k8sv1.Volume, error) {
+	volumes := []k8sv1.Volume{}
+	for _, extraVolume := range extraVolumes {
+		volume := k8sv1.Volume{Name: extraVolume.Name}
+		switch {
+		case extraVolume.ConfigMap != "":
+			volume.ConfigMap = &k8sv1.ConfigMapVolumeSource{
+				LocalObjectReference: k8sv1.LocalObjectReference{Name: extraVolume.ConfigMap},
+			}
+		case extraVolume.ServiceAccountToken != nil:
+			token := extraVolume.ServiceAccountToken
+			path := token.Path
+			if path == "" {
+				path = defaultServiceAccountTokenPath
+			}
+			projection := &k8sv1.ServiceAccountTokenProjection{
+				Audience: token.Audience,
+				Path:     path,
+			}
+			if token.ExpirationSeconds != 0 {
+				expirationSeconds := token.ExpirationSeconds
+				projection.ExpirationSeconds = &expirationSeconds
+			}
+			volume.Projected = &k8sv1.ProjectedVolumeSource{
+				Sources: []k8sv1.VolumeProjection{{ServiceAccountToken: projection}},
+			}
+		case extraVolume.EmptyDir != nil:
+			emptyDir := &k8sv1.EmptyDirVolumeSource{
+				Medium: k8sv1.StorageMedium(extraVolume.EmptyDir.Medium),
+			}
+			if extraVolume.EmptyDir.SizeLimit != "" {
+				sizeLimit, err := resource.ParseQuantity(extraVolume.EmptyDir.SizeLimit)
+				if err != nil {
+					return nil, fmt.Errorf("invalid size limit for volume '%s': %v", extraVolume.Name, err)
+				}
+				emptyDir.SizeLimit = &sizeLimit
+			}
+			volume.EmptyDir = emptyDir
+		default:
+			return nil, fmt.Errorf("volume '%s' has no source", extraVolume.Name)
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes, nil
+}
+
+// mountExtraVolumes mounts the extra volumes in the init containers and containers of the pod
+func mountExtraVolumes(pod *k8sv1.Pod, extraVolumes []ExtraVolume) error {
+	for _, extraVolume := range extraVolumes {
+		for _, mount := range extraVolume.Mounts {
+			containerName := mount.Container
+			if containerName == "" {
+				containerName = "hatchery-container"
+			}
+			container := podContainer(pod, containerName)
+			if container == nil {
+				return fmt.Errorf("volume '%s' is mounted in container '%s', which is not in the pod", extraVolume.Name, containerName)
+			}
+			// the mounts of the friends and init containers are shared with the config
+			container.VolumeMounts = append(append([]k8sv1.VolumeMount{}, container.VolumeMounts...), k8sv1.VolumeMount{
+				Name:      extraVolume.Name,
+				MountPath: mount.MountPath,
+				SubPath:   mount.SubPath,
+				ReadOnly:  mount.ReadOnly || extraVolume.ConfigMap != "" || extraVolume.ServiceAccountToken != nil,
+			})
+		}
+	}
+	return nil
+}
+
+// podContainer finds a container or an init container of the pod by name
+func podContainer(pod *k8sv1.Pod, name string) *k8sv1.Container {
+	for i := range pod.Spec.InitContainers {
+		if pod.Spec.InitContainers[i].Name == name {
+			return &pod.Spec.InitContainers[i]
+		}
+	}
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == name {
+			return &pod.Spec.Containers[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package hatchery
+
+import (
+	"testing"
+
+	k8sv1 "k8s.io/api/core/v1"
+)
+
+func Test_BuildPodWithInitContainersAndExtraVolumes(t *testing.T) {
+	defer SetupAndTeardownTest()()
+	loadRenderTestConfig(t)
+
+	seedMounts := []k8sv1.VolumeMount{{Name: "user-data", MountPath: "/home/jovyan"}}
+	hatchApp := &Container{
+		Name:               "Jupyter",
+		Image:              "jupyter",
+		CPULimit:           "1",
+		MemoryLimit:        "1Gi",
+		UserVolumeLocation: "/home/jovyan",
+		InitContainers: []k8sv1.Container{
+			{Name: "seed-notebooks", Image: "jupyter", Command: []string{"cp", "-rn", "/examples", "/home/jovyan"}, VolumeMounts: seedMounts},
+		},
+		ExtraVolumes: []ExtraVolume{
+			{Name: "notebooks", ConfigMap: "example-notebooks", Mounts: []ExtraVolumeMount{{Container: "seed-notebooks", MountPath: "/examples"}}},
+			{Name: "oidc-token", ServiceAccountToken: &ServiceAccountTokenVolume{Audience: "sts.amazonaws.com", ExpirationSeconds: 3600}, Mounts: []ExtraVolumeMount{{MountPath: "/var/run/secrets/oidc"}}},
+			{Name: "scratch", EmptyDir: &EmptyDirVolume{SizeLimit: "10Gi"}, Mounts: []ExtraVolumeMount{{MountPath: "/scratch"}}},
+		},
+	}
+	pod, err := buildPod(Config(), hatchApp, "frickjack", "", nil)
+	if err != nil {
+		t.Fatalf("failed to build a pod - %v", err)
+	}
+
+	if len(pod.Spec.InitContainers) != 1 || pod.Spec.InitContainers[0].Name != "seed-notebooks" {
+		t.Fatalf("\nassertion error while testing `buildPod` init containers: \nWant:%s\nGot:%+v", "seed-notebooks", pod.Spec.InitContainers)
+	}
+	volumes := map[string]k8sv1.Volume{}
+	for _, volume := range pod.Spec.Volumes {
+		volumes[volume.Name] = volume
+	}
+	if volume := volumes["notebooks"]; volume.ConfigMap == nil || volume.ConfigMap.Name != "example-notebooks" {
+		t.Errorf("\nassertion error while testing `buildExtraVolumes` ConfigMap: \nWant:%s\nGot:%+v", "example-notebooks", volume)
+	}
+	if volume := volumes["oidc-token"]; volume.Projected == nil || volume.Projected.Sources[0].ServiceAccountToken.Path != defaultServiceAccountTokenPath ||
+		*volume.Projected.Sources[0].ServiceAccountToken.ExpirationSeconds != 3600 {
+		t.Errorf("\nassertion error while testing `buildExtraVolumes` service account token: \nWant:%s\nGot:%+v", defaultServiceAccountTokenPath, volume)
+	}
+	if volume := volumes["scratch"]; volume.EmptyDir == nil || volume.EmptyDir.SizeLimit.String() != "10Gi" {
+		t.Errorf("\nassertion error while testing `buildExtraVolumes` emptyDir: \nWant:%s\nGot:%+v", "10Gi", volume)
+	}
+
+	mountPaths := func(container *k8sv1.Container) map[string]string {
+		paths := map[string]string{}
+		for _, mount := range container.VolumeMounts {
+			paths[mount.Name] = mount.MountPath
+		}
+		return paths
+	}
+	if paths := mountPaths(podContainer(pod, "seed-notebooks")); paths["notebooks"] != "/examples" || paths["user-data"] != "/home/jovyan" {
+		t.Errorf("\nassertion error while testing `mountExtraVolumes` init container: \nWant:%s\nGot:%v", "/examples", paths)
+	}
+	if paths := mountPaths(podContainer(pod, "hatchery-container")); paths["oidc-token"] != "/var/run/secrets/oidc" || paths["scratch"] != "/scratch" {
+		t.Errorf("\nassertion error while testing `mountExtraVolumes` workspace container: \nWant:%s\nGot:%v", "/scratch", paths)
+	}
+	if paths := mountPaths(podContainer(pod, "fuse-container")); paths["scratch"] != "" {
+		t.Errorf("\nassertion error while testing `mountExtraVolumes` sidecar: \nWant:%s\nGot:%v", "no extra volume", paths)
+	}
+	// the container config is left untouched for the next launch
+	if len(hatchApp.InitContainers[0].VolumeMounts) != 1 {
+		t.Errorf("\nassertion error while testing `mountExtraVolumes` config: \nWant:%d\nGot:%+v", 1, hatchApp.InitContainers[0].VolumeMounts)
+	}
+
+	// mounting in a container that is not in the pod is an error
+	hatchApp.ExtraVolumes[2].Mounts = append(hatchApp.ExtraVolumes[2].Mounts, ExtraVolumeMount{Container: "viewer", MountPath: "/scratch"})
+	if _, err := buildPod(Config(), hatchApp, "frickjack", "", nil); err == nil {
+		t.Errorf("\nassertion error while testing `mountExtraVolumes` unknown container: \nWant:%s\nGot:%v", "an error", err)
+	}
+}
@@ -13,7 +13,7 @@ import (
 )
 
 func setupVPC(userName string) (*string, error) {
-	Config.Logger.Printf("Setting up VPC for user %s", userName)
+	Config().Logger.Printf("Setting up VPC for user %s", userName)
 	pm, err := getCurrentPayModel(userName)
 	if err != nil {
 		return nil, err
@@ -42,7 +42,7 @@ func setupVPC(userName string) (*string, error) {
 	}
 	subnetString := subnet.String()
 
-	Config.Logger.Printf("Using subnet: %s for user %s. Make sure this does not overlap with other users", subnetString, userName)
+	Config().Logger.Printf("Using subnet: %s for user %s. Make sure this does not overlap with other users", subnetString, userName)
 
 	// VPC stuff
 	vpcname := userToResourceName(userName, "service") + "-" + strings.ReplaceAll(os.Getenv("GEN3_ENDPOINT"), ".", "-") + "-vpc"
@@ -72,7 +72,7 @@ func setupVPC(userName string) (*string, error) {
 		if err != nil {
 			return nil, err
 		}
-		Config.Logger.Printf("VPC created in remote account")
+		Config().Logger.Printf("VPC created in remote account")
 		_, err = createInternetGW(vpcname, *vpc.Vpc.VpcId, ec2Remote)
 		if err != nil {
 			return nil, err
@@ -149,7 +149,7 @@ func createSubnet(vpccidr string, vpcid string, svc *ec2.EC2) error {
 		panic(err)
 	}
 
-	Config.Logger.Print(cidrs)
+	Config().Logger.Print(cidrs)
 	createSubnet1Input := &ec2.CreateSubnetInput{
 		CidrBlock: aws.String(subnet1Cidr.String()),
 		//TODO: Make this configurable ?
@@ -175,7 +175,7 @@ func createSubnet(vpccidr string, vpcid string, svc *ec2.EC2) error {
 }
 
 func createInternetGW(name string, vpcid string, svc *ec2.EC2) (*string, error) {
-	Config.Logger.Printf("Setting up internet Gateway for VPC: %s", vpcid)
+	Config().Logger.Printf("Setting up internet Gateway for VPC: %s", vpcid)
 	describeInternetGWInput := &ec2.DescribeInternetGatewaysInput{
 		Filters: []*ec2.Filter{
 			{
@@ -189,7 +189,7 @@ func createInternetGW(name string, vpcid string, svc *ec2.EC2) (*string, error)
 		return nil, err
 	}
 	if len(exIgw.InternetGateways) == 0 {
-		Config.Logger.Printf("No existing gateways found. Creating internet gateway for VPC: %s", vpcid)
+		Config().Logger.Printf("No existing gateways found. Creating internet gateway for VPC: %s", vpcid)
 		createInternetGWInput := &ec2.CreateInternetGatewayInput{
 			TagSpecifications: []*ec2.TagSpecification{
 				{
@@ -238,7 +238,7 @@ func createInternetGW(name string, vpcid string, svc *ec2.EC2) (*string, error)
 			if aerr, ok := err.(awserr.Error); ok {
 				if aerr.Code() == "RouteAlreadyExists" {
 					// the route already exists, replace it
-					Config.Logger.Print("Debug: Route already exists, replacing it")
+					Config().Logger.Print("Debug: Route already exists, replacing it")
 					_, err = svc.ReplaceRoute(&ec2.ReplaceRouteInput{
 						DestinationCidrBlock: aws.String("0.0.0.0/0"),
 						GatewayId:            igw.InternetGateway.InternetGatewayId,
@@ -257,7 +257,7 @@ func createInternetGW(name string, vpcid string, svc *ec2.EC2) (*string, error)
 		return igw.InternetGateway.InternetGatewayId, nil
 	} else {
 		if len(exIgw.InternetGateways[0].Attachments) == 0 {
-			Config.Logger.Printf("Existing gateway found but not attached to IGW. Attaching internet gateway for VPC: %s", vpcid)
+			Config().Logger.Printf("Existing gateway found but not attached to IGW. Attaching internet gateway for VPC: %s", vpcid)
 			_, err = svc.AttachInternetGateway(&ec2.AttachInternetGatewayInput{
 				InternetGatewayId: exIgw.InternetGateways[0].InternetGatewayId,
 				VpcId:             &vpcid,
@@ -288,7 +288,7 @@ func createInternetGW(name string, vpcid string, svc *ec2.EC2) (*string, error)
 			if aerr, ok := err.(awserr.Error); ok {
 				if aerr.Code() == "RouteAlreadyExists" {
 					// the route already exists, replace it
-					Config.Logger.Print("Debug: Route already exists, replacing it")
+					Config().Logger.Print("Debug: Route already exists, replacing it")
 					_, err = svc.ReplaceRoute(&ec2.ReplaceRouteInput{
 						DestinationCidrBlock: aws.String("0.0.0.0/0"),
 						GatewayId:            exIgw.InternetGateways[0].InternetGatewayId,
@@ -312,7 +312,7 @@ func createInternetGW(name string, vpcid string, svc *ec2.EC2) (*string, error)
 func VPCRoutes(remote_network_info *NetworkInfo, main_network_info *NetworkInfo, tgwId *string, ec2_remote *ec2.EC2, ec2_local *ec2.EC2, teardown bool) error {
 	if !teardown {
 		for _, cidr := range main_network_info.vpcCidrBlocks {
-			Config.Logger.Printf("Creating route to %s in remote VPC route table %s", *cidr, *main_network_info.routeTable.RouteTables[0].RouteTableId)
+			Config().Logger.Printf("Creating route to %s in remote VPC route table %s", *cidr, *main_network_info.routeTable.RouteTables[0].RouteTableId)
 			err := createVPCRoute(remote_network_info.routeTable.RouteTables[0].RouteTableId, cidr, tgwId, ec2_remote)
 			if err != nil {
 				return err
@@ -328,14 +328,14 @@ func VPCRoutes(remote_network_info *NetworkInfo, main_network_info *NetworkInfo,
 	} else {
 		// Delete Routes for all VPC CIDR's
 		for _, cidr := range main_network_info.vpcCidrBlocks {
-			Config.Logger.Printf("Deleting route %s from remote VPC %s", *cidr, *remote_network_info.vpc.Vpcs[0].VpcId)
+			Config().Logger.Printf("Deleting route %s from remote VPC %s", *cidr, *remote_network_info.vpc.Vpcs[0].VpcId)
 			err := deleteVPCRoute(remote_network_info.routeTable.RouteTables[0].RouteTableId, cidr, ec2_remote)
 			if err != nil {
 				return err
 			}
 		}
 
-		Config.Logger.Printf("Deleting route %s from main VPC %s", *remote_network_info.vpc.Vpcs[0].CidrBlock, *main_network_info.vpc.Vpcs[0].VpcId)
+		Config().Logger.Printf("Deleting route %s from main VPC %s", *remote_network_info.vpc.Vpcs[0].CidrBlock, *main_network_info.vpc.Vpcs[0].VpcId)
 		localDeleteRouteInput := &ec2.DeleteRouteInput{
 			DestinationCidrBlock: remote_network_info.vpc.Vpcs[0].CidrBlock,
 			RouteTableId:         main_network_info.routeTable.RouteTables[0].RouteTableId,
@@ -347,7 +347,7 @@ func VPCRoutes(remote_network_info *NetworkInfo, main_network_info *NetworkInfo,
 				switch aerr.Code() {
 				case "InvalidRoute.NotFound":
 					// Route already deleted, we are happy :)
-					Config.Logger.Printf("Route %s to remote vpc was already deleted from main VPC %s", *remote_network_info.vpc.Vpcs[0].CidrBlock, *main_network_info.vpc.Vpcs[0].VpcId)
+					Config().Logger.Printf("Route %s to remote vpc was already deleted from main VPC %s", *remote_network_info.vpc.Vpcs[0].CidrBlock, *main_network_info.vpc.Vpcs[0].VpcId)
 					return nil
 				}
 			}
@@ -393,7 +393,7 @@ func createVPCRoute(routeTableId *string, cidrBlock *string, tgwId *string, svc
 	if err != nil {
 		return err
 	}
-	Config.Logger.Printf("Route to %s added.", *cidrBlock)
+	Config().Logger.Printf("Route to %s added.", *cidrBlock)
 	return nil
 }
 
@@ -0,0 +1,224 @@
+package hatchery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// A user can run several workspace instances at the same time. Each instance is
+// identified by the user name plus a workspace id. The default instance has an
+// empty id, and keeps the resource names and routing hatchery always used, so
+// that clients unaware of workspace ids keep working unchanged
+
+// Annotation carrying the workspace id on the resources of named workspaces
+const workspaceIdAnnotation = "gen3workspace"
+
+// Annotation carrying the name of the container a workspace runs
+const containerNameAnnotation = "gen3container"
+
+var workspaceIdRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,18}[a-z0-9])?$`)
+
+// WorkspaceRef identifies a workspace instance
+type WorkspaceRef struct {
+	UserName    string
+	WorkspaceId string
+}
+
+// UserWorkspace is a workspace instance with its status, as returned by /workspaces
+type UserWorkspace struct {
+	WorkspaceId string           `json:"workspaceId"`
+	Status      *WorkspaceStatus `json:"status"`
+}
+
+// validateWorkspaceId checks that the workspace id can be used in
+// Kubernetes resource names and routing prefixes
+func validateWorkspaceId(workspaceId string) error {
+	if workspaceId == "" || workspaceIdRegex.MatchString(workspaceId) {
+		return nil
+	}
+	return fmt.Errorf("invalid 'workspace' parameter '%s': must be at most 20 lowercase alphanumeric characters or '-', and start and end with an alphanumeric character", workspaceId)
+}
+
+// getWorkspaceId returns the workspace id requested with the `workspace`
+// query parameter, or "" for the default workspace
+func getWorkspaceId(r *http.Request) (string, error) {
+	workspaceId := r.URL.Query().Get("workspace")
+	return workspaceId, validateWorkspaceId(workspaceId)
+}
+
+// workspaceToResourceName is a helper for generating names for the
+// different types of kubernetes resources of a workspace instance.
+// The default workspace uses the same names as userToResourceName
+func workspaceToResourceName(userName string, workspaceId string, resourceType string) string {
+	if workspaceId == "" {
+		return userToResourceName(userName, resourceType)
+	}
+	// escapism never outputs "--", so it can't collide with another user's name
+	return safeNameToResourceName(escapism(userName)+"--"+workspaceId, resourceType)
+}
+
+// workspaceRoutingPrefix returns the prefix under which the workspace is served. The
+// default workspace is served under "/", so named workspaces get a segment its app
+// can't use for its own paths, such as "/api/"
+func workspaceRoutingPrefix(workspaceId string) string {
+	if workspaceId == "" {
+		return "/"
+	}
+	return "/@" + workspaceId + "/"
+}
+
+// workspaceRefFromAnnotations reads the workspace a resource belongs to
+func workspaceRefFromAnnotations(annotations map[string]string) (WorkspaceRef, bool) {
+	userName := annotations["gen3username"]
+	if userName == "" {
+		return WorkspaceRef{}, false
+	}
+	return WorkspaceRef{UserName: userName, WorkspaceId: annotations[workspaceIdAnnotation]}, true
+}
+
+// listWorkspaces returns every workspace instance that currently exists.
+// Local and external EKS workspaces all get a service in the local cluster,
+// and ECS workspaces get one for the ambassador mapping, so the annotations
+// on pods and services cover every backend
+var listWorkspaces = func(ctx context.Context) ([]WorkspaceRef, error) {
+	podClient := getLocalPodClient()
+	if podClient == nil {
+		return nil, errors.New("unable to get local pod client")
+	}
+
+	seen := make(map[WorkspaceRef]bool)
+	refs := []WorkspaceRef{}
+	addWorkspace := func(annotations map[string]string) {
+		ref, ok := workspaceRefFromAnnotations(annotations)
+		if ok && !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	pods, err := podClient.Pods(Config().Config.UserNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		addWorkspace(pod.Annotations)
+	}
+
+	services, err := podClient.Services(Config().Config.UserNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services.Items {
+		addWorkspace(service.Annotations)
+	}
+	return refs, nil
+}
+
+// getWorkspaceContainerName returns the name of the container the workspace
+// runs, or "" if it is not known
+var getWorkspaceContainerName = func(ctx context.Context, userName string, workspaceId string) string {
+	podClient := getLocalPodClient()
+	if podClient == nil {
+		return ""
+	}
+	service, err := podClient.Services(Config().Config.UserNamespace).Get(ctx, workspaceToResourceName(userName, workspaceId, "service"), metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+	return service.Annotations[containerNameAnnotation]
+}
+
+// listUserWorkspaceIds returns the ids of the user's existing workspace instances
+func listUserWorkspaceIds(ctx context.Context, userName string) ([]string, error) {
+	refs, err := listWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	workspaceIds := []string{}
+	for _, ref := range refs {
+		if ref.UserName == userName {
+			workspaceIds = append(workspaceIds, ref.WorkspaceId)
+		}
+	}
+	sort.Strings(workspaceIds)
+	return workspaceIds, nil
+}
+
+// checkWorkspaceLimit returns an error if launching the workspace would
+// exceed the maximum number of workspaces per user
+func checkWorkspaceLimit(ctx context.Context, userName string, workspaceId string) error {
+	maxWorkspaces := Config().Config.MaxWorkspacesPerUser
+	if maxWorkspaces <= 0 {
+		return nil
+	}
+	workspaceIds, err := listUserWorkspaceIds(ctx, userName)
+	if err != nil {
+		return fmt.Errorf("unable to count existing workspaces: %v", err)
+	}
+	if stringArrayContains(workspaceIds, workspaceId) {
+		// relaunching an existing instance does not add a workspace
+		return nil
+	}
+	if len(workspaceIds) >= maxWorkspaces {
+		return fmt.Errorf("maximum number of workspaces (%d) reached, terminate a workspace before launching a new one", maxWorkspaces)
+	}
+	return nil
+}
+
+// hasOtherWorkspaces returns true if the user has workspace instances other than
+// the given one. Resources shared by all of a user's workspaces are only released
+// along with the last one. If the workspaces cannot be listed, the given one is
+// assumed to be the last
+func hasOtherWorkspaces(ctx context.Context, userName string, workspaceId string) bool {
+	workspaceIds, err := listUserWorkspaceIds(ctx, userName)
+	if err != nil {
+		Config().Logger.Printf("Unable to list workspaces for user %s, assuming workspace '%s' is the last one: %v", userName, workspaceId, err)
+		return false
+	}
+	for _, id := range workspaceIds {
+		if id != workspaceId {
+			return true
+		}
+	}
+	return false
+}
+
+// workspaces lists the current user's workspace instances and their status
+func workspaces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userName := getCurrentUserName(r)
+	accessToken := getBearerToken(r)
+
+	workspaceIds, err := listUserWorkspaceIds(r.Context(), userName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := []UserWorkspace{}
+	for _, workspaceId := range workspaceIds {
+		status, err := getWorkspaceStatus(r.Context(), userName, workspaceId, accessToken)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result = append(result, UserWorkspace{WorkspaceId: workspaceId, Status: status})
+	}
+
+	out, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, string(out))
+}
@@ -0,0 +1,169 @@
+package hatchery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_ValidateWorkspaceId(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	testCases := []struct {
+		workspaceId string
+		wantErr     bool
+	}{
+		{workspaceId: "", wantErr: false},
+		{workspaceId: "rstudio", wantErr: false},
+		{workspaceId: "gpu-2", wantErr: false},
+		{workspaceId: "a", wantErr: false},
+		{workspaceId: "RStudio", wantErr: true},
+		{workspaceId: "-rstudio", wantErr: true},
+		{workspaceId: "rstudio-", wantErr: true},
+		{workspaceId: "r/studio", wantErr: true},
+		{workspaceId: "a-very-long-workspace-id", wantErr: true},
+	}
+
+	for _, testcase := range testCases {
+		t.Logf("Testing ValidateWorkspaceId with '%s'", testcase.workspaceId)
+		err := validateWorkspaceId(testcase.workspaceId)
+		if (err != nil) != testcase.wantErr {
+			t.Errorf("\nassertion error while testing `ValidateWorkspaceId` with '%s': \nWant error:%v\nGot:%v", testcase.workspaceId, testcase.wantErr, err)
+		}
+	}
+}
+
+func Test_WorkspaceToResourceName(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	for _, resourceType := range []string{"pod", "service", "mapping", "claim"} {
+		got := workspaceToResourceName("frickjack", "", resourceType)
+		want := userToResourceName("frickjack", resourceType)
+		if got != want {
+			t.Errorf("\nassertion error while testing `WorkspaceToResourceName` for default %s: \nWant:%s\nGot:%s", resourceType, want, got)
+		}
+	}
+
+	testCases := []struct {
+		resourceType string
+		want         string
+	}{
+		{resourceType: "pod", want: "hatchery-frickjack--rstudio"},
+		{resourceType: "service", want: "h-frickjack--rstudio-s"},
+		{resourceType: "mapping", want: "frickjack--rstudio-mapping"},
+		{resourceType: "claim", want: "claim-frickjack--rstudio"},
+	}
+	for _, testcase := range testCases {
+		got := workspaceToResourceName("frickjack", "rstudio", testcase.resourceType)
+		if got != testcase.want {
+			t.Errorf("\nassertion error while testing `WorkspaceToResourceName` for named %s: \nWant:%s\nGot:%s", testcase.resourceType, testcase.want, got)
+		}
+	}
+
+	if got := workspaceRoutingPrefix("rstudio"); got != "/@rstudio/" {
+		t.Errorf("\nassertion error while testing `WorkspaceRoutingPrefix`: \nWant:%s\nGot:%s", "/@rstudio/", got)
+	}
+}
+
+func Test_CheckWorkspaceLimit(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalListWorkspaces := listWorkspaces
+	defer func() {
+		listWorkspaces = originalListWorkspaces
+	}()
+
+	withTestConfig(t, func(config *FullHatcheryConfig) {
+		config.Config = HatcheryConfig{MaxWorkspacesPerUser: 2}
+	})
+	listWorkspaces = func(ctx context.Context) ([]WorkspaceRef, error) {
+		return []WorkspaceRef{
+			{UserName: "fullUser"},
+			{UserName: "fullUser", WorkspaceId: "rstudio"},
+			{UserName: "otherUser"},
+		}, nil
+	}
+
+	testCases := []struct {
+		name        string
+		userName    string
+		workspaceId string
+		wantErr     bool
+	}{
+		{name: "UnderLimit", userName: "otherUser", workspaceId: "rstudio", wantErr: false},
+		{name: "AtLimit", userName: "fullUser", workspaceId: "gpu", wantErr: true},
+		{name: "RelaunchExisting", userName: "fullUser", workspaceId: "rstudio", wantErr: false},
+	}
+	for _, testcase := range testCases {
+		t.Logf("Testing CheckWorkspaceLimit when %s", testcase.name)
+		err := checkWorkspaceLimit(context.Background(), testcase.userName, testcase.workspaceId)
+		if (err != nil) != testcase.wantErr {
+			t.Errorf("\nassertion error while testing `%s`: \nWant error:%v\nGot:%v", testcase.name, testcase.wantErr, err)
+		}
+	}
+
+	listWorkspaces = func(ctx context.Context) ([]WorkspaceRef, error) {
+		return nil, errors.New("unable to list pods")
+	}
+	if err := checkWorkspaceLimit(context.Background(), "otherUser", ""); err == nil {
+		t.Errorf("\nassertion error while testing `CheckWorkspaceLimit` when listing fails: \nWant an error\nGot:nil")
+	}
+}
+
+func Test_HasOtherWorkspaces(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	originalListWorkspaces := listWorkspaces
+	defer func() {
+		listWorkspaces = originalListWorkspaces
+	}()
+
+	listWorkspaces = func(ctx context.Context) ([]WorkspaceRef, error) {
+		return []WorkspaceRef{
+			{UserName: "testUser"},
+			{UserName: "testUser", WorkspaceId: "rstudio"},
+			{UserName: "otherUser", WorkspaceId: "gpu"},
+		}, nil
+	}
+
+	if !hasOtherWorkspaces(context.Background(), "testUser", "") {
+		t.Errorf("\nassertion error while testing `HasOtherWorkspaces`: \nWant:true\nGot:false")
+	}
+	if hasOtherWorkspaces(context.Background(), "otherUser", "gpu") {
+		t.Errorf("\nassertion error while testing `HasOtherWorkspaces` for last workspace: \nWant:false\nGot:true")
+	}
+
+	ids, _ := listUserWorkspaceIds(context.Background(), "testUser")
+	if want := []string{"", "rstudio"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("\nassertion error while testing `ListUserWorkspaceIds`: \nWant:%v\nGot:%v", want, ids)
+	}
+}
+
+func TestBuildPodForNamedWorkspace(t *testing.T) {
+	defer SetupAndTeardownTest()()
+
+	config, err := LoadConfig("../testData/testConfig.json", nil)
+	if nil != err {
+		t.Errorf("failed to load config, got: %v", err)
+		return
+	}
+	app := &config.Config.Containers[0]
+	pod, err := buildPod(config, app, "frickjack", "rstudio", nil)
+	if nil != err {
+		t.Errorf("failed to build a pod - %v", err)
+		return
+	}
+
+	if pod.Name != "hatchery-frickjack--rstudio" {
+		t.Errorf("\nassertion error while testing `BuildPodForNamedWorkspace` pod name: \nWant:%s\nGot:%s", "hatchery-frickjack--rstudio", pod.Name)
+	}
+	if got := pod.Annotations[workspaceIdAnnotation]; got != "rstudio" {
+		t.Errorf("\nassertion error while testing `BuildPodForNamedWorkspace` annotation: \nWant:%s\nGot:%s", "rstudio", got)
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName != "claim-frickjack--rstudio" {
+			t.Errorf("\nassertion error while testing `BuildPodForNamedWorkspace` claim: \nWant:%s\nGot:%s", "claim-frickjack--rstudio", volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+}
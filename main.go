@@ -2,15 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/uc-cdis/hatchery/hatchery"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		os.Exit(render(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
+	}
+
 	configPath := "/var/hatchery/hatchery.json"
 	devMode := false
 
@@ -32,6 +42,8 @@ func main() {
 			`Use: hatchery -config path/to/hatchery.json
 		- also harvests dockstore/bla.yml app definitions where dockstore/
 		  is in the same folder as hatchery.json
+     hatchery validate -config path/to/hatchery.json
+     hatchery render -config path/to/hatchery.json -container name -user someone
 `)
 		return
 	}
@@ -58,11 +70,17 @@ func main() {
 	config, err := hatchery.LoadConfig(cleanPath, logger)
 	if err != nil {
 		message := err.Error()
-		if os.IsPermission(err) {
+		var configErrors hatchery.ConfigErrors
+		if errors.As(err, &configErrors) {
+			for _, configError := range configErrors {
+				fmt.Fprintln(os.Stderr, configError.Error())
+			}
+			message = fmt.Sprintf("%d problem(s) found", len(configErrors))
+		} else if os.IsPermission(err) {
 			message = "permission issue"
 		}
-		config.Logger.Printf("Failed to load config - got %s", message)
-		return
+		logger.Printf("Failed to load config - got %s", message)
+		os.Exit(1)
 	}
 
 	if config.Config.PayModelsDynamodbTable != "" {
@@ -80,7 +98,42 @@ func main() {
 		log.Printf("Pricings: %+v", config.Config.Pricing)
 	}
 
-	hatchery.Config = config
+	hatchery.SetConfig(config)
+
+	if err := hatchery.InitAuditLog(config.Config.Audit); err != nil {
+		config.Logger.Printf("Failed to set up the audit log - got %s", err.Error())
+		return
+	}
+
+	if config.Config.IdleCuller.Enabled {
+		culler := hatchery.NewIdleCuller(time.Duration(config.Config.IdleCuller.IntervalSeconds) * time.Second)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Start terminating idle workspaces
+		go culler.Start(ctx)
+	}
+
+	if config.Config.Snapshots.Enabled && config.Config.Snapshots.ScheduleIntervalHours > 0 {
+		scheduler := hatchery.NewSnapshotScheduler(time.Duration(config.Config.Snapshots.ScheduleIntervalHours) * time.Hour)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Start snapshotting the user volumes
+		go scheduler.Start(ctx)
+	}
+
+	if config.Config.ConfigReload.Enabled {
+		reloader := hatchery.NewConfigReloader(cleanPath, time.Duration(config.Config.ConfigReload.IntervalSeconds)*time.Second)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// Start swapping in the config when hatchery.json changes
+		go reloader.Start(ctx)
+	}
 
 	config.Logger.Printf("Setting up routes")
 	hatchery.RegisterSystem()
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/uc-cdis/hatchery/hatchery"
+)
+
+// render prints the manifests a launch of the container would create, without
+// creating anything. Secrets are redacted, e.g.:
+//
+//	hatchery render -config hatchery.json -container "R Studio" -user someone@example.com
+func render(args []string) int {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	configPath := flags.String("config", "./hatchery.json", "path to the hatchery config")
+	containerName := flags.String("container", "", "name of the container to launch")
+	userName := flags.String("user", "", "user launching the workspace")
+	workspaceId := flags.String("workspace", "", "name of the workspace, for users running several workspaces")
+	backend := flags.String("backend", "local", "where the workspace runs: local, external-eks or ecs")
+	awsAccountId := flags.String("aws-account-id", "", "AWS account of the pay model, for the external-eks and ecs backends")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *containerName == "" || *userName == "" {
+		fmt.Fprintln(os.Stderr, "-container and -user are required")
+		flags.Usage()
+		return 2
+	}
+
+	var payModel *hatchery.PayModel
+	switch *backend {
+	case "local":
+		payModel = nil
+	case "external-eks":
+		payModel = &hatchery.PayModel{AWSAccountId: *awsAccountId}
+	case "ecs":
+		payModel = &hatchery.PayModel{Ecs: true, AWSAccountId: *awsAccountId}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown backend '%s'\n", *backend)
+		return 2
+	}
+
+	// logs go to stderr so the manifests can be piped to kubectl
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	config, err := hatchery.LoadConfig(*configPath, logger)
+	if err != nil {
+		logger.Printf("Failed to load config - got %s", err.Error())
+		return 1
+	}
+	hatchery.SetConfig(config)
+
+	out, err := hatchery.RenderWorkspace(*containerName, *userName, *workspaceId, payModel)
+	if err != nil {
+		logger.Printf("Failed to render workspace - got %s", err.Error())
+		return 1
+	}
+	os.Stdout.Write(out)
+	return 0
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/uc-cdis/hatchery/hatchery"
+)
+
+// validate checks the config the same way the service does at startup, and
+// prints every problem found with its JSON path, e.g.:
+//
+//	hatchery validate -config hatchery.json
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	configPath := flags.String("config", "./hatchery.json", "path to the hatchery config")
+	verbose := flags.Bool("v", false, "print the logs of the config load")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	logOutput := io.Discard
+	if *verbose {
+		logOutput = os.Stderr
+	}
+	_, err := hatchery.LoadConfig(*configPath, log.New(logOutput, "", log.LstdFlags))
+	if err != nil {
+		var configErrors hatchery.ConfigErrors
+		if errors.As(err, &configErrors) {
+			for _, configError := range configErrors {
+				fmt.Println(configError.Error())
+			}
+			fmt.Fprintf(os.Stderr, "%s: found %d problem(s)\n", *configPath, len(configErrors))
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *configPath, err)
+		}
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%s: ok\n", *configPath)
+	return 0
+}